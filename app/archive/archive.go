@@ -0,0 +1,53 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// Archive is a read-only set of tiles made by other tooling, e.g. MBTiles file or z/x/y directory tree
+type Archive interface {
+	// Tiles call fn for every tile of archive with time when the tile was modified, iteration is
+	// stopped when fn returns error
+	Tiles(fn func(t *tile.Tile, modified time.Time) error) error
+	Close() error
+}
+
+// ErrNotFound returned by Reader when archive doesn't contain tile
+var ErrNotFound = errors.New("tile not found in archive")
+
+// Reader is an archive with random access to tiles, coordinates are in XYZ scheme
+type Reader interface {
+	// Tile return image of tile and time when it was modified
+	Tile(z, x, y int) ([]byte, time.Time, error)
+	Close() error
+}
+
+// Open open directory as z/x/y tree or file as MBTiles, tms flips y of directory tree tiles
+// (MBTiles rows are always flipped as required by specification)
+func Open(path string, tms bool) (Archive, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	if stat.IsDir() {
+		return &ZXY{Path: path, TMS: tms}, nil
+	}
+
+	return OpenMBTiles(path)
+}
+
+// flipY convert y between XYZ and TMS schemes
+func flipY(y, zoom int) int {
+	return 1<<zoom - 1 - y
+}
+
+// validTile check that tile coordinates are within zoom
+func validTile(x, y, zoom int) bool {
+	return zoom >= 0 && zoom <= 30 && x >= 0 && y >= 0 && x < 1<<zoom && y < 1<<zoom
+}
@@ -0,0 +1,73 @@
+package archive
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// progressEvery is a count of processed tiles between progress reports
+const progressEvery = 1000
+
+// Report contains import counters
+type Report struct {
+	Imported int
+	Skipped  int // cached tile is the same age or newer than archived one
+	Invalid  int // archived data is not an image
+}
+
+// Import save archive tiles to cache as tiles of vendor. Format of image is detected from its content,
+// time of archived tile becomes its Last-Modified and tile is skipped if cached one is not older
+// unless force is set. Progress is reported every 1000 tiles.
+func Import(c *cache.MapCache, vendor string, a Archive, force bool, progress func(Report)) (Report, error) {
+	var r Report
+
+	err := a.Tiles(func(t *tile.Tile, modified time.Time) error {
+		defer func() {
+			if progress != nil && (r.Imported+r.Skipped+r.Invalid)%progressEvery == 0 {
+				progress(r)
+			}
+		}()
+
+		if !strings.HasPrefix(http.DetectContentType(t.Image), "image/") {
+			r.Invalid++
+			return nil
+		}
+
+		if !force && cachedNewer(c, vendor, t, modified) {
+			r.Skipped++
+			return nil
+		}
+
+		t.Meta.LastModified = modified.UTC().Format(http.TimeFormat)
+		if err := c.SaveTile(vendor, t); err != nil {
+			return fmt.Errorf("failed to save tile %d/%d/%d: %w", t.Z, t.X, t.Y, err)
+		}
+
+		r.Imported++
+		return nil
+	})
+
+	return r, err
+}
+
+// cachedNewer check that cached tile was modified at the same time or after archived one,
+// tiles cached without Last-Modified are compared by time when they were saved
+func cachedNewer(c *cache.MapCache, vendor string, t *tile.Tile, modified time.Time) bool {
+	info, err := c.Info(vendor, t)
+	if err != nil {
+		return false
+	}
+
+	cached := info.Saved
+	if lm, err := http.ParseTime(info.LastModified); err == nil {
+		cached = lm
+	}
+
+	// Last-Modified has seconds precision
+	return !cached.Before(modified.Truncate(time.Second))
+}
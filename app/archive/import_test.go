@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestImport(t *testing.T) {
+	c, err := cache.NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	m, err := OpenMBTiles("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+	defer m.Close()
+
+	var reports []Report
+	r, err := Import(c, "osm", m, false, func(r Report) { reports = append(reports, r) })
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Imported: 278, Invalid: 1}, r)
+	assert.Empty(t, reports) // less than 1000 tiles
+
+	loaded := &tile.Tile{X: 1, Y: 0, Z: 1}
+	img, err := c.LoadTile("osm", loaded)
+	assert.NoError(t, err)
+	z, x, y := pixel(t, img)
+	assert.Equal(t, [3]int{1, 1, 0}, [3]int{z, x, y})
+	assert.Equal(t, "image/png", loaded.Meta.ContentType)
+	assert.Equal(t, "testdata/plain.mbtiles", loaded.Meta.Source)
+	assert.Equal(t, m.modified.UTC().Format(http.TimeFormat), loaded.Meta.LastModified)
+
+	_, err = c.LoadTile("osm", &tile.Tile{X: 1, Y: 0, Z: 3})
+	assert.Error(t, err) // not an image
+
+	// the same archive is not newer than cached tiles
+	r, err = Import(c, "osm", m, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Skipped: 278, Invalid: 1}, r)
+
+	r, err = Import(c, "osm", m, true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Imported: 278, Invalid: 1}, r)
+}
+
+func TestImport_SkipNewer(t *testing.T) {
+	c, err := cache.NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	root := t.TempDir()
+	writeTile(t, root, "0/0/0.png", pngTile(t))
+	writeTile(t, root, "1/0/0.png", pngTile(t))
+	writeTile(t, root, "1/0/1.png", []byte("not an image"))
+
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "1/0/0.png"), old, old))
+
+	// tile cached now without Last-Modified is newer than archived one
+	assert.NoError(t, c.SaveTile("osm", &tile.Tile{X: 0, Y: 0, Z: 1, Image: pngTile(t)}))
+	// tile modified two days ago upstream is older than archived one
+	assert.NoError(t, c.SaveTile("osm", &tile.Tile{X: 0, Y: 0, Z: 0, Image: pngTile(t),
+		Meta: tile.Meta{LastModified: old.Add(-time.Hour).UTC().Format(http.TimeFormat)}}))
+
+	r, err := Import(c, "osm", &ZXY{Path: root}, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Imported: 1, Skipped: 1, Invalid: 1}, r)
+
+	info, err := c.Info("osm", &tile.Tile{X: 0, Y: 0, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "0/0/0.png"), info.Source)
+}
+
+func TestImport_Progress(t *testing.T) {
+	c, err := cache.NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	root := t.TempDir()
+	for i := 0; i < 1100; i++ {
+		writeTile(t, root, filepath.Join("11", "0", strconv.Itoa(i)+".txt"), []byte("text"))
+	}
+
+	var reports []Report
+	r, err := Import(c, "osm", &ZXY{Path: root}, false, func(r Report) { reports = append(reports, r) })
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Invalid: 1100}, r)
+	assert.Equal(t, []Report{{Invalid: 1000}}, reports)
+}
+
+// pngTile return encoded 1x1 PNG image
+func pngTile(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	return buf.Bytes()
+}
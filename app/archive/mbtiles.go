@@ -0,0 +1,361 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// MBTiles is a SQLite file with tiles in TMS scheme, both plain tiles table and
+// deduplicated map/images schema made by mbutil are supported
+type MBTiles struct {
+	db       *sqliteDB
+	path     string
+	modified time.Time // tiles don't have own timestamps, so modification time of file is used
+	tables   map[string]sqliteTable
+
+	lookups map[string]*lookup // random access to rows of tables by key columns
+}
+
+// lookup finds rows by key columns with index b-tree, tables without suitable index are
+// read once to memory map of keys
+type lookup struct {
+	table   sqliteTable
+	columns []int  // positions of key columns in table
+	alias   int    // position of column stored as rowid, -1 if table doesn't have it
+	index   uint32 // root page of index, zero if table doesn't have it
+
+	once sync.Once
+	rows map[string]int64 // key -> rowid if index is missing
+	err  error
+}
+
+// OpenMBTiles open MBTiles file and check that it contains tiles
+func OpenMBTiles(path string) (*MBTiles, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mbtiles: %w", err)
+	}
+
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mbtiles: %w", err)
+	}
+
+	tables, err := db.tables()
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to read mbtiles schema: %w", err)
+	}
+
+	m := &MBTiles{db: db, path: path, modified: stat.ModTime(), tables: tables, lookups: make(map[string]*lookup)}
+
+	if !m.hasTable("tiles") && !(m.hasTable("map") && m.hasTable("images")) {
+		_ = db.Close()
+		return nil, fmt.Errorf("mbtiles doesn't contain tiles table")
+	}
+
+	if m.hasTable("tiles") {
+		err = m.addLookup("tiles", "zoom_level", "tile_column", "tile_row")
+	} else {
+		err = errors.Join(m.addLookup("map", "zoom_level", "tile_column", "tile_row"), m.addLookup("images", "tile_id"))
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Tile return image of tile, XYZ row is flipped to TMS one
+func (m *MBTiles) Tile(z, x, y int) ([]byte, time.Time, error) {
+	if !validTile(x, y, z) {
+		return nil, time.Time{}, ErrNotFound
+	}
+	key := []any{int64(z), int64(x), int64(flipY(y, z))}
+
+	if m.hasTable("tiles") {
+		values, err := m.find("tiles", key)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		img, _ := m.column("tiles", values, "tile_data").([]byte)
+		return img, m.modified, nil
+	}
+
+	values, err := m.find("map", key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if values, err = m.find("images", []any{m.column("map", values, "tile_id")}); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	img, _ := m.column("images", values, "tile_data").([]byte)
+	return img, m.modified, nil
+}
+
+// addLookup prepare random access to table rows by key columns, index which starts with key columns is used if it exists
+func (m *MBTiles) addLookup(table string, columns ...string) error {
+	if withoutRowid(m.tables[table].SQL) {
+		return fmt.Errorf("table %s is WITHOUT ROWID table, it's not supported", table)
+	}
+
+	idx, err := columnIndexes(m.tables[table], columns...)
+	if err != nil {
+		return err
+	}
+
+	l := &lookup{table: m.tables[table], columns: idx, alias: rowidColumn(m.tables[table].SQL)}
+
+	for _, t := range m.tables {
+		if t.Type != "index" || t.TableName != table || t.RootPage == 0 {
+			continue
+		}
+
+		indexColumns := columnNames(t.SQL)
+		if len(indexColumns) >= len(columns) && slices.Equal(indexColumns[:len(columns)], columns) {
+			l.index = t.RootPage
+			break
+		}
+	}
+
+	m.lookups[table] = l
+	return nil
+}
+
+// find return values of table row by key, ErrNotFound is returned if row doesn't exist
+func (m *MBTiles) find(table string, key []any) ([]any, error) {
+	l := m.lookups[table]
+
+	var rowid int64
+	if id, ok := key[0].(int64); ok && len(l.columns) == 1 && l.columns[0] == l.alias {
+		rowid = id // key is rowid
+	} else if l.index != 0 {
+		entry, err := m.db.seek(l.index, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s index: %w", table, err)
+		}
+		if entry == nil {
+			return nil, ErrNotFound
+		}
+
+		id, ok := entry[len(entry)-1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s index entry", table)
+		}
+		rowid = id
+	} else {
+		l.once.Do(func() { l.rows, l.err = m.readKeys(l) })
+		if l.err != nil {
+			return nil, l.err
+		}
+
+		id, ok := l.rows[fmt.Sprint(key...)]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		rowid = id
+	}
+
+	row, err := m.db.find(l.table.RootPage, rowid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s row %d: %w", table, rowid, err)
+	}
+	if row == nil {
+		return nil, ErrNotFound
+	}
+
+	values, err := row.values()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s row %d: %w", table, rowid, err)
+	}
+
+	return m.rowidAlias(l, row, values), nil
+}
+
+// readKeys read key columns of all table rows
+func (m *MBTiles) readKeys(l *lookup) (map[string]int64, error) {
+	rows := make(map[string]int64)
+
+	err := m.db.scan(l.table.RootPage, func(r *sqliteRow) error {
+		values, err := r.values()
+		if err != nil {
+			return fmt.Errorf("failed to read %s row %d: %w", l.table.Name, r.rowid, err)
+		}
+		values = m.rowidAlias(l, r, values)
+
+		key := make([]any, len(l.columns))
+		for i, c := range l.columns {
+			key[i] = value(values, c)
+		}
+		rows[fmt.Sprint(key...)] = r.rowid
+		return nil
+	})
+
+	return rows, err
+}
+
+// rowidAlias fill INTEGER PRIMARY KEY column with rowid, the column is stored as NULL
+func (m *MBTiles) rowidAlias(l *lookup, r *sqliteRow, values []any) []any {
+	if l.alias >= 0 && l.alias < len(values) {
+		values[l.alias] = r.rowid
+	}
+	return values
+}
+
+// column return value of table column
+func (m *MBTiles) column(table string, values []any, name string) any {
+	idx, err := columnIndexes(m.tables[table], name)
+	if err != nil {
+		return nil
+	}
+	return value(values, idx[0])
+}
+
+// Close close MBTiles file
+func (m *MBTiles) Close() error {
+	return m.db.Close()
+}
+
+// Tiles call fn for every tile of MBTiles
+func (m *MBTiles) Tiles(fn func(t *tile.Tile, modified time.Time) error) error {
+	if m.hasTable("tiles") {
+		return m.plainTiles(fn)
+	}
+	return m.mappedTiles(fn)
+}
+
+// hasTable check that schema contains table, views are not tables
+func (m *MBTiles) hasTable(name string) bool {
+	t, ok := m.tables[name]
+	return ok && t.Type == "table"
+}
+
+// plainTiles read tiles(zoom_level, tile_column, tile_row, tile_data) table
+func (m *MBTiles) plainTiles(fn func(t *tile.Tile, modified time.Time) error) error {
+	table := m.tables["tiles"]
+
+	idx, err := columnIndexes(table, "zoom_level", "tile_column", "tile_row", "tile_data")
+	if err != nil {
+		return err
+	}
+
+	return m.db.scan(table.RootPage, func(r *sqliteRow) error {
+		values, err := r.values()
+		if err != nil {
+			return fmt.Errorf("failed to read tile row %d: %w", r.rowid, err)
+		}
+
+		t, ok := m.tile(value(values, idx[0]), value(values, idx[1]), value(values, idx[2]))
+		if !ok {
+			return nil
+		}
+		t.Image, _ = value(values, idx[3]).([]byte)
+
+		return fn(t, m.modified)
+	})
+}
+
+// mappedTiles read map(zoom_level, tile_column, tile_row, tile_id) and images(tile_data, tile_id) tables,
+// the same image may be used by several tiles
+func (m *MBTiles) mappedTiles(fn func(t *tile.Tile, modified time.Time) error) error {
+	mapTable, imagesTable := m.tables["map"], m.tables["images"]
+
+	mapIdx, err := columnIndexes(mapTable, "zoom_level", "tile_column", "tile_row", "tile_id")
+	if err != nil {
+		return err
+	}
+
+	imagesIdx, err := columnIndexes(imagesTable, "tile_data", "tile_id")
+	if err != nil {
+		return err
+	}
+
+	coords := make(map[string][]*tile.Tile)
+	err = m.db.scan(mapTable.RootPage, func(r *sqliteRow) error {
+		values, err := r.values()
+		if err != nil {
+			return fmt.Errorf("failed to read map row %d: %w", r.rowid, err)
+		}
+
+		t, ok := m.tile(value(values, mapIdx[0]), value(values, mapIdx[1]), value(values, mapIdx[2]))
+		if !ok {
+			return nil
+		}
+
+		id := fmt.Sprint(value(values, mapIdx[3]))
+		coords[id] = append(coords[id], t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.db.scan(imagesTable.RootPage, func(r *sqliteRow) error {
+		values, err := r.values()
+		if err != nil {
+			return fmt.Errorf("failed to read images row %d: %w", r.rowid, err)
+		}
+
+		values = m.rowidAlias(m.lookups["images"], r, values)
+
+		img, _ := value(values, imagesIdx[0]).([]byte)
+		for _, t := range coords[fmt.Sprint(value(values, imagesIdx[1]))] {
+			t.Image = img
+			if err = fn(t, m.modified); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// tile convert MBTiles coordinates to XYZ tile, rows with invalid coordinates are skipped
+func (m *MBTiles) tile(zoom, column, row any) (*tile.Tile, bool) {
+	z, okZ := zoom.(int64)
+	x, okX := column.(int64)
+	y, okY := row.(int64)
+	if !okZ || !okX || !okY || !validTile(int(x), int(y), int(z)) {
+		return nil, false
+	}
+
+	return &tile.Tile{X: int(x), Y: flipY(int(y), int(z)), Z: int(z), Meta: tile.Meta{Source: m.path}}, true
+}
+
+// columnIndexes return positions of columns in table
+func columnIndexes(table sqliteTable, names ...string) ([]int, error) {
+	columns := columnNames(table.SQL)
+
+	idx := make([]int, len(names))
+	for i, name := range names {
+		idx[i] = -1
+		for j, column := range columns {
+			if column == name {
+				idx[i] = j
+			}
+		}
+
+		if idx[i] < 0 {
+			return nil, fmt.Errorf("table %s doesn't contain column %s", table.Name, name)
+		}
+	}
+
+	return idx, nil
+}
+
+// value return column value, columns added by ALTER TABLE may be missing in old rows
+func value(values []any, i int) any {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
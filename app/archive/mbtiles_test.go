@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// pixel return color of top left pixel of PNG tile, test tiles encode z, x and XYZ y in it
+func pixel(t *testing.T, img []byte) (z, x, y int) {
+	decoded, err := png.Decode(bytes.NewReader(img))
+	assert.NoError(t, err)
+
+	r, g, b, _ := decoded.At(0, 0).RGBA()
+	return int(r>>8) / 40, int(g>>8) / 10, int(b>>8) / 10
+}
+
+func TestMBTiles_Plain(t *testing.T) {
+	m, err := OpenMBTiles("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+	defer m.Close()
+
+	tiles := make(map[[3]int][]byte)
+	assert.NoError(t, m.Tiles(func(tl *tile.Tile, modified time.Time) error {
+		assert.Equal(t, m.modified, modified)
+		assert.Equal(t, "testdata/plain.mbtiles", tl.Meta.Source)
+		tiles[[3]int{tl.Z, tl.X, tl.Y}] = tl.Image
+		return nil
+	}))
+
+	// 277 tiles of zoom 0-2 and 4, noise and text at zoom 3, out of range row is skipped
+	assert.Len(t, tiles, 279)
+	for key, img := range tiles {
+		if key[0] == 3 {
+			continue
+		}
+		z, x, y := pixel(t, img)
+		assert.Equal(t, key, [3]int{z, x, y})
+	}
+
+	assert.Greater(t, len(tiles[[3]int{3, 0, 0}]), 512) // stored in overflow pages
+	_, err = png.Decode(bytes.NewReader(tiles[[3]int{3, 0, 0}]))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("not an image"), tiles[[3]int{3, 1, 0}])
+}
+
+func TestMBTiles_Mapped(t *testing.T) {
+	m, err := OpenMBTiles("testdata/mbutil.mbtiles")
+	assert.NoError(t, err)
+	defer m.Close()
+
+	tiles := make(map[[3]int][]byte)
+	assert.NoError(t, m.Tiles(func(tl *tile.Tile, _ time.Time) error {
+		tiles[[3]int{tl.Z, tl.X, tl.Y}] = tl.Image
+		return nil
+	}))
+
+	assert.Len(t, tiles, 3)
+	assert.Equal(t, tiles[[3]int{1, 0, 0}], tiles[[3]int{1, 1, 0}])
+
+	z, x, y := pixel(t, tiles[[3]int{1, 1, 1}])
+	assert.Equal(t, [3]int{1, 1, 1}, [3]int{z, x, y})
+}
+
+func TestMBTiles_Stop(t *testing.T) {
+	m, err := OpenMBTiles("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+	defer m.Close()
+
+	count := 0
+	err = m.Tiles(func(_ *tile.Tile, _ time.Time) error {
+		count++
+		if count == 3 {
+			return assert.AnError
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 3, count)
+}
+
+func TestMBTiles_Tile(t *testing.T) {
+	tests := []struct {
+		path  string
+		tiles [][3]int
+	}{
+		{path: "testdata/plain.mbtiles", tiles: [][3]int{{0, 0, 0}, {2, 1, 3}, {4, 15, 0}, {4, 7, 9}}},
+		{path: "testdata/mbutil.mbtiles", tiles: [][3]int{{1, 1, 1}}},
+		{path: "testdata/noindex.mbtiles", tiles: [][3]int{{1, 1, 1}}},
+	}
+
+	for _, tt := range tests {
+		m, err := OpenMBTiles(tt.path)
+		assert.NoError(t, err)
+
+		for _, c := range tt.tiles {
+			img, modified, err := m.Tile(c[0], c[1], c[2])
+			assert.NoError(t, err, tt.path)
+			assert.Equal(t, m.modified, modified)
+
+			z, x, y := pixel(t, img)
+			assert.Equal(t, c, [3]int{z, x, y}, tt.path)
+		}
+
+		// shared image
+		img, _, err := m.Tile(1, 0, 0)
+		assert.NoError(t, err, tt.path)
+		z, x, y := pixel(t, img)
+		assert.Equal(t, [3]int{1, 0, 0}, [3]int{z, x, y}, tt.path)
+
+		_, _, err = m.Tile(3, 5, 5)
+		assert.ErrorIs(t, err, ErrNotFound, tt.path)
+		_, _, err = m.Tile(1, 2, 0)
+		assert.ErrorIs(t, err, ErrNotFound, tt.path)
+
+		assert.NoError(t, m.Close())
+	}
+}
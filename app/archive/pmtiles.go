@@ -0,0 +1,281 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// PMTiles compression types
+const (
+	pmtilesNone = 1
+	pmtilesGzip = 2
+)
+
+// pmtilesHeaderSize is a size of PMTiles v3 header
+const pmtilesHeaderSize = 127
+
+// PMTiles is a single file archive of tiles in XYZ scheme addressed by Hilbert curve, version 3 is supported
+// with uncompressed or gzip compressed directories and tiles
+type PMTiles struct {
+	file     *os.File
+	modified time.Time // tiles don't have own timestamps, so modification time of file is used
+
+	root         []pmtilesEntry
+	leafOffset   uint64
+	dataOffset   uint64
+	internalComp byte // compression of directories
+	tileComp     byte
+}
+
+// pmtilesEntry is an entry of PMTiles directory: run of tiles with the same data or leaf directory if run length is zero
+type pmtilesEntry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+}
+
+// OpenPMTiles open PMTiles file and read its root directory
+func OpenPMTiles(path string) (*PMTiles, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pmtiles: %w", err)
+	}
+
+	p, err := readPMTiles(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open pmtiles: %w", err)
+	}
+
+	return p, nil
+}
+
+// readPMTiles check header and read root directory
+func readPMTiles(file *os.File) (*PMTiles, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, pmtilesHeaderSize)
+	if _, err = file.ReadAt(header, 0); err != nil || string(header[:7]) != "PMTiles" {
+		return nil, fmt.Errorf("file is not a PMTiles archive")
+	}
+
+	if header[7] != 3 {
+		return nil, fmt.Errorf("pmtiles version %d not supported", header[7])
+	}
+
+	p := &PMTiles{
+		file:         file,
+		modified:     stat.ModTime(),
+		leafOffset:   binary.LittleEndian.Uint64(header[40:48]),
+		dataOffset:   binary.LittleEndian.Uint64(header[56:64]),
+		internalComp: header[97],
+		tileComp:     header[98],
+	}
+
+	for _, c := range []byte{p.internalComp, p.tileComp} {
+		if c != pmtilesNone && c != pmtilesGzip {
+			return nil, fmt.Errorf("pmtiles compression %d not supported", c)
+		}
+	}
+
+	p.root, err = p.directory(binary.LittleEndian.Uint64(header[8:16]), binary.LittleEndian.Uint64(header[16:24]))
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Close close PMTiles file
+func (p *PMTiles) Close() error {
+	return p.file.Close()
+}
+
+// Tile return image of tile
+func (p *PMTiles) Tile(z, x, y int) ([]byte, time.Time, error) {
+	if !validTile(x, y, z) {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	id := hilbertID(z, x, y)
+	entries := p.root
+
+	// leaf directories may be nested, but not deeper than 3 levels
+	for depth := 0; depth < 4; depth++ {
+		e, ok := findEntry(entries, id)
+		if !ok {
+			return nil, time.Time{}, ErrNotFound
+		}
+
+		if e.RunLength > 0 {
+			data, err := p.read(p.dataOffset+e.Offset, uint64(e.Length), p.tileComp)
+			if err != nil {
+				return nil, time.Time{}, fmt.Errorf("failed to read tile %d/%d/%d: %w", z, x, y, err)
+			}
+			return data, p.modified, nil
+		}
+
+		var err error
+		if entries, err = p.directory(p.leafOffset+e.Offset, uint64(e.Length)); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	return nil, time.Time{}, ErrNotFound
+}
+
+// directory read and decode directory
+func (p *PMTiles) directory(offset, length uint64) ([]pmtilesEntry, error) {
+	data, err := p.read(offset, length, p.internalComp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pmtiles directory: %w", err)
+	}
+
+	entries, err := decodeDirectory(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pmtiles directory: %w", err)
+	}
+
+	return entries, nil
+}
+
+// read read part of file and decompress it
+func (p *PMTiles) read(offset, length uint64, compression byte) ([]byte, error) {
+	if length > 1<<30 {
+		return nil, fmt.Errorf("length %d is too large", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := p.file.ReadAt(data, int64(offset)); err != nil {
+		return nil, err
+	}
+
+	if compression != pmtilesGzip {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// decodeDirectory decode directory: count of entries followed by columns of delta encoded tile IDs,
+// run lengths, lengths and offsets (zero offset means that data follows previous entry)
+func decodeDirectory(data []byte) ([]pmtilesEntry, error) {
+	r := bytes.NewReader(data)
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid count of entries %d", count)
+	}
+
+	entries := make([]pmtilesEntry, count)
+
+	var id uint64
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		id += v
+		entries[i].TileID = id
+	}
+
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].RunLength = uint32(v)
+	}
+
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Length = uint32(v)
+	}
+
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if v == 0 && i > 0 {
+			entries[i].Offset = entries[i-1].Offset + uint64(entries[i-1].Length)
+		} else {
+			entries[i].Offset = v - 1
+		}
+	}
+
+	return entries, nil
+}
+
+// findEntry return entry which contains tile or leaf directory which may contain it
+func findEntry(entries []pmtilesEntry, id uint64) (pmtilesEntry, bool) {
+	lo, hi := 0, len(entries)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case id > entries[mid].TileID:
+			lo = mid + 1
+		case id < entries[mid].TileID:
+			hi = mid - 1
+		default:
+			return entries[mid], true
+		}
+	}
+
+	// hi is the last entry before tile
+	if hi >= 0 && (entries[hi].RunLength == 0 || id-entries[hi].TileID < uint64(entries[hi].RunLength)) {
+		return entries[hi], true
+	}
+
+	return pmtilesEntry{}, false
+}
+
+// hilbertID return PMTiles tile ID: count of tiles of lower zooms plus position of tile on Hilbert curve
+func hilbertID(z, x, y int) uint64 {
+	id := (uint64(1)<<(2*z) - 1) / 3
+
+	tx, ty := uint64(x), uint64(y)
+	for s := uint64(1) << z >> 1; s > 0; s >>= 1 {
+		rx, ry := uint64(0), uint64(0)
+		if tx&s > 0 {
+			rx = 1
+		}
+		if ty&s > 0 {
+			ry = 1
+		}
+
+		id += s * s * ((3 * rx) ^ ry)
+
+		// rotate quadrant
+		if ry == 0 {
+			if rx == 1 {
+				tx, ty = s-1-tx, s-1-ty
+			}
+			tx, ty = ty, tx
+		}
+	}
+
+	return id
+}
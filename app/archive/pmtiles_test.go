@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHilbertID(t *testing.T) {
+	assert.Equal(t, uint64(0), hilbertID(0, 0, 0))
+	assert.Equal(t, uint64(1), hilbertID(1, 0, 0))
+	assert.Equal(t, uint64(2), hilbertID(1, 0, 1))
+	assert.Equal(t, uint64(3), hilbertID(1, 1, 1))
+	assert.Equal(t, uint64(4), hilbertID(1, 1, 0))
+	assert.Equal(t, uint64(5), hilbertID(2, 0, 0))
+	assert.Equal(t, uint64(19078479), hilbertID(12, 3423, 1763))
+}
+
+func TestPMTiles(t *testing.T) {
+	p, err := OpenPMTiles("testdata/tiles.pmtiles")
+	assert.NoError(t, err)
+	defer p.Close()
+
+	// tiles of root and leaf directories
+	for _, c := range [][3]int{{0, 0, 0}, {1, 0, 1}, {1, 1, 0}, {2, 0, 0}, {2, 3, 3}} {
+		img, modified, err := p.Tile(c[0], c[1], c[2])
+		assert.NoError(t, err)
+		assert.Equal(t, p.modified, modified)
+
+		z, x, y := pixel(t, img)
+		assert.Equal(t, c, [3]int{z, x, y})
+	}
+
+	// run of tiles 17-20 with the same data
+	first, _, err := p.Tile(2, 2, 0)
+	assert.NoError(t, err)
+	for _, c := range [][3]int{{2, 3, 0}, {2, 2, 1}, {2, 3, 1}} {
+		img, _, err := p.Tile(c[0], c[1], c[2])
+		assert.NoError(t, err)
+		assert.Equal(t, first, img)
+		assert.GreaterOrEqual(t, hilbertID(c[0], c[1], c[2]), uint64(17))
+	}
+
+	_, _, err = p.Tile(3, 0, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = p.Tile(1, 2, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = OpenPMTiles("testdata/plain.mbtiles")
+	assert.EqualError(t, err, "failed to open pmtiles: file is not a PMTiles archive")
+
+	data, err := os.ReadFile("testdata/tiles.pmtiles")
+	assert.NoError(t, err)
+	data[7] = 2
+	path := filepath.Join(t.TempDir(), "v2.pmtiles")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	_, err = OpenPMTiles(path)
+	assert.EqualError(t, err, "failed to open pmtiles: pmtiles version 2 not supported")
+}
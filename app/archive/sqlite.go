@@ -0,0 +1,658 @@
+package archive
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB is a minimal read-only reader of SQLite database file, it supports scanning of rowid tables only
+// which is enough to read MBTiles without cgo driver
+type sqliteDB struct {
+	file     *os.File
+	size     int64 // file size, payload of any row is smaller
+	pageSize int
+	usable   int // page size without reserved space
+}
+
+// sqliteTable is an entry of sqlite_master
+type sqliteTable struct {
+	Type      string
+	Name      string
+	TableName string // table of index
+	RootPage  uint32
+	SQL       string
+}
+
+// sqliteRow is a table row with lazily read payload
+type sqliteRow struct {
+	db       *sqliteDB
+	rowid    int64
+	size     int    // payload size
+	local    []byte // payload part stored in b-tree page
+	overflow uint32 // first overflow page, zero if payload is local
+}
+
+// openSQLite open SQLite database file and check its header
+func openSQLite(path string) (*sqliteDB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	header := make([]byte, 100)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read database header: %w", err)
+	}
+
+	if n < len(header) || string(header[:16]) != "SQLite format 3\x00" {
+		_ = file.Close()
+		return nil, fmt.Errorf("file is not a SQLite database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 || int(header[20]) >= pageSize-480 {
+		_ = file.Close()
+		return nil, fmt.Errorf("invalid database page size %d", pageSize)
+	}
+
+	// pages changed in write-ahead log aren't read, so the file may be outdated
+	if header[18] == 2 || header[19] == 2 {
+		_ = file.Close()
+		return nil, fmt.Errorf("WAL mode databases are not supported, disable it with PRAGMA journal_mode=DELETE")
+	}
+
+	if encoding := binary.BigEndian.Uint32(header[56:60]); encoding > 1 {
+		_ = file.Close()
+		return nil, fmt.Errorf("only UTF-8 databases are supported")
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	return &sqliteDB{file: file, size: stat.Size(), pageSize: pageSize, usable: pageSize - int(header[20])}, nil
+}
+
+// Close closes database file
+func (db *sqliteDB) Close() error {
+	return db.file.Close()
+}
+
+// page read database page, pages are numbered from 1
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("invalid page number 0")
+	}
+
+	buf := make([]byte, db.pageSize)
+	if _, err := db.file.ReadAt(buf, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %w", n, err)
+	}
+
+	return buf, nil
+}
+
+// tables return entries of sqlite_master
+func (db *sqliteDB) tables() (map[string]sqliteTable, error) {
+	tables := make(map[string]sqliteTable)
+
+	err := db.scan(1, func(r *sqliteRow) error {
+		values, err := r.values()
+		if err != nil || len(values) < 5 {
+			return fmt.Errorf("failed to decode schema: %w", err)
+		}
+
+		t := sqliteTable{}
+		t.Type, _ = values[0].(string)
+		t.Name, _ = values[1].(string)
+		t.TableName, _ = values[2].(string)
+		if root, ok := values[3].(int64); ok {
+			t.RootPage = uint32(root)
+		}
+		t.SQL, _ = values[4].(string)
+
+		tables[t.Name] = t
+		return nil
+	})
+
+	return tables, err
+}
+
+// scan call fn for every row of table b-tree ordered by rowid
+func (db *sqliteDB) scan(root uint32, fn func(r *sqliteRow) error) error {
+	return db.scanPage(root, fn, make(map[uint32]bool))
+}
+
+// scanPage walks b-tree page, visited pages protect from loops in corrupted file
+func (db *sqliteDB) scanPage(n uint32, fn func(r *sqliteRow) error, visited map[uint32]bool) error {
+	if visited[n] {
+		return fmt.Errorf("page %d is referenced twice, database is corrupted", n)
+	}
+	visited[n] = true
+
+	p, offset, err := db.btreePage(n)
+	if err != nil {
+		return err
+	}
+
+	switch p[offset] {
+	case 0x05: // interior table page
+		pointers, err := cellPointers(p, offset, n)
+		if err != nil {
+			return err
+		}
+		for _, cell := range pointers {
+			if cell+4 > len(p) {
+				return fmt.Errorf("invalid cell offset on page %d", n)
+			}
+			if err = db.scanPage(binary.BigEndian.Uint32(p[cell:]), fn, visited); err != nil {
+				return err
+			}
+		}
+		return db.scanPage(binary.BigEndian.Uint32(p[offset+8:]), fn, visited)
+	case 0x0d: // leaf table page
+		pointers, err := cellPointers(p, offset, n)
+		if err != nil {
+			return err
+		}
+		for _, cell := range pointers {
+			row, err := db.leafCell(p, cell)
+			if err != nil {
+				return fmt.Errorf("failed to read cell of page %d: %w", n, err)
+			}
+			if err = fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("page %d is not a table b-tree page", n)
+	}
+}
+
+// find return row of table b-tree by rowid
+func (db *sqliteDB) find(root uint32, rowid int64) (*sqliteRow, error) {
+	n := root
+	for depth := 0; depth <= 64; depth++ {
+		p, offset, err := db.btreePage(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if p[offset] != 0x05 && p[offset] != 0x0d {
+			return nil, fmt.Errorf("page %d is not a table b-tree page", n)
+		}
+
+		pointers, err := cellPointers(p, offset, n)
+		if err != nil {
+			return nil, err
+		}
+
+		switch p[offset] {
+		case 0x05: // interior table page, child of cell contains rowids up to cell key
+			next := binary.BigEndian.Uint32(p[offset+8:])
+			for _, cell := range pointers {
+				if cell+4 > len(p) {
+					return nil, fmt.Errorf("invalid cell offset on page %d", n)
+				}
+				if key, _ := varint(p[cell+4:]); rowid <= int64(key) {
+					next = binary.BigEndian.Uint32(p[cell:])
+					break
+				}
+			}
+			n = next
+		default: // leaf table page
+			for _, cell := range pointers {
+				row, err := db.leafCell(p, cell)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read cell of page %d: %w", n, err)
+				}
+				if row.rowid == rowid {
+					return row, nil
+				}
+			}
+			return nil, nil
+		}
+	}
+
+	return nil, fmt.Errorf("b-tree is too deep, database is corrupted")
+}
+
+// seek search index b-tree for entry which starts with key values, entry values are followed by rowid
+// of table row. Nil is returned if index doesn't contain key.
+func (db *sqliteDB) seek(root uint32, key []any) ([]any, error) {
+	maxLocal := (db.usable-12)*64/255 - 23
+
+	n := root
+	for depth := 0; depth <= 64; depth++ {
+		p, offset, err := db.btreePage(n)
+		if err != nil {
+			return nil, err
+		}
+
+		interior := p[offset] == 0x02
+		if !interior && p[offset] != 0x0a {
+			return nil, fmt.Errorf("page %d is not an index b-tree page", n)
+		}
+
+		pointers, err := cellPointers(p, offset, n)
+		if err != nil {
+			return nil, err
+		}
+
+		next := uint32(0)
+		if interior {
+			next = binary.BigEndian.Uint32(p[offset+8:])
+		}
+
+		for _, cell := range pointers {
+			child := uint32(0)
+			if interior {
+				if cell+4 > len(p) {
+					return nil, fmt.Errorf("invalid cell offset on page %d", n)
+				}
+				child = binary.BigEndian.Uint32(p[cell:])
+				cell += 4
+			}
+			if cell >= len(p) {
+				return nil, fmt.Errorf("invalid cell offset on page %d", n)
+			}
+
+			size, l := varint(p[cell:])
+			row, err := db.cell(p, cell+l, 0, int(size), maxLocal)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cell of page %d: %w", n, err)
+			}
+
+			values, err := row.values()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read index entry of page %d: %w", n, err)
+			}
+
+			c := compareKey(values, key)
+			if c == 0 {
+				return values, nil
+			}
+			if c > 0 {
+				next = child // zero for leaf page, so search is finished
+				break
+			}
+		}
+
+		if next == 0 {
+			return nil, nil
+		}
+		n = next
+	}
+
+	return nil, fmt.Errorf("b-tree is too deep, database is corrupted")
+}
+
+// btreePage read b-tree page and return offset of its header
+func (db *sqliteDB) btreePage(n uint32) ([]byte, int, error) {
+	p, err := db.page(n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if n == 1 {
+		return p, 100, nil // database header
+	}
+	return p, 0, nil
+}
+
+// cellPointers return offsets of cells of b-tree page with header at offset, pointers follow the header
+// of 12 bytes on interior pages and of 8 bytes on leaf pages
+func cellPointers(p []byte, offset int, n uint32) ([]int, error) {
+	start := offset + 8
+	if p[offset] == 0x02 || p[offset] == 0x05 {
+		start = offset + 12
+	}
+
+	cells := int(binary.BigEndian.Uint16(p[offset+3:]))
+	if start+2*cells > len(p) {
+		return nil, fmt.Errorf("invalid cell count %d on page %d", cells, n)
+	}
+
+	pointers := make([]int, cells)
+	for i := range pointers {
+		pointers[i] = int(binary.BigEndian.Uint16(p[start+2*i:]))
+	}
+
+	return pointers, nil
+}
+
+// compareKey compare first values of index entry with key
+func compareKey(values, key []any) int {
+	for i, k := range key {
+		if i >= len(values) {
+			return -1
+		}
+		if c := compareValue(values[i], k); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValue compare record values like SQLite with BINARY collation:
+// NULL is less than numbers, numbers are less than text and text is less than blob
+func compareValue(a, b any) int {
+	rank := func(v any) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch va := a.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		if ia, ok := va.(int64); ok {
+			if ib, ok := b.(int64); ok {
+				return cmp.Compare(ia, ib)
+			}
+		}
+		return cmp.Compare(number(va), number(b))
+	case string:
+		return strings.Compare(va, b.(string))
+	default:
+		return bytes.Compare(va.([]byte), b.([]byte))
+	}
+}
+
+// number convert integer or float value to float64
+func number(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// leafCell decode cell of leaf table page
+func (db *sqliteDB) leafCell(p []byte, cell int) (*sqliteRow, error) {
+	if cell >= len(p) {
+		return nil, fmt.Errorf("invalid cell offset")
+	}
+
+	size, n := varint(p[cell:])
+	cell += n
+	rowid, n := varint(p[cell:])
+	cell += n
+
+	return db.cell(p, cell, int64(rowid), int(size), db.usable-35)
+}
+
+// cell read payload part stored in page and pointer to its overflow pages, max local payload size
+// differs for table and index pages
+func (db *sqliteDB) cell(p []byte, cell int, rowid int64, size, maxLocal int) (*sqliteRow, error) {
+	if size < 0 || int64(size) > db.size {
+		return nil, fmt.Errorf("invalid payload size %d", size)
+	}
+
+	row := &sqliteRow{db: db, rowid: rowid, size: size}
+
+	local := db.localSize(row.size, maxLocal)
+	if cell+local > len(p) {
+		return nil, fmt.Errorf("payload is out of page")
+	}
+	row.local = p[cell : cell+local]
+
+	if local < row.size {
+		if cell+local+4 > len(p) {
+			return nil, fmt.Errorf("overflow page is out of page")
+		}
+		row.overflow = binary.BigEndian.Uint32(p[cell+local:])
+	}
+
+	return row, nil
+}
+
+// localSize return size of payload part stored in b-tree page
+func (db *sqliteDB) localSize(size, maxLocal int) int {
+	if size <= maxLocal {
+		return size
+	}
+
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// payload return first n bytes of row payload, overflow pages are read only if needed
+func (r *sqliteRow) payload(n int) ([]byte, error) {
+	n = min(n, r.size)
+	if n <= len(r.local) {
+		return r.local[:n], nil
+	}
+
+	data := make([]byte, 0, n)
+	data = append(data, r.local...)
+
+	next := r.overflow
+	for pages := 0; len(data) < n; pages++ {
+		if next == 0 || pages > r.size/(r.db.usable-4)+1 {
+			return nil, fmt.Errorf("overflow chain is broken")
+		}
+
+		p, err := r.db.page(next)
+		if err != nil {
+			return nil, err
+		}
+
+		next = binary.BigEndian.Uint32(p)
+		data = append(data, p[4:min(r.db.usable, 4+n-len(data))]...)
+	}
+
+	return data, nil
+}
+
+// values decode all columns of row
+func (r *sqliteRow) values() ([]any, error) {
+	head, err := r.payload(9)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize, start := varint(head)
+	if headerSize < uint64(start) || headerSize > uint64(r.size) {
+		return nil, fmt.Errorf("invalid record header size %d", headerSize)
+	}
+
+	header, err := r.payload(int(headerSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var types []uint64
+	var sizes []int
+	end := int(headerSize)
+	for pos := start; pos < len(header); {
+		t, l := varint(header[pos:])
+		pos += l
+
+		size := serialSize(t)
+		if size < 0 || size > r.size-end {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		types = append(types, t)
+		sizes = append(sizes, size)
+		end += size
+	}
+
+	data, err := r.payload(end)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < end {
+		return nil, fmt.Errorf("record is truncated")
+	}
+
+	values := make([]any, len(types))
+	pos := int(headerSize)
+	for i, t := range types {
+		values[i] = serialValue(t, data[pos:pos+sizes[i]])
+		pos += sizes[i]
+	}
+
+	return values, nil
+}
+
+// serialSize return size of record value by its serial type
+func serialSize(t uint64) int {
+	switch {
+	case t >= 12 && t%2 == 0:
+		return int(t-12) / 2
+	case t >= 13:
+		return int(t-13) / 2
+	case t == 5:
+		return 6
+	case t == 6 || t == 7:
+		return 8
+	case t >= 1 && t <= 4:
+		return int(t)
+	default:
+		return 0
+	}
+}
+
+// serialValue decode record value: int64, float64, string, []byte or nil
+func serialValue(t uint64, b []byte) any {
+	switch {
+	case t == 0:
+		return nil
+	case t >= 1 && t <= 6:
+		v := int64(0)
+		if b[0]&0x80 != 0 {
+			v = -1 // sign extension
+		}
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		return v
+	case t == 7:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case t == 8:
+		return int64(0)
+	case t == 9:
+		return int64(1)
+	case t >= 12 && t%2 == 0:
+		return b
+	case t >= 13:
+		return string(b)
+	default:
+		return nil
+	}
+}
+
+// varint decode SQLite variable-length integer and return its length
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// columnNames return column names from CREATE TABLE or CREATE INDEX statement, table constraints are skipped
+func columnNames(sql string) []string {
+	var names []string
+	for _, def := range columnDefs(sql) {
+		names = append(names, def[0])
+	}
+	return names
+}
+
+// rowidColumn return position of INTEGER PRIMARY KEY column which is stored as rowid, -1 if table doesn't have it
+func rowidColumn(sql string) int {
+	for i, def := range columnDefs(sql) {
+		if len(def) > 3 && strings.EqualFold(def[1], "INTEGER") && strings.EqualFold(def[2], "PRIMARY") && strings.EqualFold(def[3], "KEY") {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutRowid check that CREATE TABLE statement declares WITHOUT ROWID table, such tables are stored
+// in index b-trees and aren't supported
+func withoutRowid(sql string) bool {
+	end := strings.LastIndex(sql, ")")
+	if end < 0 {
+		return false
+	}
+
+	for _, option := range strings.Split(sql[end+1:], ",") {
+		if strings.EqualFold(strings.Join(strings.Fields(option), " "), "WITHOUT ROWID") {
+			return true
+		}
+	}
+	return false
+}
+
+// columnDefs return fields of column definitions from statement, names are unquoted
+func columnDefs(sql string) [][]string {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+
+	var defs [][]string
+	depth, from := 0, start+1
+	for i := start + 1; i <= end; i++ {
+		switch {
+		case sql[i] == '(' && i != end:
+			depth++
+		case sql[i] == ')' && i != end:
+			depth--
+		case (sql[i] == ',' && depth == 0) || i == end:
+			fields := strings.Fields(sql[from:i])
+			from = i + 1
+			if len(fields) == 0 {
+				continue
+			}
+
+			switch strings.ToUpper(fields[0]) {
+			case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+				continue
+			}
+			fields[0] = strings.Trim(fields[0], "\"`[]'")
+			defs = append(defs, fields)
+		}
+	}
+
+	return defs
+}
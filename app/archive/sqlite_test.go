@@ -0,0 +1,224 @@
+package archive
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestVarint(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected uint64
+		length   int
+	}{
+		{data: []byte{0x05}, expected: 5, length: 1},
+		{data: []byte{0x81, 0x00}, expected: 128, length: 2},
+		{data: []byte{0x82, 0x80, 0x01}, expected: 0x8001, length: 3},
+		{data: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, expected: 0xffffffffffffffff, length: 9},
+	}
+
+	for _, tt := range tests {
+		v, n := varint(tt.data)
+		assert.Equal(t, tt.expected, v)
+		assert.Equal(t, tt.length, n)
+	}
+}
+
+func TestSerialValue(t *testing.T) {
+	assert.Nil(t, serialValue(0, nil))
+	assert.Equal(t, int64(-2), serialValue(1, []byte{0xfe}))
+	assert.Equal(t, int64(300), serialValue(2, []byte{0x01, 0x2c}))
+	assert.Equal(t, int64(0), serialValue(8, nil))
+	assert.Equal(t, int64(1), serialValue(9, nil))
+	assert.Equal(t, 1.5, serialValue(7, []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0}))
+	assert.Equal(t, []byte("ab"), serialValue(16, []byte("ab")))
+	assert.Equal(t, "ab", serialValue(17, []byte("ab")))
+
+	assert.Equal(t, 6, serialSize(5))
+	assert.Equal(t, 2, serialSize(16))
+	assert.Equal(t, 2, serialSize(17))
+}
+
+func TestColumnNames(t *testing.T) {
+	assert.Equal(t, []string{"zoom_level", "tile_column", "tile_row", "tile_data"},
+		columnNames("CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)"))
+	assert.Equal(t, []string{"id", "name", "value"},
+		columnNames(`CREATE TABLE "t" ("id" INTEGER PRIMARY KEY, [name] varchar(10, 2), value text, PRIMARY KEY (id, name))`))
+	assert.Nil(t, columnNames("CREATE TABLE broken"))
+}
+
+func TestOpenSQLite(t *testing.T) {
+	db, err := openSQLite("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, 512, db.pageSize)
+
+	tables, err := db.tables()
+	assert.NoError(t, err)
+	assert.Equal(t, "table", tables["tiles"].Type)
+	assert.Equal(t, "table", tables["metadata"].Type)
+	assert.Equal(t, "index", tables["tile_index"].Type)
+
+	rows := 0
+	assert.NoError(t, db.scan(tables["tiles"].RootPage, func(r *sqliteRow) error {
+		rows++
+		values, err := r.values()
+		assert.NoError(t, err)
+		assert.Len(t, values, 4)
+		return nil
+	}))
+	assert.Equal(t, 280, rows)
+
+	_, err = openSQLite("testdata/missing.mbtiles")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "text.mbtiles")
+	assert.NoError(t, os.WriteFile(path, make([]byte, 200), 0o600))
+	_, err = openSQLite(path)
+	assert.EqualError(t, err, "file is not a SQLite database")
+}
+
+func TestCompareValue(t *testing.T) {
+	assert.Equal(t, 0, compareValue(nil, nil))
+	assert.Negative(t, compareValue(nil, int64(1)))
+	assert.Negative(t, compareValue(int64(1), int64(2)))
+	assert.Positive(t, compareValue(2.5, int64(2)))
+	assert.Negative(t, compareValue(int64(9), "a"))
+	assert.Negative(t, compareValue("a", "b"))
+	assert.Negative(t, compareValue("b", []byte("a")))
+	assert.Equal(t, 0, compareKey([]any{int64(1), int64(2), int64(7)}, []any{int64(1), int64(2)}))
+	assert.Negative(t, compareKey([]any{int64(1)}, []any{int64(1), int64(2)}))
+}
+
+func TestRowidColumn(t *testing.T) {
+	assert.Equal(t, 1, rowidColumn("CREATE TABLE images (tile_data BLOB, tile_id integer primary key)"))
+	assert.Equal(t, -1, rowidColumn("CREATE TABLE images (tile_data blob, tile_id text)"))
+}
+
+func TestSQLite_Corrupted(t *testing.T) {
+	plain, err := os.ReadFile("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+
+	// open copy of plain.mbtiles changed by fn, tiles table has interior root page 3 of 512 bytes
+	open := func(fn func(data []byte)) (*sqliteDB, error) {
+		data := append([]byte(nil), plain...)
+		fn(data)
+
+		path := filepath.Join(t.TempDir(), "corrupted.mbtiles")
+		assert.NoError(t, os.WriteFile(path, data, 0o600))
+		return openSQLite(path)
+	}
+
+	_, err = open(func(data []byte) { data[18], data[19] = 2, 2 })
+	assert.ErrorContains(t, err, "WAL mode databases are not supported")
+
+	db, err := open(func(data []byte) { binary.BigEndian.PutUint32(data[2*512+8:], 3) })
+	assert.NoError(t, err)
+	assert.EqualError(t, db.scan(3, func(*sqliteRow) error { return nil }), "page 3 is referenced twice, database is corrupted")
+	assert.NoError(t, db.Close())
+
+	db, err = open(func(data []byte) { binary.BigEndian.PutUint16(data[2*512+3:], 0xffff) })
+	assert.NoError(t, err)
+	assert.EqualError(t, db.scan(3, func(*sqliteRow) error { return nil }), "invalid cell count 65535 on page 3")
+	_, err = db.find(3, 1)
+	assert.EqualError(t, err, "invalid cell count 65535 on page 3")
+	assert.NoError(t, db.Close())
+
+	db, err = openSQLite("testdata/plain.mbtiles")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = (&sqliteRow{db: db, size: 3, local: []byte{0xff, 0xff, 0x7f}}).values()
+	assert.EqualError(t, err, "invalid record header size 2097151")
+	_, err = (&sqliteRow{db: db, size: 3, local: []byte{0x02, 0x07, 0x00}}).values()
+	assert.EqualError(t, err, "record is truncated")
+	_, err = db.cell(make([]byte, 512), 0, 1, -1, 100)
+	assert.EqualError(t, err, "invalid payload size -1")
+}
+
+func TestWithoutRowid(t *testing.T) {
+	assert.True(t, withoutRowid("CREATE TABLE tiles (z, x, y, data, PRIMARY KEY (z, x, y)) without  rowid"))
+	assert.True(t, withoutRowid("CREATE TABLE tiles (z, x, y, data, PRIMARY KEY (z, x, y)) STRICT, WITHOUT ROWID"))
+	assert.False(t, withoutRowid("CREATE TABLE tiles (z, x, y, data)"))
+}
+
+// FuzzMBTiles check that malformed pages of database are reported as errors instead of panics,
+// fuzzed page replaces page of one of test databases
+func FuzzMBTiles(f *testing.F) {
+	files := make([][]byte, 0, 3)
+	for _, name := range []string{"plain.mbtiles", "mbutil.mbtiles", "noindex.mbtiles"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		files = append(files, data)
+
+		pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+		for n := 0; n < len(data)/pageSize; n++ {
+			f.Add(uint8(len(files)-1), uint16(n), data[n*pageSize:(n+1)*pageSize])
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, file uint8, n uint16, page []byte) {
+		data := slices.Clone(files[int(file)%len(files)])
+		pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+		copy(data[int(n)%(len(data)/pageSize)*pageSize:], page[:min(len(page), pageSize)])
+
+		path := filepath.Join(t.TempDir(), "fuzz.mbtiles")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := OpenMBTiles(path)
+		if err != nil {
+			return
+		}
+		defer m.Close()
+
+		_ = m.Tiles(func(*tile.Tile, time.Time) error { return nil })
+		for z := 0; z <= 2; z++ {
+			for x := 0; x < 1<<z; x++ {
+				for y := 0; y < 1<<z; y++ {
+					_, _, _ = m.Tile(z, x, y)
+				}
+			}
+		}
+	})
+}
+
+// FuzzSQLiteRecord check that malformed records are reported as errors instead of panics,
+// records of schema and of first tiles of plain.mbtiles are seeds
+func FuzzSQLiteRecord(f *testing.F) {
+	db, err := openSQLite("testdata/plain.mbtiles")
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, root := range []uint32{1, 4} {
+		err = db.scan(root, func(r *sqliteRow) error {
+			record, err := r.payload(r.size)
+			if err != nil {
+				return err
+			}
+			f.Add(record)
+			return nil
+		})
+		if err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, record []byte) {
+		_, _ = (&sqliteRow{db: db, size: len(record), local: record}).values()
+	})
+}
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// ZXY is a directory tree of z/x/y.ext tile images, modification time of file is used as tile timestamp
+type ZXY struct {
+	Path string
+	TMS  bool // y is counted from bottom like in TMS
+}
+
+// Close does nothing, files are opened only while tile is read
+func (a *ZXY) Close() error {
+	return nil
+}
+
+// Tile return image of tile file, any extension of file is accepted
+func (a *ZXY) Tile(z, x, y int) ([]byte, time.Time, error) {
+	if !validTile(x, y, z) {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	if a.TMS {
+		y = flipY(y, z)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(a.Path, strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".*"))
+	if len(matches) == 0 {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	stat, err := os.Stat(matches[0])
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read tile file: %w", err)
+	}
+
+	img, err := os.ReadFile(matches[0])
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read tile file: %w", err)
+	}
+
+	return img, stat.ModTime(), nil
+}
+
+// Tiles call fn for every tile file ordered by zoom, x and y, entries which are not z/x/y.ext are skipped
+func (a *ZXY) Tiles(fn func(t *tile.Tile, modified time.Time) error) error {
+	zooms, err := numericEntries(a.Path, true)
+	if err != nil {
+		return err
+	}
+
+	for _, z := range zooms {
+		zPath := filepath.Join(a.Path, strconv.Itoa(z))
+
+		columns, err := numericEntries(zPath, true)
+		if err != nil {
+			return err
+		}
+
+		for _, x := range columns {
+			xPath := filepath.Join(zPath, strconv.Itoa(x))
+
+			rows, err := numericEntries(xPath, false)
+			if err != nil {
+				return err
+			}
+
+			for _, y := range rows {
+				if err = a.tile(xPath, x, y, z, fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// tile read tile file and call fn
+func (a *ZXY) tile(dir string, x, y, z int, fn func(t *tile.Tile, modified time.Time) error) error {
+	matches, err := filepath.Glob(filepath.Join(dir, strconv.Itoa(y)+".*"))
+	if err != nil || len(matches) == 0 {
+		return nil
+	}
+
+	path := matches[0]
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read tile file: %w", err)
+	}
+
+	if a.TMS {
+		y = flipY(y, z)
+	}
+	if !validTile(x, y, z) {
+		return nil
+	}
+
+	img, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read tile file: %w", err)
+	}
+
+	return fn(&tile.Tile{X: x, Y: y, Z: z, Image: img, Meta: tile.Meta{Source: path}}, stat.ModTime())
+}
+
+// numericEntries return sorted numbers from names of directories or files (without extension) of dir
+func numericEntries(dir string, dirs bool) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	seen := make(map[int]bool)
+	var numbers []int
+	for _, e := range entries {
+		if e.IsDir() != dirs {
+			continue
+		}
+
+		name := e.Name()
+		if !dirs {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 0 || seen[n] {
+			continue
+		}
+
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+
+	sort.Ints(numbers)
+	return numbers, nil
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// writeTile write file of z/x/y tree
+func writeTile(t *testing.T, root, name string, data []byte) {
+	path := filepath.Join(root, name)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestZXY(t *testing.T) {
+	root := t.TempDir()
+	writeTile(t, root, "1/0/1.png", []byte("a"))
+	writeTile(t, root, "1/1/0.jpg", []byte("b"))
+	writeTile(t, root, "0/0/0.png", []byte("c"))
+	writeTile(t, root, "1/5/0.png", []byte("out of range"))
+	writeTile(t, root, "1/0/readme.txt", []byte("skipped"))
+	writeTile(t, root, "meta/0/0.png", []byte("skipped"))
+
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "1/0/1.png"), modified, modified))
+
+	tests := []struct {
+		tms      bool
+		expected []string
+	}{
+		{tms: false, expected: []string{"0/0/0 c", "1/0/1 a", "1/1/0 b"}},
+		{tms: true, expected: []string{"0/0/0 c", "1/0/0 a", "1/1/1 b"}},
+	}
+
+	for _, tt := range tests {
+		a, err := Open(root, tt.tms)
+		assert.NoError(t, err)
+
+		var tiles []string
+		assert.NoError(t, a.Tiles(func(tl *tile.Tile, m time.Time) error {
+			if string(tl.Image) == "a" {
+				assert.True(t, modified.Equal(m))
+				assert.Equal(t, filepath.Join(root, "1/0/1.png"), tl.Meta.Source)
+			}
+			tiles = append(tiles, fmt.Sprintf("%d/%d/%d", tl.Z, tl.X, tl.Y)+" "+string(tl.Image))
+			return nil
+		}))
+		assert.Equal(t, tt.expected, tiles)
+		assert.NoError(t, a.Close())
+	}
+
+	_, err := Open(filepath.Join(root, "missing"), false)
+	assert.Error(t, err)
+
+	_, err = Open(filepath.Join(root, "1/0/1.png"), false)
+	assert.EqualError(t, err, "failed to open mbtiles: file is not a SQLite database")
+}
+
+func TestZXY_Tile(t *testing.T) {
+	root := t.TempDir()
+	writeTile(t, root, "1/0/1.png", []byte("a"))
+
+	a := &ZXY{Path: root}
+	img, modified, err := a.Tile(1, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), img)
+	assert.False(t, modified.IsZero())
+
+	_, _, err = a.Tile(1, 0, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = a.Tile(1, 2, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	a.TMS = true
+	img, _, err = a.Tile(1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), img)
+}
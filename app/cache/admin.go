@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+//go:generate moq -out admin_mock.go . Admin
+
+// Admin describe cache management: statistics, purging and inspection of cached tiles
+type Admin interface {
+	Stats() (map[string]*Stats, error)
+	Purge(vendor string, match func(t *tile.Tile) bool) (int, error)
+	Info(vendor string, t *tile.Tile) (*Info, error)
+}
+
+// Stats contains statistics of cached tiles of vendor
+type Stats struct {
+	Tiles   int          `json:"tiles"`
+	Bytes   int64        `json:"bytes"`
+	Expired int          `json:"expired"`
+	Age     AgeHistogram `json:"age"`
+
+	// loads since start
+	Hits     int64   `json:"hits"`
+	Stale    int64   `json:"stale"` // expired tile was loaded
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// AgeHistogram contains count of cached tiles by time since they were saved
+type AgeHistogram struct {
+	Hour  int `json:"lt_1h"`
+	Day   int `json:"lt_1d"`
+	Week  int `json:"lt_7d"`
+	Month int `json:"lt_30d"`
+	Older int `json:"older"`
+}
+
+// Info contains cache metadata of single tile
+type Info struct {
+	tile.Meta
+	Saved    time.Time `json:"saved"`
+	Expires  time.Time `json:"expires"`
+	Expired  bool      `json:"expired"`
+	Size     int64     `json:"size"`
+	Hits     int64     `json:"hits"`
+	Accessed time.Time `json:"accessed"`
+	Path     string    `json:"path"`
+}
+
+// counters contains loads of vendor tiles since start
+type counters struct {
+	hits   int64
+	stale  int64
+	misses int64
+}
+
+// AdminOf return management interface of cache if it's supported
+func AdminOf(c Cache) (Admin, bool) {
+	switch v := c.(type) {
+	case *MapCache:
+		return v, true
+	case *TieredCache:
+		return v, v.admin() != nil
+	case *NotifyCache:
+		return AdminOf(v.Cache)
+	default:
+		return nil, false
+	}
+}
+
+// add adds age of tile to histogram
+func (h *AgeHistogram) add(age time.Duration) {
+	switch {
+	case age < time.Hour:
+		h.Hour++
+	case age < 24*time.Hour:
+		h.Day++
+	case age < 7*24*time.Hour:
+		h.Week++
+	case age < 30*24*time.Hour:
+		h.Month++
+	default:
+		h.Older++
+	}
+}
+
+// Stats return statistics of cached tiles per vendor
+func (c *MapCache) Stats() (map[string]*Stats, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	stats := make(map[string]*Stats)
+	now := time.Now()
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			if string(name) == string(blobsBucket) {
+				return nil
+			}
+
+			s := &Stats{Bytes: c.sizes[string(name)]}
+			stats[string(name)] = s
+
+			return b.ForEach(func(_, v []byte) error {
+				s.Tiles++
+
+				saved, e, err := entryDecode(v)
+				if err != nil {
+					return nil
+				}
+
+				s.Age.add(now.Sub(saved))
+				if !now.Before(c.expires(saved, e)) {
+					s.Expired++
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	c.accessMutex.Lock()
+	defer c.accessMutex.Unlock()
+
+	for vendor, cnt := range c.counters {
+		s, ok := stats[vendor]
+		if !ok {
+			s = &Stats{}
+			stats[vendor] = s
+		}
+
+		s.Hits, s.Stale, s.Misses = cnt.hits, cnt.stale, cnt.misses
+		if total := cnt.hits + cnt.stale + cnt.misses; total > 0 {
+			s.HitRatio = float64(cnt.hits) / float64(total)
+		}
+	}
+
+	return stats, nil
+}
+
+// Purge remove cached tiles of vendor which match, nil match removes all tiles of vendor. Return count of removed tiles.
+func (c *MapCache) Purge(vendor string, match func(t *tile.Tile) bool) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.flushAccess(); err != nil {
+		return 0, err
+	}
+
+	var purged []candidate
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(vendor))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			coords, err := parseTileKey(k)
+			if err != nil || (match != nil && !match(coords)) {
+				return nil
+			}
+
+			_, e, _ := entryDecode(v)
+			purged = append(purged, newCandidate(vendor, k, e))
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	if err = c.remove(purged); err != nil {
+		return 0, err
+	}
+
+	return len(purged), nil
+}
+
+// Info return cache metadata of tile
+func (c *MapCache) Info(vendor string, t *tile.Tile) (*Info, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	info := &Info{}
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(vendor))
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+
+		value := bucket.Get(tileKey(t))
+		if value == nil {
+			return fmt.Errorf("tile not found")
+		}
+
+		saved, e, err := entryDecode(value)
+		if err != nil {
+			return err
+		}
+
+		info.Meta = e.Meta
+		info.Saved = saved
+		info.Expires = c.expires(saved, e)
+		info.Expired = !time.Now().Before(info.Expires)
+		info.Size = e.Size
+		info.Hits = e.Hits
+		info.Path = c.entryPath(vendor, t, e)
+		if e.Accessed > 0 {
+			info.Accessed = time.Unix(e.Accessed, 0)
+		}
+
+		if info.Size == 0 {
+			if stat, statErr := os.Stat(info.Path); statErr == nil {
+				info.Size = stat.Size() // legacy entries don't contain size
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tile info: %w", err)
+	}
+
+	// access stats which are not flushed yet
+	c.accessMutex.Lock()
+	if a, ok := c.access[vendor+"/"+string(tileKey(t))]; ok {
+		info.Hits += a.hits
+		info.Accessed = time.Unix(a.accessed, 0)
+	}
+	c.accessMutex.Unlock()
+
+	return info, nil
+}
+
+// countLoad remember result of tile load for statistics
+func (c *MapCache) countLoad(vendor string, err error) {
+	c.accessMutex.Lock()
+	defer c.accessMutex.Unlock()
+
+	cnt, ok := c.counters[vendor]
+	if !ok {
+		cnt = &counters{}
+		c.counters[vendor] = cnt
+	}
+
+	switch {
+	case err == nil:
+		cnt.hits++
+	case errors.Is(err, ErrExpired):
+		cnt.stale++
+	default:
+		cnt.misses++
+	}
+}
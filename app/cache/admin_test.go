@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+	"go.etcd.io/bbolt"
+)
+
+func TestStats(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{2}, 10)}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+
+	// tile saved 2 hours ago is expired
+	assert.NoError(t, cache.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("a"))
+		_, e, err := entryDecode(b.Get([]byte("2_1_1")))
+		if err != nil {
+			return err
+		}
+		value, err := entryEncode(time.Now().Add(-2*time.Hour), e)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("2_1_1"), value)
+	}))
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 2, Y: 1, Z: 1})
+	assert.ErrorIs(t, err, ErrExpired)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 3, Y: 1, Z: 1})
+	assert.Error(t, err)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	stats, err := cache.Stats()
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, &Stats{
+		Tiles: 2, Bytes: 20, Expired: 1, Age: AgeHistogram{Hour: 1, Day: 1},
+		Hits: 2, Stale: 1, Misses: 1, HitRatio: 0.5,
+	}, stats["a"])
+	assert.Equal(t, &Stats{Tiles: 1, Bytes: 10, Age: AgeHistogram{Hour: 1}}, stats["b"])
+}
+
+func TestAgeHistogram(t *testing.T) {
+	h := AgeHistogram{}
+	for _, age := range []time.Duration{time.Minute, 2 * time.Hour, 48 * time.Hour, 10 * 24 * time.Hour, 60 * 24 * time.Hour} {
+		h.add(age)
+	}
+	assert.Equal(t, AgeHistogram{Hour: 1, Day: 1, Week: 1, Month: 1, Older: 1}, h)
+}
+
+func TestPurge(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	for z := 1; z <= 3; z++ {
+		assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: z, Image: bytes.Repeat([]byte{byte(z)}, 10)}))
+	}
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+
+	purged, err := cache.Purge("a", func(t *tile.Tile) bool { return t.Z >= 2 })
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, map[string]int64{"a": 10, "b": 10}, cache.Sizes())
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 2})
+	assert.Error(t, err)
+
+	purged, err = cache.Purge("a", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	purged, err = cache.Purge("unknown", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	// shared blob is still used by other vendor
+	_, err = cache.LoadTile("b", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+}
+
+func TestInfo(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	img := createPNG(t)
+	meta := tile.Meta{ETag: `"v1"`, Source: "http://example.com"}
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 2, Z: 3, Image: img, Meta: meta}))
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.NoError(t, err)
+
+	info, err := cache.Info("a", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, `"v1"`, info.ETag)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, "http://example.com", info.Source)
+	assert.Equal(t, imageHash(img), info.Hash)
+	assert.Equal(t, int64(len(img)), info.Size)
+	assert.Equal(t, int64(1), info.Hits)
+	assert.False(t, info.Expired)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), info.Expires, 2*time.Second)
+	assert.Equal(t, cache.blobPath(info.Hash, info.ContentType), info.Path)
+
+	_, err = cache.Info("a", &tile.Tile{X: 9, Y: 9, Z: 9})
+	assert.Error(t, err)
+	_, err = cache.Info("b", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.Error(t, err)
+}
+
+func TestAdminOf(t *testing.T) {
+	disk, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer disk.Close()
+	memory, err := NewMemoryCache(time.Hour, 100)
+	assert.NoError(t, err)
+
+	_, ok := AdminOf(disk)
+	assert.True(t, ok)
+	_, ok = AdminOf(memory)
+	assert.False(t, ok)
+
+	memoryOnly, err := NewTieredCache(memory)
+	assert.NoError(t, err)
+	_, ok = AdminOf(memoryOnly)
+	assert.False(t, ok)
+
+	tiered, err := NewTieredCache(memory, disk)
+	assert.NoError(t, err)
+	admin, ok := AdminOf(tiered)
+	assert.True(t, ok)
+
+	// purge removes tiles from all tiers
+	assert.NoError(t, tiered.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img")}))
+	purged, err := admin.Purge("a", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = memory.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.Error(t, err)
+
+	stats, err := admin.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats["a"].Tiles)
+}
+
+func TestMemoryCache_Purge(t *testing.T) {
+	cache, err := NewMemoryCache(time.Hour, 100)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img")}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 2, Image: []byte("img")}))
+	assert.NoError(t, cache.SaveTile("ab", &tile.Tile{X: 1, Y: 1, Z: 2, Image: []byte("img")}))
+
+	purged, err := cache.Purge("a", func(t *tile.Tile) bool { return t.Z == 2 })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, int64(6), cache.size)
+
+	_, err = cache.LoadTile("ab", &tile.Tile{X: 1, Y: 1, Z: 2})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/bbolt"
+)
+
+// blobsBucket is an index bucket with reference counts of image blobs, NUL prefix keeps it apart from vendor buckets
+var blobsBucket = []byte("\x00blobs")
+
+// blobPath return path of content-addressed image blob
+func (c *MapCache) blobPath(hash, contentType string) string {
+	return filepath.Join(c.path, "blobs", hash[:2], hash+"."+extension(contentType))
+}
+
+// writeBlob saves image blob atomically, existing blob is kept as is because it has the same content
+func (c *MapCache) writeBlob(hash, contentType string, img []byte) error {
+	path := c.blobPath(hash, contentType)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	return writeFileAtomic(path, img)
+}
+
+// writeFileAtomic write data to temporary file and rename it to path, so partially written file is never visible
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name()) // no-op after successful rename
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync image: %w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close image: %w", err)
+	}
+
+	if err = os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename image: %w", err)
+	}
+
+	// persist rename itself, so index never points to image lost on crash
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
+
+// incRef increment reference count of blob and return true if blob is referenced first time
+func incRef(tx *bbolt.Tx, hash string) (bool, error) {
+	b, err := tx.CreateBucketIfNotExists(blobsBucket)
+	if err != nil {
+		return false, err
+	}
+
+	refs := refDecode(b.Get([]byte(hash)))
+	return refs == 0, b.Put([]byte(hash), refEncode(refs+1))
+}
+
+// decRef decrement reference count of blob and return true if blob is not referenced anymore
+func decRef(tx *bbolt.Tx, hash string) (bool, error) {
+	b := tx.Bucket(blobsBucket)
+	if b == nil {
+		return true, nil
+	}
+
+	refs := refDecode(b.Get([]byte(hash)))
+	if refs <= 1 {
+		return true, b.Delete([]byte(hash))
+	}
+
+	return false, b.Put([]byte(hash), refEncode(refs-1))
+}
+
+// refEncode encodes reference count
+func refEncode(refs uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, refs)
+	return buf
+}
+
+// refDecode decodes reference count, missing or corrupted value is zero
+func refDecode(value []byte) uint64 {
+	if len(value) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// forEachTile iterate over index entries of all vendors
+func forEachTile(tx *bbolt.Tx, fn func(vendor string, k, v []byte) error) error {
+	return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+		if string(name) == string(blobsBucket) {
+			return nil
+		}
+
+		vendor := string(name)
+		return b.ForEach(func(k, v []byte) error {
+			return fn(vendor, k, v)
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+	"go.etcd.io/bbolt"
+)
+
+// blobRefs return reference count of blob
+func blobRefs(t *testing.T, cache *MapCache, hash string) uint64 {
+	var refs uint64
+	assert.NoError(t, cache.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket(blobsBucket); b != nil {
+			refs = refDecode(b.Get([]byte(hash)))
+		}
+		return nil
+	}))
+	return refs
+}
+
+func TestSaveTile_Deduplicate(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	img := bytes.Repeat([]byte{1}, 10)
+	hash := imageHash(img)
+	blob := filepath.Join(tmpDir, "blobs", hash[:2], hash+".bin")
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: img}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: img}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: img}))
+	// saving the same image again doesn't add reference
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: img}))
+
+	files, err := os.ReadDir(filepath.Dir(blob))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, uint64(3), blobRefs(t, cache, hash))
+	assert.Equal(t, map[string]int64{"a": 20, "b": 10}, cache.Sizes())
+
+	// replaced tile releases its reference
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{2}, 10)}))
+	assert.Equal(t, uint64(2), blobRefs(t, cache, hash))
+
+	// blob is removed with the last reference
+	assert.NoError(t, cache.remove([]candidate{
+		{vendor: "a", key: []byte("1_1_1"), contentType: "application/octet-stream", hash: hash, blob: true, size: 10},
+	}))
+	_, err = os.Stat(blob)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.remove([]candidate{
+		{vendor: "a", key: []byte("2_1_1"), contentType: "application/octet-stream", hash: hash, blob: true, size: 10},
+	}))
+	_, err = os.Stat(blob)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, uint64(0), blobRefs(t, cache, hash))
+	assert.Equal(t, map[string]int64{"a": 0, "b": 10}, cache.Sizes())
+}
+
+func TestRefEncodeDecode(t *testing.T) {
+	assert.Equal(t, uint64(42), refDecode(refEncode(42)))
+	assert.Equal(t, uint64(0), refDecode(nil))
+	assert.Equal(t, uint64(0), refDecode([]byte{1}))
+}
@@ -2,7 +2,10 @@ package cache
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,10 +18,14 @@ import (
 
 //go:generate moq -out cache_mock.go . Cache
 
+// ErrExpired returned by LoadTile with cached image when tile is expired, tile meta is filled for revalidation
+var ErrExpired = errors.New("tile expired")
+
 // Cache describe basic cache for tiles
 type Cache interface {
 	SaveTile(vendor string, t *tile.Tile) error
 	LoadTile(vendor string, t *tile.Tile) ([]byte, error)
+	Touch(vendor string, t *tile.Tile) error
 	Close() error
 }
 
@@ -28,6 +35,27 @@ type MapCache struct {
 	path  string
 	alive time.Duration
 	mutex sync.RWMutex
+
+	maxSize int64          // max summary size of cached images in bytes, zero is unlimited
+	policy  EvictionPolicy // which tiles are evicted first when maxSize is exceeded
+
+	sizes    map[string]int64     // summary size of cached images per vendor, shared blob is counted for every tile, guarded by mutex
+	disk     int64                // summary size of image files, shared blob is counted once, guarded by mutex
+	access   map[string]access    // access stats not flushed to index yet, guarded by accessMutex
+	counters map[string]*counters // loads since start per vendor, guarded by accessMutex
+
+	accessMutex sync.Mutex
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// entry is an index value of cached tile
+type entry struct {
+	tile.Meta
+	Size     int64 `json:"size,omitempty"`
+	Accessed int64 `json:"accessed,omitempty"` // unix time of last access
+	Hits     int64 `json:"hits,omitempty"`
+	Blob     bool  `json:"blob,omitempty"` // image is stored as content-addressed blob
 }
 
 // NewCache initializes a new Cache instance
@@ -43,27 +71,86 @@ func NewCache(path string, alive time.Duration, indexOpts *bbolt.Options) (*MapC
 		return nil, fmt.Errorf("failed to open bolt db: %w", err)
 	}
 
-	return &MapCache{db: db, path: path, alive: alive, mutex: sync.RWMutex{}}, nil
+	c := &MapCache{
+		db:       db,
+		path:     path,
+		alive:    alive,
+		mutex:    sync.RWMutex{},
+		policy:   LRU,
+		access:   make(map[string]access),
+		counters: make(map[string]*counters),
+		done:     make(chan struct{}),
+	}
+
+	c.sizes, c.disk, err = c.calcSizes()
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to calculate cache size: %w", err)
+	}
+
+	return c, nil
 }
 
 // Close close boldDB file
 func (c *MapCache) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	if !c.db.IsReadOnly() {
+		_ = c.flushAccess()
+	}
+
 	return c.db.Close()
 }
 
-// SaveTile saves a tile to both BoltDB and disk storage
+// SaveTile saves a tile image as content-addressed blob and then points index to it.
+// Content type and hash of image are filled in tile meta, tile copied from other tier keeps its expiration.
 func (c *MapCache) SaveTile(vendor string, t *tile.Tile) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	err := c.db.Update(func(tx *bbolt.Tx) error {
+	t.Meta.ContentType = http.DetectContentType(t.Image)
+	t.Meta.Hash = imageHash(t.Image)
+
+	err := c.writeBlob(t.Meta.Hash, t.Meta.ContentType, t.Image)
+	if err != nil {
+		return err
+	}
+
+	var old *entry
+	var added bool    // new blob is referenced first time
+	var released bool // old blob is not referenced anymore
+
+	err = c.db.Update(func(tx *bbolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(vendor))
 		if err != nil {
 			return err
 		}
 
-		key := []byte(fmt.Sprintf("%d_%d_%d", t.X, t.Y, t.Z))
-		value := unixTimeEncode(time.Now())
+		key := tileKey(t)
+		if value := bucket.Get(key); value != nil {
+			if _, oldEntry, decodeErr := entryDecode(value); decodeErr == nil {
+				old = oldEntry
+			}
+		}
+
+		if old == nil || !old.Blob || old.Hash != t.Meta.Hash {
+			if added, err = incRef(tx, t.Meta.Hash); err != nil {
+				return err
+			}
+
+			if old != nil && old.Blob {
+				if released, err = decRef(tx, old.Hash); err != nil {
+					return err
+				}
+			}
+		}
+
+		e := &entry{Meta: t.Meta, Size: int64(len(t.Image)), Accessed: time.Now().Unix(), Blob: true}
+		value, err := entryEncode(savedTime(&t.Meta, c.alive), e)
+		if err != nil {
+			return err
+		}
+
 		return bucket.Put(key, value)
 	})
 
@@ -71,60 +158,195 @@ func (c *MapCache) SaveTile(vendor string, t *tile.Tile) error {
 		return fmt.Errorf("failed to update db: %w", err)
 	}
 
-	return c.saveImage(vendor, t)
+	if added {
+		c.disk += int64(len(t.Image))
+	}
+
+	var oldSize int64
+	if old != nil {
+		oldSize = old.Size
+		switch {
+		case !old.Blob:
+			path := c.imagePath(vendor, &tile.Tile{X: t.X, Y: t.Y, Z: t.Z, Meta: old.Meta})
+			if info, statErr := os.Stat(path); statErr == nil {
+				c.disk -= info.Size()
+			}
+			_ = os.Remove(path)
+		case released:
+			c.disk -= old.Size
+			_ = os.Remove(c.blobPath(old.Hash, old.ContentType))
+		}
+	}
+
+	c.sizes[vendor] += int64(len(t.Image)) - oldSize
+
+	if c.maxSize > 0 && c.disk > c.maxSize {
+		return c.evict(c.maxSize * evictTargetPercent / 100)
+	}
+
+	return nil
+}
+
+// Touch refreshes saved time and meta of already cached tile, e.g. after upstream answered 304 Not Modified
+func (c *MapCache) Touch(vendor string, t *tile.Tile) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(vendor))
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+
+		key := tileKey(t)
+		old := bucket.Get(key)
+		if old == nil {
+			return fmt.Errorf("tile not found")
+		}
+
+		_, e, err := entryDecode(old)
+		if err != nil {
+			return err
+		}
+		e.Revalidate(&t.Meta)
+
+		value, err := entryEncode(time.Now(), e)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to touch tile: %w", err)
+	}
+
+	return nil
 }
 
-// LoadTile attempts to load a tile from cache, checking both BoltDB and disk storage
+// LoadTile attempts to load a tile from cache, checking both BoltDB and disk storage.
+// Tile meta (including content type) is copied to t, expired tile is returned together with ErrExpired.
 func (c *MapCache) LoadTile(vendor string, t *tile.Tile) ([]byte, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
 	var img []byte
-	var err error
+	var expired bool
 
-	err = c.db.View(func(tx *bbolt.Tx) error {
+	err := c.db.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(vendor))
 		if bucket == nil {
 			return fmt.Errorf("bucket not found")
 		}
 
-		key := []byte(fmt.Sprintf("%d_%d_%d", t.X, t.Y, t.Z))
-		value := bucket.Get(key)
-		if value == nil || !time.Now().Before(unixTimeDecode(value).Add(c.alive)) {
-			return fmt.Errorf("tile not found or expired")
+		value := bucket.Get(tileKey(t))
+		if value == nil {
+			return fmt.Errorf("tile not found")
+		}
+
+		saved, e, err := entryDecode(value)
+		if err != nil {
+			return err
 		}
 
-		img, err = os.ReadFile(filepath.Clean(filepath.Join(c.path, vendor, fmt.Sprintf("%d", t.Z), fmt.Sprintf("%d_%d.jpeg", t.X, t.Y))))
+		t.Meta = e.Meta
+		t.Meta.Expires = c.expires(saved, e)
+		expired = !time.Now().Before(t.Meta.Expires)
+
+		img, err = os.ReadFile(c.entryPath(vendor, t, e))
 		return err
 	})
 	if err != nil {
+		c.countLoad(vendor, err)
 		return nil, fmt.Errorf("failed to load tile: %w", err)
 	}
 
+	c.recordAccess(vendor, tileKey(t))
+
+	if expired {
+		c.countLoad(vendor, ErrExpired)
+		return img, ErrExpired
+	}
+
+	c.countLoad(vendor, nil)
 	return img, nil
 }
 
-// saveImage saves an image file to disk
-func (c *MapCache) saveImage(vendor string, t *tile.Tile) error {
-	dirPath := filepath.Join(c.path, vendor, fmt.Sprintf("%d", t.Z))
-	filePath := filepath.Join(dirPath, fmt.Sprintf("%d_%d.jpeg", t.X, t.Y))
+// expires return expiration time of cached tile, upstream max-age overrides cache alive
+func (c *MapCache) expires(saved time.Time, e *entry) time.Time {
+	if e.MaxAge > 0 {
+		return saved.Add(e.MaxAge)
+	}
+	return saved.Add(c.alive)
+}
 
-	err := os.MkdirAll(dirPath, 0o700)
-	if err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+// savedTime return saved time of tile which is now, but tile can't live longer than its known expiration
+// (e.g. when copied from other tier), so its saved time is moved back
+func savedTime(meta *tile.Meta, alive time.Duration) time.Time {
+	if meta.MaxAge > 0 {
+		alive = meta.MaxAge
 	}
 
-	file, err := os.Create(filepath.Clean(filePath))
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	now := time.Now()
+	if saved := meta.Expires.Add(-alive); !meta.Expires.IsZero() && saved.Before(now) {
+		return saved
+	}
+
+	return now
+}
+
+// entryPath return path of cached image, tiles saved before content-addressed storage have own image file
+func (c *MapCache) entryPath(vendor string, t *tile.Tile, e *entry) string {
+	if e.Blob {
+		return c.blobPath(e.Hash, e.ContentType)
+	}
+	return c.imagePath(vendor, &tile.Tile{X: t.X, Y: t.Y, Z: t.Z, Meta: e.Meta})
+}
+
+// imagePath return path of legacy per-tile image file, extension depends on content type of tile
+func (c *MapCache) imagePath(vendor string, t *tile.Tile) string {
+	name := fmt.Sprintf("%d_%d.%s", t.X, t.Y, extension(t.Meta.ContentType))
+	return filepath.Clean(filepath.Join(c.path, vendor, fmt.Sprintf("%d", t.Z), name))
+}
+
+// tileKey return index key for specified tile
+func tileKey(t *tile.Tile) []byte {
+	return []byte(fmt.Sprintf("%d_%d_%d", t.X, t.Y, t.Z))
+}
+
+// parseTileKey parse index key back to tile coords
+func parseTileKey(key []byte) (*tile.Tile, error) {
+	t := &tile.Tile{}
+	if _, err := fmt.Sscanf(string(key), "%d_%d_%d", &t.X, &t.Y, &t.Z); err != nil {
+		return nil, fmt.Errorf("invalid tile key %q: %w", key, err)
 	}
+	return t, nil
+}
 
-	_, err = file.Write(t.Image)
+// entryEncode encodes index entry: saved time followed by JSON encoded entry
+func entryEncode(saved time.Time, e *entry) ([]byte, error) {
+	m, err := json.Marshal(e)
 	if err != nil {
-		return fmt.Errorf("failed to write image: %w", err)
+		return nil, fmt.Errorf("failed to encode tile meta: %w", err)
 	}
 
-	return nil
+	return append(unixTimeEncode(saved), m...), nil
+}
+
+// entryDecode decodes index entry, legacy entries contain only saved time
+func entryDecode(value []byte) (time.Time, *entry, error) {
+	if len(value) < 8 {
+		return time.Time{}, nil, fmt.Errorf("index entry is corrupted")
+	}
+
+	e := &entry{}
+	if len(value) > 8 {
+		if err := json.Unmarshal(value[8:], e); err != nil {
+			return time.Time{}, nil, fmt.Errorf("failed to decode tile meta: %w", err)
+		}
+	}
+
+	return unixTimeDecode(value[:8]), e, nil
 }
 
 // unixTimeEncode encodes time.Time to []byte
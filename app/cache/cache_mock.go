@@ -27,6 +27,9 @@ var _ Cache = &CacheMock{}
 //			SaveTileFunc: func(vendor string, t *tile.Tile) error {
 //				panic("mock out the SaveTile method")
 //			},
+//			TouchFunc: func(vendor string, t *tile.Tile) error {
+//				panic("mock out the Touch method")
+//			},
 //		}
 //
 //		// use mockedCache in code that requires Cache
@@ -43,6 +46,9 @@ type CacheMock struct {
 	// SaveTileFunc mocks the SaveTile method.
 	SaveTileFunc func(vendor string, t *tile.Tile) error
 
+	// TouchFunc mocks the Touch method.
+	TouchFunc func(vendor string, t *tile.Tile) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Close holds details about calls to the Close method.
@@ -62,10 +68,18 @@ type CacheMock struct {
 			// T is the t argument value.
 			T *tile.Tile
 		}
+		// Touch holds details about calls to the Touch method.
+		Touch []struct {
+			// Vendor is the vendor argument value.
+			Vendor string
+			// T is the t argument value.
+			T *tile.Tile
+		}
 	}
 	lockClose    sync.RWMutex
 	lockLoadTile sync.RWMutex
 	lockSaveTile sync.RWMutex
+	lockTouch    sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -166,3 +180,39 @@ func (mock *CacheMock) SaveTileCalls() []struct {
 	mock.lockSaveTile.RUnlock()
 	return calls
 }
+
+// Touch calls TouchFunc.
+func (mock *CacheMock) Touch(vendor string, t *tile.Tile) error {
+	if mock.TouchFunc == nil {
+		panic("CacheMock.TouchFunc: method is nil but Cache.Touch was just called")
+	}
+	callInfo := struct {
+		Vendor string
+		T      *tile.Tile
+	}{
+		Vendor: vendor,
+		T:      t,
+	}
+	mock.lockTouch.Lock()
+	mock.calls.Touch = append(mock.calls.Touch, callInfo)
+	mock.lockTouch.Unlock()
+	return mock.TouchFunc(vendor, t)
+}
+
+// TouchCalls gets all the calls that were made to Touch.
+// Check the length with:
+//
+//	len(mockedCache.TouchCalls())
+func (mock *CacheMock) TouchCalls() []struct {
+	Vendor string
+	T      *tile.Tile
+} {
+	var calls []struct {
+		Vendor string
+		T      *tile.Tile
+	}
+	mock.lockTouch.RLock()
+	calls = mock.calls.Touch
+	mock.lockTouch.RUnlock()
+	return calls
+}
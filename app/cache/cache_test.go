@@ -113,20 +113,25 @@ func TestLoadTile_FailedTileError(t *testing.T) {
 	assert.Nil(t, loadedTile)
 }
 
-func TestSaveImage_Success(t *testing.T) {
+func TestWriteBlob_Success(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "map-tile-provider-test-save-image")
 	defer os.RemoveAll(tmpDir)
 
 	cache, err := NewCache(tmpDir, time.Hour, nil)
 	assert.NoError(t, err)
 
-	testTile := &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("test-image")}
-	err = cache.saveImage("vendor", testTile)
+	hash := imageHash([]byte("test-image"))
+	err = cache.writeBlob(hash, "image/jpeg", []byte("test-image"))
+	assert.NoError(t, err)
+
+	img, err := os.ReadFile(filepath.Join(tmpDir, "blobs", hash[:2], hash+".jpeg"))
 	assert.NoError(t, err)
+	assert.Equal(t, []byte("test-image"), img)
 
-	imagePath := filepath.Join(tmpDir, "vendor", "3", "1_2.jpeg")
-	_, err = os.Stat(imagePath)
+	// no temporary files are left
+	files, err := os.ReadDir(filepath.Join(tmpDir, "blobs", hash[:2]))
 	assert.NoError(t, err)
+	assert.Len(t, files, 1)
 }
 
 func TestUnixTimeEncodeDecode(t *testing.T) {
@@ -136,3 +141,98 @@ func TestUnixTimeEncodeDecode(t *testing.T) {
 
 	assert.Equal(t, now.Unix(), decoded.Unix())
 }
+
+func TestLoadTile_Expired(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "map-tile-provider-test-load-expired")
+	defer os.RemoveAll(tmpDir)
+
+	cache, err := NewCache(tmpDir, -time.Second, nil)
+	assert.NoError(t, err)
+
+	testTile := &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("test-image"), Meta: tile.Meta{ETag: `"v1"`}}
+	err = cache.SaveTile("vendor", testTile)
+	assert.NoError(t, err)
+
+	loadTile := &tile.Tile{X: 1, Y: 2, Z: 3}
+	loadedImage, err := cache.LoadTile("vendor", loadTile)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, []byte("test-image"), loadedImage)
+	assert.Equal(t, `"v1"`, loadTile.Meta.ETag)
+}
+
+func TestLoadTile_MaxAgeOverride(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "map-tile-provider-test-load-max-age")
+	defer os.RemoveAll(tmpDir)
+
+	cache, err := NewCache(tmpDir, -time.Second, nil)
+	assert.NoError(t, err)
+
+	testTile := &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("test-image"), Meta: tile.Meta{MaxAge: time.Hour}}
+	err = cache.SaveTile("vendor", testTile)
+	assert.NoError(t, err)
+
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.NoError(t, err)
+}
+
+func TestTouch_Success(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "map-tile-provider-test-touch")
+	defer os.RemoveAll(tmpDir)
+
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+
+	testTile := &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("test-image"), Meta: tile.Meta{ETag: `"v1"`}}
+	assert.NoError(t, cache.SaveTile("vendor", testTile))
+
+	testTile.Meta.ETag = `"v2"`
+	assert.NoError(t, cache.Touch("vendor", testTile))
+
+	loadTile := &tile.Tile{X: 1, Y: 2, Z: 3}
+	_, err = cache.LoadTile("vendor", loadTile)
+	assert.NoError(t, err)
+	assert.Equal(t, `"v2"`, loadTile.Meta.ETag)
+}
+
+func TestTouch_Failed(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "map-tile-provider-test-touch-failed")
+	defer os.RemoveAll(tmpDir)
+
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+
+	err = cache.Touch("vendor", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bucket not found")
+
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("test-image")}))
+
+	err = cache.Touch("vendor", &tile.Tile{X: 3, Y: 2, Z: 1})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tile not found")
+}
+
+func TestEntryEncodeDecode(t *testing.T) {
+	now := time.Now()
+	e := &entry{Meta: tile.Meta{ETag: `"v1"`, LastModified: "Mon, 01 Jan 2024 00:00:00 GMT", MaxAge: time.Minute}, Size: 10, Hits: 2}
+
+	value, err := entryEncode(now, e)
+	assert.NoError(t, err)
+
+	saved, decoded, err := entryDecode(value)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Unix(), saved.Unix())
+	assert.Equal(t, e, decoded)
+
+	// legacy entries contain only timestamp
+	saved, decoded, err = entryDecode(unixTimeEncode(now))
+	assert.NoError(t, err)
+	assert.Equal(t, now.Unix(), saved.Unix())
+	assert.Equal(t, &entry{}, decoded)
+
+	_, _, err = entryDecode([]byte{0x01})
+	assert.Error(t, err)
+
+	_, _, err = entryDecode(append(unixTimeEncode(now), []byte("{invalid")...))
+	assert.Error(t, err)
+}
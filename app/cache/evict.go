@@ -0,0 +1,388 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// EvictionPolicy defines which tiles are evicted first when cache size limit is exceeded
+type EvictionPolicy string
+
+const (
+	// LRU evicts least recently used tiles first
+	LRU EvictionPolicy = "lru"
+	// LFU evicts least frequently used tiles first
+	LFU EvictionPolicy = "lfu"
+)
+
+// evictTargetPercent is a percent of max size which cache is shrunk to by eviction,
+// so eviction is not triggered by every new tile
+const evictTargetPercent = 90
+
+// access contains tile access stats collected in memory between index flushes
+type access struct {
+	vendor   string
+	key      []byte
+	accessed int64
+	hits     int64
+}
+
+// candidate is a cached tile which can be evicted
+type candidate struct {
+	vendor      string
+	key         []byte
+	contentType string
+	hash        string
+	blob        bool
+	size        int64
+	accessed    int64
+	hits        int64
+}
+
+// newCandidate create candidate from index entry, corrupted (nil) entry has zero stats
+func newCandidate(vendor string, key []byte, e *entry) candidate {
+	cand := candidate{vendor: vendor, key: append([]byte{}, key...)}
+	if e != nil {
+		cand.contentType = e.ContentType
+		cand.hash = e.Hash
+		cand.blob = e.Blob
+		cand.size = e.Size
+		cand.accessed = e.Accessed
+		cand.hits = e.Hits
+	}
+	return cand
+}
+
+// SetLimit set max summary size of image files in bytes and eviction policy (LRU if empty), zero maxSize disables limit.
+// Image shared by tiles is counted once, so it's a size of cache on disk except of index.
+func (c *MapCache) SetLimit(maxSize int64, policy EvictionPolicy) error {
+	if policy == "" {
+		policy = LRU
+	}
+
+	if policy != LRU && policy != LFU {
+		return fmt.Errorf("eviction policy %q not supported", policy)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.maxSize = maxSize
+	c.policy = policy
+
+	if c.maxSize > 0 && c.disk > c.maxSize {
+		return c.evict(c.maxSize * evictTargetPercent / 100)
+	}
+
+	return nil
+}
+
+// Sizes return summary size of cached images in bytes per vendor, image shared by tiles is counted for every tile
+func (c *MapCache) Sizes() map[string]int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	sizes := make(map[string]int64, len(c.sizes))
+	for vendor, size := range c.sizes {
+		sizes[vendor] = size
+	}
+
+	return sizes
+}
+
+// RunSweeper start background sweeping of expired tiles every interval until cache is closed.
+// Tiles are removed when they are expired for more than retain, so stale tiles can be still served.
+func (c *MapCache) RunSweeper(interval, retain time.Duration, report func(removed int, err error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				removed, err := c.Sweep(retain)
+				if report != nil {
+					report(removed, err)
+				}
+			}
+		}
+	}()
+}
+
+// Sweep remove tiles which are expired for more than retain and flush access stats to index
+func (c *MapCache) Sweep(retain time.Duration) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.flushAccess(); err != nil {
+		return 0, err
+	}
+
+	var expired []candidate
+	now := time.Now()
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return forEachTile(tx, func(vendor string, k, v []byte) error {
+			saved, e, err := entryDecode(v)
+			if err != nil || now.After(c.expires(saved, e).Add(retain)) {
+				expired = append(expired, newCandidate(vendor, k, e))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	if err = c.remove(expired); err != nil {
+		return 0, err
+	}
+
+	return len(expired), nil
+}
+
+// evict remove tiles according to eviction policy until summary size is less than target, mutex must be locked
+func (c *MapCache) evict(target int64) error {
+	if err := c.flushAccess(); err != nil {
+		return err
+	}
+
+	var candidates []candidate
+	refs := make(map[string]uint64)
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket(blobsBucket); b != nil {
+			if err := b.ForEach(func(k, v []byte) error {
+				refs[string(k)] = refDecode(v)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		return forEachTile(tx, func(vendor string, k, v []byte) error {
+			// corrupted entries have zero access time, so they are evicted first
+			_, e, _ := entryDecode(v)
+			candidates = append(candidates, newCandidate(vendor, k, e))
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if c.policy == LFU && candidates[i].hits != candidates[j].hits {
+			return candidates[i].hits < candidates[j].hits
+		}
+		return candidates[i].accessed < candidates[j].accessed
+	})
+
+	// shared blob frees space only with its last reference
+	total := c.disk
+	var evicted []candidate
+	for _, cand := range candidates {
+		if total <= target {
+			break
+		}
+		evicted = append(evicted, cand)
+
+		if cand.blob {
+			if refs[cand.hash] > 1 {
+				refs[cand.hash]--
+				continue
+			}
+			delete(refs, cand.hash)
+		}
+		total -= cand.size
+	}
+
+	return c.remove(evicted)
+}
+
+// remove delete tiles from index and their images from disk, shared blobs are removed with the last reference, mutex must be locked
+func (c *MapCache) remove(tiles []candidate) error {
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	released := make(map[string]bool)
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		for _, t := range tiles {
+			b := tx.Bucket([]byte(t.vendor))
+			if b == nil || b.Get(t.key) == nil {
+				continue
+			}
+			if err := b.Delete(t.key); err != nil {
+				return err
+			}
+
+			if t.blob {
+				free, err := decRef(tx, t.hash)
+				if err != nil {
+					return err
+				}
+				released[t.hash] = free
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove tiles from index: %w", err)
+	}
+
+	for _, t := range tiles {
+		if t.blob {
+			c.sizes[t.vendor] -= t.size
+			if !released[t.hash] {
+				continue
+			}
+			c.disk -= t.size
+			released[t.hash] = false // the same blob may be referenced by several removed tiles
+
+			if err = os.Remove(c.blobPath(t.hash, t.contentType)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove tile image: %w", err)
+			}
+			continue
+		}
+
+		coords, err := parseTileKey(t.key)
+		if err != nil {
+			continue
+		}
+		coords.Meta.ContentType = t.contentType
+
+		path := c.imagePath(t.vendor, coords)
+		size := t.size
+		if info, statErr := os.Stat(path); statErr == nil && size == 0 {
+			size = info.Size() // legacy entries don't contain size
+		}
+		c.sizes[t.vendor] -= size
+		c.disk -= size
+
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove tile image: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// recordAccess remember tile access, stats are written to index by flushAccess
+func (c *MapCache) recordAccess(vendor string, key []byte) {
+	c.accessMutex.Lock()
+	defer c.accessMutex.Unlock()
+
+	k := vendor + "/" + string(key)
+	a, ok := c.access[k]
+	if !ok {
+		a = access{vendor: vendor, key: key}
+	}
+	a.accessed = time.Now().Unix()
+	a.hits++
+	c.access[k] = a
+}
+
+// flushAccess write collected access stats to index
+func (c *MapCache) flushAccess() error {
+	c.accessMutex.Lock()
+	pending := c.access
+	c.access = make(map[string]access)
+	c.accessMutex.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		for _, a := range pending {
+			b := tx.Bucket([]byte(a.vendor))
+			if b == nil {
+				continue
+			}
+
+			value := b.Get(a.key)
+			if value == nil {
+				continue // tile was removed after access
+			}
+
+			saved, e, err := entryDecode(value)
+			if err != nil {
+				continue
+			}
+			e.Accessed = a.accessed
+			e.Hits += a.hits
+
+			value, err = entryEncode(saved, e)
+			if err != nil {
+				return err
+			}
+
+			if err = b.Put(a.key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to flush access stats: %w", err)
+	}
+
+	return nil
+}
+
+// calcSizes calculate summary size of cached images per vendor and size of image files where shared blob is
+// counted once, legacy entries without size are checked on disk
+func (c *MapCache) calcSizes() (map[string]int64, int64, error) {
+	sizes := make(map[string]int64)
+	blobs := make(map[string]bool)
+	var disk int64
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			if string(name) == string(blobsBucket) {
+				return nil
+			}
+
+			vendor := string(name)
+			sizes[vendor] = 0
+
+			return b.ForEach(func(k, v []byte) error {
+				_, e, err := entryDecode(v)
+				if err == nil && e.Size > 0 {
+					sizes[vendor] += e.Size
+					if !e.Blob || !blobs[e.Hash] {
+						disk += e.Size
+					}
+					if e.Blob {
+						blobs[e.Hash] = true
+					}
+					return nil
+				}
+
+				coords, err := parseTileKey(k)
+				if err != nil {
+					return nil
+				}
+				if e != nil {
+					coords.Meta.ContentType = e.ContentType
+				}
+
+				if info, statErr := os.Stat(c.imagePath(vendor, coords)); statErr == nil {
+					sizes[vendor] += info.Size()
+					disk += info.Size()
+				}
+				return nil
+			})
+		})
+	})
+
+	return sizes, disk, err
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+	"go.etcd.io/bbolt"
+)
+
+func TestSetLimit_FailedPolicy(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	err = cache.SetLimit(100, EvictionPolicy("fifo"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `eviction policy "fifo" not supported`)
+}
+
+func TestSizes(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 20)}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 5)}))
+
+	// overwrite must not count size twice
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 15)}))
+
+	assert.Equal(t, map[string]int64{"a": 25, "b": 5}, cache.Sizes())
+}
+
+func TestSizes_Reopen(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+
+	// legacy entry without size
+	assert.NoError(t, cache.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("a")).Put([]byte("2_1_1"), unixTimeEncode(time.Now()))
+	}))
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "a", "1"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a", "1", "2_1.jpeg"), bytes.Repeat([]byte{1}, 7), 0o600))
+	assert.NoError(t, cache.Close())
+
+	cache, err = NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.Equal(t, map[string]int64{"a": 17}, cache.Sizes())
+}
+
+func TestEvict_LRU(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SetLimit(35, LRU))
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{2}, 10)}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 3, Y: 1, Z: 1, Image: bytes.Repeat([]byte{3}, 10)}))
+
+	// access times are stored in seconds
+	time.Sleep(1100 * time.Millisecond)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	// limit is exceeded, least recently used tiles are evicted until 90% of limit
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 4, Y: 1, Z: 1, Image: bytes.Repeat([]byte{4}, 10)}))
+	assert.Equal(t, int64(30), cache.Sizes()["a"])
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 4, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 2, Y: 1, Z: 1})
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(cache.path, "a", "1", "2_1.jpeg"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEvict_LFU(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{2}, 10)}))
+
+	for i := 0; i < 3; i++ {
+		_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+		assert.NoError(t, err)
+	}
+	_, err = cache.LoadTile("b", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	// lowering limit evicts immediately
+	assert.NoError(t, cache.SetLimit(15, LFU))
+	assert.Equal(t, map[string]int64{"a": 10, "b": 0}, cache.Sizes())
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("b", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.Error(t, err)
+}
+
+func TestEvict_SharedBlob(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SetLimit(15, LRU))
+
+	// the same image is stored once, so limit is not exceeded
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.Equal(t, map[string]int64{"a": 10, "b": 10}, cache.Sizes())
+
+	// access times are stored in seconds
+	time.Sleep(1100 * time.Millisecond)
+
+	// the shared image frees space only with its last tile, so both tiles are evicted
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{2}, 10)}))
+	assert.Equal(t, map[string]int64{"a": 10, "b": 0}, cache.Sizes())
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 2, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("b", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.Error(t, err)
+}
+
+func TestSweep(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10),
+		Meta: tile.Meta{MaxAge: time.Second}}))
+
+	// expired tiles are kept during retain period
+	time.Sleep(1100 * time.Millisecond)
+	removed, err := cache.Sweep(time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	removed, err = cache.Sweep(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, int64(10), cache.Sizes()["a"])
+
+	_, err = os.Stat(filepath.Join(cache.path, "a", "1", "2_1.jpeg"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunSweeper(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), -time.Hour, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: bytes.Repeat([]byte{1}, 10)}))
+
+	reports := make(chan int, 10)
+	cache.RunSweeper(10*time.Millisecond, 0, func(removed int, err error) {
+		assert.NoError(t, err)
+		reports <- removed
+	})
+
+	assert.Equal(t, 1, <-reports)
+	assert.NoError(t, cache.Close())
+}
+
+func TestFlushAccess(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("test-image")}))
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.flushAccess())
+	assert.Empty(t, cache.access)
+
+	assert.NoError(t, cache.db.View(func(tx *bbolt.Tx) error {
+		_, e, err := entryDecode(tx.Bucket([]byte("a")).Get([]byte("1_1_1")))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), e.Hits)
+		assert.NotZero(t, e.Accessed)
+		return nil
+	}))
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.etcd.io/bbolt"
+)
+
+// legacyContentType is a content type of tiles cached before format was stored, all of them were saved as .jpeg
+const legacyContentType = ""
+
+// extension return file extension for image content type
+func extension(contentType string) string {
+	switch contentType {
+	case legacyContentType, "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/webp":
+		return "webp"
+	case "image/gif":
+		return "gif"
+	case "image/bmp":
+		return "bmp"
+	default:
+		return "bin"
+	}
+}
+
+// imageHash return hex encoded sha256 of image
+func imageHash(img []byte) string {
+	sum := sha256.Sum256(img)
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrate upgrade tiles cached by older versions (own file per tile, possibly always named .jpeg):
+// real content type is sniffed, image is moved to content-addressed blob and size and hash are written to index.
+// Index entries without image are removed. Return count of migrated tiles.
+func (c *MapCache) Migrate() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.flushAccess(); err != nil {
+		return 0, err
+	}
+
+	var legacy []candidate
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return forEachTile(tx, func(vendor string, k, v []byte) error {
+			_, e, err := entryDecode(v)
+			if err == nil && !e.Blob {
+				legacy = append(legacy, newCandidate(vendor, k, e))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	var missing []candidate
+	migrated := 0
+
+	for _, cand := range legacy {
+		coords, err := parseTileKey(cand.key)
+		if err != nil {
+			continue
+		}
+		coords.Meta.ContentType = cand.contentType
+
+		oldPath := c.imagePath(cand.vendor, coords)
+		img, err := os.ReadFile(oldPath)
+		if err != nil {
+			missing = append(missing, cand)
+			continue
+		}
+
+		contentType, hash := http.DetectContentType(img), imageHash(img)
+		if err = c.writeBlob(hash, contentType, img); err != nil {
+			return migrated, err
+		}
+
+		err = c.db.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(cand.vendor))
+			value := b.Get(cand.key)
+			if value == nil {
+				return nil
+			}
+
+			saved, e, err := entryDecode(value)
+			if err != nil {
+				return err
+			}
+			e.ContentType = contentType
+			e.Hash = hash
+			e.Size = int64(len(img))
+			e.Blob = true
+
+			value, err = entryEncode(saved, e)
+			if err != nil {
+				return err
+			}
+
+			if _, err = incRef(tx, hash); err != nil {
+				return err
+			}
+
+			return b.Put(cand.key, value)
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("failed to update index: %w", err)
+		}
+
+		_ = os.Remove(oldPath)
+		migrated++
+	}
+
+	if err = c.remove(missing); err != nil {
+		return migrated, err
+	}
+
+	// migrated images may share blobs, so size on disk is calculated again
+	if c.sizes, c.disk, err = c.calcSizes(); err != nil {
+		return migrated, fmt.Errorf("failed to calculate cache size: %w", err)
+	}
+
+	return migrated, nil
+}
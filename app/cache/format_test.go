@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+	"go.etcd.io/bbolt"
+)
+
+// createPNG return encoded 1x1 png image
+func createPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	return buf.Bytes()
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, "jpeg", extension(""))
+	assert.Equal(t, "jpeg", extension("image/jpeg"))
+	assert.Equal(t, "png", extension("image/png"))
+	assert.Equal(t, "webp", extension("image/webp"))
+	assert.Equal(t, "bin", extension("image/x-unknown"))
+}
+
+func TestSaveTile_Format(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	img := createPNG(t)
+	meta := tile.Meta{ContentType: "image/png", Source: "http://example.com/3/1/2"}
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 2, Z: 3, Image: img, Meta: meta}))
+
+	hash := imageHash(img)
+	_, err = os.Stat(filepath.Join(tmpDir, "blobs", hash[:2], hash+".png"))
+	assert.NoError(t, err)
+
+	loaded := &tile.Tile{X: 1, Y: 2, Z: 3}
+	loadedImg, err := cache.LoadTile("vendor", loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, img, loadedImg)
+	assert.Equal(t, "image/png", loaded.Meta.ContentType)
+	assert.Equal(t, "http://example.com/3/1/2", loaded.Meta.Source)
+	assert.Equal(t, imageHash(img), loaded.Meta.Hash)
+
+	// content type is sniffed by cache, old blob is removed with the last reference
+	jpeg := []byte("\xff\xd8\xff\xe0jpeg")
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 2, Z: 3, Image: jpeg, Meta: tile.Meta{ContentType: "image/png"}}))
+	_, err = os.Stat(filepath.Join(tmpDir, "blobs", hash[:2], hash+".png"))
+	assert.True(t, os.IsNotExist(err))
+	jpegHash := imageHash(jpeg)
+	_, err = os.Stat(filepath.Join(tmpDir, "blobs", jpegHash[:2], jpegHash+".jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(jpeg)), cache.Sizes()["vendor"])
+}
+
+func TestMigrate(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	img := createPNG(t)
+
+	// legacy cache: timestamp only index and png saved as .jpeg
+	err = cache.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("vendor"))
+		if err != nil {
+			return err
+		}
+		if err = b.Put(tileKey(&tile.Tile{X: 1, Y: 2, Z: 3}), unixTimeEncode(time.Now())); err != nil {
+			return err
+		}
+		return b.Put(tileKey(&tile.Tile{X: 4, Y: 5, Z: 6}), unixTimeEncode(time.Now()))
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "vendor", "3"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "vendor", "3", "1_2.jpeg"), img, 0o600))
+
+	migrated, err := cache.Migrate()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	// image is moved to blob
+	hash := imageHash(img)
+	_, err = os.Stat(filepath.Join(tmpDir, "blobs", hash[:2], hash+".png"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tmpDir, "vendor", "3", "1_2.jpeg"))
+	assert.True(t, os.IsNotExist(err))
+
+	loaded := &tile.Tile{X: 1, Y: 2, Z: 3}
+	loadedImg, err := cache.LoadTile("vendor", loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, img, loadedImg)
+	assert.Equal(t, "image/png", loaded.Meta.ContentType)
+	assert.Equal(t, imageHash(img), loaded.Meta.Hash)
+
+	// entry without image is removed
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 4, Y: 5, Z: 6})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tile not found")
+
+	// nothing to migrate on second run
+	migrated, err = cache.Migrate()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
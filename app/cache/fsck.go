@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register gif decoder for image check
+	_ "image/jpeg" // register jpeg decoder for image check
+	_ "image/png"  // register png decoder for image check
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/bbolt"
+)
+
+// FsckReport contains problems found by cache integrity check
+type FsckReport struct {
+	Checked   int      // count of checked index entries
+	Orphans   []string // image files not referenced by index
+	Dangling  []string // index keys (vendor/key) pointing to missing image
+	Corrupted []string // index keys (vendor/key) with undecodable index entry or image
+	BadRefs   int      // blobs with wrong reference count
+	Repaired  bool
+}
+
+// Problems return count of found problems
+func (r *FsckReport) Problems() int {
+	return len(r.Orphans) + len(r.Dangling) + len(r.Corrupted) + r.BadRefs
+}
+
+// Fsck check that every index entry has valid image, every image file is referenced by index
+// and blob reference counts are correct. With repair broken entries and orphan files are removed
+// and reference counts are rebuilt, cache must not be opened read-only then.
+func (c *MapCache) Fsck(repair bool) (*FsckReport, error) {
+	if repair && c.db.IsReadOnly() {
+		return nil, fmt.Errorf("can't repair cache opened in read-only mode")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if repair {
+		if err := c.flushAccess(); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &FsckReport{}
+
+	var broken []candidate
+	refs := make(map[string]uint64)
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		err := forEachTile(tx, func(vendor string, k, v []byte) error {
+			report.Checked++
+			name := vendor + "/" + string(k)
+
+			_, e, err := entryDecode(v)
+			if err != nil {
+				report.Corrupted = append(report.Corrupted, name)
+				broken = append(broken, newCandidate(vendor, k, nil))
+				return nil
+			}
+
+			coords, err := parseTileKey(k)
+			if err != nil {
+				report.Corrupted = append(report.Corrupted, name)
+				broken = append(broken, newCandidate(vendor, k, e))
+				return nil
+			}
+
+			if e.Blob {
+				refs[e.Hash]++
+			}
+
+			img, err := os.ReadFile(c.entryPath(vendor, coords, e))
+			switch {
+			case err != nil:
+				report.Dangling = append(report.Dangling, name)
+				broken = append(broken, newCandidate(vendor, k, e))
+			case !validImage(img, e):
+				report.Corrupted = append(report.Corrupted, name)
+				broken = append(broken, newCandidate(vendor, k, e))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		report.BadRefs = countBadRefs(tx, refs)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	if repair {
+		if err = c.remove(broken); err != nil {
+			return nil, err
+		}
+
+		if err = c.rebuildRefs(); err != nil {
+			return nil, err
+		}
+
+		if c.sizes, c.disk, err = c.calcSizes(); err != nil {
+			return nil, fmt.Errorf("failed to calculate cache size: %w", err)
+		}
+	}
+
+	report.Orphans, err = c.findOrphans()
+	if err != nil {
+		return nil, err
+	}
+
+	if repair {
+		for _, path := range report.Orphans {
+			if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to remove orphan file: %w", err)
+			}
+		}
+		report.Repaired = true
+	}
+
+	return report, nil
+}
+
+// validImage check image hash and format, images of formats without stdlib decoder (e.g. webp) are checked only by hash
+func validImage(img []byte, e *entry) bool {
+	if e.Hash != "" && e.Hash != imageHash(img) {
+		return false
+	}
+
+	_, _, err := image.DecodeConfig(bytes.NewReader(img))
+	return err == nil || (errors.Is(err, image.ErrFormat) && len(img) > 0)
+}
+
+// countBadRefs return count of blobs which stored reference count differs from expected
+func countBadRefs(tx *bbolt.Tx, expected map[string]uint64) int {
+	bad := 0
+	stored := make(map[string]uint64)
+
+	if b := tx.Bucket(blobsBucket); b != nil {
+		_ = b.ForEach(func(k, v []byte) error {
+			stored[string(k)] = refDecode(v)
+			return nil
+		})
+	}
+
+	for hash, refs := range stored {
+		if expected[hash] != refs {
+			bad++
+		}
+	}
+
+	for hash := range expected {
+		if _, ok := stored[hash]; !ok {
+			bad++
+		}
+	}
+
+	return bad
+}
+
+// rebuildRefs recount blob references from index entries, mutex must be locked
+func (c *MapCache) rebuildRefs() error {
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(blobsBucket) != nil {
+			if err := tx.DeleteBucket(blobsBucket); err != nil {
+				return err
+			}
+		}
+
+		refs := make(map[string]uint64)
+		err := forEachTile(tx, func(_ string, _, v []byte) error {
+			if _, e, err := entryDecode(v); err == nil && e.Blob {
+				refs[e.Hash]++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		b, err := tx.CreateBucket(blobsBucket)
+		if err != nil {
+			return err
+		}
+
+		for hash, count := range refs {
+			if err = b.Put([]byte(hash), refEncode(count)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild blob references: %w", err)
+	}
+
+	return nil
+}
+
+// findOrphans return files in cache directory which are not referenced by index, including leftover temporary files
+func (c *MapCache) findOrphans() ([]string, error) {
+	referenced := map[string]bool{filepath.Join(c.path, "index.db"): true}
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return forEachTile(tx, func(vendor string, k, v []byte) error {
+			_, e, err := entryDecode(v)
+			if err != nil {
+				return nil
+			}
+
+			coords, err := parseTileKey(k)
+			if err != nil {
+				return nil
+			}
+
+			referenced[c.entryPath(vendor, coords, e)] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan index: %w", err)
+	}
+
+	var orphans []string
+	err = filepath.WalkDir(c.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() && !referenced[filepath.Clean(path)] {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+
+	return orphans, nil
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+	"go.etcd.io/bbolt"
+)
+
+func TestFsck(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	valid, missing, broken := createPNG(t), bytes.Repeat([]byte{1}, 10), bytes.Repeat([]byte{2}, 10)
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 1, Y: 1, Z: 1, Image: valid}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 2, Y: 1, Z: 1, Image: valid}))
+	assert.NoError(t, cache.SaveTile("a", &tile.Tile{X: 3, Y: 1, Z: 1, Image: missing}))
+	assert.NoError(t, cache.SaveTile("b", &tile.Tile{X: 1, Y: 1, Z: 1, Image: broken}))
+
+	// image of a/3_1_1 is lost, image of b/1_1_1 is truncated
+	missingHash, brokenHash := imageHash(missing), imageHash(broken)
+	assert.NoError(t, os.Remove(cache.blobPath(missingHash, "application/octet-stream")))
+	assert.NoError(t, os.WriteFile(cache.blobPath(brokenHash, "application/octet-stream"), broken[:5], 0o600))
+
+	// leftover temporary file and wrong reference count
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "blobs", ".tmp-123"), []byte("partial"), 0o600))
+	assert.NoError(t, cache.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(blobsBucket).Put([]byte(imageHash(valid)), refEncode(5))
+	}))
+
+	report, err := cache.Fsck(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, []string{"a/3_1_1"}, report.Dangling)
+	assert.Equal(t, []string{"b/1_1_1"}, report.Corrupted)
+	assert.Equal(t, []string{filepath.Join(tmpDir, "blobs", ".tmp-123")}, report.Orphans)
+	assert.Equal(t, 1, report.BadRefs)
+	assert.Equal(t, 4, report.Problems())
+	assert.False(t, report.Repaired)
+
+	report, err = cache.Fsck(true)
+	assert.NoError(t, err)
+	assert.True(t, report.Repaired)
+	assert.Equal(t, []string{filepath.Join(tmpDir, "blobs", ".tmp-123")}, report.Orphans)
+
+	// broken blob is removed together with the last tile referencing it
+	_, err = os.Stat(cache.blobPath(brokenHash, "application/octet-stream"))
+	assert.True(t, os.IsNotExist(err))
+
+	report, err = cache.Fsck(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 0, report.Problems())
+	assert.Equal(t, uint64(2), blobRefs(t, cache, imageHash(valid)))
+	assert.Equal(t, map[string]int64{"a": int64(2 * len(valid)), "b": 0}, cache.Sizes())
+
+	_, err = cache.LoadTile("a", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+}
+
+func TestFsck_FailedRepairReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache, err := NewCache(tmpDir, time.Hour, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Close())
+
+	cache, err = NewCache(tmpDir, time.Hour, &bbolt.Options{ReadOnly: true})
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	_, err = cache.Fsck(true)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only")
+}
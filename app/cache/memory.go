@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// MemoryCache keeps tiles in memory and evicts least recently used tiles when max size is exceeded
+type MemoryCache struct {
+	alive   time.Duration
+	maxSize int64
+	size    int64
+	lru     *list.List // front is most recently used
+	items   map[string]*list.Element
+	mutex   sync.Mutex
+}
+
+// memoryItem is a tile stored in MemoryCache
+type memoryItem struct {
+	key     string
+	img     []byte
+	meta    tile.Meta
+	expires time.Time
+}
+
+// NewMemoryCache initializes a new MemoryCache with max summary size of images in bytes
+func NewMemoryCache(alive time.Duration, maxSize int64) (*MemoryCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("memory cache size must be greater than 0")
+	}
+
+	return &MemoryCache{
+		alive:   alive,
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+	}, nil
+}
+
+// SaveTile saves a tile to memory, tile can't live longer than its known expiration (e.g. when copied from other tier)
+func (c *MemoryCache) SaveTile(vendor string, t *tile.Tile) error {
+	size := int64(len(t.Image))
+	if size > c.maxSize {
+		return fmt.Errorf("tile is larger than memory cache")
+	}
+
+	expires := time.Now().Add(c.alive)
+	if t.Meta.MaxAge > 0 {
+		expires = time.Now().Add(t.Meta.MaxAge)
+	}
+	if !t.Meta.Expires.IsZero() && t.Meta.Expires.Before(expires) {
+		expires = t.Meta.Expires
+	}
+
+	meta := t.Meta
+	meta.Expires = time.Time{}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := memoryKey(vendor, t)
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	c.items[key] = c.lru.PushFront(&memoryItem{key: key, img: t.Image, meta: meta, expires: expires})
+	c.size += size
+
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+
+	return nil
+}
+
+// LoadTile load a tile from memory, expired tile is returned together with ErrExpired
+func (c *MemoryCache) LoadTile(vendor string, t *tile.Tile) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[memoryKey(vendor, t)]
+	if !ok {
+		return nil, fmt.Errorf("failed to load tile: tile not found")
+	}
+	c.lru.MoveToFront(el)
+
+	item := el.Value.(*memoryItem)
+	t.Meta = item.meta
+	t.Meta.Expires = item.expires
+
+	if !time.Now().Before(item.expires) {
+		return item.img, ErrExpired
+	}
+
+	return item.img, nil
+}
+
+// Touch refreshes expiration and meta of already cached tile
+func (c *MemoryCache) Touch(vendor string, t *tile.Tile) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[memoryKey(vendor, t)]
+	if !ok {
+		return fmt.Errorf("failed to touch tile: tile not found")
+	}
+	c.lru.MoveToFront(el)
+
+	item := el.Value.(*memoryItem)
+	item.meta.Revalidate(&t.Meta)
+	item.expires = time.Now().Add(c.alive)
+	if t.Meta.MaxAge > 0 {
+		item.expires = time.Now().Add(t.Meta.MaxAge)
+	}
+
+	return nil
+}
+
+// Close drop all tiles
+func (c *MemoryCache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+
+	return nil
+}
+
+// removeElement remove tile from memory, mutex must be locked
+func (c *MemoryCache) removeElement(el *list.Element) {
+	item := c.lru.Remove(el).(*memoryItem)
+	delete(c.items, item.key)
+	c.size -= int64(len(item.img))
+}
+
+// memoryKey return key of tile in MemoryCache
+func memoryKey(vendor string, t *tile.Tile) string {
+	return fmt.Sprintf("%s/%d_%d_%d", vendor, t.X, t.Y, t.Z)
+}
+
+// Purge remove tiles of vendor which match, nil match removes all tiles of vendor. Return count of removed tiles.
+func (c *MemoryCache) Purge(vendor string, match func(t *tile.Tile) bool) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	prefix := vendor + "/"
+	purged := 0
+
+	for key, el := range c.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		t := &tile.Tile{}
+		if _, err := fmt.Sscanf(strings.TrimPrefix(key, prefix), "%d_%d_%d", &t.X, &t.Y, &t.Z); err != nil {
+			continue
+		}
+
+		if match == nil || match(t) {
+			c.removeElement(el)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestNewMemoryCache_FailedSize(t *testing.T) {
+	cache, err := NewMemoryCache(time.Hour, 0)
+	assert.Error(t, err)
+	assert.Nil(t, cache)
+}
+
+func TestMemoryCache_SaveLoad(t *testing.T) {
+	cache, err := NewMemoryCache(time.Hour, 100)
+	assert.NoError(t, err)
+
+	err = cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 2, Z: 3, Image: []byte("img"), Meta: tile.Meta{ETag: `"v1"`}})
+	assert.NoError(t, err)
+
+	loaded := &tile.Tile{X: 1, Y: 2, Z: 3}
+	img, err := cache.LoadTile("vendor", loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("img"), img)
+	assert.Equal(t, `"v1"`, loaded.Meta.ETag)
+
+	_, err = cache.LoadTile("other", &tile.Tile{X: 1, Y: 2, Z: 3})
+	assert.Error(t, err)
+}
+
+func TestMemoryCache_Expired(t *testing.T) {
+	cache, err := NewMemoryCache(-time.Minute, 100)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img")}))
+
+	img, err := cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, []byte("img"), img)
+
+	// touch with max-age makes tile fresh again
+	assert.NoError(t, cache.Touch("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Meta: tile.Meta{MaxAge: time.Hour}}))
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	assert.Error(t, cache.Touch("vendor", &tile.Tile{X: 2, Y: 2, Z: 2}))
+}
+
+func TestMemoryCache_KnownExpiration(t *testing.T) {
+	cache, err := NewMemoryCache(time.Hour, 100)
+	assert.NoError(t, err)
+
+	expires := time.Now().Add(-time.Second)
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img"), Meta: tile.Meta{Expires: expires}}))
+
+	loaded := &tile.Tile{X: 1, Y: 1, Z: 1}
+	_, err = cache.LoadTile("vendor", loaded)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, expires, loaded.Meta.Expires)
+}
+
+func TestMemoryCache_Evict(t *testing.T) {
+	cache, err := NewMemoryCache(time.Hour, 25)
+	assert.NoError(t, err)
+
+	img := bytes.Repeat([]byte{1}, 10)
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: img}))
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 2, Y: 2, Z: 2, Image: img}))
+
+	// access first tile, so second one is least recently used
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.SaveTile("vendor", &tile.Tile{X: 3, Y: 3, Z: 3, Image: img}))
+
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 2, Y: 2, Z: 2})
+	assert.Error(t, err)
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 3, Y: 3, Z: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), cache.size)
+
+	err = cache.SaveTile("vendor", &tile.Tile{X: 4, Y: 4, Z: 4, Image: bytes.Repeat([]byte{1}, 30)})
+	assert.Error(t, err)
+
+	assert.NoError(t, cache.Close())
+	assert.Equal(t, int64(0), cache.size)
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// ResultCache keeps merged images in memory, evicts least recently used ones when max size is exceeded
+// and drops results when any of their tiles is saved with other image
+type ResultCache struct {
+	alive   time.Duration
+	maxSize int64
+	size    int64
+	lru     *list.List // front is most recently used
+	items   map[string]*list.Element
+	tiles   map[string]map[string]string // tile key -> keys of results containing the tile -> hash of tile image
+	mutex   sync.Mutex
+}
+
+// resultItem is a merged image stored in ResultCache
+type resultItem struct {
+	key     string
+	vendor  string
+	img     []byte
+	tiles   []string
+	expires time.Time
+}
+
+// NewResultCache initializes a new ResultCache with max summary size of images in bytes
+func NewResultCache(alive time.Duration, maxSize int64) (*ResultCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("result cache size must be greater than 0")
+	}
+
+	return &ResultCache{
+		alive:   alive,
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+		tiles:   make(map[string]map[string]string),
+	}, nil
+}
+
+// Get return merged image, expired image is removed
+func (c *ResultCache) Get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := el.Value.(*resultItem)
+	if !time.Now().Before(item.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return item.img, true
+}
+
+// Put saves merged image of vendor tiles
+func (c *ResultCache) Put(key, vendor string, tiles []tile.Tile, img []byte) {
+	if int64(len(img)) > c.maxSize {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	item := &resultItem{key: key, vendor: vendor, img: img, expires: time.Now().Add(c.alive)}
+	for i := range tiles {
+		tk := memoryKey(vendor, &tiles[i])
+		item.tiles = append(item.tiles, tk)
+
+		if c.tiles[tk] == nil {
+			c.tiles[tk] = make(map[string]string)
+		}
+		c.tiles[tk][key] = tileHash(&tiles[i])
+	}
+
+	c.items[key] = c.lru.PushFront(item)
+	c.size += int64(len(img))
+
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// Invalidate remove results containing other image of tile, results built from the same image are kept
+func (c *ResultCache) Invalidate(vendor string, t *tile.Tile) {
+	hash := tileHash(t)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, built := range c.tiles[memoryKey(vendor, t)] {
+		if el, ok := c.items[key]; ok && built != hash {
+			c.removeElement(el)
+		}
+	}
+}
+
+// tileHash return hash of tile image, hash filled by cache is used if it's known
+func tileHash(t *tile.Tile) string {
+	if t.Meta.Hash != "" {
+		return t.Meta.Hash
+	}
+	return imageHash(t.Image)
+}
+
+// Purge remove all results of vendor
+func (c *ResultCache) Purge(vendor string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, el := range c.items {
+		if el.Value.(*resultItem).vendor == vendor {
+			c.removeElement(el)
+		}
+	}
+}
+
+// removeElement remove result and its tile references, mutex must be locked
+func (c *ResultCache) removeElement(el *list.Element) {
+	item := c.lru.Remove(el).(*resultItem)
+	delete(c.items, item.key)
+	c.size -= int64(len(item.img))
+
+	for _, tk := range item.tiles {
+		delete(c.tiles[tk], item.key)
+		if len(c.tiles[tk]) == 0 {
+			delete(c.tiles, tk)
+		}
+	}
+}
+
+// NotifyCache calls hook after every successfully saved tile, e.g. to invalidate results built from it
+type NotifyCache struct {
+	Cache
+	onSave func(vendor string, t *tile.Tile)
+}
+
+// NewNotifyCache wraps cache with save hook
+func NewNotifyCache(c Cache, onSave func(vendor string, t *tile.Tile)) *NotifyCache {
+	return &NotifyCache{Cache: c, onSave: onSave}
+}
+
+// SaveTile saves a tile to wrapped cache and calls hook
+func (c *NotifyCache) SaveTile(vendor string, t *tile.Tile) error {
+	if err := c.Cache.SaveTile(vendor, t); err != nil {
+		return err
+	}
+
+	c.onSave(vendor, t)
+	return nil
+}
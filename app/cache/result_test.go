@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestNewResultCache_FailedSize(t *testing.T) {
+	results, err := NewResultCache(time.Hour, 0)
+	assert.Error(t, err)
+	assert.Nil(t, results)
+}
+
+func TestResultCache_GetPut(t *testing.T) {
+	results, err := NewResultCache(time.Hour, 10)
+	assert.NoError(t, err)
+
+	_, ok := results.Get("key")
+	assert.False(t, ok)
+
+	results.Put("key", "vendor", []tile.Tile{{X: 1, Y: 1, Z: 1}}, []byte("img"))
+	img, ok := results.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("img"), img)
+
+	// replaced result keeps size
+	results.Put("key", "vendor", []tile.Tile{{X: 1, Y: 1, Z: 1}}, []byte("img2"))
+	assert.Equal(t, int64(4), results.size)
+
+	// too large result is not cached
+	results.Put("large", "vendor", nil, []byte("01234567890"))
+	_, ok = results.Get("large")
+	assert.False(t, ok)
+}
+
+func TestResultCache_Expired(t *testing.T) {
+	results, err := NewResultCache(-time.Minute, 10)
+	assert.NoError(t, err)
+
+	results.Put("key", "vendor", []tile.Tile{{X: 1, Y: 1, Z: 1}}, []byte("img"))
+	_, ok := results.Get("key")
+	assert.False(t, ok)
+	assert.Empty(t, results.items)
+	assert.Empty(t, results.tiles)
+}
+
+func TestResultCache_Evict(t *testing.T) {
+	results, err := NewResultCache(time.Hour, 6)
+	assert.NoError(t, err)
+
+	results.Put("a", "vendor", nil, []byte("aaa"))
+	results.Put("b", "vendor", nil, []byte("bbb"))
+	_, _ = results.Get("a") // b is least recently used now
+	results.Put("c", "vendor", nil, []byte("ccc"))
+
+	_, ok := results.Get("a")
+	assert.True(t, ok)
+	_, ok = results.Get("b")
+	assert.False(t, ok)
+	_, ok = results.Get("c")
+	assert.True(t, ok)
+}
+
+func TestResultCache_Invalidate(t *testing.T) {
+	results, err := NewResultCache(time.Hour, 100)
+	assert.NoError(t, err)
+
+	img := []byte("tile")
+	results.Put("a", "vendor", []tile.Tile{{X: 1, Y: 1, Z: 1, Image: img}, {X: 2, Y: 1, Z: 1, Image: img}}, []byte("a"))
+	results.Put("b", "vendor", []tile.Tile{{X: 2, Y: 1, Z: 1, Image: img}, {X: 3, Y: 1, Z: 1, Image: img}}, []byte("b"))
+	results.Put("c", "other", []tile.Tile{{X: 1, Y: 1, Z: 1, Image: img}}, []byte("c"))
+
+	// tile saved again with the same image doesn't change results
+	results.Invalidate("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: img})
+	_, ok := results.Get("a")
+	assert.True(t, ok)
+
+	results.Invalidate("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("new")})
+	_, ok = results.Get("a")
+	assert.False(t, ok)
+	_, ok = results.Get("b")
+	assert.True(t, ok)
+	_, ok = results.Get("c")
+	assert.True(t, ok)
+
+	results.Invalidate("vendor", &tile.Tile{X: 3, Y: 1, Z: 1, Meta: tile.Meta{Hash: "new"}})
+	_, ok = results.Get("b")
+	assert.False(t, ok)
+	assert.Len(t, results.tiles, 1)
+
+	results.Purge("other")
+	_, ok = results.Get("c")
+	assert.False(t, ok)
+	assert.Empty(t, results.tiles)
+	assert.Equal(t, int64(0), results.size)
+}
+
+func TestNotifyCache(t *testing.T) {
+	var saved []string
+	mock := &CacheMock{
+		SaveTileFunc: func(vendor string, t *tile.Tile) error {
+			if t.X == 0 {
+				return fmt.Errorf("mock error")
+			}
+			return nil
+		},
+	}
+
+	c := NewNotifyCache(mock, func(vendor string, t *tile.Tile) {
+		saved = append(saved, fmt.Sprintf("%s/%d", vendor, t.X))
+	})
+
+	assert.NoError(t, c.SaveTile("vendor", &tile.Tile{X: 1}))
+	assert.Error(t, c.SaveTile("vendor", &tile.Tile{X: 0}))
+	assert.Equal(t, []string{"vendor/1"}, saved)
+
+	_, ok := AdminOf(c)
+	assert.False(t, ok)
+
+	mc, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer mc.Close()
+
+	admin, ok := AdminOf(NewNotifyCache(mc, func(string, *tile.Tile) {}))
+	assert.True(t, ok)
+	assert.Equal(t, mc, admin)
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// S3Config contains settings of S3-compatible object storage
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.amazonaws.com or http://minio:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // key prefix inside bucket
+	PathStyle bool   // use http://endpoint/bucket/key instead of http://bucket.endpoint/key
+}
+
+// S3Cache stores tiles in S3-compatible object storage, tile meta is kept in object user metadata
+type S3Cache struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	alive    time.Duration
+}
+
+// user metadata headers of tile objects
+const (
+	s3MetaSaved        = "X-Amz-Meta-Saved"
+	s3MetaETag         = "X-Amz-Meta-Etag"
+	s3MetaLastModified = "X-Amz-Meta-Last-Modified"
+	s3MetaCacheControl = "X-Amz-Meta-Cache-Control"
+	s3MetaMaxAge       = "X-Amz-Meta-Max-Age"
+	s3MetaHash         = "X-Amz-Meta-Hash"
+	s3MetaSource       = "X-Amz-Meta-Source"
+)
+
+// NewS3Cache initializes a new S3Cache
+func NewS3Cache(cfg S3Config, alive time.Duration, client *http.Client) (*S3Cache, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not specified")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3Cache{cfg: cfg, endpoint: endpoint, client: client, alive: alive}, nil
+}
+
+// SaveTile uploads tile to object storage, image hash is filled in tile meta, tile copied from other tier keeps
+// its expiration
+func (c *S3Cache) SaveTile(vendor string, t *tile.Tile) error {
+	t.Meta.Hash = imageHash(t.Image)
+
+	req, err := c.newRequest(http.MethodPut, vendor, t, t.Image)
+	if err != nil {
+		return err
+	}
+	setS3Meta(req.Header, savedTime(&t.Meta, c.alive), &t.Meta)
+
+	return c.do(req, nil)
+}
+
+// LoadTile downloads tile from object storage, expired tile is returned together with ErrExpired
+func (c *S3Cache) LoadTile(vendor string, t *tile.Tile) ([]byte, error) {
+	req, err := c.newRequest(http.MethodGet, vendor, t, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var img []byte
+	var header http.Header
+	err = c.do(req, func(resp *http.Response) error {
+		header = resp.Header
+		img, err = io.ReadAll(resp.Body)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tile: %w", err)
+	}
+
+	saved := getS3Meta(header, &t.Meta)
+	alive := c.alive
+	if t.Meta.MaxAge > 0 {
+		alive = t.Meta.MaxAge
+	}
+	t.Meta.Expires = saved.Add(alive)
+
+	if !time.Now().Before(t.Meta.Expires) {
+		return img, ErrExpired
+	}
+
+	return img, nil
+}
+
+// Touch refreshes saved time and meta of object by copying it onto itself
+func (c *S3Cache) Touch(vendor string, t *tile.Tile) error {
+	req, err := c.newRequest(http.MethodPut, vendor, t, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Copy-Source", "/"+c.cfg.Bucket+"/"+c.objectKey(vendor, t))
+	req.Header.Set("X-Amz-Metadata-Directive", "REPLACE")
+	setS3Meta(req.Header, time.Now(), &t.Meta)
+
+	if err = c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to touch tile: %w", err)
+	}
+
+	return nil
+}
+
+// Close does nothing, object storage is stateless
+func (c *S3Cache) Close() error {
+	return nil
+}
+
+// objectKey return object key of tile
+func (c *S3Cache) objectKey(vendor string, t *tile.Tile) string {
+	key := fmt.Sprintf("%s/%d/%d_%d", vendor, t.Z, t.X, t.Y)
+	if c.cfg.Prefix != "" {
+		key = strings.Trim(c.cfg.Prefix, "/") + "/" + key
+	}
+	return key
+}
+
+// newRequest create signed request to tile object
+func (c *S3Cache) newRequest(method, vendor string, t *tile.Tile, body []byte) (*http.Request, error) {
+	u := *c.endpoint
+	key := c.objectKey(vendor, t)
+	if c.cfg.PathStyle {
+		u.Path = "/" + c.cfg.Bucket + "/" + key
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 request: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+
+	return req, nil
+}
+
+// do sign and send request, handle is called for successful response
+func (c *S3Cache) do(req *http.Request, handle func(resp *http.Response) error) error {
+	signS3(req, c.cfg.Region, c.cfg.AccessKey, c.cfg.SecretKey, time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send s3 request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("s3 returned invalid status code: code=%d", resp.StatusCode)
+	}
+
+	if handle == nil {
+		return nil
+	}
+
+	return handle(resp)
+}
+
+// setS3Meta set tile meta as object content type and user metadata
+func setS3Meta(header http.Header, saved time.Time, meta *tile.Meta) {
+	if meta.ContentType != "" {
+		header.Set("Content-Type", meta.ContentType)
+	}
+	header.Set(s3MetaHash, meta.Hash)
+	header.Set(s3MetaSource, meta.Source)
+	header.Set(s3MetaSaved, strconv.FormatInt(saved.Unix(), 10))
+	header.Set(s3MetaETag, meta.ETag)
+	header.Set(s3MetaLastModified, meta.LastModified)
+	header.Set(s3MetaCacheControl, meta.CacheControl)
+	header.Set(s3MetaMaxAge, strconv.FormatInt(int64(meta.MaxAge/time.Second), 10))
+}
+
+// getS3Meta read tile meta from object content type and user metadata and return saved time
+func getS3Meta(header http.Header, meta *tile.Meta) time.Time {
+	meta.ContentType = header.Get("Content-Type")
+	meta.Hash = header.Get(s3MetaHash)
+	meta.Source = header.Get(s3MetaSource)
+	meta.ETag = header.Get(s3MetaETag)
+	meta.LastModified = header.Get(s3MetaLastModified)
+	meta.CacheControl = header.Get(s3MetaCacheControl)
+
+	maxAge, _ := strconv.ParseInt(header.Get(s3MetaMaxAge), 10, 64)
+	meta.MaxAge = time.Duration(maxAge) * time.Second
+
+	saved, _ := strconv.ParseInt(header.Get(s3MetaSaved), 10, 64)
+	return time.Unix(saved, 0)
+}
+
+// signS3 sign request with AWS Signature Version 4, X-Amz-Content-Sha256 header must be set
+func signS3(req *http.Request, region, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Del("Authorization")
+
+	canonical, signedHeaders := canonicalS3Request(req)
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalS3Request build canonical request of AWS Signature Version 4, host and x-amz-* headers are signed
+func canonicalS3Request(req *http.Request) (canonical, signedHeaders string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders = strings.Join(names, ";")
+
+	canonical = strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	return canonical, signedHeaders
+}
+
+// hmacSHA256 return HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
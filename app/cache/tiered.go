@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// TieredCache combines caches from fastest to slowest, e.g. memory -> disk -> object storage.
+// Tiles found in slower tier are copied to all faster tiers.
+type TieredCache struct {
+	tiers []Cache
+}
+
+// NewTieredCache initializes a new TieredCache, tiers are ordered from fastest to slowest
+func NewTieredCache(tiers ...Cache) (*TieredCache, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("at least one cache tier must be specified")
+	}
+
+	return &TieredCache{tiers: tiers}, nil
+}
+
+// SaveTile saves a tile to all tiers
+func (c *TieredCache) SaveTile(vendor string, t *tile.Tile) error {
+	var errs []error
+	for _, tier := range c.tiers {
+		if err := tier.SaveTile(vendor, t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadTile looks up tiers one by one until fresh tile is found and copies it to faster tiers.
+// If all found tiles are expired, the first one is returned together with ErrExpired.
+func (c *TieredCache) LoadTile(vendor string, t *tile.Tile) ([]byte, error) {
+	var expiredImg []byte
+	var expiredMeta tile.Meta
+
+	for i, tier := range c.tiers {
+		found := tile.Tile{X: t.X, Y: t.Y, Z: t.Z}
+
+		img, err := tier.LoadTile(vendor, &found)
+		if errors.Is(err, ErrExpired) {
+			if expiredImg == nil {
+				expiredImg, expiredMeta = img, found.Meta
+			}
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		found.Image = img
+		for _, faster := range c.tiers[:i] {
+			_ = faster.SaveTile(vendor, &found)
+		}
+
+		t.Meta = found.Meta
+		return img, nil
+	}
+
+	if expiredImg != nil {
+		t.Meta = expiredMeta
+		return expiredImg, ErrExpired
+	}
+
+	return nil, fmt.Errorf("failed to load tile: tile not found in any tier")
+}
+
+// Touch refreshes tile in all tiers, it fails only if tile can't be refreshed anywhere
+func (c *TieredCache) Touch(vendor string, t *tile.Tile) error {
+	var errs []error
+	for _, tier := range c.tiers {
+		if err := tier.Touch(vendor, t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(c.tiers) {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// Close closes all tiers
+func (c *TieredCache) Close() error {
+	var errs []error
+	for _, tier := range c.tiers {
+		if err := tier.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// purger is a cache tier which supports purging of tiles
+type purger interface {
+	Purge(vendor string, match func(t *tile.Tile) bool) (int, error)
+}
+
+// admin return first tier which supports management
+func (c *TieredCache) admin() Admin {
+	for _, tier := range c.tiers {
+		if admin, ok := AdminOf(tier); ok {
+			return admin
+		}
+	}
+	return nil
+}
+
+// Stats return statistics of the first tier which supports management
+func (c *TieredCache) Stats() (map[string]*Stats, error) {
+	admin := c.admin()
+	if admin == nil {
+		return nil, fmt.Errorf("cache tiers don't support stats")
+	}
+	return admin.Stats()
+}
+
+// Info return tile metadata from the first tier which supports management
+func (c *TieredCache) Info(vendor string, t *tile.Tile) (*Info, error) {
+	admin := c.admin()
+	if admin == nil {
+		return nil, fmt.Errorf("cache tiers don't support tile info")
+	}
+	return admin.Info(vendor, t)
+}
+
+// Purge remove matched tiles from all tiers which support purging (object storage tier is not purged).
+// Return count of tiles removed from the first tier which supports management.
+func (c *TieredCache) Purge(vendor string, match func(t *tile.Tile) bool) (int, error) {
+	admin := c.admin()
+
+	var errs []error
+	purged := 0
+	for _, tier := range c.tiers {
+		p, ok := tier.(purger)
+		if !ok {
+			continue
+		}
+
+		n, err := p.Purge(vendor, match)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if a, ok := AdminOf(tier); ok && a == admin {
+			purged = n
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestNewTieredCache_Failed(t *testing.T) {
+	cache, err := NewTieredCache()
+	assert.Error(t, err)
+	assert.Nil(t, cache)
+}
+
+func TestTieredCache_Backfill(t *testing.T) {
+	memory, err := NewMemoryCache(time.Hour, 100)
+	assert.NoError(t, err)
+	disk, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+
+	cache, err := NewTieredCache(memory, disk)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	assert.NoError(t, disk.SaveTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img"), Meta: tile.Meta{ETag: `"v1"`}}))
+
+	loaded := &tile.Tile{X: 1, Y: 1, Z: 1}
+	img, err := cache.LoadTile("vendor", loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("img"), img)
+	assert.Equal(t, `"v1"`, loaded.Meta.ETag)
+
+	// tile is copied to memory tier
+	img, err = memory.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("img"), img)
+
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 2, Y: 2, Z: 2})
+	assert.Error(t, err)
+}
+
+func TestTieredCache_BackfillKeepsExpiration(t *testing.T) {
+	expires := time.Now().Add(10 * time.Minute)
+	slow := &CacheMock{
+		LoadTileFunc: func(vendor string, t *tile.Tile) ([]byte, error) {
+			t.Meta.Expires = expires
+			return []byte("img"), nil
+		},
+	}
+	disk, err := NewCache(t.TempDir(), time.Hour, nil)
+	assert.NoError(t, err)
+	defer disk.Close()
+
+	cache, err := NewTieredCache(disk, slow)
+	assert.NoError(t, err)
+
+	_, err = cache.LoadTile("vendor", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+
+	// promoted tile expires together with the slower copy, not after the whole alive time of faster tier
+	loaded := &tile.Tile{X: 1, Y: 1, Z: 1}
+	_, err = disk.LoadTile("vendor", loaded)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, expires, loaded.Meta.Expires, time.Second)
+}
+
+func TestTieredCache_Expired(t *testing.T) {
+	fast := &CacheMock{
+		LoadTileFunc: func(vendor string, t *tile.Tile) ([]byte, error) {
+			t.Meta.ETag = `"fast"`
+			return []byte("fast"), ErrExpired
+		},
+	}
+	slow := &CacheMock{
+		LoadTileFunc: func(vendor string, t *tile.Tile) ([]byte, error) {
+			t.Meta.ETag = `"slow"`
+			return []byte("slow"), ErrExpired
+		},
+	}
+
+	cache, err := NewTieredCache(fast, slow)
+	assert.NoError(t, err)
+
+	loaded := &tile.Tile{X: 1, Y: 1, Z: 1}
+	img, err := cache.LoadTile("vendor", loaded)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, []byte("fast"), img)
+	assert.Equal(t, `"fast"`, loaded.Meta.ETag)
+	assert.Len(t, slow.LoadTileCalls(), 1)
+}
+
+func TestTieredCache_SaveTouchClose(t *testing.T) {
+	failed := errors.New("failed")
+	ok := &CacheMock{
+		SaveTileFunc: func(vendor string, t *tile.Tile) error { return nil },
+		TouchFunc:    func(vendor string, t *tile.Tile) error { return nil },
+		CloseFunc:    func() error { return nil },
+	}
+	broken := &CacheMock{
+		SaveTileFunc: func(vendor string, t *tile.Tile) error { return failed },
+		TouchFunc:    func(vendor string, t *tile.Tile) error { return failed },
+		CloseFunc:    func() error { return failed },
+	}
+
+	cache, err := NewTieredCache(ok, broken)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, cache.SaveTile("vendor", &tile.Tile{}), failed)
+	assert.Len(t, ok.SaveTileCalls(), 1)
+
+	// touch fails only when all tiers failed
+	assert.NoError(t, cache.Touch("vendor", &tile.Tile{}))
+
+	allBroken, err := NewTieredCache(broken, broken)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, allBroken.Touch("vendor", &tile.Tile{}), failed)
+
+	assert.ErrorIs(t, cache.Close(), failed)
+	assert.Len(t, ok.CloseCalls(), 1)
+}
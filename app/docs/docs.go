@@ -15,21 +15,53 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/healthcheck": {
-            "get": {
-                "description": "just return HealthCheckModel with API status (always return 200)",
+        "/cache/purge": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "remove all cached tiles of provider or only tiles in zoom range and/or bounding box",
                 "consumes": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "handler for health check",
+                "summary": "handler remove cached tiles of provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tile provider",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "min zoom of purged tiles",
+                        "name": "min_zoom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "max zoom of purged tiles",
+                        "name": "max_zoom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "bounding box of purged tiles: min_long,min_lat,max_long,max_lat",
+                        "name": "bbox",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.healthCheckModel"
+                            "$ref": "#/definitions/api.cachePurgeModel"
                         },
                         "headers": {
                             "X-Request-Id": {
@@ -37,56 +69,188 @@ const docTemplate = `{
                                 "description": "request_id"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
                     }
                 }
             }
         },
-        "/map": {
+        "/cache/seed": {
             "get": {
-                "description": "return merged satellite tiles in one image",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "POST start seeding job for bbox or GeoJSON polygon and zoom range, tiles already fresh in cache are skipped.\nGET return status of job specified by id or all jobs. DELETE cancel job specified by id.",
                 "consumes": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "produces": [
-                    "image/jpeg"
+                    "application/json"
                 ],
-                "summary": "handler for generating satellite map for specified lat long and from specified vendor",
+                "summary": "handler start, show and cancel cache seeding jobs",
                 "parameters": [
+                    {
+                        "description": "seeding job, required for POST",
+                        "name": "job",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.cacheSeedModel"
+                        }
+                    },
                     {
                         "type": "string",
-                        "description": "tile provider",
-                        "name": "provider",
-                        "in": "query",
-                        "required": true
+                        "description": "job id, required for DELETE",
+                        "name": "id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/seed.Status"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/seed.Status"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
                     },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
                     {
-                        "type": "number",
-                        "description": "latitude",
-                        "name": "lat",
-                        "in": "query",
-                        "required": true
+                        "AdminToken": []
+                    }
+                ],
+                "description": "POST start seeding job for bbox or GeoJSON polygon and zoom range, tiles already fresh in cache are skipped.\nGET return status of job specified by id or all jobs. DELETE cancel job specified by id.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler start, show and cancel cache seeding jobs",
+                "parameters": [
+                    {
+                        "description": "seeding job, required for POST",
+                        "name": "job",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.cacheSeedModel"
+                        }
                     },
                     {
-                        "type": "number",
-                        "description": "longitude",
-                        "name": "long",
-                        "in": "query",
-                        "required": true
+                        "type": "string",
+                        "description": "job id, required for DELETE",
+                        "name": "id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/seed.Status"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/seed.Status"
+                        }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
                     {
-                        "type": "integer",
-                        "description": "zoom of image",
-                        "name": "zoom",
-                        "in": "query",
-                        "required": true
+                        "AdminToken": []
+                    }
+                ],
+                "description": "POST start seeding job for bbox or GeoJSON polygon and zoom range, tiles already fresh in cache are skipped.\nGET return status of job specified by id or all jobs. DELETE cancel job specified by id.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler start, show and cancel cache seeding jobs",
+                "parameters": [
+                    {
+                        "description": "seeding job, required for POST",
+                        "name": "job",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.cacheSeedModel"
+                        }
                     },
                     {
-                        "maximum": 10,
-                        "minimum": 1,
-                        "type": "integer",
-                        "default": 3,
-                        "description": "count of tile of result image square",
-                        "name": "side",
+                        "type": "string",
+                        "description": "job id, required for DELETE",
+                        "name": "id",
                         "in": "query"
                     }
                 ],
@@ -94,7 +258,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/seed.Status"
                         },
                         "headers": {
                             "X-Request-Id": {
@@ -103,32 +267,55 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/seed.Status"
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/api.mapErrorModel"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
                     }
                 }
             }
         },
-        "/provider": {
+        "/cache/stats": {
             "get": {
-                "description": "reutrn JSON array with avalible provders",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "return JSON object with tile count, size, age histogram and hit/miss ratio per provider",
                 "consumes": [
                     "text/plain"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "handler return all registered providers",
+                "summary": "handler return statistics of cached tiles",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.providerModel"
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/cache.Stats"
                             }
                         },
                         "headers": {
@@ -137,47 +324,1691 @@ const docTemplate = `{
                                 "description": "request_id"
                             }
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.healthCheckModel": {
-            "type": "object",
-            "properties": {
-                "body": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "integer"
-                }
-            }
-        },
-        "api.mapErrorModel": {
-            "type": "object",
-            "properties": {
-                "body": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "integer"
-                }
-            }
         },
-        "api.providerModel": {
-            "type": "object",
-            "properties": {
-                "key": {
-                    "type": "string"
-                },
-                "max_zoom": {
-                    "type": "integer"
-                },
-                "name": {
-                    "type": "string"
-                }
-            }
+        "/cache/tile": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "return JSON object with validators, content type, hash, source, saved and expiration time of cached tile",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler return cache metadata of single tile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tile provider",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "tile x",
+                        "name": "x",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "tile y",
+                        "name": "y",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "tile zoom",
+                        "name": "z",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/cache.Info"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/export": {
+            "get": {
+                "description": "return PDF sheet with map frame, title, scale bar and provider attribution.\nMap frame is centered on lat, long at scale 1:scale or shows bbox, zoom and pixel size of the frame are chosen by paper, orientation and dpi.\nExports of more than max side squared tiles or with async run in background, status is returned with 202 and the document is served by /export/{id}/pdf.",
+                "produces": [
+                    "application/pdf"
+                ],
+                "summary": "handler for print-ready PDF export of map at paper size, DPI and scale",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tile provider",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "a0",
+                            "a1",
+                            "a2",
+                            "a3",
+                            "a4",
+                            "a5",
+                            "letter",
+                            "legal",
+                            "tabloid"
+                        ],
+                        "type": "string",
+                        "default": "a4",
+                        "description": "paper size",
+                        "name": "paper",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "portrait",
+                            "landscape"
+                        ],
+                        "type": "string",
+                        "default": "portrait",
+                        "description": "paper orientation",
+                        "name": "orientation",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 600,
+                        "minimum": 72,
+                        "type": "integer",
+                        "default": 300,
+                        "description": "resolution of map frame in dots per inch",
+                        "name": "dpi",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100000000,
+                        "minimum": 100,
+                        "type": "number",
+                        "description": "denominator of scale 1:scale, required unless bbox is set",
+                        "name": "scale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "latitude of sheet center, required unless bbox is set",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "longitude of sheet center, required unless bbox is set",
+                        "name": "long",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "min_long,min_lat,max_long,max_lat fitted into map frame",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "maxLength": 200,
+                        "type": "string",
+                        "description": "title above map frame",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "serve tiles only from cache, never request upstream",
+                        "name": "cache_only",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "run export in background regardless of its size",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Map-Bounds": {
+                                "type": "string",
+                                "description": "west,south,east,north of map frame"
+                            },
+                            "X-Map-Scale": {
+                                "type": "number",
+                                "description": "denominator of scale at the center of map frame"
+                            },
+                            "X-Map-Zoom": {
+                                "type": "integer",
+                                "description": "zoom of tiles of map frame"
+                            },
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/export.Status"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapMissingModel"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/export/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler return status of export running in background",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/export.Status"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/export/{id}/pdf": {
+            "get": {
+                "produces": [
+                    "application/pdf"
+                ],
+                "summary": "handler return PDF document of export done in background",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthcheck": {
+            "get": {
+                "description": "just return HealthCheckModel with API status (always return 200)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler for health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.healthCheckModel"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/map": {
+            "get": {
+                "description": "return merged satellite tiles in one image, markers, paths and polygons of query parameters or of POST body are drawn over it.\nWith fit the zoom and the position of image are chosen to show all overlays.\nScale bar, north arrow, coordinate grid and caption may be drawn for printing.\nImage may be post-processed by chain of filters: tone and color adjustments, sharpening and resize.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "image/jpeg"
+                ],
+                "summary": "handler for generating satellite map for specified lat long and from specified vendor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tile provider",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "latitude, required unless fit is set",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "longitude, required unless fit is set",
+                        "name": "long",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "zoom of image, required unless fit is set, max zoom of fitted image otherwise",
+                        "name": "zoom",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 3,
+                        "description": "count of tile of result image square",
+                        "name": "side",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "serve tiles only from cache, never request upstream",
+                        "name": "cache_only",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "choose the highest zoom and the position where all overlays fit into image",
+                        "name": "fit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "width of fitted image in pixels, side*256 by default",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "height of fitted image in pixels, side*256 by default",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 32,
+                        "description": "padding of fitted overlays in pixels",
+                        "name": "padding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "markers color:red|icon:pin|label:A|lat,long|lat,long, icon is pin, circle or square",
+                        "name": "marker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "polyline color:blue|width:3|lat,long|lat,long or color:blue|enc:encoded_polyline",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "polygon color:blue|width:3|fill:0x0000ff80|lat,long|lat,long|lat,long or with enc:encoded_polyline",
+                        "name": "polygon",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "metric",
+                            "imperial",
+                            "both"
+                        ],
+                        "type": "string",
+                        "description": "scale bar of ground resolution at image center",
+                        "name": "scale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "draw north arrow",
+                        "name": "north",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "latlong",
+                            "utm"
+                        ],
+                        "type": "string",
+                        "description": "coordinate grid with labels",
+                        "name": "grid",
+                        "in": "query"
+                    },
+                    {
+                        "maxLength": 200,
+                        "type": "string",
+                        "description": "caption in top left corner",
+                        "name": "caption",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "draw UTC time of request under caption",
+                        "name": "timestamp",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "filters applied in order before overlays: brightness:-100..100, contrast:-100..100, gamma:0.1..10, grayscale, sharpen:0.1..5, autolevels or resize:width",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "overlays of POST request",
+                        "name": "overlays",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.overlaysModel"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Cache-Missing": {
+                                "type": "string",
+                                "description": "count of tiles which are not cached and replaced with placeholders"
+                            },
+                            "X-Cache-Stale": {
+                                "type": "string",
+                                "description": "true if some tiles are served from cache after expiration"
+                            },
+                            "X-Map-Bounds": {
+                                "type": "string",
+                                "description": "west,south,east,north of fitted image"
+                            },
+                            "X-Map-Zoom": {
+                                "type": "integer",
+                                "description": "zoom of fitted image"
+                            },
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            },
+                            "X-Result-Cache": {
+                                "type": "string",
+                                "description": "hit if merged image is served from result cache, miss otherwise"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapMissingModel"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "return merged satellite tiles in one image, markers, paths and polygons of query parameters or of POST body are drawn over it.\nWith fit the zoom and the position of image are chosen to show all overlays.\nScale bar, north arrow, coordinate grid and caption may be drawn for printing.\nImage may be post-processed by chain of filters: tone and color adjustments, sharpening and resize.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "image/jpeg"
+                ],
+                "summary": "handler for generating satellite map for specified lat long and from specified vendor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tile provider",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "latitude, required unless fit is set",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "longitude, required unless fit is set",
+                        "name": "long",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "zoom of image, required unless fit is set, max zoom of fitted image otherwise",
+                        "name": "zoom",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 3,
+                        "description": "count of tile of result image square",
+                        "name": "side",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "serve tiles only from cache, never request upstream",
+                        "name": "cache_only",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "choose the highest zoom and the position where all overlays fit into image",
+                        "name": "fit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "width of fitted image in pixels, side*256 by default",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "height of fitted image in pixels, side*256 by default",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 32,
+                        "description": "padding of fitted overlays in pixels",
+                        "name": "padding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "markers color:red|icon:pin|label:A|lat,long|lat,long, icon is pin, circle or square",
+                        "name": "marker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "polyline color:blue|width:3|lat,long|lat,long or color:blue|enc:encoded_polyline",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "polygon color:blue|width:3|fill:0x0000ff80|lat,long|lat,long|lat,long or with enc:encoded_polyline",
+                        "name": "polygon",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "metric",
+                            "imperial",
+                            "both"
+                        ],
+                        "type": "string",
+                        "description": "scale bar of ground resolution at image center",
+                        "name": "scale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "draw north arrow",
+                        "name": "north",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "latlong",
+                            "utm"
+                        ],
+                        "type": "string",
+                        "description": "coordinate grid with labels",
+                        "name": "grid",
+                        "in": "query"
+                    },
+                    {
+                        "maxLength": 200,
+                        "type": "string",
+                        "description": "caption in top left corner",
+                        "name": "caption",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "draw UTC time of request under caption",
+                        "name": "timestamp",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "filters applied in order before overlays: brightness:-100..100, contrast:-100..100, gamma:0.1..10, grayscale, sharpen:0.1..5, autolevels or resize:width",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "overlays of POST request",
+                        "name": "overlays",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.overlaysModel"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Cache-Missing": {
+                                "type": "string",
+                                "description": "count of tiles which are not cached and replaced with placeholders"
+                            },
+                            "X-Cache-Stale": {
+                                "type": "string",
+                                "description": "true if some tiles are served from cache after expiration"
+                            },
+                            "X-Map-Bounds": {
+                                "type": "string",
+                                "description": "west,south,east,north of fitted image"
+                            },
+                            "X-Map-Zoom": {
+                                "type": "integer",
+                                "description": "zoom of fitted image"
+                            },
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            },
+                            "X-Result-Cache": {
+                                "type": "string",
+                                "description": "hit if merged image is served from result cache, miss otherwise"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapMissingModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/provider": {
+            "get": {
+                "description": "reutrn JSON array with avalible provders",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler return all registered providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.providerModel"
+                            }
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/provider/{id}/style.json": {
+            "get": {
+                "description": "return MapLibre style with single raster layer of provider tiles, wgs84 providers aren't supported",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler return MapLibre style of provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "provider ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.styleModel"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/provider/{id}/tilejson.json": {
+            "get": {
+                "description": "return TileJSON 3.0.0 with url template of provider tiles served by WMTS endpoint, wgs84 providers aren't supported",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "handler return TileJSON of provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "provider ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.tileJSONModel"
+                        },
+                        "headers": {
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.mapErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/wms": {
+            "get": {
+                "description": "GetCapabilities lists every provider as a layer, GetMap renders providers tiles to the exact requested bbox and size,\nbest zoom is chosen by resolution of request. Layers are drawn over each other in requested order.",
+                "produces": [
+                    "text/xml",
+                    "image/png",
+                    "image/jpeg"
+                ],
+                "summary": "OGC WMS 1.1.1 and 1.3.0 service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "WMS",
+                        "name": "SERVICE",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "GetCapabilities or GetMap",
+                        "name": "REQUEST",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "1.3.0",
+                        "description": "1.1.1 or 1.3.0",
+                        "name": "VERSION",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated provider IDs",
+                        "name": "LAYERS",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "EPSG:3857, EPSG:3395, EPSG:4326 or CRS:84 (SRS in 1.1.1)",
+                        "name": "CRS",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minx,miny,maxx,maxy in CRS axis order",
+                        "name": "BBOX",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "image width",
+                        "name": "WIDTH",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "image height",
+                        "name": "HEIGHT",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "image/png or image/jpeg",
+                        "name": "FORMAT",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "transparent background of png image",
+                        "name": "TRANSPARENT",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "0xFFFFFF",
+                        "description": "background color",
+                        "name": "BGCOLOR",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "serve tiles only from cache, never request upstream",
+                        "name": "cache_only",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Cache-Missing": {
+                                "type": "string",
+                                "description": "count of tiles which are not cached and replaced with placeholders"
+                            },
+                            "X-Cache-Stale": {
+                                "type": "string",
+                                "description": "true if some tiles are served from cache after expiration"
+                            },
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "OGC service exception report",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "OGC service exception report",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/wmts": {
+            "get": {
+                "description": "GetCapabilities and GetTile requests in KVP form. RESTful form is served too:\n/wmts/1.0.0/WMTSCapabilities.xml and /wmts/{layer}/{style}/{tile_matrix_set}/{tile_matrix}/{tile_row}/{tile_col}.{png|jpg}\nTiles are converted to the requested format if it differs from the format of provider.",
+                "produces": [
+                    "text/xml",
+                    "image/png",
+                    "image/jpeg"
+                ],
+                "summary": "OGC WMTS 1.0.0 service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "WMTS",
+                        "name": "SERVICE",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "GetCapabilities or GetTile",
+                        "name": "REQUEST",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "provider ID",
+                        "name": "LAYER",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GoogleMapsCompatible or WorldMercatorWGS84Quad",
+                        "name": "TILEMATRIXSET",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "zoom",
+                        "name": "TILEMATRIX",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "tile y",
+                        "name": "TILEROW",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "tile x",
+                        "name": "TILECOL",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "image/png or image/jpeg, format of provider if omitted",
+                        "name": "FORMAT",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "serve tile only from cache, never request upstream",
+                        "name": "cache_only",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Cache-Missing": {
+                                "type": "string",
+                                "description": "1 if tile is not cached and replaced with placeholder"
+                            },
+                            "X-Cache-Stale": {
+                                "type": "string",
+                                "description": "true if tile is served from cache after expiration"
+                            },
+                            "X-Request-Id": {
+                                "type": "string",
+                                "description": "request_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "OGC exception report",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "OGC exception report",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.cachePurgeModel": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.cacheSeedModel": {
+            "type": "object",
+            "properties": {
+                "bbox": {
+                    "description": "min_long, min_lat, max_long, max_lat",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "max_zoom": {
+                    "type": "integer"
+                },
+                "min_zoom": {
+                    "type": "integer"
+                },
+                "polygon": {
+                    "description": "GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection",
+                    "type": "object"
+                },
+                "provider": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.healthCheckModel": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.mapErrorModel": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.mapMissingModel": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string"
+                },
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.tileModel"
+                    }
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.markerModel": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string"
+                },
+                "icon": {
+                    "type": "string",
+                    "enum": [
+                        "pin",
+                        "circle",
+                        "square"
+                    ]
+                },
+                "label": {
+                    "type": "string"
+                },
+                "point": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                }
+            }
+        },
+        "api.overlaysModel": {
+            "type": "object",
+            "properties": {
+                "geojson": {
+                    "description": "geometry, Feature or FeatureCollection styled with simplestyle-spec",
+                    "type": "object"
+                },
+                "markers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.markerModel"
+                    }
+                },
+                "paths": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.pathModel"
+                    }
+                },
+                "polygons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.polygonModel"
+                    }
+                }
+            }
+        },
+        "api.pathModel": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "polyline": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.polygonModel": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string"
+                },
+                "fill": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "polyline": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.providerModel": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "license": {
+                    "type": "string"
+                },
+                "max_zoom": {
+                    "type": "integer"
+                },
+                "min_zoom": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "restrictions": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.styleLayerModel": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.styleModel": {
+            "type": "object",
+            "properties": {
+                "center": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "layers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.styleLayerModel"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sources": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/api.styleSourceModel"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                },
+                "zoom": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.styleSourceModel": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "string"
+                },
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "maxzoom": {
+                    "type": "integer"
+                },
+                "minzoom": {
+                    "type": "integer"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "tileSize": {
+                    "type": "integer"
+                },
+                "tiles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.tileJSONModel": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "string"
+                },
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "center": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "maxzoom": {
+                    "type": "integer"
+                },
+                "minzoom": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "tilejson": {
+                    "type": "string"
+                },
+                "tiles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.tileModel": {
+            "type": "object",
+            "properties": {
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                },
+                "z": {
+                    "type": "integer"
+                }
+            }
+        },
+        "cache.AgeHistogram": {
+            "type": "object",
+            "properties": {
+                "lt_1d": {
+                    "type": "integer"
+                },
+                "lt_1h": {
+                    "type": "integer"
+                },
+                "lt_30d": {
+                    "type": "integer"
+                },
+                "lt_7d": {
+                    "type": "integer"
+                },
+                "older": {
+                    "type": "integer"
+                }
+            }
+        },
+        "cache.Info": {
+            "type": "object",
+            "properties": {
+                "accessed": {
+                    "type": "string"
+                },
+                "cache_control": {
+                    "type": "string"
+                },
+                "content_type": {
+                    "description": "sniffed image type, e.g. image/png",
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires": {
+                    "type": "string"
+                },
+                "hash": {
+                    "description": "sha256 of image, filled by cache on save",
+                    "type": "string"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "last_modified": {
+                    "type": "string"
+                },
+                "max_age": {
+                    "description": "overrides cache alive if not zero",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/time.Duration"
+                        }
+                    ]
+                },
+                "path": {
+                    "type": "string"
+                },
+                "saved": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "upstream URL of image",
+                    "type": "string"
+                }
+            }
+        },
+        "cache.Stats": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "$ref": "#/definitions/cache.AgeHistogram"
+                },
+                "bytes": {
+                    "type": "integer"
+                },
+                "expired": {
+                    "type": "integer"
+                },
+                "hit_ratio": {
+                    "type": "number"
+                },
+                "hits": {
+                    "description": "loads since start",
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "stale": {
+                    "description": "expired tile was loaded",
+                    "type": "integer"
+                },
+                "tiles": {
+                    "type": "integer"
+                }
+            }
+        },
+        "export.Status": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "started": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tiles": {
+                    "type": "integer"
+                }
+            }
+        },
+        "seed.Progress": {
+            "type": "object",
+            "properties": {
+                "blank": {
+                    "description": "downloaded placeholder tiles which are not cached",
+                    "type": "integer"
+                },
+                "done": {
+                    "description": "processed tiles, including resumed ones",
+                    "type": "integer"
+                },
+                "downloaded": {
+                    "description": "tiles downloaded and saved to cache",
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "fresh": {
+                    "description": "tiles already fresh in cache",
+                    "type": "integer"
+                },
+                "resumed": {
+                    "description": "tiles processed by previous run",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "zoom": {
+                    "description": "zoom of last processed tile",
+                    "type": "integer"
+                }
+            }
+        },
+        "seed.Status": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_zoom": {
+                    "type": "integer"
+                },
+                "min_zoom": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "$ref": "#/definitions/seed.Progress"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "started": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "time.Duration": {
+            "type": "integer",
+            "enum": [
+                -9223372036854775808,
+                9223372036854775807,
+                1,
+                1000,
+                1000000,
+                1000000000,
+                60000000000,
+                3600000000000,
+                1,
+                1000,
+                1000000,
+                1000000000,
+                60000000000,
+                3600000000000
+            ],
+            "x-enum-varnames": [
+                "minDuration",
+                "maxDuration",
+                "Nanosecond",
+                "Microsecond",
+                "Millisecond",
+                "Second",
+                "Minute",
+                "Hour",
+                "Nanosecond",
+                "Microsecond",
+                "Millisecond",
+                "Second",
+                "Minute",
+                "Hour"
+            ]
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "admin token as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -1,9 +1,14 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/provider"
@@ -15,15 +20,21 @@ import (
 // Downloader implements basic downloader interface
 type Downloader interface {
 	Download(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error)
+	DownloadCached(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error)
 	Merge(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error)
 }
 
 // MapDownloader implements interface Downloader
 type MapDownloader struct {
-	client *http.Client
+	client *http.Client // fallback client for providers without own client
+
+	StaleWhileRevalidate bool          // serve expired tiles immediately and refresh them in background
+	MaxStale             time.Duration // serve expired tiles on upstream error up to MaxStale after expiration
+
+	refreshing sync.Map // tiles with running background refresh
 }
 
-// NewMapDownloader create new MapDownloader with specified httpClient
+// NewMapDownloader create new MapDownloader with specified fallback httpClient
 func NewMapDownloader(client *http.Client) *MapDownloader {
 	return &MapDownloader{client: client}
 }
@@ -39,8 +50,17 @@ func (m *MapDownloader) Download(c cache.Cache, l provider.Provider, tiles ...ti
 	jobs := make(chan downloadQuery, l.MaxJobs())
 	results := make(chan downloadQuery, len(tiles))
 
+	client := m.client
+	if pc := l.Client(); pc != nil {
+		client = pc
+	}
+
+	// tiles are saved to cache in background, results are returned after saves are finished,
+	// so caches notified about saved tiles are up to date when caller gets tiles
+	var saves sync.WaitGroup
+
 	for w := 1; w <= l.MaxJobs(); w++ {
-		go m.worker(c, l.ID(), jobs, results)
+		go m.worker(c, client, l, jobs, results, &saves)
 	}
 
 	for _, p := range tiles {
@@ -61,19 +81,67 @@ func (m *MapDownloader) Download(c cache.Cache, l provider.Provider, tiles ...ti
 		result = append(result, r.Tile)
 	}
 
+	saves.Wait()
+
+	return result, nil
+}
+
+// MissingError is returned when tiles are not found in cache and can't be downloaded
+type MissingError struct {
+	Tiles []tile.Tile
+}
+
+// Error return count of missing tiles
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("%d tiles not found in cache", len(e.Tiles))
+}
+
+// DownloadCached load tiles from cache only, network is never used and cache expiration is ignored.
+// If some tiles are not cached, found tiles are returned together with *MissingError.
+func (m *MapDownloader) DownloadCached(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+	var result = make([]tile.Tile, 0, len(tiles))
+	var missing []tile.Tile
+
+	for _, t := range tiles {
+		if c == nil {
+			missing = append(missing, t)
+			continue
+		}
+
+		img, err := c.LoadTile(l.ID(), &t)
+		if err != nil && !errors.Is(err, cache.ErrExpired) {
+			missing = append(missing, t)
+			continue
+		}
+
+		t.Image = img
+		result = append(result, t)
+	}
+
+	if len(missing) > 0 {
+		return result, &MissingError{Tiles: missing}
+	}
+
 	return result, nil
 }
 
 // worker download image
-func (m *MapDownloader) worker(c cache.Cache, vendor string, jobs <-chan downloadQuery, results chan<- downloadQuery) {
+func (m *MapDownloader) worker(c cache.Cache, client *http.Client, l provider.Provider,
+	jobs <-chan downloadQuery, results chan<- downloadQuery, saves *sync.WaitGroup) {
 	for j := range jobs {
+		var cached []byte
+
 		if c != nil {
-			cacheImg, err := c.LoadTile(vendor, &j.Tile)
+			cacheImg, err := c.LoadTile(l.ID(), &j.Tile)
 			if err == nil {
 				j.Tile.Image = cacheImg
 				results <- j
 				continue
 			}
+
+			if errors.Is(err, cache.ErrExpired) {
+				cached = cacheImg
+			}
 		}
 
 		if j.Request == nil {
@@ -82,34 +150,163 @@ func (m *MapDownloader) worker(c cache.Cache, vendor string, jobs <-chan downloa
 			continue
 		}
 
-		resp, err := m.client.Do(j.Request)
-		if err != nil {
-			j.Error = fmt.Errorf("error occurred when sending request to the server: err=%w", err)
-			results <- j
-			continue
-		}
+		if cached != nil && m.StaleWhileRevalidate {
+			m.refresh(c, client, l, j.Request, j.Tile, cached)
 
-		img, err := io.ReadAll(resp.Body)
-		if err != nil {
-			j.Error = fmt.Errorf("can't readAll body from server answer: err=%w", err)
+			j.Tile.Image = cached
+			j.Tile.Stale = true
 			results <- j
-			_ = resp.Body.Close()
 			continue
 		}
 
-		if resp.StatusCode != 200 {
-			j.Error = fmt.Errorf("server returned invalid status code: code=%d", resp.StatusCode)
-			results <- j
-			_ = resp.Body.Close()
-			continue
+		err := m.update(c, client, l, j.Request, &j.Tile, cached, saves)
+		if err != nil && cached != nil && m.MaxStale > 0 && time.Since(j.Tile.Meta.Expires) <= m.MaxStale {
+			j.Tile.Image = cached
+			j.Tile.Stale = true
+			err = nil
 		}
 
-		j.Tile.Image = img
-		_ = resp.Body.Close()
+		j.Error = err
 		results <- j
+	}
+}
+
+// refresh run background update of expired tile, only one refresh per tile is running at the same time
+func (m *MapDownloader) refresh(c cache.Cache, client *http.Client, l provider.Provider, req *http.Request, t tile.Tile, cached []byte) {
+	key := fmt.Sprintf("%s/%d/%d/%d", l.ID(), t.Z, t.X, t.Y)
+	if _, running := m.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer m.refreshing.Delete(key)
+		var saves sync.WaitGroup
+		_ = m.update(c, client, l, req, &t, cached, &saves)
+		saves.Wait()
+	}()
+}
+
+// update download tile from upstream, revalidating cached image if it's specified, and save result to cache
+// in background, saves are added to WaitGroup
+func (m *MapDownloader) update(c cache.Cache, client *http.Client, l provider.Provider, req *http.Request,
+	t *tile.Tile, cached []byte, saves *sync.WaitGroup) error {
+	if cached != nil {
+		setConditionalHeaders(req, &t.Meta)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error occurred when sending request to the server: err=%w", err)
+	}
+
+	img, err := readBody(resp.Body, l.MaxSize())
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		t.Image = cached
+		updateMeta(&t.Meta, resp.Header, l.RespectMaxAge())
 
 		if c != nil {
-			go c.SaveTile(vendor, &j.Tile)
+			saved := *t
+			saves.Add(1)
+			go func() {
+				defer saves.Done()
+				_ = c.Touch(l.ID(), &saved)
+			}()
 		}
+		return nil
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("server returned invalid status code: code=%d", resp.StatusCode)
+	}
+
+	contentType := http.DetectContentType(img)
+	if !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("server returned non-image response: content-type=%s", contentType)
+	}
+
+	t.Image = img
+	t.Meta = tile.Meta{ContentType: contentType, Source: req.URL.String()}
+	updateMeta(&t.Meta, resp.Header, l.RespectMaxAge())
+
+	// blank tiles are placeholders ("no data yet"), so they must not stick in cache
+	t.Blank = l.IsBlank(img)
+	if c != nil && !t.Blank {
+		saved := *t
+		saves.Add(1)
+		go func() {
+			defer saves.Done()
+			_ = c.SaveTile(l.ID(), &saved)
+		}()
 	}
+
+	return nil
+}
+
+// setConditionalHeaders set If-None-Match and If-Modified-Since headers for revalidation of cached tile
+func setConditionalHeaders(req *http.Request, meta *tile.Meta) {
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+}
+
+// updateMeta copy cache validators from upstream headers, 304 answer may omit some of them
+func updateMeta(meta *tile.Meta, header http.Header, respectMaxAge bool) {
+	if v := header.Get("ETag"); v != "" {
+		meta.ETag = v
+	}
+
+	if v := header.Get("Last-Modified"); v != "" {
+		meta.LastModified = v
+	}
+
+	if v := header.Get("Cache-Control"); v != "" {
+		meta.CacheControl = v
+	}
+
+	meta.MaxAge = 0
+	if respectMaxAge {
+		meta.MaxAge = parseMaxAge(meta.CacheControl)
+	}
+}
+
+// parseMaxAge return max-age directive of Cache-Control header, zero if not specified
+func parseMaxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds <= 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
+
+// readBody read whole body but not more than maxSize bytes
+func readBody(body io.Reader, maxSize int64) ([]byte, error) {
+	img, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't readAll body from server answer: err=%w", err)
+	}
+
+	if int64(len(img)) > maxSize {
+		return nil, fmt.Errorf("server answer is too large: max_size=%d", maxSize)
+	}
+
+	return img, nil
 }
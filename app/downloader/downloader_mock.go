@@ -24,6 +24,9 @@ var _ Downloader = &DownloaderMock{}
 //			DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
 //				panic("mock out the Download method")
 //			},
+//			DownloadCachedFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+//				panic("mock out the DownloadCached method")
+//			},
 //			MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) {
 //				panic("mock out the Merge method")
 //			},
@@ -37,6 +40,9 @@ type DownloaderMock struct {
 	// DownloadFunc mocks the Download method.
 	DownloadFunc func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error)
 
+	// DownloadCachedFunc mocks the DownloadCached method.
+	DownloadCachedFunc func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error)
+
 	// MergeFunc mocks the Merge method.
 	MergeFunc func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error)
 
@@ -51,6 +57,15 @@ type DownloaderMock struct {
 			// Tiles is the tiles argument value.
 			Tiles []tile.Tile
 		}
+		// DownloadCached holds details about calls to the DownloadCached method.
+		DownloadCached []struct {
+			// C is the c argument value.
+			C cache.Cache
+			// L is the l argument value.
+			L provider.Provider
+			// Tiles is the tiles argument value.
+			Tiles []tile.Tile
+		}
 		// Merge holds details about calls to the Merge method.
 		Merge []struct {
 			// Side is the side argument value.
@@ -61,8 +76,9 @@ type DownloaderMock struct {
 			Tiles []tile.Tile
 		}
 	}
-	lockDownload sync.RWMutex
-	lockMerge    sync.RWMutex
+	lockDownload       sync.RWMutex
+	lockDownloadCached sync.RWMutex
+	lockMerge          sync.RWMutex
 }
 
 // Download calls DownloadFunc.
@@ -105,6 +121,46 @@ func (mock *DownloaderMock) DownloadCalls() []struct {
 	return calls
 }
 
+// DownloadCached calls DownloadCachedFunc.
+func (mock *DownloaderMock) DownloadCached(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+	if mock.DownloadCachedFunc == nil {
+		panic("DownloaderMock.DownloadCachedFunc: method is nil but Downloader.DownloadCached was just called")
+	}
+	callInfo := struct {
+		C     cache.Cache
+		L     provider.Provider
+		Tiles []tile.Tile
+	}{
+		C:     c,
+		L:     l,
+		Tiles: tiles,
+	}
+	mock.lockDownloadCached.Lock()
+	mock.calls.DownloadCached = append(mock.calls.DownloadCached, callInfo)
+	mock.lockDownloadCached.Unlock()
+	return mock.DownloadCachedFunc(c, l, tiles...)
+}
+
+// DownloadCachedCalls gets all the calls that were made to DownloadCached.
+// Check the length with:
+//
+//	len(mockedDownloader.DownloadCachedCalls())
+func (mock *DownloaderMock) DownloadCachedCalls() []struct {
+	C     cache.Cache
+	L     provider.Provider
+	Tiles []tile.Tile
+} {
+	var calls []struct {
+		C     cache.Cache
+		L     provider.Provider
+		Tiles []tile.Tile
+	}
+	mock.lockDownloadCached.RLock()
+	calls = mock.calls.DownloadCached
+	mock.lockDownloadCached.RUnlock()
+	return calls
+}
+
 // Merge calls MergeFunc.
 func (mock *DownloaderMock) Merge(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) {
 	if mock.MergeFunc == nil {
@@ -3,9 +3,13 @@ package downloader
 import (
 	"errors"
 	"fmt"
+	"image/color"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/superboomer/maptile/app/cache"
@@ -17,7 +21,7 @@ func TestDownload_SuccessfulWithoutCacheHit(t *testing.T) {
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("image data"))
+		w.Write(createTestImage(color.White))
 	}))
 	defer ts.Close()
 
@@ -26,8 +30,12 @@ func TestDownload_SuccessfulWithoutCacheHit(t *testing.T) {
 			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
 			return req
 		},
-		MaxJobsFunc: func() int { return 2 },
-		IDFunc:      func() string { return "name" },
+		MaxJobsFunc:       func() int { return 2 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
 	}
 
 	mockCache := &cache.CacheMock{
@@ -44,6 +52,8 @@ func TestDownload_SuccessfulWithoutCacheHit(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(tiles), len(downloadedTiles))
+	assert.Equal(t, "image/jpeg", downloadedTiles[0].Meta.ContentType)
+	assert.Equal(t, ts.URL, downloadedTiles[0].Meta.Source)
 	// Assert interactions
 	assert.Len(t, mockProvider.GetRequestCalls(), 1) // Assuming 1 calls expected
 }
@@ -52,7 +62,7 @@ func TestDownload_FailedDownload(t *testing.T) {
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("image data"))
+		w.Write(createTestImage(color.White))
 	}))
 	defer ts.Close()
 
@@ -64,8 +74,12 @@ func TestDownload_FailedDownload(t *testing.T) {
 			}
 			return nil
 		},
-		MaxJobsFunc: func() int { return 1 },
-		IDFunc:      func() string { return "name" },
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
 	}
 
 	mockCache := &cache.CacheMock{
@@ -86,8 +100,12 @@ func TestDownload_FailedDownload(t *testing.T) {
 
 func TestDownload_SuccessfulLoadFromCache(t *testing.T) {
 	mockProvider := &provider.ProviderMock{
-		MaxJobsFunc: func() int { return 2 },
-		IDFunc:      func() string { return "name" },
+		MaxJobsFunc:       func() int { return 2 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
 		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
 			return &http.Request{}
 		},
@@ -121,8 +139,12 @@ func TestDownload_FailedRequest(t *testing.T) {
 			req, _ := http.NewRequest(http.MethodGet, "", http.NoBody)
 			return req
 		},
-		MaxJobsFunc: func() int { return 1 },
-		IDFunc:      func() string { return "name" },
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
 	}
 
 	downloader := NewMapDownloader(http.DefaultClient)
@@ -146,8 +168,12 @@ func TestDownload_FailedServerReturnedInvalidCode(t *testing.T) {
 			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
 			return req
 		},
-		MaxJobsFunc: func() int { return 1 },
-		IDFunc:      func() string { return "name" },
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
 	}
 
 	downloader := NewMapDownloader(http.DefaultClient)
@@ -158,3 +184,430 @@ func TestDownload_FailedServerReturnedInvalidCode(t *testing.T) {
 	assert.Contains(t, err.Error(), "server returned invalid status code")
 	assert.Len(t, mockProvider.GetRequestCalls(), 1) // Still expect 2 calls despite failure
 }
+
+func TestDownload_UseProviderClient(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(createTestImage(color.White))
+	}))
+	defer ts.Close()
+
+	var used bool
+	providerClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return providerClient },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	_, err := downloader.Download(nil, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.True(t, used)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDownload_FailedNonImageResponse(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html><body>map data not yet available</body></html>"))
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(string, *tile.Tile) ([]byte, error) { return nil, fmt.Errorf("not found") },
+		SaveTileFunc: func(string, *tile.Tile) error { return nil },
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	_, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server returned non-image response: content-type=text/html")
+	assert.Len(t, mockCache.SaveTileCalls(), 0)
+}
+
+func TestDownload_FailedTooLargeResponse(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(createTestImage(color.White))
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 10 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	_, err := downloader.Download(nil, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server answer is too large: max_size=10")
+}
+
+func TestDownload_BlankTileNotCached(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(createTestImage(color.White))
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return true },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(string, *tile.Tile) ([]byte, error) { return nil, fmt.Errorf("not found") },
+		SaveTileFunc: func(string, *tile.Tile) error { return nil },
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	tiles, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 1)
+	assert.NotNil(t, tiles[0].Image)
+	assert.True(t, tiles[0].Blank)
+	assert.Len(t, mockProvider.IsBlankCalls(), 1)
+	assert.Len(t, mockCache.SaveTileCalls(), 0)
+}
+
+func TestDownload_RevalidateNotModified(t *testing.T) {
+	cachedImage := createTestImage(color.Black)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2024 00:00:00 GMT" {
+			w.Header().Set("Cache-Control", "public, max-age=600")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(createTestImage(color.White))
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return true },
+		IDFunc:            func() string { return "name" },
+	}
+
+	touched := make(chan tile.Tile, 1)
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(_ string, t *tile.Tile) ([]byte, error) {
+			t.Meta = tile.Meta{ETag: `"v1"`, LastModified: "Mon, 01 Jan 2024 00:00:00 GMT"}
+			return cachedImage, cache.ErrExpired
+		},
+		SaveTileFunc: func(string, *tile.Tile) error { return nil },
+		TouchFunc: func(_ string, t *tile.Tile) error {
+			touched <- *t
+			return nil
+		},
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	tiles, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 1)
+	assert.Equal(t, cachedImage, tiles[0].Image)
+
+	touchedTile := <-touched
+	assert.Equal(t, `"v1"`, touchedTile.Meta.ETag)
+	assert.Equal(t, 10*time.Minute, touchedTile.Meta.MaxAge)
+	assert.Len(t, mockCache.SaveTileCalls(), 0)
+}
+
+func TestDownload_RevalidateModified(t *testing.T) {
+	freshImage := createTestImage(color.White)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(freshImage)
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	saved := make(chan tile.Tile, 1)
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(_ string, t *tile.Tile) ([]byte, error) {
+			t.Meta = tile.Meta{ETag: `"v1"`}
+			return createTestImage(color.Black), cache.ErrExpired
+		},
+		SaveTileFunc: func(_ string, t *tile.Tile) error {
+			saved <- *t
+			return nil
+		},
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	tiles, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.Equal(t, freshImage, tiles[0].Image)
+
+	savedTile := <-saved
+	assert.Equal(t, `"v2"`, savedTile.Meta.ETag)
+	assert.Zero(t, savedTile.Meta.MaxAge)
+}
+
+func TestParseMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		expected     time.Duration
+	}{
+		{cacheControl: "", expected: 0},
+		{cacheControl: "no-cache", expected: 0},
+		{cacheControl: "max-age=60", expected: time.Minute},
+		{cacheControl: "public, Max-Age=\"3600\"", expected: time.Hour},
+		{cacheControl: "max-age=invalid", expected: 0},
+		{cacheControl: "max-age=-5", expected: 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, parseMaxAge(tt.cacheControl), tt.cacheControl)
+	}
+}
+
+func TestDownload_StaleWhileRevalidate(t *testing.T) {
+	cachedImage := createTestImage(color.Black)
+	freshImage := createTestImage(color.White)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(freshImage)
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	saved := make(chan tile.Tile, 1)
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(_ string, t *tile.Tile) ([]byte, error) {
+			t.Meta = tile.Meta{Expires: time.Now().Add(-time.Hour)}
+			return cachedImage, cache.ErrExpired
+		},
+		SaveTileFunc: func(_ string, t *tile.Tile) error {
+			saved <- *t
+			return nil
+		},
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+	downloader.StaleWhileRevalidate = true
+
+	tiles, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 1)
+	assert.Equal(t, cachedImage, tiles[0].Image)
+	assert.True(t, tiles[0].Stale)
+
+	// background refresh saves fresh tile
+	savedTile := <-saved
+	assert.Equal(t, freshImage, savedTile.Image)
+	assert.False(t, savedTile.Stale)
+}
+
+func TestDownload_ServeStaleOnError(t *testing.T) {
+	cachedImage := createTestImage(color.Black)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	mockProvider := &provider.ProviderMock{
+		GetRequestFunc: func(testTile *tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 1 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+		IDFunc:            func() string { return "name" },
+	}
+
+	expires := time.Now().Add(-time.Hour)
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(_ string, t *tile.Tile) ([]byte, error) {
+			t.Meta = tile.Meta{Expires: expires}
+			return cachedImage, cache.ErrExpired
+		},
+		SaveTileFunc: func(string, *tile.Tile) error { return nil },
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	// stale serving is disabled by default
+	_, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server returned invalid status code: code=503")
+
+	downloader.MaxStale = 2 * time.Hour
+
+	tiles, err := downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.NoError(t, err)
+	assert.Equal(t, cachedImage, tiles[0].Image)
+	assert.True(t, tiles[0].Stale)
+
+	// tile is older than max stale
+	expires = time.Now().Add(-3 * time.Hour)
+
+	_, err = downloader.Download(mockCache, mockProvider, []tile.Tile{{X: 4, Y: 5, Z: 6}}...)
+	assert.Error(t, err)
+}
+
+func TestDownloadCached(t *testing.T) {
+	mockProvider := &provider.ProviderMock{
+		IDFunc: func() string { return "name" },
+	}
+
+	mockCache := &cache.CacheMock{
+		LoadTileFunc: func(_ string, t *tile.Tile) ([]byte, error) {
+			switch t.X {
+			case 1:
+				return []byte("fresh"), nil
+			case 2:
+				return []byte("expired"), cache.ErrExpired
+			default:
+				return nil, errors.New("not found")
+			}
+		},
+	}
+
+	downloader := NewMapDownloader(http.DefaultClient)
+
+	tiles, err := downloader.DownloadCached(mockCache, mockProvider, tile.Tile{X: 1, Y: 1, Z: 1}, tile.Tile{X: 2, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("fresh"), tiles[0].Image)
+	assert.Equal(t, []byte("expired"), tiles[1].Image)
+	assert.False(t, tiles[1].Stale) // expiration is ignored
+
+	tiles, err = downloader.DownloadCached(mockCache, mockProvider, tile.Tile{X: 1, Y: 1, Z: 1}, tile.Tile{X: 3, Y: 1, Z: 1})
+	var missingErr *MissingError
+	assert.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, []tile.Tile{{X: 3, Y: 1, Z: 1}}, missingErr.Tiles)
+	assert.Equal(t, "1 tiles not found in cache", err.Error())
+	assert.Len(t, tiles, 1)
+
+	// without cache all tiles are missing
+	tiles, err = downloader.DownloadCached(nil, mockProvider, tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.ErrorAs(t, err, &missingErr)
+	assert.Len(t, missingErr.Tiles, 1)
+	assert.Empty(t, tiles)
+}
+
+func TestDownload_LocalProvider(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "providers.json")
+	spec := `[{"name":"Local","id":"local","type":"mbtiles","path":"../archive/testdata/plain.mbtiles","max_zoom":4,"proj":"spherical"}]`
+	assert.NoError(t, os.WriteFile(schema, []byte(spec), 0o600))
+
+	list, err := provider.LoadProviderList(schema)
+	assert.NoError(t, err)
+	l, err := list.Get("local")
+	assert.NoError(t, err)
+
+	md := NewMapDownloader(http.DefaultClient)
+	tiles, err := md.Download(nil, l, tile.Tile{X: 1, Y: 1, Z: 1}, tile.Tile{X: 3, Y: 3, Z: 2})
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 2)
+	for _, tl := range tiles {
+		assert.Equal(t, "image/png", tl.Meta.ContentType)
+		assert.NotEmpty(t, tl.Meta.LastModified)
+	}
+
+	center := tile.Tile{X: 1, Y: 1, Z: 2}
+	tiles, err = md.Download(nil, l, center.GetNearby(2)...)
+	assert.NoError(t, err)
+	img, err := md.Merge(2, center, tiles...)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, img)
+
+	_, err = md.Download(nil, l, tile.Tile{X: 5, Y: 5, Z: 3})
+	assert.EqualError(t, err, "server returned invalid status code: code=404")
+}
@@ -74,6 +74,9 @@ func createMergedImage(side int, centerTile tile.Tile, coordsData *imageTileSlic
 		if err != nil {
 			return nil, fmt.Errorf("error occurred with decoding image: %w", err)
 		}
+		if file.Blank {
+			img = blankImage(img.Bounds())
+		}
 
 		x := file.X - centerTile.X + (side / 2)
 		y := file.Y - centerTile.Y + (side / 2)
@@ -85,6 +88,13 @@ func createMergedImage(side int, centerTile tile.Tile, coordsData *imageTileSlic
 	return mergeImagesIntoResult(images, side)
 }
 
+// blankImage return white image in place of blank tile, so placeholder of upstream isn't shown as map
+func blankImage(bounds image.Rectangle) image.Image {
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	return img
+}
+
 // prepareImageGrid initializes a grid to hold images based on the side length.
 func prepareImageGrid(side int) [][]image.Image {
 	images := make([][]image.Image, side)
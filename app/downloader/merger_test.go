@@ -61,6 +61,18 @@ func TestMerge_Success(t *testing.T) {
 	}
 }
 
+func TestMerge_BlankTile(t *testing.T) {
+	tiles := []tile.Tile{{X: 1, Y: 1, Image: createTestImage(color.Black), Blank: true}}
+
+	resultBytes, err := NewMapDownloader(http.DefaultClient).Merge(1, tile.Tile{X: 1, Y: 1}, tiles...)
+	assert.NoError(t, err)
+
+	resultImg, _, err := image.Decode(bytes.NewReader(resultBytes))
+	assert.NoError(t, err)
+	r, g, b, _ := resultImg.At(50, 50).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+}
+
 func TestMerge_FailInvalidTile(t *testing.T) {
 	side := 3
 	centerTile := tile.Tile{X: 1, Y: 1}
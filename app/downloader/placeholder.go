@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// placeholderColor is a color of tiles which are not found in cache
+var placeholderColor = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+
+// Placeholders return plain gray tiles in place of missing ones. Placeholder size is the same as size
+// of the first found tile, so tiles can be merged, 256x256 is used if nothing is found.
+func Placeholders(found, missing []tile.Tile) []tile.Tile {
+	width, height := tile.Size, tile.Size
+	for _, t := range found {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(t.Image)); err == nil {
+			width, height = cfg.Width, cfg.Height
+			break
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: placeholderColor}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+
+	placeholders := make([]tile.Tile, 0, len(missing))
+	for _, t := range missing {
+		t.Image = buf.Bytes()
+		t.Meta = tile.Meta{ContentType: "image/png"}
+		placeholders = append(placeholders, t)
+	}
+
+	return placeholders
+}
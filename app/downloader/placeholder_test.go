@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestPlaceholders(t *testing.T) {
+	missing := []tile.Tile{{X: 1, Y: 2, Z: 3}, {X: 2, Y: 2, Z: 3}}
+
+	// size of found tiles is used
+	placeholders := Placeholders([]tile.Tile{{Image: []byte("broken")}, {Image: createTestImage(color.White)}}, missing)
+	assert.Len(t, placeholders, 2)
+	assert.Equal(t, 2, placeholders[1].X)
+	assert.Equal(t, "image/png", placeholders[0].Meta.ContentType)
+
+	img, _, err := image.Decode(bytes.NewReader(placeholders[0].Image))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), img.Bounds())
+	r, g, b, _ := img.At(50, 50).RGBA()
+	assert.Equal(t, []uint32{0xe0e0, 0xe0e0, 0xe0e0}, []uint32{r, g, b})
+
+	// default size
+	placeholders = Placeholders(nil, missing)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(placeholders[0].Image))
+	assert.NoError(t, err)
+	assert.Equal(t, 256, cfg.Width)
+
+	// placeholders can be merged with found tiles
+	center := tile.Tile{X: 1, Y: 2, Z: 3}
+	nearby := center.GetNearby(2)
+	found := []tile.Tile{{X: nearby[0].X, Y: nearby[0].Y, Z: 3, Image: createTestImage(color.White)}}
+
+	merged, err := NewMapDownloader(nil).Merge(2, center, append(found, Placeholders(found, nearby[1:])...)...)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, merged)
+}
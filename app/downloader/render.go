@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// Render draws image from tiles of the same zoom. Every column and row of image has position of its pixel
+// centers in tiles (fractional part is position inside tile), so tiles are cropped and resampled to any grid
+// with separable axes like mercator or plate carrée. Pixels out of tiles, blank tiles and NaN positions stay transparent.
+func Render(columns, rows []float64, tiles ...tile.Tile) (*image.RGBA, error) {
+	images := make(map[[2]int]*image.RGBA, len(tiles))
+	for _, t := range tiles {
+		if t.Image == nil || t.Blank {
+			continue
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(t.Image))
+		if err != nil {
+			return nil, fmt.Errorf("error occurred with decoding image of tile x=%d, y=%d, z=%d: %w", t.X, t.Y, t.Z, err)
+		}
+
+		rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		images[[2]int{t.X, t.Y}] = rgba
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, len(columns), len(rows)))
+
+	for py, row := range rows {
+		if math.IsNaN(row) {
+			continue
+		}
+		ty, fy := math.Modf(row)
+
+		for px, column := range columns {
+			if math.IsNaN(column) {
+				continue
+			}
+			tx, fx := math.Modf(column)
+
+			img, ok := images[[2]int{int(tx), int(ty)}]
+			if !ok || fx < 0 || fy < 0 {
+				continue
+			}
+
+			sx := min(int(fx*float64(img.Rect.Dx())), img.Rect.Dx()-1)
+			sy := min(int(fy*float64(img.Rect.Dy())), img.Rect.Dy()-1)
+
+			src := img.PixOffset(sx, sy)
+			dst := result.PixOffset(px, py)
+			copy(result.Pix[dst:dst+4], img.Pix[src:src+4])
+		}
+	}
+
+	return result, nil
+}
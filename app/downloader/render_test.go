@@ -0,0 +1,58 @@
+package downloader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestRender(t *testing.T) {
+	// left half of tile is colored, right half is white
+	pngTile := func(c color.Color) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		for x := 0; x < 4; x++ {
+			for y := 0; y < 4; y++ {
+				img.Set(x, y, color.White)
+				if x < 2 {
+					img.Set(x, y, c)
+				}
+			}
+		}
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, img)
+		return buf.Bytes()
+	}
+
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+	tiles := []tile.Tile{
+		{X: 0, Y: 0, Z: 1, Image: pngTile(red)},
+		{X: 1, Y: 0, Z: 1, Image: pngTile(green)},
+	}
+
+	img, err := Render([]float64{0.1, 0.9, 1.25, 1.75, 2.5, math.NaN(), -0.5}, []float64{0.5, 0.999, 1.5}, tiles...)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 7, 3), img.Bounds())
+
+	expected := []color.Color{red, color.White, green, color.White, color.Transparent, color.Transparent, color.Transparent}
+	for x, c := range expected {
+		assert.Equal(t, color.RGBAModel.Convert(c), img.At(x, 0), "pixel %d", x)
+		assert.Equal(t, color.RGBAModel.Convert(c), img.At(x, 1), "pixel %d", x)
+		assert.Equal(t, color.RGBAModel.Convert(color.Transparent), img.At(x, 2), "pixel %d", x)
+	}
+
+	// blank tiles aren't drawn
+	tiles[0].Blank = true
+	img, err = Render([]float64{0.1}, []float64{0.5}, tiles...)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBAModel.Convert(color.Transparent), img.At(0, 0))
+
+	_, err = Render([]float64{0.5}, []float64{0.5}, tile.Tile{X: 0, Y: 0, Z: 1, Image: []byte("broken")})
+	assert.ErrorContains(t, err, "x=0, y=0, z=1")
+}
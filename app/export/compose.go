@@ -0,0 +1,74 @@
+package export
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/superboomer/maptile/app/overlay"
+)
+
+// Font sizes in points
+const (
+	titleSize   = 14.0
+	minTitle    = 6.0
+	captionSize = 7.0
+)
+
+// maxBarWidth is a max length of scale bar in millimeters
+const maxBarWidth = 60.0
+
+// Map is a content of sheet
+type Map struct {
+	Image       []byte  // JPEG image of map frame of sheet pixels
+	Title       string  // title above map frame, it's shrunk to fit sheet width
+	Attribution string  // plain text attribution of provider under map frame
+	Scale       float64 // denominator of scale of map frame at its center
+}
+
+// Compose return PDF document of sheet with map frame, title above it, scale bar and attribution under it
+func (s *Sheet) Compose(m *Map) []byte {
+	width, height := s.Size()
+	page := NewPage(width, height)
+
+	// the frame is placed in its exact size in pixels to keep the scale precise
+	columns, rows := s.Pixels()
+	frameWidth, frameHeight := float64(columns)*25.4/float64(s.DPI), float64(rows)*25.4/float64(s.DPI)
+	frameTop := height - margin - header
+	page.Image(m.Image, columns, rows, margin, frameTop-frameHeight, frameWidth, frameHeight)
+	page.Rect(margin, frameTop-frameHeight, frameWidth, frameHeight, 0, false, 0.5)
+
+	if m.Title != "" {
+		size := max(minTitle, min(titleSize, titleSize*(width-2*margin)/TextWidth(m.Title, titleSize)))
+		page.Text(m.Title, HelveticaBold, size, margin, height-margin-header+4)
+	}
+
+	// scale bar of four segments with its length and the scale next to it
+	barTop := frameTop - frameHeight - 4
+	label := "Scale 1:" + groupThousands(m.Scale)
+	if bars := overlay.ScaleBars(overlay.ScaleMetric, m.Scale/1000, maxBarWidth); len(bars) > 0 {
+		bar := bars[0]
+		for i := range 4 {
+			page.Rect(margin+bar.Width*float64(i)/4, barTop-1.5, bar.Width/4, 1.5, float64(i%2), true, 0)
+		}
+		page.Rect(margin, barTop-1.5, bar.Width, 1.5, 0, false, 0.5)
+		label = bar.Label + "    " + label
+		page.Text(label, Helvetica, captionSize, margin+bar.Width+2, barTop-1.5)
+	} else {
+		page.Text(label, Helvetica, captionSize, margin, barTop-1.5)
+	}
+
+	if m.Attribution != "" {
+		page.Text(m.Attribution, Helvetica, captionSize, margin, margin+1)
+	}
+
+	return page.Bytes()
+}
+
+// groupThousands format rounded number with thousands separated by spaces
+func groupThousands(value float64) string {
+	s := strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + " " + s[i:]
+	}
+	return s
+}
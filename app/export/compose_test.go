@@ -0,0 +1,36 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheet_Compose(t *testing.T) {
+	s := &Sheet{Paper: "a4", Orientation: Landscape, DPI: 100}
+	doc := string(s.Compose(&Map{Image: []byte("jpeg"), Title: "Flood (north)", Attribution: "© OpenStreetMap", Scale: 5000}))
+	assertXref(t, []byte(doc))
+
+	columns, rows := s.Pixels()
+	assert.Equal(t, [2]int{1091, 646}, [2]int{columns, rows})
+	assert.Contains(t, doc, "/Width 1091 /Height 646")
+
+	assert.Contains(t, doc, `(Flood \(north\)) Tj`)
+	assert.Contains(t, doc, `(\251 OpenStreetMap) Tj`)
+
+	// scale bar isn't longer than 60 mm, it's 200 m of 40 mm at 1:5000
+	assert.Contains(t, doc, "(200 m    Scale 1:5 000) Tj")
+	assert.Contains(t, doc, "0 G 0.5 w 28.346 52.203 113.386 4.252 re S")
+
+	// long titles are shrunk
+	doc = string(s.Compose(&Map{Image: []byte("jpeg"), Title: strings.Repeat("Flood ", 25), Scale: 1e9}))
+	assert.Contains(t, doc, "BT /F2 9.")
+	assert.Contains(t, doc, "(50000 km    Scale 1:1 000 000 000) Tj")
+}
+
+func TestGroupThousands(t *testing.T) {
+	assert.Equal(t, "999", groupThousands(999))
+	assert.Equal(t, "1 000", groupThousands(999.5))
+	assert.Equal(t, "1 234 567", groupThousands(1234567.4))
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/superboomer/maptile/app/jobs"
+)
+
+// ErrBusy is returned when max count of jobs are already running
+var ErrBusy = jobs.ErrBusy
+
+// Status contains state of export job started by Jobs
+type Status struct {
+	jobs.Job
+	Provider string `json:"provider"`
+	Tiles    int    `json:"tiles"`
+
+	result []byte // PDF document of done job
+}
+
+// Jobs runs export jobs in background and keeps their statuses and documents in memory,
+// finished jobs are removed after alive duration
+type Jobs struct {
+	registry *jobs.Registry[Status, *Status]
+}
+
+// NewJobs create Jobs which run up to maxRunning jobs at the same time with deadline of timeout
+// and keep finished jobs for alive duration
+func NewJobs(alive, timeout time.Duration, maxRunning int) *Jobs {
+	return &Jobs{registry: jobs.NewRegistry[Status]("export", alive, timeout, maxRunning)}
+}
+
+// Start run export of provider tiles in background, run returns PDF document
+func (j *Jobs) Start(provider string, tiles int, run func(ctx context.Context) ([]byte, error)) (Status, error) {
+	status := Status{Provider: provider, Tiles: tiles}
+
+	return j.registry.Start(status, func(ctx context.Context, update func(fn func(st *Status))) error {
+		result, err := run(ctx)
+		if err != nil {
+			return err
+		}
+		update(func(st *Status) { st.result = result })
+		return nil
+	})
+}
+
+// Get return status of job
+func (j *Jobs) Get(id string) (Status, error) {
+	return j.registry.Get(id)
+}
+
+// Result return PDF document of done job
+func (j *Jobs) Result(id string) ([]byte, error) {
+	st, err := j.registry.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Status != jobs.StatusDone {
+		return nil, fmt.Errorf("export %s is %s", id, st.Status)
+	}
+
+	return st.result, nil
+}
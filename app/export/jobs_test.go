@@ -0,0 +1,83 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/jobs"
+)
+
+func TestJobs(t *testing.T) {
+	j := NewJobs(time.Hour, time.Minute, 2)
+
+	st, err := j.Start("vendor", 10, func(context.Context) ([]byte, error) { return []byte("pdf"), nil })
+	assert.NoError(t, err)
+	assert.Equal(t, jobs.StatusRunning, st.Status)
+	assert.Equal(t, "vendor", st.Provider)
+	assert.Equal(t, 10, st.Tiles)
+
+	assert.Eventually(t, func() bool {
+		st, err = j.Get(st.ID)
+		return err == nil && st.Status == jobs.StatusDone
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, st.Finished.IsZero())
+
+	result, err := j.Result(st.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("pdf"), result)
+
+	st, err = j.Start("vendor", 10, func(context.Context) ([]byte, error) { return nil, errors.New("no tiles") })
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		st, err = j.Get(st.ID)
+		return err == nil && st.Status == jobs.StatusFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "no tiles", st.Error)
+
+	_, err = j.Result(st.ID)
+	assert.EqualError(t, err, "export "+st.ID+" is failed")
+
+	_, err = j.Get("missing")
+	assert.EqualError(t, err, "export missing not found")
+	_, err = j.Result("missing")
+	assert.EqualError(t, err, "export missing not found")
+}
+
+func TestJobs_Busy(t *testing.T) {
+	j := NewJobs(time.Hour, time.Minute, 1)
+
+	release := make(chan struct{})
+	st, err := j.Start("vendor", 1, func(context.Context) ([]byte, error) {
+		<-release
+		return []byte("pdf"), nil
+	})
+	assert.NoError(t, err)
+
+	_, err = j.Result(st.ID)
+	assert.EqualError(t, err, "export "+st.ID+" is running")
+
+	_, err = j.Start("vendor", 1, func(context.Context) ([]byte, error) { return nil, nil })
+	assert.ErrorIs(t, err, ErrBusy)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, err = j.Start("vendor", 1, func(context.Context) ([]byte, error) { return nil, nil })
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestJobs_Expire(t *testing.T) {
+	j := NewJobs(time.Millisecond, time.Minute, 1)
+
+	st, err := j.Start("vendor", 1, func(context.Context) ([]byte, error) { return []byte("pdf"), nil })
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err = j.Get(st.ID)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/superboomer/maptile/app/watermark"
+)
+
+// Fonts of page, they are standard PDF fonts which aren't embedded
+const (
+	Helvetica     = "F1"
+	HelveticaBold = "F2"
+)
+
+// pointsInMillimeter is a count of PDF points in millimeter
+const pointsInMillimeter = 72 / 25.4
+
+// pdfImage is a JPEG image of page
+type pdfImage struct {
+	data          []byte
+	width, height int
+}
+
+// Page is a single page PDF document with text, rectangles, lines and JPEG images, coordinates are in millimeters
+// from bottom left corner of the page
+type Page struct {
+	width, height float64
+	content       bytes.Buffer
+	images        []pdfImage
+}
+
+// NewPage create page of width x height millimeters
+func NewPage(width, height float64) *Page {
+	return &Page{width: width, height: height}
+}
+
+// Image draw JPEG image of width x height pixels into rectangle
+func (p *Page) Image(data []byte, width, height int, x, y, w, h float64) {
+	p.images = append(p.images, pdfImage{data: data, width: width, height: height})
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", pt(w), pt(h), pt(x), pt(y), len(p.images))
+}
+
+// Text draw text in font of size in points, baseline starts at x, y. Text is encoded as WinAnsi, so signs out
+// of Latin-1 are transliterated
+func (p *Page) Text(text, font string, size, x, y float64) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), pt(x), pt(y), escape(text))
+}
+
+// Rect draw rectangle filled with gray level if fill is true or stroked with line of width in points otherwise,
+// gray level is within 0 (black) .. 1 (white)
+func (p *Page) Rect(x, y, w, h, gray float64, fill bool, width float64) {
+	if fill {
+		fmt.Fprintf(&p.content, "%s g %s %s %s %s re f\n", num(gray), pt(x), pt(y), pt(w), pt(h))
+		return
+	}
+	fmt.Fprintf(&p.content, "%s G %s w %s %s %s %s re S\n", num(gray), num(width), pt(x), pt(y), pt(w), pt(h))
+}
+
+// Bytes return PDF document of page
+func (p *Page) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	// binary comment marks the file as binary for transfer tools
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// images are objects after content, first image is object 7
+	var resources strings.Builder
+	for i := range p.images {
+		fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, i+7)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Contents 6 0 R "+
+		"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> /XObject <<%s >> >> >>", pt(p.width), pt(p.height), resources.String()), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	object(fmt.Sprintf("<< /Length %d >>", p.content.Len()), p.content.Bytes())
+	for _, img := range p.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB "+
+			"/BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", img.width, img.height, len(img.data)), img.data)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// TextWidth return approximate width of text in font of size in millimeters, it's an average width of Helvetica
+// glyphs, so it's good enough to fit text into sheet but not to align it precisely
+func TextWidth(text string, size float64) float64 {
+	return float64(len(winAnsi(text))) * 0.55 * size / pointsInMillimeter
+}
+
+// pt format length in millimeters as PDF points
+func pt(mm float64) string {
+	return num(mm * pointsInMillimeter)
+}
+
+// num format number of PDF content
+func num(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.3f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// winAnsi encode text as WinAnsi, it matches Latin-1 for printable signs, other signs are transliterated
+func winAnsi(text string) []byte {
+	var b []byte
+	for _, r := range text {
+		switch {
+		case r >= 0x20 && r <= 0x7e, r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			b = append(b, watermark.Transliterate(string(r))...)
+		}
+	}
+	return b
+}
+
+// escape encode text as PDF string literal content, non-ASCII bytes are written as octal codes
+func escape(text string) string {
+	var b strings.Builder
+	for _, c := range winAnsi(text) {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c > 0x7e:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
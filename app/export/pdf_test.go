@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertXref check that xref table of document points to its objects
+func assertXref(t *testing.T, doc []byte) {
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if !assert.NotNil(t, m) {
+		return
+	}
+
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	assert.NotEmpty(t, entries)
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(doc[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestPage_Bytes(t *testing.T) {
+	p := NewPage(210, 297)
+	p.Image([]byte("jpeg"), 2, 3, 10, 20, 30, 40)
+	p.Text("Title", HelveticaBold, 14, 10, 280)
+	p.Rect(10, 20, 30, 40, 0, false, 0.5)
+	p.Rect(10, 20, 5, 1, 1, true, 0)
+
+	doc := p.Bytes()
+	assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	assertXref(t, doc)
+
+	assert.Contains(t, string(doc), "/MediaBox [0 0 595.276 841.89]")
+	assert.Contains(t, string(doc), "/XObject << /Im1 7 0 R >>")
+	assert.Contains(t, string(doc), "/Width 2 /Height 3 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length 4 >>\n"+
+		"stream\njpeg\nendstream")
+	assert.Contains(t, string(doc), "q 85.039 0 0 113.386 28.346 56.693 cm /Im1 Do Q\n")
+	assert.Contains(t, string(doc), "BT /F2 14 Tf 28.346 793.701 Td (Title) Tj ET\n")
+	assert.Contains(t, string(doc), "0 G 0.5 w 28.346 56.693 85.039 113.386 re S\n")
+	assert.Contains(t, string(doc), "1 g 28.346 56.693 14.173 2.835 re f\n")
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\ \251 - ?`, escape("a(b)\\ © — №"))
+	assert.Equal(t, `Stra\337e`, escape("Straße"))
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.InDelta(t, 10*0.55*10/72*25.4, TextWidth("abcdefghij", 10), 1e-9)
+	assert.InDelta(t, TextWidth("(c)", 10), TextWidth("©©©", 10), 1e-9)
+}
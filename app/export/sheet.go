@@ -0,0 +1,158 @@
+// Package export compose print-ready PDF sheets of maps: paper size, DPI and scale define the zoom and pixel size
+// of map frame which is placed on the sheet with title, scale bar and attribution.
+package export
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/superboomer/maptile/app/overlay"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// Orientations of sheet
+const (
+	Portrait  = "portrait"
+	Landscape = "landscape"
+)
+
+// Limits of sheet and map frame
+const (
+	MinDPI    = 72
+	MaxDPI    = 600
+	MinScale  = 100         // 1:100
+	MaxScale  = 100_000_000 // 1:100 000 000
+	MaxPixels = 40_000_000  // max pixels of map frame, it's A2 at 300 DPI
+	MaxTitle  = 200         // max length of title in characters
+)
+
+// Layout of sheet in millimeters
+const (
+	margin = 10.0 // margin on each side
+	header = 12.0 // height of title area above map frame
+	footer = 14.0 // height of scale and attribution area under map frame
+)
+
+// metersInInch is a length of inch in meters
+const metersInInch = 0.0254
+
+// maxLatitude is a max latitude of sheet center, mercator isn't defined at the poles
+const maxLatitude = 85
+
+// Papers contains sizes of supported papers in millimeters in portrait orientation
+var Papers = map[string][2]float64{
+	"a0":      {841, 1189},
+	"a1":      {594, 841},
+	"a2":      {420, 594},
+	"a3":      {297, 420},
+	"a4":      {210, 297},
+	"a5":      {148, 210},
+	"letter":  {215.9, 279.4},
+	"legal":   {215.9, 355.6},
+	"tabloid": {279.4, 431.8},
+}
+
+// Sheet is a paper of print-ready export
+type Sheet struct {
+	Paper       string // one of papers
+	Orientation string // one of orientations, portrait if empty
+	DPI         int
+}
+
+// Validate check paper, orientation, DPI and pixel size of map frame
+func (s *Sheet) Validate() error {
+	if _, ok := Papers[s.Paper]; !ok {
+		names := make([]string, 0, len(Papers))
+		for name := range Papers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("paper %q not supported, must be one of %s", s.Paper, strings.Join(names, ", "))
+	}
+
+	if s.Orientation != "" && s.Orientation != Portrait && s.Orientation != Landscape {
+		return fmt.Errorf("orientation %q not supported", s.Orientation)
+	}
+
+	if s.DPI < MinDPI || s.DPI > MaxDPI {
+		return fmt.Errorf("dpi must be within %d..%d", MinDPI, MaxDPI)
+	}
+
+	if width, height := s.Pixels(); width*height > MaxPixels {
+		return fmt.Errorf("map frame of %dx%d pixels is larger than %d pixels, decrease paper size or dpi", width, height, MaxPixels)
+	}
+
+	return nil
+}
+
+// Size return width and height of sheet in millimeters
+func (s *Sheet) Size() (width, height float64) {
+	size := Papers[s.Paper]
+	if s.Orientation == Landscape {
+		return size[1], size[0]
+	}
+	return size[0], size[1]
+}
+
+// Pixels return width and height of map frame in pixels
+func (s *Sheet) Pixels() (width, height int) {
+	w, h := s.Size()
+	return int(math.Round((w - 2*margin) / 25.4 * float64(s.DPI))), int(math.Round((h - 2*margin - header - footer) / 25.4 * float64(s.DPI)))
+}
+
+// pixelSize return size of pixel of map frame on paper in meters
+func (s *Sheet) pixelSize() float64 {
+	return metersInInch / float64(s.DPI)
+}
+
+// AtScale return view of map frame centered on latitude and longitude at scale 1:scale, the view zoom is the lowest
+// zoom within minZoom..maxZoom which tiles are at least as detailed as the frame, tiles are resampled to the scale
+func (s *Sheet) AtScale(proj *tile.Elips, lat, long, scale float64, minZoom, maxZoom int) *overlay.View {
+	lat = max(-maxLatitude, min(maxLatitude, lat))
+	x, y := tile.ConvertToPosition(lat, long, 0, proj)
+
+	// magnification of zoom 0 which gives resolution of the scale at the center
+	magnification := (&overlay.View{Proj: proj, TileSize: tile.Size}).Resolution(lat) / (scale * s.pixelSize())
+
+	return s.view(proj, x, y, magnification, minZoom, maxZoom)
+}
+
+// FitBBox return view of map frame where bbox (min_long, min_lat, max_long, max_lat) fits and scale of the view
+// at its center
+func (s *Sheet) FitBBox(proj *tile.Elips, bbox [4]float64, minZoom, maxZoom int) (view *overlay.View, scale float64) {
+	x0, y0 := tile.ConvertToPosition(max(-maxLatitude, min(maxLatitude, bbox[3])), bbox[0], 0, proj)
+	x1, y1 := tile.ConvertToPosition(max(-maxLatitude, min(maxLatitude, bbox[1])), bbox[2], 0, proj)
+
+	width, height := s.Pixels()
+	magnification := min(float64(width)/max((x1-x0)*tile.Size, 1e-9), float64(height)/max((y1-y0)*tile.Size, 1e-9))
+
+	view = s.view(proj, (x0+x1)/2, (y0+y1)/2, magnification, minZoom, maxZoom)
+	return view, s.Scale(view)
+}
+
+// Scale return denominator of scale of view at its center
+func (s *Sheet) Scale(view *overlay.View) float64 {
+	width, height := s.Pixels()
+	x, y := view.X+float64(width)/2/view.TileSize, view.Y+float64(height)/2/view.TileSize
+	lat, _ := tile.ConvertFromTile(x, y, float64(view.Zoom), view.Proj)
+	return view.Resolution(lat) / s.pixelSize()
+}
+
+// view return view of map frame centered on position of zoom 0 in tiles, tiles of zoom 0 are magnified
+func (s *Sheet) view(proj *tile.Elips, x, y, magnification float64, minZoom, maxZoom int) *overlay.View {
+	// a tiny tolerance keeps native zoom when magnification is a power of two
+	zoom := max(minZoom, min(maxZoom, int(math.Ceil(math.Log2(magnification)-1e-9))))
+	size := tile.Size * magnification / math.Pow(2, float64(zoom))
+	scale := math.Pow(2, float64(zoom))
+
+	width, height := s.Pixels()
+	return &overlay.View{
+		Proj:     proj,
+		Zoom:     zoom,
+		X:        x*scale - float64(width)/2/size,
+		Y:        y*scale - float64(height)/2/size,
+		TileSize: size,
+	}
+}
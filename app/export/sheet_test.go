@@ -0,0 +1,80 @@
+package export
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestSheet_Size(t *testing.T) {
+	s := &Sheet{Paper: "a4", DPI: 300}
+	w, h := s.Size()
+	assert.Equal(t, [2]float64{210, 297}, [2]float64{w, h})
+
+	columns, rows := s.Pixels()
+	assert.Equal(t, 2244, columns)
+	assert.Equal(t, 2965, rows)
+
+	s.Orientation = Landscape
+	w, h = s.Size()
+	assert.Equal(t, [2]float64{297, 210}, [2]float64{w, h})
+}
+
+func TestSheet_Validate(t *testing.T) {
+	assert.NoError(t, (&Sheet{Paper: "a3", Orientation: Landscape, DPI: 300}).Validate())
+	assert.NoError(t, (&Sheet{Paper: "letter", DPI: 72}).Validate())
+
+	assert.ErrorContains(t, (&Sheet{Paper: "b5", DPI: 300}).Validate(), `paper "b5" not supported, must be one of a0, a1, a2, a3`)
+	assert.EqualError(t, (&Sheet{Paper: "a4", Orientation: "diagonal", DPI: 300}).Validate(), `orientation "diagonal" not supported`)
+	assert.EqualError(t, (&Sheet{Paper: "a4", DPI: 50}).Validate(), "dpi must be within 72..600")
+	assert.ErrorContains(t, (&Sheet{Paper: "a1", DPI: 300}).Validate(), "decrease paper size or dpi")
+}
+
+func TestSheet_AtScale(t *testing.T) {
+	s := &Sheet{Paper: "a4", DPI: 254} // pixel is 0.1 mm
+
+	// zoom 10 tiles are shown in native size
+	scale := 2 * math.Pi * 6378137 / 256 / 1024 / 0.0001
+	v := s.AtScale(&tile.ElipsSpherical, 0, 0, scale, 0, 19)
+	assert.Equal(t, 10, v.Zoom)
+	assert.InDelta(t, 256, v.TileSize, 1e-6)
+	assert.InDelta(t, scale, s.Scale(v), 1e-3)
+
+	// the lowest zoom which is detailed enough is chosen, its tiles are shrunk
+	v = s.AtScale(&tile.ElipsSpherical, 55.75, 37.6, 5000, 0, 19)
+	assert.Equal(t, 18, v.Zoom)
+	assert.Greater(t, v.TileSize, 128.0)
+	assert.LessOrEqual(t, v.TileSize, 256.0)
+	assert.InDelta(t, 0.5, v.Resolution(55.75), 1e-9)
+	assert.InDelta(t, 5000, s.Scale(v), 1)
+
+	// frame is centered on the point
+	columns, rows := s.Pixels()
+	lat, long := tile.ConvertFromTile(v.X+float64(columns)/2/v.TileSize, v.Y+float64(rows)/2/v.TileSize, float64(v.Zoom), v.Proj)
+	assert.InDelta(t, 55.75, lat, 1e-9)
+	assert.InDelta(t, 37.6, long, 1e-9)
+
+	// tiles are magnified beyond max zoom, the scale is kept
+	v = s.AtScale(&tile.ElipsSpherical, 55.75, 37.6, 5000, 0, 15)
+	assert.Equal(t, 15, v.Zoom)
+	assert.Greater(t, v.TileSize, 256.0)
+	assert.InDelta(t, 5000, s.Scale(v), 1)
+}
+
+func TestSheet_FitBBox(t *testing.T) {
+	s := &Sheet{Paper: "a4", DPI: 150}
+	columns, rows := s.Pixels()
+
+	v, scale := s.FitBBox(&tile.ElipsWGS84, [4]float64{30, 50, 40, 60}, 0, 19)
+	assert.InDelta(t, scale, s.Scale(v), 1e-9)
+
+	// bbox is taller than the portrait frame in mercator, so it takes the whole height
+	bounds := v.Bounds(columns, rows)
+	assert.InDelta(t, 50, bounds[1], 1e-6)
+	assert.InDelta(t, 60, bounds[3], 1e-6)
+	assert.Less(t, bounds[0], 30.0)
+	assert.Greater(t, bounds[2], 40.0)
+	assert.Equal(t, 7, v.Zoom)
+}
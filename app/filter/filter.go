@@ -0,0 +1,277 @@
+// Package filter post-process map images with chainable tone, color, sharpness and size operations
+package filter
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Names of filters
+const (
+	Brightness = "brightness"
+	Contrast   = "contrast"
+	Gamma      = "gamma"
+	Grayscale  = "grayscale"
+	Sharpen    = "sharpen"
+	AutoLevels = "autolevels"
+	Resize     = "resize"
+)
+
+// MaxFilters is a max count of filters in chain, it bounds processing time of image
+const MaxFilters = 8
+
+// minResize is a min width of resized image in pixels
+const minResize = 16
+
+// levelsClip is a share of the darkest and the brightest pixels of channel which are clipped by auto levels
+const levelsClip = 0.005
+
+// ranges contains allowed values of filters, filters without range have no value
+var ranges = map[string][2]float64{
+	Brightness: {-100, 100},
+	Contrast:   {-100, 100},
+	Gamma:      {0.1, 10},
+	Sharpen:    {0.1, 5},
+	Grayscale:  {},
+	AutoLevels: {},
+}
+
+// Filter is an operation of chain
+type Filter struct {
+	Name  string
+	Value float64
+}
+
+// Chain is a list of filters applied one after another
+type Chain []Filter
+
+// Parse parse filters of name:value or name form, resize width is limited by maxSize
+func Parse(values []string, maxSize int) (Chain, error) {
+	if len(values) > MaxFilters {
+		return nil, fmt.Errorf("more than %d filters", MaxFilters)
+	}
+
+	chain := make(Chain, 0, len(values))
+	for _, v := range values {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(v), ":")
+		name = strings.ToLower(name)
+
+		limits, ok := ranges[name]
+		if name == Resize {
+			limits, ok = [2]float64{minResize, float64(maxSize)}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("filter %q not supported", name)
+		}
+
+		f := Filter{Name: name}
+		switch {
+		case limits == [2]float64{} && hasValue:
+			return nil, fmt.Errorf("filter %s has no value", name)
+		case limits == [2]float64{}:
+		case !hasValue:
+			return nil, fmt.Errorf("filter %s value not specified", name)
+		default:
+			var err error
+			if f.Value, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("filter %s value error: %w", name, err)
+			}
+			if !(f.Value >= limits[0] && f.Value <= limits[1]) {
+				return nil, fmt.Errorf("filter %s value must be within %s..%s", name,
+					strconv.FormatFloat(limits[0], 'f', -1, 64), strconv.FormatFloat(limits[1], 'f', -1, 64))
+			}
+			if name == Resize {
+				f.Value = math.Round(f.Value)
+			}
+		}
+
+		chain = append(chain, f)
+	}
+
+	return chain, nil
+}
+
+// Apply apply filters to image in order, resized image is returned as a new image and its height is limited by
+// maxSize too, other filters change image in place
+func (c Chain) Apply(img *image.RGBA, maxSize int) *image.RGBA {
+	for _, f := range c {
+		switch f.Name {
+		case Brightness:
+			shift := f.Value / 100 * 255
+			applyTone(img, func(v float64) float64 { return v + shift })
+		case Contrast:
+			factor := 1 + f.Value/100
+			applyTone(img, func(v float64) float64 { return (v-127.5)*factor + 127.5 })
+		case Gamma:
+			exponent := 1 / f.Value
+			applyTone(img, func(v float64) float64 { return 255 * math.Pow(v/255, exponent) })
+		case Grayscale:
+			grayscale(img)
+		case Sharpen:
+			img = sharpen(img, f.Value)
+		case AutoLevels:
+			autoLevels(img)
+		case Resize:
+			img = resize(img, int(f.Value), maxSize)
+		}
+	}
+
+	return img
+}
+
+// lookup return table of tone function of channel values
+func lookup(tone func(v float64) float64) *[256]uint8 {
+	var table [256]uint8
+	for i := range table {
+		table[i] = clamp(tone(float64(i)))
+	}
+	return &table
+}
+
+// clamp round value to the nearest channel value
+func clamp(v float64) uint8 {
+	return uint8(max(0, min(255, math.Round(v))))
+}
+
+// unpremultiply return straight channel value of premultiplied value v of pixel with alpha a, a must not be zero
+func unpremultiply(v, a uint8) uint8 {
+	return uint8(min(255, (int(v)*255+int(a)/2)/int(a)))
+}
+
+// premultiply return premultiplied channel value of straight value v of pixel with alpha a
+func premultiply(v, a uint8) uint8 {
+	return uint8((int(v)*int(a) + 127) / 255)
+}
+
+// applyTone map color channels of image by tone function, alpha is kept
+func applyTone(img *image.RGBA, tone func(v float64) float64) {
+	table := lookup(tone)
+	applyTables(img, [3]*[256]uint8{table, table, table})
+}
+
+// applyTables map every straight color channel of image by its table, alpha is kept and transparent pixels are skipped
+func applyTables(img *image.RGBA, tables [3]*[256]uint8) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			switch a := row[i+3]; a {
+			case 0:
+			case 255:
+				row[i], row[i+1], row[i+2] = tables[0][row[i]], tables[1][row[i+1]], tables[2][row[i+2]]
+			default:
+				for c := range 3 {
+					row[i+c] = premultiply(tables[c][unpremultiply(row[i+c], a)], a)
+				}
+			}
+		}
+	}
+}
+
+// grayscale replace colors of image with their luma of Rec. 601, luma is linear, so premultiplied colors are kept valid
+func grayscale(img *image.RGBA) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			luma := clamp(0.299*float64(row[i]) + 0.587*float64(row[i+1]) + 0.114*float64(row[i+2]))
+			row[i], row[i+1], row[i+2] = luma, luma, luma
+		}
+	}
+}
+
+// autoLevels stretch every color channel of image to the full range, so color cast is removed too.
+// The darkest and the brightest pixels are clipped to ignore noise, channels of a single value are kept.
+// Levels are counted by straight colors of pixels which aren't transparent.
+func autoLevels(img *image.RGBA) {
+	var histograms [3][256]int
+	var pixels int
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			a := row[i+3]
+			if a == 0 {
+				continue
+			}
+			pixels++
+			for c := range 3 {
+				histograms[c][unpremultiply(row[i+c], a)]++
+			}
+		}
+	}
+
+	clip := int(float64(pixels) * levelsClip)
+
+	var tables [3]*[256]uint8
+	for c, histogram := range histograms {
+		low, high := 0, 255
+		for sum := histogram[low]; sum <= clip && low < 255; sum += histogram[low] {
+			low++
+		}
+		for sum := histogram[high]; sum <= clip && high > 0; sum += histogram[high] {
+			high--
+		}
+
+		if low >= high {
+			tables[c] = lookup(func(v float64) float64 { return v })
+			continue
+		}
+
+		scale := 255 / float64(high-low)
+		tables[c] = lookup(func(v float64) float64 { return (v - float64(low)) * scale })
+	}
+
+	applyTables(img, tables)
+}
+
+// sharpen return image sharpened by unsharp mask of 3x3 box blur, edge pixels are repeated beyond image.
+// Mask is linear, so it's applied to premultiplied colors, they are limited by alpha to keep them valid.
+func sharpen(img *image.RGBA, amount float64) *image.RGBA {
+	result := image.NewRGBA(img.Rect)
+	bounds := img.Rect
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var sum [3]int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					o := img.PixOffset(max(bounds.Min.X, min(bounds.Max.X-1, x+dx)), max(bounds.Min.Y, min(bounds.Max.Y-1, y+dy)))
+					sum[0], sum[1], sum[2] = sum[0]+int(img.Pix[o]), sum[1]+int(img.Pix[o+1]), sum[2]+int(img.Pix[o+2])
+				}
+			}
+
+			o, ro := img.PixOffset(x, y), result.PixOffset(x, y)
+			a := img.Pix[o+3]
+			for c := range 3 {
+				v := float64(img.Pix[o+c])
+				result.Pix[ro+c] = min(a, clamp(v+amount*(v-float64(sum[c])/9)))
+			}
+			result.Pix[ro+3] = a
+		}
+	}
+
+	return result
+}
+
+// resize return image of width with the same aspect ratio resampled by Catmull-Rom kernel,
+// width is reduced if height is larger than maxSize
+func resize(img *image.RGBA, width, maxSize int) *image.RGBA {
+	height := int(math.Round(float64(img.Rect.Dy()) * float64(width) / float64(img.Rect.Dx())))
+	if height > maxSize {
+		width = max(1, int(math.Round(float64(width)*float64(maxSize)/float64(height))))
+		height = maxSize
+	}
+	height = max(1, height)
+
+	if width == img.Rect.Dx() && height == img.Rect.Dy() {
+		return img
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(result, result.Rect, img, img.Rect, xdraw.Src, nil)
+
+	return result
+}
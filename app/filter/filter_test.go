@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uniform return image of width x height filled with color
+func uniform(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestParse(t *testing.T) {
+	chain, err := Parse([]string{"brightness:10", "Contrast:-20", "gamma:2.2", "grayscale", "sharpen:1", "autolevels", "resize:100.4"}, 512)
+	assert.NoError(t, err)
+	assert.Equal(t, Chain{{Brightness, 10}, {Contrast, -20}, {Gamma, 2.2}, {Grayscale, 0}, {Sharpen, 1}, {AutoLevels, 0}, {Resize, 100}},
+		chain)
+
+	chain, err = Parse(nil, 512)
+	assert.NoError(t, err)
+	assert.Empty(t, chain)
+
+	tests := []struct {
+		values []string
+		err    string
+	}{
+		{[]string{"blur:1"}, `filter "blur" not supported`},
+		{[]string{"grayscale:1"}, "filter grayscale has no value"},
+		{[]string{"gamma"}, "filter gamma value not specified"},
+		{[]string{"gamma:x"}, "filter gamma value error"},
+		{[]string{"gamma:0"}, "filter gamma value must be within 0.1..10"},
+		{[]string{"brightness:NaN"}, "filter brightness value must be within -100..100"},
+		{[]string{"resize:1024"}, "filter resize value must be within 16..512"},
+		{[]string{"grayscale", "grayscale", "grayscale", "grayscale", "grayscale", "grayscale", "grayscale", "grayscale",
+			"grayscale"}, "more than 8 filters"},
+	}
+
+	for _, tt := range tests {
+		_, err = Parse(tt.values, 512)
+		assert.ErrorContains(t, err, tt.err, tt.values)
+	}
+}
+
+func TestChain_ApplyTone(t *testing.T) {
+	apply := func(c color.RGBA, chain Chain) color.RGBA {
+		return chain.Apply(uniform(2, 2, c), 512).RGBAAt(1, 1)
+	}
+
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	assert.Equal(t, color.RGBA{R: 126, G: 126, B: 126, A: 255}, apply(gray, Chain{{Brightness, 10}}))
+	assert.Equal(t, color.RGBA{A: 255}, apply(gray, Chain{{Brightness, -100}}))
+	assert.Equal(t, color.RGBA{R: 73, G: 73, B: 73, A: 255}, apply(gray, Chain{{Contrast, 100}}))
+	assert.Equal(t, color.RGBA{R: 128, G: 128, B: 128, A: 255}, apply(gray, Chain{{Contrast, -100}}))
+	assert.Equal(t, color.RGBA{R: 160, G: 160, B: 160, A: 255}, apply(gray, Chain{{Gamma, 2}}))
+
+	// filters are applied in order
+	assert.Equal(t, color.RGBA{R: 9, G: 9, B: 9, A: 255}, apply(gray, Chain{{Contrast, 100}, {Brightness, -10}, {Gamma, 0.5}}))
+
+	assert.Equal(t, color.RGBA{R: 76, G: 76, B: 76, A: 255}, apply(color.RGBA{R: 255, A: 255}, Chain{{Grayscale, 0}}))
+}
+
+func TestChain_ApplyAutoLevels(t *testing.T) {
+	// bluish image of two tones is stretched in every channel
+	img := uniform(10, 10, color.RGBA{R: 50, G: 60, B: 120, A: 255})
+	for x := 0; x < 10; x++ {
+		img.SetRGBA(x, 0, color.RGBA{R: 100, G: 110, B: 200, A: 255})
+	}
+
+	img = Chain{{AutoLevels, 0}}.Apply(img, 512)
+	assert.Equal(t, color.RGBA{A: 255}, img.RGBAAt(5, 5))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, img.RGBAAt(5, 0))
+
+	// single tone channels are kept
+	img = Chain{{AutoLevels, 0}}.Apply(uniform(4, 4, color.RGBA{R: 50, G: 60, B: 120, A: 255}), 512)
+	assert.Equal(t, color.RGBA{R: 50, G: 60, B: 120, A: 255}, img.RGBAAt(1, 1))
+}
+
+func TestChain_ApplySharpen(t *testing.T) {
+	img := uniform(5, 5, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	img.SetRGBA(2, 2, color.RGBA{R: 190, G: 190, B: 190, A: 255})
+
+	img = Chain{{Sharpen, 1}}.Apply(img, 512)
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, img.RGBAAt(2, 2))
+	assert.Equal(t, color.RGBA{R: 90, G: 90, B: 90, A: 255}, img.RGBAAt(1, 1))
+	assert.Equal(t, color.RGBA{R: 100, G: 100, B: 100, A: 255}, img.RGBAAt(0, 0))
+}
+
+func TestChain_ApplyTransparent(t *testing.T) {
+	// transparent pixels are kept, colors of translucent pixels are filtered without alpha
+	img := uniform(2, 2, color.RGBA{})
+	img.SetRGBA(1, 1, color.RGBA{R: 50, G: 50, B: 50, A: 128})
+	img = Chain{{Brightness, 10}}.Apply(img, 512)
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 63, G: 63, B: 63, A: 128}, img.RGBAAt(1, 1))
+
+	// levels are counted without transparent pixels
+	img = uniform(10, 10, color.RGBA{})
+	for x := 0; x < 10; x++ {
+		img.SetRGBA(x, 0, color.RGBA{R: 100, G: 110, B: 200, A: 255})
+		img.SetRGBA(x, 1, color.RGBA{R: 50, G: 60, B: 120, A: 255})
+		img.SetRGBA(x, 2, color.RGBA{R: 25, G: 30, B: 60, A: 128})
+	}
+	img = Chain{{AutoLevels, 0}}.Apply(img, 512)
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(5, 5))
+	assert.Equal(t, color.RGBA{A: 128}, img.RGBAAt(5, 2))
+	assert.Equal(t, color.RGBA{A: 255}, img.RGBAAt(5, 1))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, img.RGBAAt(5, 0))
+
+	// sharpened colors don't exceed alpha
+	img = uniform(3, 3, color.RGBA{})
+	img.SetRGBA(1, 1, color.RGBA{R: 100, G: 100, B: 100, A: 128})
+	img = Chain{{Sharpen, 1}}.Apply(img, 512)
+	assert.Equal(t, color.RGBA{R: 128, G: 128, B: 128, A: 128}, img.RGBAAt(1, 1))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(0, 0))
+}
+
+func TestChain_ApplyResize(t *testing.T) {
+	c := color.RGBA{R: 10, G: 20, B: 30, A: 255}
+
+	img := Chain{{Resize, 50}}.Apply(uniform(200, 100, c), 512)
+	assert.Equal(t, image.Rect(0, 0, 50, 25), img.Rect)
+	assert.Equal(t, c, img.RGBAAt(20, 10))
+
+	img = Chain{{Resize, 400}}.Apply(uniform(200, 100, c), 512)
+	assert.Equal(t, image.Rect(0, 0, 400, 200), img.Rect)
+
+	// height is limited too
+	img = Chain{{Resize, 512}}.Apply(uniform(100, 200, c), 512)
+	assert.Equal(t, image.Rect(0, 0, 256, 512), img.Rect)
+}
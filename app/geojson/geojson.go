@@ -0,0 +1,95 @@
+// Package geojson decode geometries of GeoJSON objects, coordinates are [long, lat] like in GeoJSON
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Object contains supported part of GeoJSON object: geometry, Feature or FeatureCollection
+type Object struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *Object         `json:"geometry"`
+	Geometries  []Object        `json:"geometries"`
+	Features    []Object        `json:"features"`
+	Properties  map[string]any  `json:"properties"`
+}
+
+// Geometry is a decoded geometry, only the field of its type is filled
+type Geometry struct {
+	Type     string
+	Points   [][2]float64     // Point and MultiPoint
+	Lines    [][][2]float64   // LineString and MultiLineString
+	Polygons [][][][2]float64 // Polygon and MultiPolygon, polygons of rings, first ring is outer, others are holes
+}
+
+// Parse decode GeoJSON object
+func Parse(data []byte) (*Object, error) {
+	var o Object
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("can't decode geojson: %w", err)
+	}
+	return &o, nil
+}
+
+// Walk decode geometries of object one by one and call fn with every geometry and properties of its feature
+// (nil for geometries out of features). Geometry collections are flattened, features without geometry are skipped.
+func (o *Object) Walk(fn func(g *Geometry, properties map[string]any) error) error {
+	return o.walk(fn, nil)
+}
+
+// walk decode geometries of object, properties are inherited from enclosing feature
+func (o *Object) walk(fn func(g *Geometry, properties map[string]any) error, properties map[string]any) error {
+	switch o.Type {
+	case "Feature":
+		if o.Geometry == nil {
+			return nil
+		}
+		return o.Geometry.walk(fn, o.Properties)
+	case "FeatureCollection":
+		for i := range o.Features {
+			if err := o.Features[i].walk(fn, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "GeometryCollection":
+		for i := range o.Geometries {
+			if err := o.Geometries[i].walk(fn, properties); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	g := &Geometry{Type: o.Type}
+
+	var dst any
+	switch o.Type {
+	case "Point":
+		g.Points = make([][2]float64, 1)
+		dst = &g.Points[0]
+	case "MultiPoint":
+		dst = &g.Points
+	case "LineString":
+		g.Lines = make([][][2]float64, 1)
+		dst = &g.Lines[0]
+	case "MultiLineString":
+		dst = &g.Lines
+	case "Polygon":
+		g.Polygons = make([][][][2]float64, 1)
+		dst = &g.Polygons[0]
+	case "MultiPolygon":
+		dst = &g.Polygons
+	default:
+		return fmt.Errorf("geojson type %q not supported", o.Type)
+	}
+
+	if err := json.Unmarshal(o.Coordinates, dst); err != nil {
+		return fmt.Errorf("can't decode %s: %w", strings.ToLower(o.Type), err)
+	}
+
+	return fn(g, properties)
+}
@@ -0,0 +1,55 @@
+package geojson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObject_Walk(t *testing.T) {
+	o, err := Parse([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"name":"a"},"geometry":{"type":"GeometryCollection","geometries":[
+			{"type":"Point","coordinates":[1,2,100]},
+			{"type":"MultiLineString","coordinates":[[[1,2],[3,4]]]}
+		]}},
+		{"type":"Feature","properties":{"name":"b"},"geometry":null},
+		{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[2,2],[3,2],[3,3],[2,2]]]]}
+	]}`))
+	assert.NoError(t, err)
+
+	var geometries []Geometry
+	var names []any
+	err = o.Walk(func(g *Geometry, properties map[string]any) error {
+		geometries = append(geometries, *g)
+		names = append(names, properties["name"])
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Geometry{
+		{Type: "Point", Points: [][2]float64{{1, 2}}},
+		{Type: "MultiLineString", Lines: [][][2]float64{{{1, 2}, {3, 4}}}},
+		{Type: "MultiPolygon", Polygons: [][][][2]float64{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, {{{2, 2}, {3, 2}, {3, 3}, {2, 2}}}}},
+	}, geometries)
+	assert.Equal(t, []any{"a", "a", nil}, names)
+}
+
+func TestObject_WalkErrors(t *testing.T) {
+	_, err := Parse([]byte(`[`))
+	assert.ErrorContains(t, err, "can't decode geojson")
+
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"type":"Circle"}`, `geojson type "Circle" not supported`},
+		{`{"type":"Point","coordinates":"1,2"}`, "can't decode point"},
+		{`{"type":"Feature","geometry":{"type":"LineString","coordinates":[1,2]}}`, "can't decode linestring"},
+	}
+
+	for _, tt := range tests {
+		o, err := Parse([]byte(tt.data))
+		assert.NoError(t, err)
+		assert.ErrorContains(t, o.Walk(func(*Geometry, map[string]any) error { return nil }), tt.err, tt.data)
+	}
+}
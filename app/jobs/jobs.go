@@ -0,0 +1,188 @@
+// Package jobs runs jobs in background and keeps their statuses in memory, it's shared by seeding and export jobs
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses
+const (
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrBusy is returned when max count of jobs are already running
+var ErrBusy = errors.New("too many jobs are running, try again later")
+
+// Job contains common state of job, statuses of jobs embed it
+type Job struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// job return common state, it's promoted to statuses which embed Job
+func (j *Job) job() *Job {
+	return j
+}
+
+// Status is a pointer to status of job which embeds Job
+type Status[T any] interface {
+	*T
+	job() *Job
+}
+
+// Registry runs jobs in background and keeps their statuses of T in memory,
+// finished jobs are removed after alive duration
+type Registry[T any, P Status[T]] struct {
+	name       string
+	alive      time.Duration
+	timeout    time.Duration
+	maxRunning int
+
+	mutex   sync.Mutex
+	jobs    map[string]P
+	cancels map[string]context.CancelFunc
+}
+
+// NewRegistry create Registry which names jobs by name in errors, runs up to maxRunning jobs at the same time with
+// deadline of timeout and keeps finished jobs for alive duration. Zero values of limits mean no limit.
+func NewRegistry[T any, P Status[T]](name string, alive, timeout time.Duration, maxRunning int) *Registry[T, P] {
+	return &Registry[T, P]{
+		name:       name,
+		alive:      alive,
+		timeout:    timeout,
+		maxRunning: maxRunning,
+		jobs:       make(map[string]P),
+		cancels:    make(map[string]context.CancelFunc),
+	}
+}
+
+// Start run job with initial status in background. Run may change status under lock of registry with update,
+// its context is done on Cancel or when deadline is exceeded.
+func (r *Registry[T, P]) Start(status T, run func(ctx context.Context, update func(fn func(st P))) error) (T, error) {
+	r.mutex.Lock()
+	r.expire()
+
+	if r.maxRunning > 0 && len(r.cancels) >= r.maxRunning {
+		r.mutex.Unlock()
+		var empty T
+		return empty, ErrBusy
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), r.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	st := P(&status)
+	j := st.job()
+	j.ID, j.Status, j.Started = uuid.New().String(), StatusRunning, time.Now()
+
+	r.jobs[j.ID], r.cancels[j.ID] = st, cancel
+	res := *st
+	r.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+
+		err := run(ctx, func(fn func(st P)) {
+			r.mutex.Lock()
+			fn(st)
+			r.mutex.Unlock()
+		})
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		j.Finished = time.Now()
+		delete(r.cancels, j.ID)
+
+		switch {
+		case err != nil && errors.Is(ctx.Err(), context.Canceled):
+			j.Status = StatusCancelled
+		case err != nil:
+			j.Status, j.Error = StatusFailed, err.Error()
+		default:
+			j.Status = StatusDone
+		}
+	}()
+
+	return res, nil
+}
+
+// Get return status of job
+func (r *Registry[T, P]) Get(id string) (T, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire()
+
+	st, ok := r.jobs[id]
+	if !ok {
+		var empty T
+		return empty, fmt.Errorf("%s %s not found", r.name, id)
+	}
+
+	return *st, nil
+}
+
+// List return statuses of all jobs ordered by start time
+func (r *Registry[T, P]) List() []T {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire()
+
+	list := make([]T, 0, len(r.jobs))
+	for _, st := range r.jobs {
+		list = append(list, *st)
+	}
+
+	sort.Slice(list, func(a, b int) bool { return P(&list[a]).job().Started.Before(P(&list[b]).job().Started) })
+
+	return list
+}
+
+// Cancel stop running job
+func (r *Registry[T, P]) Cancel(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.jobs[id]; !ok {
+		return fmt.Errorf("%s %s not found", r.name, id)
+	}
+
+	cancel, ok := r.cancels[id]
+	if !ok {
+		return fmt.Errorf("%s %s is not running", r.name, id)
+	}
+
+	cancel()
+	return nil
+}
+
+// expire remove jobs finished earlier than alive duration ago, mutex must be locked
+func (r *Registry[T, P]) expire() {
+	if r.alive == 0 {
+		return
+	}
+
+	for id, st := range r.jobs {
+		if j := st.job(); j.Status != StatusRunning && time.Since(j.Finished) > r.alive {
+			delete(r.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStatus struct {
+	Job
+	Count int
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry[testStatus]("job", 0, 0, 0)
+
+	st, err := r.Start(testStatus{Count: 1}, func(_ context.Context, update func(fn func(st *testStatus))) error {
+		update(func(st *testStatus) { st.Count++ })
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRunning, st.Status)
+	assert.Equal(t, 1, st.Count)
+	assert.NotEmpty(t, st.ID)
+
+	assert.Eventually(t, func() bool {
+		st, err = r.Get(st.ID)
+		return err == nil && st.Status == StatusDone
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, st.Count)
+	assert.False(t, st.Finished.IsZero())
+
+	failed, err := r.Start(testStatus{}, func(context.Context, func(fn func(st *testStatus))) error { return errors.New("no tiles") })
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		failed, err = r.Get(failed.ID)
+		return err == nil && failed.Status == StatusFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "no tiles", failed.Error)
+
+	list := r.List()
+	assert.Len(t, list, 2)
+	assert.Equal(t, st.ID, list[0].ID)
+
+	assert.EqualError(t, r.Cancel(st.ID), "job "+st.ID+" is not running")
+	assert.EqualError(t, r.Cancel("missing"), "job missing not found")
+	_, err = r.Get("missing")
+	assert.EqualError(t, err, "job missing not found")
+}
+
+func TestRegistry_Cancel(t *testing.T) {
+	wait := func(ctx context.Context, _ func(fn func(st *testStatus))) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	// cancelled job isn't failed
+	r := NewRegistry[testStatus]("job", 0, 0, 0)
+	st, err := r.Start(testStatus{}, wait)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Cancel(st.ID))
+	assert.Eventually(t, func() bool {
+		st, err = r.Get(st.ID)
+		return err == nil && st.Status != StatusRunning
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusCancelled, st.Status)
+	assert.Empty(t, st.Error)
+
+	// job exceeded deadline is failed
+	r = NewRegistry[testStatus]("job", 0, time.Millisecond, 0)
+	st, err = r.Start(testStatus{}, wait)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		st, err = r.Get(st.ID)
+		return err == nil && st.Status != StatusRunning
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusFailed, st.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), st.Error)
+}
+
+func TestRegistry_Busy(t *testing.T) {
+	r := NewRegistry[testStatus]("job", time.Millisecond, 0, 1)
+
+	release := make(chan struct{})
+	st, err := r.Start(testStatus{}, func(context.Context, func(fn func(st *testStatus))) error {
+		<-release
+		return nil
+	})
+	assert.NoError(t, err)
+
+	_, err = r.Start(testStatus{}, func(context.Context, func(fn func(st *testStatus))) error { return nil })
+	assert.ErrorIs(t, err, ErrBusy)
+
+	close(release)
+
+	// finished job is removed after alive duration
+	assert.Eventually(t, func() bool {
+		_, err = r.Get(st.ID)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = r.Start(testStatus{}, func(context.Context, func(fn func(st *testStatus))) error { return nil })
+	assert.NoError(t, err)
+}
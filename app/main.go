@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
 	_ "image/png"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"go.etcd.io/bbolt"
+
+	"github.com/superboomer/maptile/app/archive"
+	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/options"
+	"github.com/superboomer/maptile/app/seed"
 	"github.com/superboomer/maptile/app/server"
+	"github.com/superboomer/maptile/app/server/api"
 	"github.com/umputun/go-flags"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -22,6 +30,10 @@ var Version = "dev"
 // @title Map Satellite provider
 // @version 1.0.0
 // @description This is a easy HTTP API which provide map tiles
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description admin token as "Bearer <token>"
 func main() {
 	var Opts = &options.Opts{}
 	p := flags.NewParser(Opts, flags.PrintErrors|flags.PassDoubleDash|flags.HelpFlag)
@@ -37,6 +49,29 @@ func main() {
 
 	logger.Info("build version", zap.String("build", Version))
 
+	if p.Active != nil {
+		command := p.Active.Name
+		if p.Active.Active != nil {
+			command += " " + p.Active.Active.Name
+		}
+
+		switch command {
+		case "cache fsck":
+			if err := runFsck(&Opts.Cache, &Opts.CacheCmd.Fsck, logger); err != nil {
+				logger.Fatal("cache check failed", zap.Error(err))
+			}
+		case "seed":
+			if err := runSeed(Opts, logger); err != nil {
+				logger.Fatal("cache seeding failed", zap.Error(err))
+			}
+		case "import":
+			if err := runImport(&Opts.Cache, &Opts.Import, logger); err != nil {
+				logger.Fatal("cache import failed", zap.Error(err))
+			}
+		}
+		return
+	}
+
 	if err := run(Opts, logger); err != nil {
 		logger.Fatal("fatal error", zap.Error(err))
 	}
@@ -49,6 +84,152 @@ func run(opts *options.Opts, logger *zap.Logger) error {
 	return server.Run(ctx, logger, opts)
 }
 
+// runFsck check integrity of disk cache, cache is opened read-only unless repair is requested
+func runFsck(cacheOpts *options.Cache, fsckOpts *options.Fsck, logger *zap.Logger) error {
+	indexOpts := &bbolt.Options{ReadOnly: !fsckOpts.Repair, Timeout: time.Second}
+
+	c, err := cache.NewCache(cacheOpts.Path, time.Minute*time.Duration(cacheOpts.Alive), indexOpts)
+	if err != nil {
+		return fmt.Errorf("can't load cache: %w", err)
+	}
+	defer c.Close()
+
+	report, err := c.Fsck(fsckOpts.Repair)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range report.Orphans {
+		logger.Warn("orphan file", zap.String("path", path), zap.Bool("removed", report.Repaired))
+	}
+	for _, key := range report.Dangling {
+		logger.Warn("dangling index key", zap.String("key", key), zap.Bool("removed", report.Repaired))
+	}
+	for _, key := range report.Corrupted {
+		logger.Warn("corrupted tile", zap.String("key", key), zap.Bool("removed", report.Repaired))
+	}
+
+	logger.Info("cache checked", zap.String("path", cacheOpts.Path), zap.Int("tiles", report.Checked),
+		zap.Int("orphans", len(report.Orphans)), zap.Int("dangling", len(report.Dangling)),
+		zap.Int("corrupted", len(report.Corrupted)), zap.Int("bad_refs", report.BadRefs), zap.Bool("repaired", report.Repaired))
+
+	if report.Problems() > 0 && !report.Repaired {
+		return fmt.Errorf("cache has %d problems, run with --repair to fix them", report.Problems())
+	}
+
+	return nil
+}
+
+// runSeed download tiles of area to configured cache, progress is saved to state file so interrupted seeding can be resumed
+func runSeed(opts *options.Opts, logger *zap.Logger) error {
+	seedOpts := &opts.Seed
+
+	if opts.Offline {
+		return fmt.Errorf("seeding requires network, it's not possible in offline mode")
+	}
+
+	cacheOpts := opts.Cache
+	cacheOpts.Enable = true
+	cacheOpts.SweepInterval = 0
+
+	a, err := api.CreateAPI(logger, &cacheOpts, opts.Schema, opts.MaxSide)
+	if err != nil {
+		return err
+	}
+	defer a.Cache.Close()
+
+	vendor, err := a.Providers.Get(seedOpts.Provider)
+	if err != nil {
+		return fmt.Errorf("can't find provider: %w", err)
+	}
+
+	area := &seed.Area{}
+	if seedOpts.GeoJSON != "" {
+		data, err := os.ReadFile(seedOpts.GeoJSON)
+		if err != nil {
+			return fmt.Errorf("can't read geojson: %w", err)
+		}
+
+		if area, err = seed.ParseGeoJSON(data); err != nil {
+			return err
+		}
+	}
+
+	if area.BBox, err = seed.ParseBBox(seedOpts.BBox); err != nil {
+		return fmt.Errorf("can't parse bbox: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	job := &seed.Job{Provider: vendor, Area: area, MinZoom: seedOpts.MinZoom, MaxZoom: seedOpts.MaxZoom}
+	seeder := &seed.Seeder{Cache: a.Cache, Downloader: a.Downloader}
+
+	var reported time.Time
+	logProgress := func(p seed.Progress) {
+		logger.Info("cache seeding progress", zap.Int("done", p.Done), zap.Int("total", p.Total), zap.Int("zoom", p.Zoom),
+			zap.Int("resumed", p.Resumed), zap.Int("fresh", p.Fresh), zap.Int("downloaded", p.Downloaded),
+			zap.Int("blank", p.Blank), zap.Int("failed", p.Failed))
+	}
+
+	p, err := seeder.Run(ctx, job, seedOpts.State, func(p seed.Progress) {
+		if time.Since(reported) >= 10*time.Second {
+			reported = time.Now()
+			logProgress(p)
+		}
+	})
+	logProgress(p)
+	if err != nil {
+		return err
+	}
+
+	if p.Failed > 0 {
+		return fmt.Errorf("%d tiles failed, run seeding again to retry them", p.Failed)
+	}
+
+	return nil
+}
+
+// runImport load tiles of MBTiles file or z/x/y directory tree to disk cache as tiles of provider
+func runImport(cacheOpts *options.Cache, importOpts *options.Import, logger *zap.Logger) error {
+	if importOpts.Provider == "" || importOpts.Source == "" {
+		return fmt.Errorf("provider and source must be specified")
+	}
+
+	a, err := archive.Open(importOpts.Source, importOpts.TMS)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// tiles are saved the same way as server does it, but stale tiles are not swept
+	diskOpts := *cacheOpts
+	diskOpts.SweepInterval = 0
+
+	c, err := api.CreateDiskCache(logger, &diskOpts, 0, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("can't load cache: %w", err)
+	}
+	defer c.Close()
+
+	logReport := func(r archive.Report) {
+		logger.Info("cache import progress", zap.String("provider", importOpts.Provider), zap.Int("imported", r.Imported),
+			zap.Int("skipped", r.Skipped), zap.Int("invalid", r.Invalid))
+	}
+
+	r, err := archive.Import(c, importOpts.Provider, a, importOpts.Force, logReport)
+	logReport(r)
+	if err != nil {
+		return err
+	}
+
+	if r.Invalid > 0 {
+		logger.Warn("archive contains data which is not an image", zap.Int("invalid", r.Invalid))
+	}
+
+	return nil
+}
+
 func createLogger(opts *options.Log) *zap.Logger {
 	// Setting up logging to file with rotation.
 	//
@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/options"
+	"github.com/superboomer/maptile/app/tile"
 )
 
 func TestCreateLogger(t *testing.T) {
@@ -67,3 +78,125 @@ func TestRun(t *testing.T) {
 		t.Fatalf("Expected error, got %v", err)
 	}
 }
+
+func TestRunFsck(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheOpts := &options.Cache{Path: tmpDir, Alive: 60}
+
+	// index doesn't exist yet, read-only check fails
+	err := runFsck(cacheOpts, &options.Fsck{}, zap.NewNop())
+	if err == nil {
+		t.Fatalf("Expected error, got %v", err)
+	}
+
+	err = runFsck(cacheOpts, &options.Fsck{Repair: true}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// orphan file is found by check and removed by repair
+	orphan := filepath.Join(tmpDir, "orphan.png")
+	if err = os.WriteFile(orphan, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = runFsck(cacheOpts, &options.Fsck{}, zap.NewNop())
+	if err == nil {
+		t.Fatalf("Expected error, got %v", err)
+	}
+
+	err = runFsck(cacheOpts, &options.Fsck{Repair: true}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err = os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("Expected orphan file %s to be removed", orphan)
+	}
+}
+
+func TestRunSeed(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(img.Bytes())
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	schema := filepath.Join(tmpDir, "providers.json")
+	spec := fmt.Sprintf(`[{"name":"Test","id":"test","max_jobs":2,"max_zoom":5,"proj":"spherical",`+
+		`"request":{"url":"%s/{z}/{x}/{y}"}}]`, ts.URL)
+	if err := os.WriteFile(schema, []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &options.Opts{
+		Schema: schema,
+		Cache:  options.Cache{Path: filepath.Join(tmpDir, "cache"), Alive: 60, Backend: "disk", Eviction: "lru"},
+		Seed: options.Seed{
+			Provider: "test", BBox: "1,1,100,60", MinZoom: 0, MaxZoom: 3, State: filepath.Join(tmpDir, "state.json"),
+		},
+	}
+
+	if err := runSeed(opts, zap.NewNop()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if requests.Load() != 10 {
+		t.Fatalf("Expected 10 requests, got %d", requests.Load())
+	}
+
+	// all tiles are fresh now
+	if err := runSeed(opts, zap.NewNop()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if requests.Load() != 10 {
+		t.Fatalf("Expected 10 requests, got %d", requests.Load())
+	}
+
+	opts.Seed.Provider = "missing"
+	if err := runSeed(opts, zap.NewNop()); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	opts.Seed.Provider = "test"
+	opts.Seed.BBox = "1,2,3"
+	if err := runSeed(opts, zap.NewNop()); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	cacheOpts := &options.Cache{Path: t.TempDir(), Alive: 60}
+
+	err := runImport(cacheOpts, &options.Import{Provider: "test", Source: "archive/testdata/plain.mbtiles"}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	c, err := cache.NewCache(cacheOpts.Path, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.LoadTile("test", &tile.Tile{X: 3, Y: 2, Z: 2}); err != nil {
+		t.Fatalf("Expected imported tile, got %v", err)
+	}
+
+	err = runImport(cacheOpts, &options.Import{Provider: "test", Source: "archive/testdata/missing.mbtiles"}, zap.NewNop())
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if err = runImport(cacheOpts, &options.Import{Source: "archive/testdata/plain.mbtiles"}, zap.NewNop()); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
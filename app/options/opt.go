@@ -8,6 +8,45 @@ type Opts struct {
 	Swagger bool   `long:"swagger" env:"SWAGGER" description:"host swagger docs"`
 	Schema  string `long:"SCHEMA" env:"SCHEMA" description:"providers specs"`
 	MaxSide int    `long:"MAX_SIDE" env:"MAX_SIDE" default:"10" description:"max square side"`
+
+	Offline     bool   `long:"offline" env:"OFFLINE" description:"serve tiles only from cache, upstream is never requested and cache expiration is ignored"`
+	OfflineMiss string `long:"offline-miss" env:"OFFLINE_MISS" default:"404" choice:"404" choice:"placeholder" description:"answer when tiles are not cached in offline mode: 404 with missing tiles or placeholder tiles"`
+
+	AdminToken string `long:"admin-token" env:"ADMIN_TOKEN" description:"bearer token of cache admin endpoints, endpoints are disabled if empty"`
+
+	Watermark Watermark `group:"watermark" namespace:"watermark" env-namespace:"WATERMARK"`
+
+	CacheCmd CacheCmd `command:"cache" description:"cache maintenance commands"`
+	Seed     Seed     `command:"seed" description:"download tiles of area and zoom range to cache"`
+	Import   Import   `command:"import" description:"load tiles from MBTiles file or z/x/y directory tree to cache"`
+}
+
+// CacheCmd represent struct for cache maintenance subcommands
+type CacheCmd struct {
+	Fsck Fsck `command:"fsck" description:"check cache integrity: orphan files, dangling index keys and undecodable images"`
+}
+
+// Fsck represent struct for cache fsck options
+type Fsck struct {
+	Repair bool `long:"repair" description:"remove broken index entries and orphan files"`
+}
+
+// Seed represent struct for seed command options
+type Seed struct {
+	Provider string `long:"provider" description:"tile provider"`
+	BBox     string `long:"bbox" description:"bounding box of seeded area: min_long,min_lat,max_long,max_lat"`
+	GeoJSON  string `long:"geojson" description:"GeoJSON file with polygons of seeded area"`
+	MinZoom  int    `long:"min-zoom" default:"0" description:"min zoom of seeded tiles"`
+	MaxZoom  int    `long:"max-zoom" description:"max zoom of seeded tiles"`
+	State    string `long:"state" default:"./data/seed-state.json" description:"file with seeding progress, interrupted seeding of the same area is resumed from it"`
+}
+
+// Import represent struct for import command options
+type Import struct {
+	Provider string `long:"provider" description:"provider ID which tiles are imported as"`
+	Source   string `long:"source" description:"MBTiles file or z/x/y directory tree"`
+	TMS      bool   `long:"tms" description:"y of directory tree is counted from bottom like in TMS, MBTiles are always flipped"`
+	Force    bool   `long:"force" description:"overwrite cached tiles even if they are newer than archived ones"`
 }
 
 // Cache represent struct for Cache options
@@ -15,6 +54,46 @@ type Cache struct {
 	Enable bool   `long:"enable" env:"ENABLE" description:"enable cache"`
 	Path   string `long:"path" env:"PATH" default:"./data/cache" description:"a path for cache dir"`
 	Alive  int    `long:"alive" env:"ALIVE" default:"14400" description:"cache alive in minutes"`
+
+	StaleWhileRevalidate bool `long:"stale-while-revalidate" env:"STALE_WHILE_REVALIDATE" description:"serve expired tiles immediately and refresh them in background"`
+	MaxStale             int  `long:"max-stale" env:"MAX_STALE" default:"0" description:"serve expired tiles on upstream error up to N minutes after expiration"`
+
+	MaxSize       int    `long:"max-size" env:"MAX_SIZE" default:"0" description:"max cache size in megabytes, 0 is unlimited"`
+	Eviction      string `long:"eviction" env:"EVICTION" default:"lru" choice:"lru" choice:"lfu" description:"eviction policy when max size is exceeded"`
+	SweepInterval int    `long:"sweep-interval" env:"SWEEP_INTERVAL" default:"60" description:"interval of expired tiles sweeping in minutes, 0 disables sweeping"`
+
+	Backend    string `long:"backend" env:"BACKEND" default:"disk" description:"comma separated cache tiers from fastest to slowest: memory, disk, s3"`
+	MemorySize int    `long:"memory-size" env:"MEMORY_SIZE" default:"256" description:"memory cache tier size in megabytes"`
+	S3         S3     `group:"s3" namespace:"s3" env-namespace:"S3"`
+
+	Result Result `group:"result" namespace:"result" env-namespace:"RESULT"`
+}
+
+// Result represent struct for merged images cache options
+type Result struct {
+	Enable  bool `long:"enable" env:"ENABLE" description:"cache merged images of identical map requests"`
+	Alive   int  `long:"alive" env:"ALIVE" default:"10" description:"merged image alive in minutes"`
+	MaxSize int  `long:"max-size" env:"MAX_SIZE" default:"64" description:"max size of cached merged images in megabytes"`
+}
+
+// S3 represent struct for S3-compatible cache tier options
+type S3 struct {
+	Endpoint  string `long:"endpoint" env:"ENDPOINT" description:"s3 endpoint, e.g. https://s3.amazonaws.com"`
+	Bucket    string `long:"bucket" env:"BUCKET" description:"s3 bucket"`
+	Region    string `long:"region" env:"REGION" default:"us-east-1" description:"s3 region"`
+	AccessKey string `long:"access-key" env:"ACCESS_KEY" description:"s3 access key"`
+	SecretKey string `long:"secret-key" env:"SECRET_KEY" description:"s3 secret key"`
+	Prefix    string `long:"prefix" env:"PREFIX" description:"key prefix inside s3 bucket"`
+	PathStyle bool   `long:"path-style" env:"PATH_STYLE" description:"use path-style s3 addressing"`
+}
+
+// Watermark represent struct for attribution watermark options
+type Watermark struct {
+	Enable     bool   `long:"enable" env:"ENABLE" description:"stamp provider attribution onto merged map images"`
+	Position   string `long:"position" env:"POSITION" default:"bottom-right" choice:"top-left" choice:"top-right" choice:"bottom-left" choice:"bottom-right" description:"corner of attribution text"`
+	FontSize   int    `long:"font-size" env:"FONT_SIZE" default:"13" description:"height of attribution text in pixels, rounded to multiple of 13"`
+	Color      string `long:"color" env:"COLOR" default:"#000000" description:"attribution text color: #rgb, #rrggbb or #rrggbbaa"`
+	Background string `long:"background" env:"BACKGROUND" default:"#ffffffb3" description:"attribution background color: #rgb, #rrggbb or #rrggbbaa"`
 }
 
 // Log represent struct for Log options
@@ -0,0 +1,65 @@
+package overlay
+
+import (
+	"math"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// Fit return view of the highest zoom within minZoom..maxZoom where all overlays fit into image of width x height
+// pixels with padding on each side, overlays are centered in the view. Overlays which don't fit even at minZoom
+// are centered at minZoom. Longitudes aren't wrapped, so overlays crossing the antimeridian take the whole world.
+func (o *Overlays) Fit(proj *tile.Elips, width, height, padding, minZoom, maxZoom int) *View {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	extend := func(points [][2]float64) {
+		for _, p := range points {
+			// positions at zoom 0 in pixels, positions of other zooms are scaled by 2^zoom
+			x, y := tile.ConvertToPosition(max(-maxLatitude, min(maxLatitude, p[1])), p[0], 0, proj)
+			minX, maxX = min(minX, x*tile.Size), max(maxX, x*tile.Size)
+			minY, maxY = min(minY, y*tile.Size), max(maxY, y*tile.Size)
+		}
+	}
+
+	for _, m := range o.Markers {
+		extend([][2]float64{m.Point})
+	}
+	for _, p := range o.Paths {
+		extend(p.Points)
+	}
+	for _, p := range o.Polygons {
+		for _, ring := range p.Rings {
+			extend(ring)
+		}
+	}
+
+	zoom := maxZoom
+	for ; zoom > minZoom; zoom-- {
+		scale := math.Pow(2, float64(zoom))
+		if (maxX-minX)*scale <= float64(width-2*padding) && (maxY-minY)*scale <= float64(height-2*padding) {
+			break
+		}
+	}
+
+	scale := math.Pow(2, float64(zoom))
+	if math.IsInf(minX, 1) {
+		// nothing to fit, the view is centered on the world
+		minX, maxX, minY, maxY = tile.Size/2, tile.Size/2, tile.Size/2, tile.Size/2
+	}
+
+	return &View{
+		Proj:     proj,
+		Zoom:     zoom,
+		X:        ((minX+maxX)/2*scale - float64(width)/2) / tile.Size,
+		Y:        ((minY+maxY)/2*scale - float64(height)/2) / tile.Size,
+		TileSize: tile.Size,
+	}
+}
+
+// Bounds return west, south, east and north of image of width x height pixels shown by view
+func (v *View) Bounds(width, height int) [4]float64 {
+	north, west := tile.ConvertFromTile(v.X, v.Y, float64(v.Zoom), v.Proj)
+	south, east := tile.ConvertFromTile(v.X+float64(width)/v.TileSize, v.Y+float64(height)/v.TileSize, float64(v.Zoom), v.Proj)
+	return [4]float64{west, south, east, north}
+}
@@ -0,0 +1,62 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestFit(t *testing.T) {
+	// long -90..90 is 128 pixels at zoom 0 and 512 pixels at zoom 2
+	o := &Overlays{Paths: []Path{{Points: [][2]float64{{-90, 0}, {90, 0}}}}}
+
+	v := o.Fit(&tile.ElipsSpherical, 600, 300, 20, 0, 10)
+	assert.Equal(t, 2, v.Zoom)
+	assert.InDelta(t, 2-300.0/256, v.X, 1e-9)
+	assert.InDelta(t, 2-150.0/256, v.Y, 1e-9)
+	assert.Equal(t, 256.0, v.TileSize)
+
+	// padding doesn't leave enough room for zoom 2
+	v = o.Fit(&tile.ElipsSpherical, 600, 300, 50, 0, 10)
+	assert.Equal(t, 1, v.Zoom)
+
+	// zoom is limited by min and max zoom
+	v = o.Fit(&tile.ElipsSpherical, 600, 300, 50, 2, 10)
+	assert.Equal(t, 2, v.Zoom)
+	v = o.Fit(&tile.ElipsSpherical, 4096, 4096, 0, 0, 2)
+	assert.Equal(t, 2, v.Zoom)
+
+	// height limits the zoom of tall overlays, markers and polygons are fitted too
+	o = &Overlays{
+		Markers:  []Marker{{Point: [2]float64{0, 60}}},
+		Polygons: []Polygon{{Rings: [][][2]float64{{{0, -60}, {1, -60}, {1, -59}}}}},
+	}
+	v = o.Fit(&tile.ElipsSpherical, 1024, 256, 0, 0, 10)
+	assert.Equal(t, 1, v.Zoom)
+	v = o.Fit(&tile.ElipsSpherical, 256, 1024, 0, 0, 10)
+	assert.Equal(t, 3, v.Zoom)
+
+	// a single point gets max zoom and is in the center of image
+	o = &Overlays{Markers: []Marker{{Point: [2]float64{37.61, 55.75}}}}
+	v = o.Fit(&tile.ElipsSpherical, 512, 256, 32, 0, 18)
+	assert.Equal(t, 18, v.Zoom)
+	center := v.pixel(55.75, 37.61)
+	assert.InDelta(t, 256, center.x, 1e-6)
+	assert.InDelta(t, 128, center.y, 1e-6)
+}
+
+func TestView_Bounds(t *testing.T) {
+	bounds := world.Bounds(512, 512)
+	assert.InDelta(t, -180, bounds[0], 1e-9)
+	assert.InDelta(t, -85.0511, bounds[1], 1e-4)
+	assert.InDelta(t, 180, bounds[2], 1e-9)
+	assert.InDelta(t, 85.0511, bounds[3], 1e-4)
+
+	v := &View{Proj: &tile.ElipsSpherical, Zoom: 2, X: 2, Y: 2, TileSize: 128}
+	bounds = v.Bounds(128, 128)
+	assert.InDelta(t, 0, bounds[0], 1e-9)
+	assert.InDelta(t, -66.5133, bounds[1], 1e-4)
+	assert.InDelta(t, 90, bounds[2], 1e-9)
+	assert.InDelta(t, 0, bounds[3], 1e-9)
+}
@@ -0,0 +1,437 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/superboomer/maptile/app/tile"
+	"github.com/superboomer/maptile/app/watermark"
+)
+
+// units of scale bar
+const (
+	ScaleMetric   = "metric"
+	ScaleImperial = "imperial"
+	ScaleBoth     = "both"
+)
+
+// coordinate grids
+const (
+	GridLatLong = "latlong"
+	GridUTM     = "utm"
+)
+
+// MaxCaption is a max length of caption
+const MaxCaption = 200
+
+// Furniture contains cartographic elements drawn over map image
+type Furniture struct {
+	Scale     string    // one of units, scale bar isn't drawn if empty
+	North     bool      // draw north arrow
+	Grid      string    // one of grids, grid isn't drawn if empty
+	Caption   string    // caption in top left corner
+	Timestamp time.Time // drawn under caption if not zero
+}
+
+// colors and sizes of furniture
+var (
+	furnitureText       = color.NRGBA{A: 0xff}
+	furnitureBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xc0}
+	gridHalo            = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}
+	gridLine            = color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xc0}
+)
+
+const (
+	furnitureMargin  = 8   // distance from image edges in pixels
+	textPadding      = 3   // padding of text inside background box in pixels
+	maxScaleWidth    = 200 // max length of scale bar in pixels
+	gridLines        = 4   // approximate count of grid lines across image
+	maxGridLines     = 50  // grid isn't drawn if it's too dense
+	utmGridSamples   = 32  // count of segments of curved UTM grid lines
+	utmBoundsSamples = 16  // count of samples of each image edge to find UTM extent
+	metersInFoot     = 0.3048
+	feetInMile       = 5280
+)
+
+// Empty return true if there is nothing to draw
+func (f *Furniture) Empty() bool {
+	return f == nil || f.Scale == "" && !f.North && f.Grid == "" && f.Caption == "" && f.Timestamp.IsZero()
+}
+
+// Validate check units of scale bar, grid and length of caption
+func (f *Furniture) Validate() error {
+	if f.Scale != "" && f.Scale != ScaleMetric && f.Scale != ScaleImperial && f.Scale != ScaleBoth {
+		return fmt.Errorf("scale %q not supported", f.Scale)
+	}
+	if f.Grid != "" && f.Grid != GridLatLong && f.Grid != GridUTM {
+		return fmt.Errorf("grid %q not supported", f.Grid)
+	}
+	if len([]rune(f.Caption)) > MaxCaption {
+		return fmt.Errorf("caption must not be longer than %d characters", MaxCaption)
+	}
+	return nil
+}
+
+// Draw draw grid, scale bar, north arrow and caption over image in this order
+func (f *Furniture) Draw(dst draw.Image, v *View) {
+	var lines []string
+	if f.Caption != "" {
+		lines = append(lines, wrapText(watermark.Transliterate(f.Caption), (dst.Bounds().Dx()-2*furnitureMargin-2*textPadding)/face.Advance)...)
+	}
+	if !f.Timestamp.IsZero() {
+		lines = append(lines, f.Timestamp.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+
+	switch f.Grid {
+	case GridLatLong:
+		drawLatLongGrid(dst, v)
+	case GridUTM:
+		if zone := drawUTMGrid(dst, v); zone != "" {
+			lines = append(lines, "UTM zone "+zone)
+		}
+	}
+
+	if f.Scale != "" {
+		drawScale(dst, v, f.Scale)
+	}
+
+	if f.North {
+		drawNorthArrow(dst)
+	}
+
+	if len(lines) > 0 {
+		bounds := dst.Bounds()
+		textBox(dst, lines, bounds.Min.X+furnitureMargin, bounds.Min.Y+furnitureMargin)
+	}
+}
+
+// latLong return latitude and longitude of position in image
+func (v *View) latLong(x, y float64) (lat, long float64) {
+	return tile.ConvertFromTile(v.X+x/v.TileSize, v.Y+y/v.TileSize, float64(v.Zoom), v.Proj)
+}
+
+// Resolution return ground resolution at latitude in meters per pixel, it's the length of pixel along the parallel
+// of ellipsoid of the projection
+func (v *View) Resolution(lat float64) float64 {
+	phi := lat * math.Pi / 180
+	e := v.Proj.Eccentricity
+	return 2 * math.Pi * tile.EarthRadius / (v.TileSize * math.Pow(2, float64(v.Zoom))) *
+		math.Cos(phi) / math.Sqrt(1-e*e*math.Sin(phi)*math.Sin(phi))
+}
+
+// niceNumber return the largest number 1, 2 or 5 multiplied by power of ten which isn't greater than value
+func niceNumber(value float64) float64 {
+	if !(value > 0) || math.IsInf(value, 1) {
+		return 0
+	}
+
+	power := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, m := range []float64{5, 2, 1} {
+		if m*power <= value {
+			return m * power
+		}
+	}
+	return power
+}
+
+// formatNumber format number without trailing zeros
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// ScaleBar is a bar of scale with its length in pixels
+type ScaleBar struct {
+	Label string
+	Width float64
+}
+
+// ScaleBars return bars of units for resolution in meters per pixel, bars aren't longer than maxWidth
+func ScaleBars(units string, resolution, maxWidth float64) []ScaleBar {
+	var bars []ScaleBar
+
+	if units == ScaleMetric || units == ScaleBoth {
+		if meters := niceNumber(resolution * maxWidth); meters >= 1000 {
+			bars = append(bars, ScaleBar{Label: formatNumber(meters/1000) + " km", Width: meters / resolution})
+		} else if meters > 0 {
+			bars = append(bars, ScaleBar{Label: formatNumber(meters) + " m", Width: meters / resolution})
+		}
+	}
+
+	if units == ScaleImperial || units == ScaleBoth {
+		feet := resolution * maxWidth / metersInFoot
+		if miles := niceNumber(feet / feetInMile); miles >= 1 {
+			bars = append(bars, ScaleBar{Label: formatNumber(miles) + " mi", Width: miles * feetInMile * metersInFoot / resolution})
+		} else if feet = niceNumber(feet); feet > 0 {
+			bars = append(bars, ScaleBar{Label: formatNumber(feet) + " ft", Width: feet * metersInFoot / resolution})
+		}
+	}
+
+	return bars
+}
+
+// drawScale draw scale bars of ground resolution at image center in bottom left corner
+func drawScale(dst draw.Image, v *View, units string) {
+	bounds := dst.Bounds()
+	lat, _ := v.latLong(float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2)
+
+	bars := ScaleBars(units, v.Resolution(lat), float64(min(maxScaleWidth, bounds.Dx()/3)))
+	if len(bars) == 0 {
+		return
+	}
+
+	var barWidth, labelWidth int
+	for _, b := range bars {
+		barWidth = max(barWidth, int(math.Ceil(b.Width)))
+		labelWidth = max(labelWidth, face.Advance*len(b.Label))
+	}
+
+	rowHeight := face.Height + 2*textPadding
+	box := image.Rect(0, 0, barWidth+labelWidth+4*textPadding, rowHeight*len(bars)).
+		Add(image.Pt(bounds.Min.X+furnitureMargin, bounds.Max.Y-furnitureMargin-rowHeight*len(bars)))
+	draw.Draw(dst, box, image.NewUniform(furnitureBackground), image.Point{}, draw.Over)
+
+	for i, b := range bars {
+		x := float64(box.Min.X + textPadding)
+		y := float64(box.Min.Y + i*rowHeight + rowHeight/2)
+
+		stroke(dst, []point{{x, y - 4}, {x, y}, {x + b.Width, y}, {x + b.Width, y - 4}}, false, 2, furnitureText)
+		drawText(dst, b.Label, box.Min.X+barWidth+3*textPadding, int(y)+(face.Ascent-face.Descent)/2)
+	}
+}
+
+// drawNorthArrow draw arrow pointing up in top right corner, north is up in mercator projections
+func drawNorthArrow(dst draw.Image) {
+	bounds := dst.Bounds()
+	box := image.Rect(bounds.Max.X-furnitureMargin-24, bounds.Min.Y+furnitureMargin,
+		bounds.Max.X-furnitureMargin, bounds.Min.Y+furnitureMargin+40)
+	draw.Draw(dst, box, image.NewUniform(furnitureBackground), image.Point{}, draw.Over)
+
+	cx := float64(box.Min.X+box.Max.X) / 2
+	drawText(dst, "N", int(cx)-face.Advance/2, box.Min.Y+textPadding+face.Ascent)
+
+	top := float64(box.Min.Y + textPadding + face.Height + 2)
+	fill(dst, [][]point{{{cx, top}, {cx + 7, top + 20}, {cx, top + 15}, {cx - 7, top + 20}}}, furnitureText)
+}
+
+// gridStep return step of grid in degrees, it's 1, 2 or 5 multiplied by power of ten or a divisor of 90
+func gridStep(span float64) float64 {
+	step := span / gridLines
+	if step >= 10 {
+		for _, s := range []float64{90, 45, 30, 15, 10} {
+			if s <= step {
+				return s
+			}
+		}
+	}
+	return niceNumber(step)
+}
+
+// formatDegrees format coordinate of grid line with precision of step and hemisphere letter,
+// zero and antimeridian have no letter
+func formatDegrees(value, step float64, positive, negative string) string {
+	precision := max(0, int(math.Ceil(-math.Log10(step)-1e-9)))
+	text := strconv.FormatFloat(math.Abs(value), 'f', precision, 64)
+
+	switch {
+	case math.Abs(value) < step/2:
+		return strconv.FormatFloat(0, 'f', precision, 64)
+	case math.Abs(value) > 180-step/2:
+		return strconv.FormatFloat(180, 'f', precision, 64)
+	case value > 0:
+		return text + positive
+	default:
+		return text + negative
+	}
+}
+
+// drawGridLine draw line of grid with halo, so it's visible on both light and dark maps
+func drawGridLine(dst draw.Image, line []point) {
+	stroke(dst, line, false, 3, gridHalo)
+	stroke(dst, line, false, 1, gridLine)
+}
+
+// drawLatLongGrid draw meridians and parallels with labels of longitudes at top edge and latitudes at left edge
+func drawLatLongGrid(dst draw.Image, v *View) {
+	bounds := dst.Bounds()
+	north, west := v.latLong(float64(bounds.Min.X), float64(bounds.Min.Y))
+	south, east := v.latLong(float64(bounds.Max.X), float64(bounds.Max.Y))
+
+	step := gridStep(east - west)
+	if step == 0 || (east-west)/step > maxGridLines || (north-south)/step > maxGridLines {
+		return
+	}
+
+	top, left := float64(bounds.Min.Y), float64(bounds.Min.X)
+	bottom, right := float64(bounds.Max.Y), float64(bounds.Max.X)
+
+	for long := math.Ceil(west/step) * step; long <= east; long += step {
+		x := v.pixel(0, long).x
+		drawGridLine(dst, []point{{x, top}, {x, bottom}})
+
+		// longitudes of copies of the world are shown in range -180..180
+		edgeLabel(dst, formatDegrees(math.Remainder(long, 360), step, "E", "W"), int(x)+2, bounds.Min.Y)
+	}
+
+	for lat := math.Ceil(max(south, -maxLatitude)/step) * step; lat <= min(north, maxLatitude); lat += step {
+		y := v.pixel(lat, 0).y
+		drawGridLine(dst, []point{{left, y}, {right, y}})
+		edgeLabel(dst, formatDegrees(lat, step, "N", "S"), bounds.Min.X, int(y)+2)
+	}
+}
+
+// drawUTMGrid draw grid of UTM zone of image center with labels of eastings at top edge and northings at left edge,
+// it returns the zone or empty string if the grid isn't drawn
+func drawUTMGrid(dst draw.Image, v *View) string {
+	bounds := dst.Bounds()
+	lat, long := v.latLong(float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2)
+	if math.Abs(lat) > utmMaxLatAbs {
+		return ""
+	}
+
+	zone, south := utmZone(long), lat < 0
+
+	// extent of image in UTM coordinates is found by samples of its edges as lines of grid are curved
+	minE, minN := math.Inf(1), math.Inf(1)
+	maxE, maxN := math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= utmBoundsSamples; i++ {
+		k := float64(i) / utmBoundsSamples
+		x := float64(bounds.Min.X) + k*float64(bounds.Dx())
+		y := float64(bounds.Min.Y) + k*float64(bounds.Dy())
+		edges := []point{{x, float64(bounds.Min.Y)}, {x, float64(bounds.Max.Y)}, {float64(bounds.Min.X), y}, {float64(bounds.Max.X), y}}
+		for _, p := range edges {
+			pLat, pLong := v.latLong(p.x, p.y)
+			e, n := toUTM(max(-utmMaxLatAbs, min(utmMaxLatAbs, pLat)), pLong, zone, south)
+			minE, maxE = min(minE, e), max(maxE, e)
+			minN, maxN = min(minN, n), max(maxN, n)
+		}
+	}
+
+	step := niceNumber((maxE - minE) / gridLines)
+	if step < 1 || (maxE-minE)/step > maxGridLines || (maxN-minN)/step > maxGridLines {
+		return ""
+	}
+
+	trace := func(fixed float64, from, to float64, easting bool) []point {
+		line := make([]point, 0, utmGridSamples+1)
+		for i := 0; i <= utmGridSamples; i++ {
+			moving := from + (to-from)*float64(i)/utmGridSamples
+			var pLat, pLong float64
+			if easting {
+				pLat, pLong = fromUTM(fixed, moving, zone, south)
+			} else {
+				pLat, pLong = fromUTM(moving, fixed, zone, south)
+			}
+			line = append(line, v.pixel(pLat, pLong))
+		}
+		return line
+	}
+
+	for e := math.Ceil(minE/step) * step; e <= maxE; e += step {
+		line := trace(e, minN, maxN, true)
+		drawGridLine(dst, line)
+		if x, ok := crossing(line, float64(bounds.Min.Y), false); ok {
+			edgeLabel(dst, formatNumber(e)+"mE", int(x)+2, bounds.Min.Y)
+		}
+	}
+
+	for n := math.Ceil(minN/step) * step; n <= maxN; n += step {
+		line := trace(n, minE, maxE, false)
+		drawGridLine(dst, line)
+		if y, ok := crossing(line, float64(bounds.Min.X), true); ok {
+			edgeLabel(dst, formatNumber(n)+"mN", bounds.Min.X, int(y)+2)
+		}
+	}
+
+	hemisphere := "N"
+	if south {
+		hemisphere = "S"
+	}
+	return strconv.Itoa(zone) + hemisphere
+}
+
+// crossing return position where line crosses horizontal line y = value or vertical line x = value
+func crossing(line []point, value float64, vertical bool) (float64, bool) {
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		if vertical {
+			a, b = point{a.y, a.x}, point{b.y, b.x}
+		}
+		if (a.y-value)*(b.y-value) > 0 || a.y == b.y {
+			continue
+		}
+		return a.x + (b.x-a.x)*(value-a.y)/(b.y-a.y), true
+	}
+	return 0, false
+}
+
+// wrapText split text into lines of width characters at most, words longer than width are split too
+func wrapText(text string, width int) []string {
+	width = max(1, width)
+
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		for len(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// edgeLabel draw label of grid line at image edge, labels which don't fit into image are skipped
+func edgeLabel(dst draw.Image, label string, x, y int) {
+	bounds := dst.Bounds()
+	if x+face.Advance*len(label)+2*textPadding > bounds.Max.X || y+face.Height+2*textPadding > bounds.Max.Y {
+		return
+	}
+	textBox(dst, []string{label}, x, y)
+}
+
+// textBox draw lines of text on background box with top left corner at x, y
+func textBox(dst draw.Image, lines []string, x, y int) {
+	var width int
+	for _, line := range lines {
+		width = max(width, face.Advance*len(line))
+	}
+
+	box := image.Rect(x, y, x+width+2*textPadding, y+len(lines)*face.Height+2*textPadding)
+	draw.Draw(dst, box, image.NewUniform(furnitureBackground), image.Point{}, draw.Over)
+
+	for i, line := range lines {
+		drawText(dst, line, x+textPadding, y+textPadding+i*face.Height+face.Ascent)
+	}
+}
+
+// drawText draw text with baseline at x, y
+func drawText(dst draw.Image, text string, x, y int) {
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(furnitureText), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
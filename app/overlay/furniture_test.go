@@ -0,0 +1,139 @@
+package overlay
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// darkPixels return count of dark pixels in rectangle of image
+func darkPixels(img *image.RGBA, r image.Rectangle) int {
+	var count int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c := img.RGBAAt(x, y); int(c.R)+int(c.G)+int(c.B) < 3*0x80 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// paintedPixels return count of pixels of rectangle of image which aren't white
+func paintedPixels(img *image.RGBA, r image.Rectangle) int {
+	var count int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.RGBAAt(x, y) != white {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestDrawFurniture(t *testing.T) {
+	img := whiteImage(512, 512)
+	f := &Furniture{Scale: ScaleBoth, North: true, Grid: GridLatLong, Caption: "Incident №1",
+		Timestamp: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+	f.Draw(img, world)
+
+	// caption and timestamp are in top left corner, scale bars in bottom left and north arrow in top right
+	assert.Greater(t, darkPixels(img, image.Rect(8, 8, 150, 40)), 50)
+	assert.Greater(t, darkPixels(img, image.Rect(8, 470, 200, 504)), 100)
+	assert.Greater(t, darkPixels(img, image.Rect(480, 30, 504, 48)), 50)
+	assert.Equal(t, 0, paintedPixels(img, image.Rect(300, 300, 380, 380)))
+
+	// meridians and parallels go through the whole image, step of zoom 1 world is 90 degrees
+	assert.GreaterOrEqual(t, paintedPixels(img, image.Rect(255, 300, 258, 400)), 200)
+	assert.GreaterOrEqual(t, paintedPixels(img, image.Rect(400, 255, 500, 258)), 200)
+	assert.GreaterOrEqual(t, paintedPixels(img, image.Rect(383, 300, 386, 400)), 200)
+}
+
+func TestDrawUTMGrid(t *testing.T) {
+	// 512x512 image of zoom 12 around long 37.6, lat 55.75
+	x, y := tile.ConvertToPosition(55.75, 37.6, 12, &tile.ElipsSpherical)
+	v := &View{Proj: &tile.ElipsSpherical, Zoom: 12, X: x - 1, Y: y - 1, TileSize: 256}
+
+	img := whiteImage(512, 512)
+	assert.Equal(t, "37N", drawUTMGrid(img, v))
+	assert.Greater(t, paintedPixels(img, img.Rect), 512*8)
+
+	// UTM grid isn't defined near the poles
+	img = whiteImage(512, 512)
+	x, y = tile.ConvertToPosition(85, 37.6, 12, &tile.ElipsSpherical)
+	assert.Equal(t, "", drawUTMGrid(img, &View{Proj: &tile.ElipsSpherical, Zoom: 12, X: x - 1, Y: y - 1, TileSize: 256}))
+	assert.Equal(t, 0, paintedPixels(img, img.Rect))
+
+	// zone is added to caption
+	img = whiteImage(512, 512)
+	(&Furniture{Grid: GridUTM}).Draw(img, v)
+	assert.Greater(t, darkPixels(img, image.Rect(8, 8, 100, 24)), 50)
+}
+
+func TestResolution(t *testing.T) {
+	assert.InDelta(t, 156543.03, (&View{Proj: &tile.ElipsSpherical, TileSize: 256}).Resolution(0), 0.01)
+	assert.InDelta(t, 78271.52, (&View{Proj: &tile.ElipsSpherical, TileSize: 256}).Resolution(60), 0.01)
+	assert.InDelta(t, 0.2986, (&View{Proj: &tile.ElipsSpherical, Zoom: 19, TileSize: 512}).Resolution(0)*2, 0.0001)
+
+	// parallels of ellipsoid are longer than parallels of sphere
+	assert.InDelta(t, 78468.75, (&View{Proj: &tile.ElipsWGS84, TileSize: 256}).Resolution(60), 0.1)
+}
+
+func TestScaleBars(t *testing.T) {
+	assert.Equal(t, []ScaleBar{{Label: "200 m", Width: 200}, {Label: "500 ft", Width: 152.4}}, ScaleBars(ScaleBoth, 1, 200))
+	assert.Equal(t, []ScaleBar{{Label: "20 km", Width: 200}}, ScaleBars(ScaleMetric, 100, 200))
+
+	bars := ScaleBars(ScaleImperial, 100, 200)
+	assert.Len(t, bars, 1)
+	assert.Equal(t, "10 mi", bars[0].Label)
+	assert.InDelta(t, 160.9344, bars[0].Width, 1e-9)
+
+	assert.Empty(t, ScaleBars(ScaleMetric, 0, 200))
+}
+
+func TestNiceNumber(t *testing.T) {
+	assert.Equal(t, 5.0, niceNumber(7))
+	assert.Equal(t, 200.0, niceNumber(499))
+	assert.Equal(t, 1000.0, niceNumber(1000))
+	assert.InDelta(t, 0.02, niceNumber(0.03), 1e-12)
+	assert.Equal(t, 0.0, niceNumber(0))
+}
+
+func TestGridStep(t *testing.T) {
+	assert.Equal(t, 90.0, gridStep(360))
+	assert.Equal(t, 30.0, gridStep(150))
+	assert.Equal(t, 5.0, gridStep(30))
+	assert.InDelta(t, 0.005, gridStep(0.03), 1e-12)
+}
+
+func TestFormatDegrees(t *testing.T) {
+	assert.Equal(t, "55.75N", formatDegrees(55.75, 0.05, "N", "S"))
+	assert.Equal(t, "37.600E", formatDegrees(37.6, 0.005, "E", "W"))
+	assert.Equal(t, "90W", formatDegrees(-90, 90, "E", "W"))
+	assert.Equal(t, "0", formatDegrees(1e-12, 5, "E", "W"))
+	assert.Equal(t, "180", formatDegrees(-180, 90, "E", "W"))
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, []string{"Flood at", "river", "bank"}, wrapText("Flood at  river bank", 8))
+	assert.Equal(t, []string{"abc", "abcdef", "ghij"}, wrapText("abc abcdefghij", 6))
+	assert.Empty(t, wrapText("  ", 10))
+	assert.Equal(t, []string{"a"}, wrapText("a", 0))
+}
+
+func TestFurniture_Validate(t *testing.T) {
+	assert.NoError(t, (&Furniture{Scale: ScaleMetric, Grid: GridUTM}).Validate())
+	assert.EqualError(t, (&Furniture{Scale: "nautical"}).Validate(), `scale "nautical" not supported`)
+	assert.EqualError(t, (&Furniture{Grid: "mgrs"}).Validate(), `grid "mgrs" not supported`)
+	assert.EqualError(t, (&Furniture{Caption: strings.Repeat("a", 201)}).Validate(), "caption must not be longer than 200 characters")
+
+	var f *Furniture
+	assert.True(t, f.Empty())
+	assert.True(t, (&Furniture{}).Empty())
+	assert.False(t, (&Furniture{North: true}).Empty())
+}
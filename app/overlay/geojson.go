@@ -0,0 +1,117 @@
+package overlay
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/superboomer/maptile/app/geojson"
+)
+
+// style of GeoJSON feature
+type style struct {
+	Marker        color.NRGBA
+	Label         string
+	Stroke        color.NRGBA
+	StrokeOpacity float64
+	Width         float64
+	Fill          color.NRGBA
+	FillOpacity   float64
+}
+
+// defaultStyle is a style of simplestyle-spec
+var defaultStyle = style{
+	Marker:        DefaultMarker,
+	Stroke:        DefaultStroke,
+	StrokeOpacity: 1,
+	Width:         DefaultWidth,
+	Fill:          color.NRGBA{R: DefaultFill.R, G: DefaultFill.G, B: DefaultFill.B, A: 0xff},
+	FillOpacity:   0.6,
+}
+
+// path return path of line with style
+func (s *style) path(line [][2]float64) Path {
+	return Path{Points: line, Color: WithOpacity(s.Stroke, s.StrokeOpacity), Width: s.Width}
+}
+
+// polygon return polygon of rings with style
+func (s *style) polygon(rings [][][2]float64) Polygon {
+	return Polygon{Rings: rings, Color: WithOpacity(s.Stroke, s.StrokeOpacity), Width: s.Width, Fill: WithOpacity(s.Fill, s.FillOpacity)}
+}
+
+// ParseGeoJSON parse overlays from GeoJSON geometry, Feature or FeatureCollection. Points are drawn as markers,
+// lines as paths and polygons as polygons, features are styled with simplestyle-spec properties:
+// marker-color, marker-symbol (label), stroke, stroke-width, stroke-opacity, fill and fill-opacity.
+func ParseGeoJSON(data []byte) (*Overlays, error) {
+	g, err := geojson.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Overlays{}
+	err = g.Walk(func(g *geojson.Geometry, properties map[string]any) error {
+		s, err := parseStyle(properties, &defaultStyle)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range g.Points {
+			o.Markers = append(o.Markers, Marker{Point: p, Color: s.Marker, Label: s.Label})
+		}
+		for _, line := range g.Lines {
+			o.Paths = append(o.Paths, s.path(line))
+		}
+		for _, polygon := range g.Polygons {
+			o.Polygons = append(o.Polygons, s.polygon(polygon))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// parseStyle return style of feature properties, missing properties are taken from defaults
+func parseStyle(properties map[string]any, defaults *style) (*style, error) {
+	s := *defaults
+
+	colorProperty := func(name string, value *color.NRGBA) error {
+		v, ok := properties[name].(string)
+		if !ok {
+			return nil
+		}
+
+		c, err := ParseColor(v)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		*value = c
+		return nil
+	}
+
+	if err := colorProperty("marker-color", &s.Marker); err != nil {
+		return nil, err
+	}
+	if err := colorProperty("stroke", &s.Stroke); err != nil {
+		return nil, err
+	}
+	if err := colorProperty("fill", &s.Fill); err != nil {
+		return nil, err
+	}
+
+	if v, ok := properties["marker-symbol"].(string); ok {
+		s.Label = v
+	}
+	if v, ok := properties["stroke-width"].(float64); ok {
+		s.Width = v
+	}
+	if v, ok := properties["stroke-opacity"].(float64); ok {
+		s.StrokeOpacity = v
+	}
+	if v, ok := properties["fill-opacity"].(float64); ok {
+		s.FillOpacity = v
+	}
+
+	return &s, nil
+}
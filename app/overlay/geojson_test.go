@@ -0,0 +1,61 @@
+package overlay
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeoJSON(t *testing.T) {
+	o, err := ParseGeoJSON([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"marker-color":"#f00","marker-symbol":"A"},"geometry":{"type":"Point","coordinates":[37.6,55.7,120]}},
+		{"type":"Feature","properties":{"stroke":"#0000ff","stroke-width":4,"stroke-opacity":0.5},
+			"geometry":{"type":"MultiLineString","coordinates":[[[37.6,55.7],[37.7,55.8]],[[37.5,55.6],[37.6,55.7]]]}},
+		{"type":"Feature","properties":{"fill":"green","fill-opacity":1,"stroke-width":0},
+			"geometry":{"type":"GeometryCollection","geometries":[
+				{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]},
+				{"type":"MultiPoint","coordinates":[[1,1],[2,2]]}
+			]}},
+		{"type":"Feature","properties":null,"geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}},
+		{"type":"Feature","properties":{},"geometry":null}
+	]}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Marker{
+		{Point: [2]float64{37.6, 55.7}, Color: color.NRGBA{R: 0xff, A: 0xff}, Label: "A"},
+		{Point: [2]float64{1, 1}, Color: DefaultMarker},
+		{Point: [2]float64{2, 2}, Color: DefaultMarker},
+	}, o.Markers)
+
+	assert.Equal(t, []Path{
+		{Points: [][2]float64{{37.6, 55.7}, {37.7, 55.8}}, Color: color.NRGBA{B: 0xff, A: 0x80}, Width: 4},
+		{Points: [][2]float64{{37.5, 55.6}, {37.6, 55.7}}, Color: color.NRGBA{B: 0xff, A: 0x80}, Width: 4},
+	}, o.Paths)
+
+	assert.Equal(t, []Polygon{
+		{Rings: [][][2]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, Color: DefaultStroke, Width: 0, Fill: colors["green"]},
+		{Rings: [][][2]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, Color: DefaultStroke, Width: DefaultWidth, Fill: DefaultFill},
+	}, o.Polygons)
+
+	assert.NoError(t, o.Validate())
+}
+
+func TestParseGeoJSON_Errors(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`[`, "can't decode geojson"},
+		{`{"type":"Circle"}`, `geojson type "Circle" not supported`},
+		{`{"type":"Point","coordinates":"1,2"}`, "can't decode point"},
+		{`{"type":"LineString","coordinates":[1,2]}`, "can't decode linestring"},
+		{`{"type":"Polygon","coordinates":[[1,2]]}`, "can't decode polygon"},
+		{`{"type":"Feature","properties":{"stroke":"#12"},"geometry":{"type":"Point","coordinates":[1,2]}}`, "property stroke: invalid color"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseGeoJSON([]byte(tt.data))
+		assert.ErrorContains(t, err, tt.err, tt.data)
+	}
+}
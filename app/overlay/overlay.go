@@ -0,0 +1,250 @@
+// Package overlay draws markers, paths, polygons and cartographic furniture onto map images
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/superboomer/maptile/app/tile"
+	"github.com/superboomer/maptile/app/watermark"
+)
+
+// icons of markers
+const (
+	IconPin    = "pin"
+	IconCircle = "circle"
+	IconSquare = "square"
+)
+
+// default styles, they follow simplestyle-spec
+var (
+	DefaultMarker = color.NRGBA{R: 0x7e, G: 0x7e, B: 0x7e, A: 0xff}
+	DefaultStroke = color.NRGBA{R: 0x55, G: 0x55, B: 0x55, A: 0xff}
+	DefaultFill   = color.NRGBA{R: 0x55, G: 0x55, B: 0x55, A: 0x99}
+)
+
+// DefaultWidth is a width of paths and outlines of polygons in pixels
+const DefaultWidth = 2
+
+// MaxWidth is a max width of paths and outlines of polygons in pixels
+const MaxWidth = 64
+
+// MaxMarkers is a max count of markers, it bounds drawing time of image
+const MaxMarkers = 1000
+
+// MaxPoints is a max count of points of all paths and polygons, it bounds drawing time of image
+const MaxPoints = 100000
+
+// Marker is an icon with optional label, coordinates are [long, lat] like in GeoJSON
+type Marker struct {
+	Point [2]float64
+	Icon  string // one of icons, pin if empty
+	Color color.NRGBA
+	Label string
+}
+
+// Path is a polyline, coordinates are [long, lat] like in GeoJSON
+type Path struct {
+	Points [][2]float64
+	Color  color.NRGBA
+	Width  float64
+}
+
+// Polygon is a filled polygon with outline, first ring is outer, others are holes
+type Polygon struct {
+	Rings [][][2]float64
+	Color color.NRGBA // color of outline
+	Width float64     // width of outline, outline isn't drawn if zero
+	Fill  color.NRGBA
+}
+
+// Overlays contains everything drawn over map image
+type Overlays struct {
+	Markers  []Marker
+	Paths    []Path
+	Polygons []Polygon
+}
+
+// Empty return true if there is nothing to draw
+func (o *Overlays) Empty() bool {
+	return o == nil || len(o.Markers) == 0 && len(o.Paths) == 0 && len(o.Polygons) == 0
+}
+
+// Add append overlays of other
+func (o *Overlays) Add(other *Overlays) {
+	o.Markers = append(o.Markers, other.Markers...)
+	o.Paths = append(o.Paths, other.Paths...)
+	o.Polygons = append(o.Polygons, other.Polygons...)
+}
+
+// Validate check coordinates, icons and widths of overlays
+func (o *Overlays) Validate() error {
+	if len(o.Markers) > MaxMarkers {
+		return fmt.Errorf("more than %d markers", MaxMarkers)
+	}
+
+	points := 0
+	for _, p := range o.Paths {
+		points += len(p.Points)
+	}
+	for _, p := range o.Polygons {
+		for _, ring := range p.Rings {
+			points += len(ring)
+		}
+	}
+	if points > MaxPoints {
+		return fmt.Errorf("more than %d points of paths and polygons", MaxPoints)
+	}
+
+	for i, m := range o.Markers {
+		if err := validPoints([][2]float64{m.Point}); err != nil {
+			return fmt.Errorf("marker %d: %w", i, err)
+		}
+		if m.Icon != "" && m.Icon != IconPin && m.Icon != IconCircle && m.Icon != IconSquare {
+			return fmt.Errorf("marker %d: icon %q not supported", i, m.Icon)
+		}
+	}
+
+	for i, p := range o.Paths {
+		if len(p.Points) < 2 {
+			return fmt.Errorf("path %d: must contain at least 2 points", i)
+		}
+		if err := validPoints(p.Points); err != nil {
+			return fmt.Errorf("path %d: %w", i, err)
+		}
+		if !(p.Width >= 0 && p.Width <= MaxWidth) {
+			return fmt.Errorf("path %d: width must be within 0..%d", i, MaxWidth)
+		}
+	}
+
+	for i, p := range o.Polygons {
+		if len(p.Rings) == 0 {
+			return fmt.Errorf("polygon %d: must contain at least 1 ring", i)
+		}
+		for _, ring := range p.Rings {
+			if len(ring) < 3 {
+				return fmt.Errorf("polygon %d: rings must contain at least 3 points", i)
+			}
+			if err := validPoints(ring); err != nil {
+				return fmt.Errorf("polygon %d: %w", i, err)
+			}
+		}
+		if !(p.Width >= 0 && p.Width <= MaxWidth) {
+			return fmt.Errorf("polygon %d: width must be within 0..%d", i, MaxWidth)
+		}
+	}
+
+	return nil
+}
+
+// validPoints check that coordinates are in range, NaN is out of any range
+func validPoints(points [][2]float64) error {
+	for _, p := range points {
+		if !(p[0] >= -180 && p[0] <= 180 && p[1] >= -90 && p[1] <= 90) {
+			return fmt.Errorf("coordinates %v, %v are out of range", p[1], p[0])
+		}
+	}
+	return nil
+}
+
+// View is a part of tiles grid shown in image
+type View struct {
+	Proj     *tile.Elips
+	Zoom     int
+	X, Y     float64 // position in tiles of top left corner of image
+	TileSize float64 // size of tile in pixels
+}
+
+// maxLatitude keeps mercator y of poles finite
+const maxLatitude = 89.9
+
+// pixel return position of point in image
+func (v *View) pixel(lat, long float64) point {
+	x, y := tile.ConvertToPosition(max(-maxLatitude, min(maxLatitude, lat)), long, float64(v.Zoom), v.Proj)
+	return point{x: (x - v.X) * v.TileSize, y: (y - v.Y) * v.TileSize}
+}
+
+// wrap return multiple of 360 which is added to longitude to draw it on the copy of the world closest to image center,
+// whole geometry is shifted by the offset of its first point
+func (v *View) wrap(long float64, bounds image.Rectangle) float64 {
+	center := (v.X+float64(bounds.Min.X+bounds.Max.X)/2/v.TileSize)/math.Pow(2, float64(v.Zoom))*360 - 180
+	return 360 * math.Round((center-long)/360)
+}
+
+// pixels return positions of points in image, longitudes are shifted by offset
+func (v *View) pixels(points [][2]float64, offset float64) []point {
+	result := make([]point, 0, len(points))
+	for _, p := range points {
+		result = append(result, v.pixel(p[1], p[0]+offset))
+	}
+	return result
+}
+
+// Draw draw polygons, paths and markers over image in this order
+func (o *Overlays) Draw(dst draw.Image, v *View) {
+	bounds := dst.Bounds()
+
+	for _, p := range o.Polygons {
+		offset := v.wrap(p.Rings[0][0][0], bounds)
+
+		rings := make([][]point, 0, len(p.Rings))
+		for i, ring := range p.Rings {
+			// holes are cut out by opposite winding
+			rings = append(rings, orient(v.pixels(ring, offset), i == 0))
+		}
+
+		fill(dst, rings, p.Fill)
+		if p.Width > 0 {
+			for _, ring := range rings {
+				stroke(dst, ring, true, p.Width, p.Color)
+			}
+		}
+	}
+
+	for _, p := range o.Paths {
+		stroke(dst, v.pixels(p.Points, v.wrap(p.Points[0][0], bounds)), false, p.Width, p.Color)
+	}
+
+	for i := range o.Markers {
+		m := &o.Markers[i]
+		drawMarker(dst, v.pixel(m.Point[1], m.Point[0]+v.wrap(m.Point[0], bounds)), m)
+	}
+}
+
+// colors is a palette of named colors
+var colors = map[string]color.NRGBA{
+	"black":  {A: 0xff},
+	"white":  {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"gray":   {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	"red":    {R: 0xe5, G: 0x39, B: 0x35, A: 0xff},
+	"orange": {R: 0xfb, G: 0x8c, B: 0x00, A: 0xff},
+	"yellow": {R: 0xfd, G: 0xd8, B: 0x35, A: 0xff},
+	"green":  {R: 0x43, G: 0xa0, B: 0x47, A: 0xff},
+	"blue":   {R: 0x1e, G: 0x88, B: 0xe5, A: 0xff},
+	"purple": {R: 0x8e, G: 0x24, B: 0xaa, A: 0xff},
+	"brown":  {R: 0x6d, G: 0x4c, B: 0x41, A: 0xff},
+}
+
+// ParseColor parse named color or hex color #rgb, #rrggbb or #rrggbbaa, 0x prefix is allowed instead of #
+func ParseColor(s string) (color.NRGBA, error) {
+	if c, ok := colors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	c, err := watermark.ParseColor(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+
+	return c.(color.NRGBA), nil
+}
+
+// WithOpacity return color with alpha multiplied by opacity in range 0..1
+func WithOpacity(c color.NRGBA, opacity float64) color.NRGBA {
+	c.A = uint8(math.Round(float64(c.A) * max(0, min(1, opacity))))
+	return c
+}
@@ -0,0 +1,200 @@
+package overlay
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+var (
+	red   = color.NRGBA{R: 0xff, A: 0xff}
+	blue  = color.NRGBA{B: 0xff, A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+func whiteImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	return img
+}
+
+// world is a view of the whole world at zoom 1 in 512x512 image, long 0 and lat 0 are in its center
+var world = &View{Proj: &tile.ElipsSpherical, Zoom: 1, TileSize: 256}
+
+func TestDrawMarkers(t *testing.T) {
+	img := whiteImage(512, 512)
+	o := &Overlays{Markers: []Marker{
+		{Point: [2]float64{0, 0}, Icon: IconCircle, Color: red},
+		{Point: [2]float64{-90, 0}, Icon: IconSquare, Color: blue, Label: "AB"},
+		{Point: [2]float64{90, 0}, Color: red},
+	}}
+	o.Draw(img, world)
+
+	// circle is centered on point
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(256, 256))
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(250, 256))
+	assert.Equal(t, white, img.RGBAAt(246, 256))
+
+	// square grows to fit label, label is white on dark color
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(128-10, 256-10))
+	assert.Equal(t, white, img.RGBAAt(128-14, 256))
+	var labelPixels int
+	for x := 128 - 7; x < 128+7; x++ {
+		for y := 256 - 6; y < 256+6; y++ {
+			if img.RGBAAt(x, y) == white {
+				labelPixels++
+			}
+		}
+	}
+	assert.Greater(t, labelPixels, 10)
+
+	// pin points to the point by its tip, its head is above
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(384, 256-14))
+	assert.Equal(t, white, img.RGBAAt(384, 256-20), "dot in pin head")
+	assert.Equal(t, white, img.RGBAAt(384, 256+3))
+}
+
+func TestDrawPaths(t *testing.T) {
+	img := whiteImage(512, 512)
+	o := &Overlays{Paths: []Path{{Points: [][2]float64{{-90, 0}, {90, 0}}, Color: blue, Width: 6}}}
+	o.Draw(img, world)
+
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(256, 256))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(256, 258))
+	assert.Equal(t, white, img.RGBAAt(256, 260))
+	assert.Equal(t, white, img.RGBAAt(100, 256))
+
+	// round caps
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(126, 256))
+	assert.Equal(t, white, img.RGBAAt(126, 259))
+}
+
+func TestDrawAntimeridian(t *testing.T) {
+	// image of zoom 2 is centered on antimeridian, it shows long 90..180 and -180..-90
+	view := &View{Proj: &tile.ElipsSpherical, Zoom: 2, X: 3, Y: 1, TileSize: 256}
+
+	img := whiteImage(512, 512)
+	o := &Overlays{
+		Paths: []Path{
+			{Points: [][2]float64{{135, 0}, {180, 0}}, Color: blue, Width: 4},
+			{Points: [][2]float64{{-170, 10}, {-100, 10}}, Color: blue, Width: 4},
+		},
+		Markers: []Marker{{Point: [2]float64{-135, 0}, Icon: IconCircle, Color: red}},
+	}
+	o.Draw(img, view)
+
+	// geometries are drawn on the copy of the world shown in image
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(200, 256))
+	assert.Equal(t, white, img.RGBAAt(100, 256))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(300, 228))
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(384, 256))
+}
+
+func TestDrawPolygons(t *testing.T) {
+	img := whiteImage(512, 512)
+	o := &Overlays{Polygons: []Polygon{{
+		Rings: [][][2]float64{
+			{{-90, -60}, {90, -60}, {90, 60}, {-90, 60}, {-90, -60}},
+			{{-45, -30}, {-45, 30}, {45, 30}, {45, -30}, {-45, -30}},
+		},
+		Color: blue,
+		Width: 4,
+		Fill:  color.NRGBA{R: 0xff, A: 0x80},
+	}}}
+	o.Draw(img, world)
+
+	// fill is blended, hole is cut out, outline is drawn on top
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x7f, B: 0x7f, A: 0xff}, img.RGBAAt(150, 256))
+	assert.Equal(t, white, img.RGBAAt(256, 256))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(128, 256))
+	assert.Equal(t, white, img.RGBAAt(100, 256))
+
+	// polygons with orientation of holes are filled too
+	img = whiteImage(512, 512)
+	o = &Overlays{Polygons: []Polygon{{Rings: [][][2]float64{{{-45, -30}, {-45, 30}, {45, 30}, {45, -30}}}, Fill: red}}}
+	o.Draw(img, world)
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(256, 256))
+}
+
+func TestDrawOutOfImage(t *testing.T) {
+	// zoom 20 puts points far outside of image, they must be clipped without hanging up
+	view := &View{Proj: &tile.ElipsSpherical, Zoom: 20, X: 1 << 19, Y: 1 << 19, TileSize: 256}
+
+	img := whiteImage(256, 256)
+	o := &Overlays{
+		Paths:    []Path{{Points: [][2]float64{{-10, -80}, {10, 80}}, Color: blue, Width: 10}},
+		Polygons: []Polygon{{Rings: [][][2]float64{{{-10, -80}, {10, -80}, {10, 80}, {-10, 80}}}, Fill: red}},
+		Markers:  []Marker{{Point: [2]float64{10, 10}, Color: red}},
+	}
+	o.Draw(img, view)
+
+	// image is inside the polygon and the path goes through its corner
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(128, 128))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		overlays Overlays
+		err      string
+	}{
+		{Overlays{Markers: []Marker{{Point: [2]float64{10, 10}, Icon: IconSquare}}}, ""},
+		{Overlays{Markers: []Marker{{Point: [2]float64{10, 91}}}}, "marker 0: coordinates 91, 10 are out of range"},
+		{Overlays{Markers: []Marker{{Point: [2]float64{math.NaN(), 10}}}}, "marker 0: coordinates 10, NaN are out of range"},
+		{Overlays{Markers: make([]Marker, MaxMarkers+1)}, "more than 1000 markers"},
+		{Overlays{Paths: []Path{{Points: make([][2]float64, MaxPoints+1)}}}, "more than 100000 points of paths and polygons"},
+		{Overlays{Markers: []Marker{{Point: [2]float64{10, 10}, Icon: "star"}}}, `marker 0: icon "star" not supported`},
+		{Overlays{Paths: []Path{{Points: [][2]float64{{0, 0}}, Width: 1}}}, "path 0: must contain at least 2 points"},
+		{Overlays{Paths: []Path{{Points: [][2]float64{{0, 0}, {181, 0}}, Width: 1}}}, "path 0: coordinates 0, 181 are out of range"},
+		{Overlays{Paths: []Path{{Points: [][2]float64{{0, 0}, {1, 0}}, Width: 65}}}, "path 0: width must be within 0..64"},
+		{Overlays{Paths: []Path{{Points: [][2]float64{{0, 0}, {1, 0}}, Width: math.NaN()}}}, "path 0: width must be within 0..64"},
+		{Overlays{Polygons: []Polygon{{}}}, "polygon 0: must contain at least 1 ring"},
+		{Overlays{Polygons: []Polygon{{Rings: [][][2]float64{{{0, 0}, {1, 0}}}}}}, "polygon 0: rings must contain at least 3 points"},
+		{Overlays{Polygons: []Polygon{{Rings: [][][2]float64{{{0, 0}, {1, 0}, {1, 1}}}, Width: -1}}}, "polygon 0: width must be within 0..64"},
+	}
+
+	for _, tt := range tests {
+		err := tt.overlays.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var o *Overlays
+	assert.True(t, o.Empty())
+
+	o = &Overlays{}
+	assert.True(t, o.Empty())
+
+	o.Add(&Overlays{Paths: []Path{{}}})
+	assert.False(t, o.Empty())
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("Red")
+	assert.NoError(t, err)
+	assert.Equal(t, colors["red"], c)
+
+	c, err = ParseColor("0xFF000080")
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0x80}, c)
+
+	c, err = ParseColor("#00f")
+	assert.NoError(t, err)
+	assert.Equal(t, blue, c)
+
+	_, err = ParseColor("pink")
+	assert.Error(t, err)
+
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0x80}, WithOpacity(red, 0.5))
+	assert.Equal(t, red, WithOpacity(red, 2))
+}
@@ -0,0 +1,49 @@
+package overlay
+
+import "fmt"
+
+// DecodePolyline decode line of encoded polyline algorithm format with precision of 5 digits,
+// points are [long, lat] like in GeoJSON
+func DecodePolyline(encoded string) ([][2]float64, error) {
+	var points [][2]float64
+	var lat, long int
+
+	for i := 0; i < len(encoded); {
+		var deltas [2]int
+		for j := range deltas {
+			var result, shift uint
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("polyline is truncated")
+				}
+
+				b := uint(encoded[i]) - 63
+				i++
+				if b > 0x3f {
+					return nil, fmt.Errorf("invalid character %q of polyline at %d", encoded[i-1], i-1)
+				}
+
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+				if shift > 30 {
+					return nil, fmt.Errorf("polyline value at %d is too long", i)
+				}
+			}
+
+			if result&1 != 0 {
+				deltas[j] = ^int(result >> 1)
+			} else {
+				deltas[j] = int(result >> 1)
+			}
+		}
+
+		lat += deltas[0]
+		long += deltas[1]
+		points = append(points, [2]float64{float64(long) / 1e5, float64(lat) / 1e5})
+	}
+
+	return points, nil
+}
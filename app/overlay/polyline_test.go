@@ -0,0 +1,27 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	// example of polyline algorithm documentation
+	points, err := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@")
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]float64{{-120.2, 38.5}, {-120.95, 40.7}, {-126.453, 43.252}}, points)
+
+	points, err = DecodePolyline("")
+	assert.NoError(t, err)
+	assert.Empty(t, points)
+
+	_, err = DecodePolyline("_p~iF~ps|")
+	assert.EqualError(t, err, "polyline is truncated")
+
+	_, err = DecodePolyline("_p~iF ps|U")
+	assert.EqualError(t, err, `invalid character ' ' of polyline at 5`)
+
+	_, err = DecodePolyline("~~~~~~~~?")
+	assert.ErrorContains(t, err, "is too long")
+}
@@ -0,0 +1,288 @@
+package overlay
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// point is a position in image pixels
+type point struct {
+	x, y float64
+}
+
+// rect is a clipping rectangle in image pixels
+type rect struct {
+	minX, minY, maxX, maxY float64
+}
+
+// clipRect return bounds expanded by margin
+func clipRect(bounds image.Rectangle, margin float64) rect {
+	return rect{
+		minX: float64(bounds.Min.X) - margin,
+		minY: float64(bounds.Min.Y) - margin,
+		maxX: float64(bounds.Max.X) + margin,
+		maxY: float64(bounds.Max.Y) + margin,
+	}
+}
+
+func (r rect) contains(p point) bool {
+	return p.x >= r.minX && p.x <= r.maxX && p.y >= r.minY && p.y <= r.maxY
+}
+
+// fill draw union of polygons with nonzero winding, so polygons of the same orientation are merged and
+// polygons of opposite orientation are cut out. Polygons are clipped by image bounds beforehand, it keeps
+// rasterization cheap for shapes far outside of image.
+func fill(dst draw.Image, polygons [][]point, c color.Color) {
+	bounds := dst.Bounds()
+	clip := clipRect(bounds, 1)
+
+	var box image.Rectangle
+	clipped := make([][]point, 0, len(polygons))
+	for _, polygon := range polygons {
+		polygon = clipPolygon(polygon, clip)
+		if len(polygon) < 3 {
+			continue
+		}
+
+		for _, p := range polygon {
+			box = box.Union(image.Rect(int(math.Floor(p.x)), int(math.Floor(p.y)), int(math.Ceil(p.x))+1, int(math.Ceil(p.y))+1))
+		}
+		clipped = append(clipped, polygon)
+	}
+
+	box = box.Intersect(bounds)
+	if box.Empty() {
+		return
+	}
+
+	r := vector.NewRasterizer(box.Dx(), box.Dy())
+	for _, polygon := range clipped {
+		r.MoveTo(float32(polygon[0].x-float64(box.Min.X)), float32(polygon[0].y-float64(box.Min.Y)))
+		for _, p := range polygon[1:] {
+			r.LineTo(float32(p.x-float64(box.Min.X)), float32(p.y-float64(box.Min.Y)))
+		}
+		r.ClosePath()
+	}
+
+	r.Draw(dst, box, image.NewUniform(c), image.Point{})
+}
+
+// stroke draw line of width with round joins and caps
+func stroke(dst draw.Image, line []point, closed bool, width float64, c color.Color) {
+	half := width / 2
+	clip := clipRect(dst.Bounds(), half+1)
+
+	segments := len(line) - 1
+	if closed {
+		segments = len(line)
+	}
+
+	var shapes [][]point
+	for i := 0; i < segments; i++ {
+		a, b, ok := clipSegment(line[i], line[(i+1)%len(line)], clip)
+		if !ok {
+			continue
+		}
+
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		if length == 0 {
+			continue
+		}
+
+		nx, ny := -(b.y-a.y)/length*half, (b.x-a.x)/length*half
+		shapes = append(shapes, orient([]point{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}}, true))
+	}
+
+	// joins of thin lines are not visible
+	if width > 2 {
+		for _, p := range line {
+			if clip.contains(p) {
+				shapes = append(shapes, circle(p, half))
+			}
+		}
+	}
+
+	fill(dst, shapes, c)
+}
+
+// circle return polygon approximating circle, it has positive orientation
+func circle(center point, radius float64) []point {
+	n := max(8, min(64, int(radius*2)))
+
+	polygon := make([]point, 0, n)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		polygon = append(polygon, point{center.x + radius*math.Cos(a), center.y + radius*math.Sin(a)})
+	}
+
+	return polygon
+}
+
+// area return signed area of polygon
+func area(polygon []point) float64 {
+	var s float64
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		s += p.x*q.y - q.x*p.y
+	}
+	return s / 2
+}
+
+// orient return polygon with positive or negative orientation
+func orient(polygon []point, positive bool) []point {
+	if (area(polygon) >= 0) == positive {
+		return polygon
+	}
+
+	reversed := make([]point, len(polygon))
+	for i, p := range polygon {
+		reversed[len(polygon)-1-i] = p
+	}
+	return reversed
+}
+
+// clipPolygon clip polygon by rectangle with Sutherland–Hodgman algorithm
+func clipPolygon(polygon []point, r rect) []point {
+	edges := []struct {
+		inside    func(p point) bool
+		intersect func(a, b point) point
+	}{
+		{func(p point) bool { return p.x >= r.minX }, func(a, b point) point { return atX(a, b, r.minX) }},
+		{func(p point) bool { return p.x <= r.maxX }, func(a, b point) point { return atX(a, b, r.maxX) }},
+		{func(p point) bool { return p.y >= r.minY }, func(a, b point) point { return atY(a, b, r.minY) }},
+		{func(p point) bool { return p.y <= r.maxY }, func(a, b point) point { return atY(a, b, r.maxY) }},
+	}
+
+	for _, e := range edges {
+		if len(polygon) == 0 {
+			break
+		}
+
+		input := polygon
+		polygon = make([]point, 0, len(input)+4)
+
+		prev := input[len(input)-1]
+		for _, p := range input {
+			switch {
+			case e.inside(p) && !e.inside(prev):
+				polygon = append(polygon, e.intersect(prev, p), p)
+			case e.inside(p):
+				polygon = append(polygon, p)
+			case e.inside(prev):
+				polygon = append(polygon, e.intersect(prev, p))
+			}
+			prev = p
+		}
+	}
+
+	return polygon
+}
+
+// atX return point of segment at x
+func atX(a, b point, x float64) point {
+	return point{x, a.y + (b.y-a.y)*(x-a.x)/(b.x-a.x)}
+}
+
+// atY return point of segment at y
+func atY(a, b point, y float64) point {
+	return point{a.x + (b.x-a.x)*(y-a.y)/(b.y-a.y), y}
+}
+
+// clipSegment clip segment by rectangle with Liang–Barsky algorithm
+func clipSegment(a, b point, r rect) (point, point, bool) {
+	dx, dy := b.x-a.x, b.y-a.y
+	t0, t1 := 0.0, 1.0
+
+	for _, c := range [][2]float64{{-dx, a.x - r.minX}, {dx, r.maxX - a.x}, {-dy, a.y - r.minY}, {dy, r.maxY - a.y}} {
+		p, q := c[0], c[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+
+		t := q / p
+		if p < 0 {
+			t0 = max(t0, t)
+		} else {
+			t1 = min(t1, t)
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+
+	return point{a.x + t0*dx, a.y + t0*dy}, point{a.x + t1*dx, a.y + t1*dy}, true
+}
+
+// markerRadius is a radius of marker icon without label
+const markerRadius = 8
+
+// face is a bitmap font of labels
+var face = basicfont.Face7x13
+
+// drawMarker draw icon of marker at point, pin points to it by its tip, other icons are centered on it
+func drawMarker(dst draw.Image, at point, m *Marker) {
+	label := []rune(m.Label)
+	radius := max(markerRadius, float64(face.Advance*len(label))/2+4)
+	if !clipRect(dst.Bounds(), 3*radius).contains(at) {
+		return
+	}
+
+	center := at
+	var shape []point
+	switch m.Icon {
+	case IconCircle:
+		shape = circle(at, radius)
+	case IconSquare:
+		shape = []point{{at.x - radius, at.y - radius}, {at.x + radius, at.y - radius},
+			{at.x + radius, at.y + radius}, {at.x - radius, at.y + radius}}
+	default:
+		center, shape = pin(at, radius)
+	}
+
+	outline := color.NRGBA{R: uint8(int(m.Color.R) * 3 / 5), G: uint8(int(m.Color.G) * 3 / 5), B: uint8(int(m.Color.B) * 3 / 5), A: m.Color.A}
+	fill(dst, [][]point{shape}, m.Color)
+	stroke(dst, shape, true, 1.5, outline)
+
+	// text is white on dark colors and black on light ones
+	text := color.NRGBA{A: m.Color.A}
+	if 299*int(m.Color.R)+587*int(m.Color.G)+114*int(m.Color.B) < 140*1000 {
+		text = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: m.Color.A}
+	}
+
+	if len(label) == 0 {
+		if m.Icon == "" || m.Icon == IconPin {
+			fill(dst, [][]point{circle(center, radius/3)}, text)
+		}
+		return
+	}
+
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(text), Face: face,
+		Dot: fixed.P(int(math.Round(center.x))-face.Advance*len(label)/2, int(math.Round(center.y))+(face.Ascent-face.Descent)/2)}
+	d.DrawString(string(label))
+}
+
+// pin return center of head and outline of pin with tip at point
+func pin(at point, radius float64) (point, []point) {
+	// tip is at 2.5 radii below center, outline goes by tangents from it
+	center := point{at.x, at.y - 2.5*radius}
+	tangent := math.Acos(1 / 2.5)
+
+	n := max(16, min(64, int(radius*2)))
+	shape := make([]point, 0, n+2)
+	for i := 0; i <= n; i++ {
+		a := math.Pi/2 + tangent + (2*math.Pi-2*tangent)*float64(i)/float64(n)
+		shape = append(shape, point{center.x + radius*math.Cos(a), center.y + radius*math.Sin(a)})
+	}
+
+	return center, append(shape, at)
+}
@@ -0,0 +1,50 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClipPolygon(t *testing.T) {
+	r := rect{minX: 0, minY: 0, maxX: 10, maxY: 10}
+
+	// triangle crossing right edge is cut by it
+	clipped := clipPolygon([]point{{5, 2}, {15, 2}, {5, 8}}, r)
+	assert.Equal(t, []point{{5, 2}, {10, 2}, {10, 5}, {5, 8}}, clipped)
+
+	// polygon containing rectangle becomes rectangle
+	clipped = clipPolygon([]point{{-1e9, -1e9}, {1e9, -1e9}, {1e9, 1e9}, {-1e9, 1e9}}, r)
+	assert.InDelta(t, 100, area(clipped), 1e-6)
+
+	// polygon outside of rectangle disappears
+	assert.Empty(t, clipPolygon([]point{{20, 20}, {30, 20}, {30, 30}}, r))
+}
+
+func TestClipSegment(t *testing.T) {
+	r := rect{minX: 0, minY: 0, maxX: 10, maxY: 10}
+
+	a, b, ok := clipSegment(point{-10, 5}, point{20, 5}, r)
+	assert.True(t, ok)
+	assert.Equal(t, point{0, 5}, a)
+	assert.Equal(t, point{10, 5}, b)
+
+	a, b, ok = clipSegment(point{2, 2}, point{8, 8}, r)
+	assert.True(t, ok)
+	assert.Equal(t, point{2, 2}, a)
+	assert.Equal(t, point{8, 8}, b)
+
+	_, _, ok = clipSegment(point{-10, -5}, point{20, -5}, r)
+	assert.False(t, ok)
+
+	_, _, ok = clipSegment(point{-10, 35}, point{35, -10}, r)
+	assert.False(t, ok)
+}
+
+func TestOrient(t *testing.T) {
+	square := []point{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	assert.Less(t, area(square), 0.0)
+	assert.Greater(t, area(orient(square, true)), 0.0)
+	assert.Less(t, area(orient(square, false)), 0.0)
+	assert.Greater(t, area(circle(point{}, 5)), 0.0)
+}
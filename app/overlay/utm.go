@@ -0,0 +1,95 @@
+package overlay
+
+import (
+	"math"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// WGS84 ellipsoid and UTM constants
+const (
+	utmA         = tile.EarthRadius
+	utmF         = 1 / 298.257223563
+	utmK0        = 0.9996
+	utmEasting   = 500000.0   // false easting
+	utmNorthing  = 10000000.0 // false northing of southern hemisphere
+	utmMaxLatAbs = 84.0       // UTM isn't defined closer to the poles
+)
+
+var (
+	utmE2  = utmF * (2 - utmF)
+	utmEP2 = utmE2 / (1 - utmE2)
+)
+
+// utmZone return UTM zone of longitude
+func utmZone(long float64) int {
+	long = math.Mod(long+180, 360)
+	if long < 0 {
+		long += 360
+	}
+	return min(60, int(long/6)+1)
+}
+
+// utmMeridian return central meridian of UTM zone in radians
+func utmMeridian(zone int) float64 {
+	return float64((zone-1)*6-180+3) * math.Pi / 180
+}
+
+// utmArc return length of meridian arc from equator to latitude in radians
+func utmArc(phi float64) float64 {
+	e2, e4, e6 := utmE2, utmE2*utmE2, utmE2*utmE2*utmE2
+	return utmA * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+// toUTM convert latitude and longitude to easting and northing of UTM zone by Snyder's series
+func toUTM(lat, long float64, zone int, south bool) (easting, northing float64) {
+	phi := lat * math.Pi / 180
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+
+	n := utmA / math.Sqrt(1-utmE2*sin*sin)
+	t := tan * tan
+	c := utmEP2 * cos * cos
+	a := cos * math.Remainder(long*math.Pi/180-utmMeridian(zone), 2*math.Pi)
+
+	easting = utmEasting + utmK0*n*(a+(1-t+c)*math.Pow(a, 3)/6+(5-18*t+t*t+72*c-58*utmEP2)*math.Pow(a, 5)/120)
+	northing = utmK0 * (utmArc(phi) + n*tan*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*utmEP2)*math.Pow(a, 6)/720))
+	if south {
+		northing += utmNorthing
+	}
+
+	return easting, northing
+}
+
+// fromUTM convert easting and northing of UTM zone to latitude and longitude, it's inverse of toUTM
+func fromUTM(easting, northing float64, zone int, south bool) (lat, long float64) {
+	if south {
+		northing -= utmNorthing
+	}
+
+	e2, e4, e6 := utmE2, utmE2*utmE2, utmE2*utmE2*utmE2
+	mu := northing / utmK0 / (utmA * (1 - e2/4 - 3*e4/64 - 5*e6/256))
+
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c := utmEP2 * cos * cos
+	t := tan * tan
+	n := utmA / math.Sqrt(1-e2*sin*sin)
+	r := utmA * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	d := (easting - utmEasting) / (n * utmK0)
+
+	phi := phi1 - n*tan/r*(d*d/2-(5+3*t+10*c-4*c*c-9*utmEP2)*math.Pow(d, 4)/24+
+		(61+90*t+298*c+45*t*t-252*utmEP2-3*c*c)*math.Pow(d, 6)/720)
+	lambda := utmMeridian(zone) + (d-(1+2*t+c)*math.Pow(d, 3)/6+
+		(5-2*c+28*t-3*c*c+8*utmEP2+24*t*t)*math.Pow(d, 5)/120)/cos
+
+	return phi * 180 / math.Pi, lambda * 180 / math.Pi
+}
@@ -0,0 +1,42 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTMZone(t *testing.T) {
+	assert.Equal(t, 1, utmZone(-180))
+	assert.Equal(t, 31, utmZone(0))
+	assert.Equal(t, 37, utmZone(37.61))
+	assert.Equal(t, 60, utmZone(179.9))
+	assert.Equal(t, 1, utmZone(180))
+	assert.Equal(t, 60, utmZone(-180.5))
+}
+
+func TestToUTM(t *testing.T) {
+	// central meridian at 45 degrees is meridian arc scaled by k0
+	e, n := toUTM(45, 3, 31, false)
+	assert.InDelta(t, 500000, e, 1e-6)
+	assert.InDelta(t, 4982950.40, n, 0.01)
+
+	e, n = toUTM(0, 0, 31, false)
+	assert.InDelta(t, 166021.44, e, 0.01)
+	assert.InDelta(t, 0, n, 1e-6)
+
+	// southern hemisphere has false northing
+	e, n = toUTM(-45, 3, 31, true)
+	assert.InDelta(t, 500000, e, 1e-6)
+	assert.InDelta(t, 10000000-4982950.40, n, 0.01)
+}
+
+func TestFromUTM(t *testing.T) {
+	for _, p := range [][2]float64{{55.75, 37.61}, {-33.86, 151.21}, {0.5, -0.5}, {70, 25}, {-80, -70}} {
+		zone := utmZone(p[1])
+		e, n := toUTM(p[0], p[1], zone, p[0] < 0)
+		lat, long := fromUTM(e, n, zone, p[0] < 0)
+		assert.InDelta(t, p[0], lat, 1e-7, "%v", p)
+		assert.InDelta(t, p[1], long, 1e-7, "%v", p)
+	}
+}
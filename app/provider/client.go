@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultConnectTimeout = 10 // seconds
+	defaultReadTimeout    = 30 // seconds
+)
+
+// clientSchema contains upstream http client settings for provider
+type clientSchema struct {
+	ConnectTimeout     int    `json:"connect_timeout"`
+	ReadTimeout        int    `json:"read_timeout"`
+	Proxy              string `json:"proxy"`
+	CAFile             string `json:"ca_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	MaxIdleConns       int    `json:"max_idle_conns"`
+	DisableHTTP2       bool   `json:"disable_http2"`
+}
+
+// createClient build http client with own transport by specified clientSchema
+func createClient(cs *clientSchema) (*http.Client, error) {
+	connectTimeout := time.Duration(defaultConnectTimeout) * time.Second
+	if cs.ConnectTimeout > 0 {
+		connectTimeout = time.Duration(cs.ConnectTimeout) * time.Second
+	}
+
+	readTimeout := time.Duration(defaultReadTimeout) * time.Second
+	if cs.ReadTimeout > 0 {
+		readTimeout = time.Duration(cs.ReadTimeout) * time.Second
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     !cs.DisableHTTP2,
+		MaxIdleConns:          cs.MaxIdleConns,
+		MaxIdleConnsPerHost:   cs.MaxIdleConns,
+	}
+
+	if cs.DisableHTTP2 {
+		// non-nil empty map disables automatic http2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if cs.Proxy != "" {
+		proxyURL, err := parseProxy(cs.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := createTLSConfig(cs)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   connectTimeout + readTimeout,
+	}, nil
+}
+
+// parseProxy validate proxy url, supported schemes are http, https and socks5
+func parseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy url: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return proxyURL, nil
+	default:
+		return nil, fmt.Errorf("proxy scheme %q not supported", proxyURL.Scheme)
+	}
+}
+
+// createTLSConfig create tls config with custom CA if specified
+func createTLSConfig(cs *clientSchema) (*tls.Config, error) {
+	// #nosec G402 -- insecure mode is explicitly requested by provider schema for internal servers
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cs.InsecureSkipVerify,
+	}
+
+	if cs.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(filepath.Clean(cs.CAFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to append certificates from ca file %s", cs.CAFile)
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
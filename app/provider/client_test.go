@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateClient_Defaults(t *testing.T) {
+	client, err := createClient(&clientSchema{})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+	assert.Equal(t, (defaultConnectTimeout+defaultReadTimeout)*time.Second, client.Timeout)
+
+	transport, ok := client.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.Nil(t, transport.TLSNextProto)
+	assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestCreateClient_Custom(t *testing.T) {
+	client, err := createClient(&clientSchema{
+		ConnectTimeout:     1,
+		ReadTimeout:        2,
+		Proxy:              "socks5://127.0.0.1:1080",
+		InsecureSkipVerify: true,
+		MaxIdleConns:       7,
+		DisableHTTP2:       true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, client.Timeout)
+
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, 2*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, 7, transport.MaxIdleConns)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+
+	proxyURL, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://example.com", http.NoBody))
+	assert.NoError(t, err)
+	assert.Equal(t, "socks5://127.0.0.1:1080", proxyURL.String())
+}
+
+func TestCreateClient_FailedProxy(t *testing.T) {
+	client, err := createClient(&clientSchema{Proxy: "ftp://127.0.0.1"})
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Contains(t, err.Error(), "proxy scheme")
+
+	client, err = createClient(&clientSchema{Proxy: "://invalid"})
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Contains(t, err.Error(), "failed to parse proxy url")
+}
+
+func TestCreateClient_CAFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600)
+	assert.NoError(t, err)
+
+	client, err := createClient(&clientSchema{CAFile: caFile})
+	assert.NoError(t, err)
+
+	resp, err := client.Get(ts.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+}
+
+func TestCreateClient_FailedCAFile(t *testing.T) {
+	client, err := createClient(&clientSchema{CAFile: "invalid/path"})
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Contains(t, err.Error(), "failed to read ca file")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	client, err = createClient(&clientSchema{CAFile: caFile})
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Contains(t, err.Error(), "failed to append certificates")
+}
+
+func TestCreateClient_InsecureSkipVerify(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	secure, err := createClient(&clientSchema{})
+	assert.NoError(t, err)
+	_, err = secure.Get(ts.URL)
+	assert.Error(t, err)
+
+	insecure, err := createClient(&clientSchema{InsecureSkipVerify: true})
+	assert.NoError(t, err)
+	resp, err := insecure.Get(ts.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, tls.VersionTLS13, int(resp.TLS.Version))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superboomer/maptile/app/archive"
+)
+
+// local provider types which read tiles from archives instead of upstream
+const (
+	typeMBTiles = "mbtiles"
+	typePMTiles = "pmtiles"
+	typeDir     = "dir"
+)
+
+// localTransport serves tiles of local archive as http responses to "<type>://<id>/{z}/{x}/{y}" requests,
+// so local providers use the same downloader flow as upstream ones
+type localTransport struct {
+	reader archive.Reader
+}
+
+// openLocal open archive of local provider and build url template and client for it
+func openLocal(s *schema) (string, *http.Client, error) {
+	if s.Path == "" {
+		return "", nil, fmt.Errorf("path must be specified for %s provider %v", s.Type, s.Name)
+	}
+
+	var reader archive.Reader
+	var err error
+
+	switch s.Type {
+	case typeMBTiles:
+		reader, err = archive.OpenMBTiles(s.Path)
+	case typePMTiles:
+		reader, err = archive.OpenPMTiles(s.Path)
+	default:
+		reader = &archive.ZXY{Path: s.Path, TMS: s.TMS}
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open archive of provider %v: %w", s.Name, err)
+	}
+
+	return s.Type + "://" + s.ID + "/{z}/{x}/{y}", &http.Client{Transport: &localTransport{reader: reader}}, nil
+}
+
+// isLocal check that provider type reads tiles from archive
+func isLocal(providerType string) bool {
+	return providerType == typeMBTiles || providerType == typePMTiles || providerType == typeDir
+}
+
+// RoundTrip read tile from archive, missing tile is 404 and tile which is not modified since
+// If-Modified-Since is 304 like in answers of upstream servers
+func (t *localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	z, x, y, err := parseTilePath(req.URL.Path)
+	if err != nil {
+		return localResponse(req, http.StatusBadRequest, nil, nil), nil
+	}
+
+	img, modified, err := t.reader.Tile(z, x, y)
+	if errors.Is(err, archive.ErrNotFound) {
+		return localResponse(req, http.StatusNotFound, nil, nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && !modified.Truncate(time.Second).After(since) {
+		return localResponse(req, http.StatusNotModified, header, nil), nil
+	}
+
+	header.Set("Content-Type", http.DetectContentType(img))
+	return localResponse(req, http.StatusOK, header, img), nil
+}
+
+// localResponse build http response of local transport
+func localResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// parseTilePath parse "/z/x/y" path of local tile request
+func parseTilePath(path string) (z, x, y int, err error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid tile path %q", path)
+	}
+
+	coords := make([]int, 3)
+	for i, part := range parts {
+		if coords[i], err = strconv.Atoi(part); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid tile path %q", path)
+		}
+	}
+
+	return coords[0], coords[1], coords[2], nil
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestCreateProvider_Local(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "1", "1"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1", "1", "0.png"), []byte("\x89PNG\r\n\x1a\ndir"), 0o600))
+
+	tests := []struct {
+		schema schema
+		tile   tile.Tile
+	}{
+		{schema: schema{Type: "mbtiles", Path: "../archive/testdata/plain.mbtiles"}, tile: tile.Tile{X: 3, Y: 2, Z: 2}},
+		{schema: schema{Type: "pmtiles", Path: "../archive/testdata/tiles.pmtiles"}, tile: tile.Tile{X: 0, Y: 1, Z: 1}},
+		{schema: schema{Type: "dir", Path: dir, TMS: true}, tile: tile.Tile{X: 1, Y: 1, Z: 1}},
+	}
+
+	for _, tt := range tests {
+		tt.schema.Name, tt.schema.ID, tt.schema.MaxZoom, tt.schema.Projection = "Local", "local", 4, "spherical"
+
+		p, err := createProvider(&tt.schema)
+		assert.NoError(t, err, tt.schema.Type)
+		assert.Positive(t, p.MaxJobs())
+
+		req := p.GetRequest(&tt.tile)
+		assert.Equal(t, tt.schema.Type+"://local/"+
+			fmt.Sprintf("%d/%d/%d", tt.tile.Z, tt.tile.X, tt.tile.Y), req.URL.String())
+
+		resp, err := p.Client().Do(req)
+		assert.NoError(t, err, tt.schema.Type)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.schema.Type)
+		assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+		assert.NotEmpty(t, body)
+
+		// not modified since Last-Modified
+		req = p.GetRequest(&tt.tile)
+		req.Header.Set("If-Modified-Since", resp.Header.Get("Last-Modified"))
+		resp, err = p.Client().Do(req)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, tt.schema.Type)
+
+		req = p.GetRequest(&tt.tile)
+		req.Header.Set("If-Modified-Since", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		resp, err = p.Client().Do(req)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.schema.Type)
+
+		resp, err = p.Client().Do(p.GetRequest(&tile.Tile{X: 5, Y: 5, Z: 3}))
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, tt.schema.Type)
+	}
+}
+
+func TestCreateProvider_LocalFailed(t *testing.T) {
+	_, err := createProvider(&schema{Name: "Local", ID: "local", Projection: "spherical", Type: "mbtiles"})
+	assert.EqualError(t, err, "path must be specified for mbtiles provider Local")
+
+	_, err = createProvider(&schema{Name: "Local", ID: "local", Projection: "spherical", Type: "pmtiles", Path: "missing.pmtiles"})
+	assert.ErrorContains(t, err, "failed to open archive of provider Local")
+
+	_, err = createProvider(&schema{Name: "Local", ID: "local", Projection: "spherical", Type: "ftp"})
+	assert.EqualError(t, err, "type ftp not supported for provider Local")
+}
+
+func TestParseTilePath(t *testing.T) {
+	z, x, y, err := parseTilePath("/3/1/2")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int{3, 1, 2}, [3]int{z, x, y})
+
+	_, _, _, err = parseTilePath("/3/1")
+	assert.Error(t, err)
+	_, _, _, err = parseTilePath("/3/a/2")
+	assert.Error(t, err)
+}
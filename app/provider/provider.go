@@ -1,8 +1,12 @@
 package provider
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"path"
+	"runtime"
 	"strings"
 
 	"github.com/superboomer/maptile/app/tile"
@@ -13,35 +17,76 @@ import (
 // Provider is an interface which implement all necessary stuff for map provider
 type Provider interface {
 	GetTile(lat, long, scale float64) tile.Tile
+	Projection() *tile.Elips
 
 	ID() string
 	Name() string
 	MaxJobs() int
+	MinZoom() int
 	MaxZoom() int
+	Bounds() [4]float64
+	Format() string
+	Attribution() string
+	License() string
+	Restrictions() string
 
 	GetRequest(t *tile.Tile) *http.Request
+	Client() *http.Client
+	MaxSize() int64
+	IsBlank(img []byte) bool
+	RespectMaxAge() bool
 }
 
+// defaultMaxSize is a max size of upstream tile body in bytes if schema doesn't specify it
+const defaultMaxSize = 5 << 20
+
 // MapProvider contains all data about provider
 type MapProvider struct {
-	name       string
-	id         string
-	url        string
-	headers    *http.Header
-	maxJobs    int
-	maxZoom    int
-	projection *tile.Elips
+	name         string
+	id           string
+	url          string
+	headers      *http.Header
+	maxJobs      int
+	minZoom      int
+	maxZoom      int
+	bounds       [4]float64
+	format       string
+	attribution  string
+	license      string
+	restrictions string
+	projection   *tile.Elips
+	client       *http.Client
+	maxSize      int64
+	blanks       map[string]struct{}
+	maxAge       bool
+	bbox         func(t *tile.Tile) string // bbox of tile for {bbox} placeholder of url, only for wms
 }
 
 // createProvider create new provider by specified Schema
 func createProvider(schema *schema) (Provider, error) {
 
 	p := &MapProvider{
-		name:    schema.Name,
-		id:      schema.ID,
-		url:     schema.Request.URL,
-		maxJobs: schema.MaxJobs,
-		maxZoom: schema.MaxZoom,
+		name:         schema.Name,
+		id:           schema.ID,
+		url:          schema.Request.URL,
+		maxJobs:      schema.MaxJobs,
+		minZoom:      schema.MinZoom,
+		maxZoom:      schema.MaxZoom,
+		attribution:  schema.Attribution,
+		license:      schema.License,
+		restrictions: schema.Restrictions,
+		format:       tileFormat(schema),
+		maxSize:      schema.Response.MaxSize,
+		maxAge:       schema.Cache.RespectMaxAge,
+		blanks:       make(map[string]struct{}, len(schema.Response.BlankHashes)),
+	}
+
+	if p.maxSize <= 0 {
+		p.maxSize = defaultMaxSize
+	}
+
+	for _, h := range schema.Response.BlankHashes {
+		p.blanks[strings.ToLower(h)] = struct{}{}
 	}
 	switch schema.Projection {
 	case "wgs84":
@@ -52,6 +97,26 @@ func createProvider(schema *schema) (Provider, error) {
 		return nil, fmt.Errorf("projection %v not found for provider %v", schema.Projection, schema.Name)
 	}
 
+	if p.format != "image/png" && p.format != "image/jpeg" {
+		return nil, fmt.Errorf("format %v not supported for provider %v", p.format, schema.Name)
+	}
+
+	if schema.MinZoom < 0 || schema.MinZoom > schema.MaxZoom {
+		return nil, fmt.Errorf("min zoom %v must be within 0-%v for provider %v", schema.MinZoom, schema.MaxZoom, schema.Name)
+	}
+
+	// bounds of tiles grid are used if not specified
+	maxLat, _ := tile.ConvertFromTile(0, 0, 0, p.projection)
+	p.bounds = [4]float64{-180, -maxLat, 180, maxLat}
+
+	if len(schema.Bounds) > 0 {
+		b := schema.Bounds
+		if len(b) != 4 || b[0] < -180 || b[2] > 180 || b[1] < -90 || b[3] > 90 || b[0] >= b[2] || b[1] >= b[3] {
+			return nil, fmt.Errorf("bounds of provider %v must be west, south, east, north in degrees", schema.Name)
+		}
+		copy(p.bounds[:], b)
+	}
+
 	buildHeaders := &http.Header{}
 
 	for _, h := range schema.Request.Headers {
@@ -60,6 +125,35 @@ func createProvider(schema *schema) (Provider, error) {
 
 	p.headers = buildHeaders
 
+	switch {
+	case schema.Type == "" || schema.Type == "http" || schema.Type == "wms":
+		if schema.Type == "wms" {
+			url, bbox, err := createWMS(schema, p.projection)
+			if err != nil {
+				return nil, err
+			}
+			p.url, p.bbox = url, bbox
+		}
+
+		client, err := createClient(&schema.Client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http client for provider %v: %w", schema.Name, err)
+		}
+		p.client = client
+	case isLocal(schema.Type):
+		url, client, err := openLocal(schema)
+		if err != nil {
+			return nil, err
+		}
+		p.url, p.client = url, client
+
+		if p.maxJobs <= 0 {
+			p.maxJobs = runtime.NumCPU()
+		}
+	default:
+		return nil, fmt.Errorf("type %v not supported for provider %v", schema.Type, schema.Name)
+	}
+
 	return p, nil
 }
 
@@ -75,11 +169,46 @@ func (p *MapProvider) GetTile(lat, long, scale float64) tile.Tile {
 	}
 }
 
+// Projection return mercator projection of provider tiles grid
+func (p *MapProvider) Projection() *tile.Elips {
+	return p.projection
+}
+
 // MaxJobs return count of max tile downloading per request
 func (p *MapProvider) MaxJobs() int {
 	return p.maxJobs
 }
 
+// MinZoom return min zoom for specified provider
+func (p *MapProvider) MinZoom() int {
+	return p.minZoom
+}
+
+// Bounds return west, south, east and north of tiles coverage in degrees
+func (p *MapProvider) Bounds() [4]float64 {
+	return p.bounds
+}
+
+// Format return MIME type of provider tiles, image/png or image/jpeg
+func (p *MapProvider) Format() string {
+	return p.format
+}
+
+// Attribution return attribution of provider tiles
+func (p *MapProvider) Attribution() string {
+	return p.attribution
+}
+
+// License return url of provider tiles license
+func (p *MapProvider) License() string {
+	return p.license
+}
+
+// Restrictions return usage restrictions of provider tiles
+func (p *MapProvider) Restrictions() string {
+	return p.restrictions
+}
+
 // MaxZoom return max zoom for specified provider
 func (p *MapProvider) MaxZoom() int {
 	return p.maxZoom
@@ -95,15 +224,65 @@ func (p *MapProvider) ID() string {
 	return p.id
 }
 
+// Client return http client configured for provider upstream
+func (p *MapProvider) Client() *http.Client {
+	return p.client
+}
+
+// MaxSize return max allowed size of upstream tile body in bytes
+func (p *MapProvider) MaxSize() int64 {
+	return p.maxSize
+}
+
+// IsBlank check if image is one of known blank/placeholder tiles (compared by sha256)
+func (p *MapProvider) IsBlank(img []byte) bool {
+	if len(p.blanks) == 0 {
+		return false
+	}
+
+	sum := sha256.Sum256(img)
+	_, ok := p.blanks[hex.EncodeToString(sum[:])]
+	return ok
+}
+
+// RespectMaxAge return true if upstream Cache-Control max-age should override cache alive
+func (p *MapProvider) RespectMaxAge() bool {
+	return p.maxAge
+}
+
 // GetRequest build http request for specified Tile
 func (p *MapProvider) GetRequest(t *tile.Tile) *http.Request {
 
-	replacer := strings.NewReplacer("{x}", fmt.Sprint(t.X), "{y}", fmt.Sprint(t.Y), "{z}", fmt.Sprint(t.Z))
-	req, _ := http.NewRequest(http.MethodGet, replacer.Replace(p.url), http.NoBody)
+	replacements := []string{"{x}", fmt.Sprint(t.X), "{y}", fmt.Sprint(t.Y), "{z}", fmt.Sprint(t.Z)}
+	if p.bbox != nil {
+		replacements = append(replacements, "{bbox}", p.bbox(t))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, strings.NewReplacer(replacements...).Replace(p.url), http.NoBody)
 
 	if p.headers != nil {
-		req.Header = *p.headers
+		req.Header = p.headers.Clone()
 	}
 
 	return req
 }
+
+// tileFormat return format of provider tiles from schema, it's guessed by format of wms provider or by extension
+// of url template if not specified, PNG is used when format can't be guessed
+func tileFormat(s *schema) string {
+	if s.Format != "" {
+		return s.Format
+	}
+
+	guess := path.Ext(strings.SplitN(s.Request.URL, "?", 2)[0])
+	if s.Type == "wms" {
+		guess = s.WMS.Format
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(guess, ".")) {
+	case "jpg", "jpeg", "image/jpeg":
+		return "image/jpeg"
+	default:
+		return "image/png"
+	}
+}
@@ -20,6 +20,18 @@ var _ Provider = &ProviderMock{}
 //
 //		// make and configure a mocked Provider
 //		mockedProvider := &ProviderMock{
+//			AttributionFunc: func() string {
+//				panic("mock out the Attribution method")
+//			},
+//			BoundsFunc: func() [4]float64 {
+//				panic("mock out the Bounds method")
+//			},
+//			ClientFunc: func() *http.Client {
+//				panic("mock out the Client method")
+//			},
+//			FormatFunc: func() string {
+//				panic("mock out the Format method")
+//			},
 //			GetRequestFunc: func(t *tile.Tile) *http.Request {
 //				panic("mock out the GetRequest method")
 //			},
@@ -29,15 +41,36 @@ var _ Provider = &ProviderMock{}
 //			IDFunc: func() string {
 //				panic("mock out the ID method")
 //			},
+//			IsBlankFunc: func(img []byte) bool {
+//				panic("mock out the IsBlank method")
+//			},
+//			LicenseFunc: func() string {
+//				panic("mock out the License method")
+//			},
 //			MaxJobsFunc: func() int {
 //				panic("mock out the MaxJobs method")
 //			},
+//			MaxSizeFunc: func() int64 {
+//				panic("mock out the MaxSize method")
+//			},
 //			MaxZoomFunc: func() int {
 //				panic("mock out the MaxZoom method")
 //			},
+//			MinZoomFunc: func() int {
+//				panic("mock out the MinZoom method")
+//			},
 //			NameFunc: func() string {
 //				panic("mock out the Name method")
 //			},
+//			ProjectionFunc: func() *tile.Elips {
+//				panic("mock out the Projection method")
+//			},
+//			RespectMaxAgeFunc: func() bool {
+//				panic("mock out the RespectMaxAge method")
+//			},
+//			RestrictionsFunc: func() string {
+//				panic("mock out the Restrictions method")
+//			},
 //		}
 //
 //		// use mockedProvider in code that requires Provider
@@ -45,6 +78,18 @@ var _ Provider = &ProviderMock{}
 //
 //	}
 type ProviderMock struct {
+	// AttributionFunc mocks the Attribution method.
+	AttributionFunc func() string
+
+	// BoundsFunc mocks the Bounds method.
+	BoundsFunc func() [4]float64
+
+	// ClientFunc mocks the Client method.
+	ClientFunc func() *http.Client
+
+	// FormatFunc mocks the Format method.
+	FormatFunc func() string
+
 	// GetRequestFunc mocks the GetRequest method.
 	GetRequestFunc func(t *tile.Tile) *http.Request
 
@@ -54,17 +99,50 @@ type ProviderMock struct {
 	// IDFunc mocks the ID method.
 	IDFunc func() string
 
+	// IsBlankFunc mocks the IsBlank method.
+	IsBlankFunc func(img []byte) bool
+
+	// LicenseFunc mocks the License method.
+	LicenseFunc func() string
+
 	// MaxJobsFunc mocks the MaxJobs method.
 	MaxJobsFunc func() int
 
+	// MaxSizeFunc mocks the MaxSize method.
+	MaxSizeFunc func() int64
+
 	// MaxZoomFunc mocks the MaxZoom method.
 	MaxZoomFunc func() int
 
+	// MinZoomFunc mocks the MinZoom method.
+	MinZoomFunc func() int
+
 	// NameFunc mocks the Name method.
 	NameFunc func() string
 
+	// ProjectionFunc mocks the Projection method.
+	ProjectionFunc func() *tile.Elips
+
+	// RespectMaxAgeFunc mocks the RespectMaxAge method.
+	RespectMaxAgeFunc func() bool
+
+	// RestrictionsFunc mocks the Restrictions method.
+	RestrictionsFunc func() string
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// Attribution holds details about calls to the Attribution method.
+		Attribution []struct {
+		}
+		// Bounds holds details about calls to the Bounds method.
+		Bounds []struct {
+		}
+		// Client holds details about calls to the Client method.
+		Client []struct {
+		}
+		// Format holds details about calls to the Format method.
+		Format []struct {
+		}
 		// GetRequest holds details about calls to the GetRequest method.
 		GetRequest []struct {
 			// T is the t argument value.
@@ -82,22 +160,164 @@ type ProviderMock struct {
 		// ID holds details about calls to the ID method.
 		ID []struct {
 		}
+		// IsBlank holds details about calls to the IsBlank method.
+		IsBlank []struct {
+			// Img is the img argument value.
+			Img []byte
+		}
+		// License holds details about calls to the License method.
+		License []struct {
+		}
 		// MaxJobs holds details about calls to the MaxJobs method.
 		MaxJobs []struct {
 		}
+		// MaxSize holds details about calls to the MaxSize method.
+		MaxSize []struct {
+		}
 		// MaxZoom holds details about calls to the MaxZoom method.
 		MaxZoom []struct {
 		}
+		// MinZoom holds details about calls to the MinZoom method.
+		MinZoom []struct {
+		}
 		// Name holds details about calls to the Name method.
 		Name []struct {
 		}
+		// Projection holds details about calls to the Projection method.
+		Projection []struct {
+		}
+		// RespectMaxAge holds details about calls to the RespectMaxAge method.
+		RespectMaxAge []struct {
+		}
+		// Restrictions holds details about calls to the Restrictions method.
+		Restrictions []struct {
+		}
+	}
+	lockAttribution   sync.RWMutex
+	lockBounds        sync.RWMutex
+	lockClient        sync.RWMutex
+	lockFormat        sync.RWMutex
+	lockGetRequest    sync.RWMutex
+	lockGetTile       sync.RWMutex
+	lockID            sync.RWMutex
+	lockIsBlank       sync.RWMutex
+	lockLicense       sync.RWMutex
+	lockMaxJobs       sync.RWMutex
+	lockMaxSize       sync.RWMutex
+	lockMaxZoom       sync.RWMutex
+	lockMinZoom       sync.RWMutex
+	lockName          sync.RWMutex
+	lockProjection    sync.RWMutex
+	lockRespectMaxAge sync.RWMutex
+	lockRestrictions  sync.RWMutex
+}
+
+// Attribution calls AttributionFunc.
+func (mock *ProviderMock) Attribution() string {
+	if mock.AttributionFunc == nil {
+		panic("ProviderMock.AttributionFunc: method is nil but Provider.Attribution was just called")
 	}
-	lockGetRequest sync.RWMutex
-	lockGetTile    sync.RWMutex
-	lockID         sync.RWMutex
-	lockMaxJobs    sync.RWMutex
-	lockMaxZoom    sync.RWMutex
-	lockName       sync.RWMutex
+	callInfo := struct {
+	}{}
+	mock.lockAttribution.Lock()
+	mock.calls.Attribution = append(mock.calls.Attribution, callInfo)
+	mock.lockAttribution.Unlock()
+	return mock.AttributionFunc()
+}
+
+// AttributionCalls gets all the calls that were made to Attribution.
+// Check the length with:
+//
+//	len(mockedProvider.AttributionCalls())
+func (mock *ProviderMock) AttributionCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockAttribution.RLock()
+	calls = mock.calls.Attribution
+	mock.lockAttribution.RUnlock()
+	return calls
+}
+
+// Bounds calls BoundsFunc.
+func (mock *ProviderMock) Bounds() [4]float64 {
+	if mock.BoundsFunc == nil {
+		panic("ProviderMock.BoundsFunc: method is nil but Provider.Bounds was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockBounds.Lock()
+	mock.calls.Bounds = append(mock.calls.Bounds, callInfo)
+	mock.lockBounds.Unlock()
+	return mock.BoundsFunc()
+}
+
+// BoundsCalls gets all the calls that were made to Bounds.
+// Check the length with:
+//
+//	len(mockedProvider.BoundsCalls())
+func (mock *ProviderMock) BoundsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockBounds.RLock()
+	calls = mock.calls.Bounds
+	mock.lockBounds.RUnlock()
+	return calls
+}
+
+// Client calls ClientFunc.
+func (mock *ProviderMock) Client() *http.Client {
+	if mock.ClientFunc == nil {
+		panic("ProviderMock.ClientFunc: method is nil but Provider.Client was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockClient.Lock()
+	mock.calls.Client = append(mock.calls.Client, callInfo)
+	mock.lockClient.Unlock()
+	return mock.ClientFunc()
+}
+
+// ClientCalls gets all the calls that were made to Client.
+// Check the length with:
+//
+//	len(mockedProvider.ClientCalls())
+func (mock *ProviderMock) ClientCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockClient.RLock()
+	calls = mock.calls.Client
+	mock.lockClient.RUnlock()
+	return calls
+}
+
+// Format calls FormatFunc.
+func (mock *ProviderMock) Format() string {
+	if mock.FormatFunc == nil {
+		panic("ProviderMock.FormatFunc: method is nil but Provider.Format was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockFormat.Lock()
+	mock.calls.Format = append(mock.calls.Format, callInfo)
+	mock.lockFormat.Unlock()
+	return mock.FormatFunc()
+}
+
+// FormatCalls gets all the calls that were made to Format.
+// Check the length with:
+//
+//	len(mockedProvider.FormatCalls())
+func (mock *ProviderMock) FormatCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockFormat.RLock()
+	calls = mock.calls.Format
+	mock.lockFormat.RUnlock()
+	return calls
 }
 
 // GetRequest calls GetRequestFunc.
@@ -199,6 +419,65 @@ func (mock *ProviderMock) IDCalls() []struct {
 	return calls
 }
 
+// IsBlank calls IsBlankFunc.
+func (mock *ProviderMock) IsBlank(img []byte) bool {
+	if mock.IsBlankFunc == nil {
+		panic("ProviderMock.IsBlankFunc: method is nil but Provider.IsBlank was just called")
+	}
+	callInfo := struct {
+		Img []byte
+	}{
+		Img: img,
+	}
+	mock.lockIsBlank.Lock()
+	mock.calls.IsBlank = append(mock.calls.IsBlank, callInfo)
+	mock.lockIsBlank.Unlock()
+	return mock.IsBlankFunc(img)
+}
+
+// IsBlankCalls gets all the calls that were made to IsBlank.
+// Check the length with:
+//
+//	len(mockedProvider.IsBlankCalls())
+func (mock *ProviderMock) IsBlankCalls() []struct {
+	Img []byte
+} {
+	var calls []struct {
+		Img []byte
+	}
+	mock.lockIsBlank.RLock()
+	calls = mock.calls.IsBlank
+	mock.lockIsBlank.RUnlock()
+	return calls
+}
+
+// License calls LicenseFunc.
+func (mock *ProviderMock) License() string {
+	if mock.LicenseFunc == nil {
+		panic("ProviderMock.LicenseFunc: method is nil but Provider.License was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockLicense.Lock()
+	mock.calls.License = append(mock.calls.License, callInfo)
+	mock.lockLicense.Unlock()
+	return mock.LicenseFunc()
+}
+
+// LicenseCalls gets all the calls that were made to License.
+// Check the length with:
+//
+//	len(mockedProvider.LicenseCalls())
+func (mock *ProviderMock) LicenseCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockLicense.RLock()
+	calls = mock.calls.License
+	mock.lockLicense.RUnlock()
+	return calls
+}
+
 // MaxJobs calls MaxJobsFunc.
 func (mock *ProviderMock) MaxJobs() int {
 	if mock.MaxJobsFunc == nil {
@@ -226,6 +505,33 @@ func (mock *ProviderMock) MaxJobsCalls() []struct {
 	return calls
 }
 
+// MaxSize calls MaxSizeFunc.
+func (mock *ProviderMock) MaxSize() int64 {
+	if mock.MaxSizeFunc == nil {
+		panic("ProviderMock.MaxSizeFunc: method is nil but Provider.MaxSize was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockMaxSize.Lock()
+	mock.calls.MaxSize = append(mock.calls.MaxSize, callInfo)
+	mock.lockMaxSize.Unlock()
+	return mock.MaxSizeFunc()
+}
+
+// MaxSizeCalls gets all the calls that were made to MaxSize.
+// Check the length with:
+//
+//	len(mockedProvider.MaxSizeCalls())
+func (mock *ProviderMock) MaxSizeCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockMaxSize.RLock()
+	calls = mock.calls.MaxSize
+	mock.lockMaxSize.RUnlock()
+	return calls
+}
+
 // MaxZoom calls MaxZoomFunc.
 func (mock *ProviderMock) MaxZoom() int {
 	if mock.MaxZoomFunc == nil {
@@ -253,6 +559,33 @@ func (mock *ProviderMock) MaxZoomCalls() []struct {
 	return calls
 }
 
+// MinZoom calls MinZoomFunc.
+func (mock *ProviderMock) MinZoom() int {
+	if mock.MinZoomFunc == nil {
+		panic("ProviderMock.MinZoomFunc: method is nil but Provider.MinZoom was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockMinZoom.Lock()
+	mock.calls.MinZoom = append(mock.calls.MinZoom, callInfo)
+	mock.lockMinZoom.Unlock()
+	return mock.MinZoomFunc()
+}
+
+// MinZoomCalls gets all the calls that were made to MinZoom.
+// Check the length with:
+//
+//	len(mockedProvider.MinZoomCalls())
+func (mock *ProviderMock) MinZoomCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockMinZoom.RLock()
+	calls = mock.calls.MinZoom
+	mock.lockMinZoom.RUnlock()
+	return calls
+}
+
 // Name calls NameFunc.
 func (mock *ProviderMock) Name() string {
 	if mock.NameFunc == nil {
@@ -279,3 +612,84 @@ func (mock *ProviderMock) NameCalls() []struct {
 	mock.lockName.RUnlock()
 	return calls
 }
+
+// Projection calls ProjectionFunc.
+func (mock *ProviderMock) Projection() *tile.Elips {
+	if mock.ProjectionFunc == nil {
+		panic("ProviderMock.ProjectionFunc: method is nil but Provider.Projection was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockProjection.Lock()
+	mock.calls.Projection = append(mock.calls.Projection, callInfo)
+	mock.lockProjection.Unlock()
+	return mock.ProjectionFunc()
+}
+
+// ProjectionCalls gets all the calls that were made to Projection.
+// Check the length with:
+//
+//	len(mockedProvider.ProjectionCalls())
+func (mock *ProviderMock) ProjectionCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockProjection.RLock()
+	calls = mock.calls.Projection
+	mock.lockProjection.RUnlock()
+	return calls
+}
+
+// RespectMaxAge calls RespectMaxAgeFunc.
+func (mock *ProviderMock) RespectMaxAge() bool {
+	if mock.RespectMaxAgeFunc == nil {
+		panic("ProviderMock.RespectMaxAgeFunc: method is nil but Provider.RespectMaxAge was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockRespectMaxAge.Lock()
+	mock.calls.RespectMaxAge = append(mock.calls.RespectMaxAge, callInfo)
+	mock.lockRespectMaxAge.Unlock()
+	return mock.RespectMaxAgeFunc()
+}
+
+// RespectMaxAgeCalls gets all the calls that were made to RespectMaxAge.
+// Check the length with:
+//
+//	len(mockedProvider.RespectMaxAgeCalls())
+func (mock *ProviderMock) RespectMaxAgeCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRespectMaxAge.RLock()
+	calls = mock.calls.RespectMaxAge
+	mock.lockRespectMaxAge.RUnlock()
+	return calls
+}
+
+// Restrictions calls RestrictionsFunc.
+func (mock *ProviderMock) Restrictions() string {
+	if mock.RestrictionsFunc == nil {
+		panic("ProviderMock.RestrictionsFunc: method is nil but Provider.Restrictions was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockRestrictions.Lock()
+	mock.calls.Restrictions = append(mock.calls.Restrictions, callInfo)
+	mock.lockRestrictions.Unlock()
+	return mock.RestrictionsFunc()
+}
+
+// RestrictionsCalls gets all the calls that were made to Restrictions.
+// Check the length with:
+//
+//	len(mockedProvider.RestrictionsCalls())
+func (mock *ProviderMock) RestrictionsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRestrictions.RLock()
+	calls = mock.calls.Restrictions
+	mock.lockRestrictions.RUnlock()
+	return calls
+}
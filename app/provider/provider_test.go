@@ -126,3 +126,115 @@ func TestGetMaxJobs(t *testing.T) {
 	assert.NotNil(t, req)
 	assert.Equal(t, MockProviderSchema.MaxJobs, req)
 }
+
+func TestGetClient(t *testing.T) {
+	provider, _ := createProvider(&MockProviderSchema)
+	assert.NotNil(t, provider.Client())
+
+	var invalidClientSchema = MockProviderSchema
+	invalidClientSchema.Client = clientSchema{Proxy: "ftp://127.0.0.1"}
+
+	_, err := createProvider(&invalidClientSchema)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create http client for provider")
+}
+
+func TestGetMaxSize(t *testing.T) {
+	provider, _ := createProvider(&MockProviderSchema)
+	assert.Equal(t, int64(defaultMaxSize), provider.MaxSize())
+
+	var customSizeSchema = MockProviderSchema
+	customSizeSchema.Response = respSchema{MaxSize: 1024}
+
+	provider, _ = createProvider(&customSizeSchema)
+	assert.Equal(t, int64(1024), provider.MaxSize())
+}
+
+func TestIsBlank(t *testing.T) {
+	provider, _ := createProvider(&MockProviderSchema)
+	assert.False(t, provider.IsBlank([]byte("blank")))
+
+	var blankSchema = MockProviderSchema
+	// sha256 of "blank", upper case must be accepted too
+	blankSchema.Response = respSchema{BlankHashes: []string{"FF71CF74ABB3CCB005B8B64371725DB15EDC42C1AD33413BBE561B2DA3C85EF9"}}
+
+	provider, _ = createProvider(&blankSchema)
+	assert.True(t, provider.IsBlank([]byte("blank")))
+	assert.False(t, provider.IsBlank([]byte("not blank")))
+}
+
+func TestProjection(t *testing.T) {
+	p, err := createProvider(&MockProviderSchema)
+	assert.NoError(t, err)
+	assert.Equal(t, &tile.ElipsWGS84, p.Projection())
+}
+
+func TestCoverage(t *testing.T) {
+	p, err := createProvider(&MockProviderSchema)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, p.MinZoom())
+	assert.Empty(t, p.Attribution())
+	bounds := p.Bounds()
+	assert.InDeltaSlice(t, []float64{-180, -85.0840590501, 180, 85.0840590501}, bounds[:], 1e-9)
+
+	s := MockProviderSchema
+	s.MinZoom, s.Bounds, s.Attribution = 3, []float64{30, 50, 40, 60}, "© Agency"
+
+	p, err = createProvider(&s)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, p.MinZoom())
+	assert.Equal(t, "© Agency", p.Attribution())
+	assert.Equal(t, [4]float64{30, 50, 40, 60}, p.Bounds())
+
+	for _, bounds := range [][]float64{{30, 50, 40}, {40, 50, 30, 60}, {-190, 50, 40, 60}, {30, 50, 40, 95}} {
+		s.Bounds = bounds
+		_, err = createProvider(&s)
+		assert.ErrorContains(t, err, "must be west, south, east, north in degrees")
+	}
+
+	s.Bounds, s.MinZoom = nil, s.MaxZoom+1
+	_, err = createProvider(&s)
+	assert.ErrorContains(t, err, "min zoom")
+}
+
+func TestLicense(t *testing.T) {
+	s := MockProviderSchema
+	s.License, s.Restrictions = "https://example.com/terms", "non-commercial use only"
+
+	p, err := createProvider(&s)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/terms", p.License())
+	assert.Equal(t, "non-commercial use only", p.Restrictions())
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema func(s *schema)
+		format string
+	}{
+		{"png extension", func(*schema) {}, "image/png"},
+		{"jpg extension", func(s *schema) { s.Request.URL = "https://example.com/{z}/{x}/{y}.JPG?key=1" }, "image/jpeg"},
+		{"no extension", func(s *schema) { s.Request.URL = "https://example.com/tile?x={x}&y={y}&z={z}.jpg" }, "image/png"},
+		{"specified", func(s *schema) { s.Request.URL, s.Format = "https://example.com/tile?x={x}&y={y}&z={z}", "image/jpeg" }, "image/jpeg"},
+		{"wms", func(s *schema) {
+			s.Type, s.Request.URL, s.WMS = "wms", "https://example.com/wms", wmsSchema{Layers: "ortho", Format: "image/jpeg"}
+		}, "image/jpeg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := MockProviderSchema
+			tt.schema(&s)
+
+			p, err := createProvider(&s)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, p.Format())
+		})
+	}
+
+	s := MockProviderSchema
+	s.Format = "image/webp"
+	_, err := createProvider(&s)
+	assert.ErrorContains(t, err, "format image/webp not supported")
+}
@@ -12,12 +12,25 @@ import (
 
 // schema contains all data about provider
 type schema struct {
-	Name       string    `json:"name"`
-	ID         string    `json:"id"`
-	MaxJobs    int       `json:"max_jobs"`
-	MaxZoom    int       `json:"max_zoom"`
-	Projection string    `json:"proj"`
-	Request    reqSchema `json:"request"`
+	Name         string       `json:"name"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type,omitempty"` // empty or http for url template, wms, mbtiles, pmtiles or dir for local archive
+	Path         string       `json:"path,omitempty"` // local archive path
+	TMS          bool         `json:"tms,omitempty"`  // y of dir archive is counted from bottom
+	MaxJobs      int          `json:"max_jobs"`
+	MinZoom      int          `json:"min_zoom"`
+	MaxZoom      int          `json:"max_zoom"`
+	Bounds       []float64    `json:"bounds,omitempty"`       // west, south, east, north of tiles coverage in degrees
+	Attribution  string       `json:"attribution,omitempty"`  // attribution HTML shown by map clients
+	License      string       `json:"license,omitempty"`      // url of tiles license or terms of use
+	Restrictions string       `json:"restrictions,omitempty"` // usage restrictions of tiles
+	Format       string       `json:"format,omitempty"`       // image/png or image/jpeg, guessed by url extension or wms format if empty
+	Projection   string       `json:"proj"`
+	Request      reqSchema    `json:"request"`
+	Response     respSchema   `json:"response"`
+	Cache        cacheSchema  `json:"cache"`
+	Client       clientSchema `json:"client"`
+	WMS          wmsSchema    `json:"wms"`
 }
 
 type reqSchema struct {
@@ -25,6 +38,15 @@ type reqSchema struct {
 	Headers []headersSchema `json:"headers"`
 }
 
+type respSchema struct {
+	MaxSize     int64    `json:"max_size"`
+	BlankHashes []string `json:"blank_hashes"`
+}
+
+type cacheSchema struct {
+	RespectMaxAge bool `json:"respect_max_age"`
+}
+
 type headersSchema struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
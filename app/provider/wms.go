@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// wmsSchema contains GetMap parameters of WMS provider, request url is a base url of service
+type wmsSchema struct {
+	Layers  string `json:"layers"`
+	Styles  string `json:"styles"`
+	Format  string `json:"format"`  // default image/png
+	Version string `json:"version"` // 1.1.1 or 1.3.0, default 1.3.0
+	CRS     string `json:"crs"`     // EPSG:3857, EPSG:3395, EPSG:4326 or CRS:84, default EPSG:3857
+}
+
+// createWMS build GetMap url template with {bbox} placeholder and function which calculate tile bbox in requested CRS
+func createWMS(s *schema, proj *tile.Elips) (string, func(t *tile.Tile) string, error) {
+	w := s.WMS
+	if w.Layers == "" {
+		return "", nil, fmt.Errorf("layers must be specified for wms provider %v", s.Name)
+	}
+
+	if w.Format == "" {
+		w.Format = "image/png"
+	}
+	if w.Version == "" {
+		w.Version = "1.3.0"
+	}
+	if w.CRS == "" {
+		w.CRS = "EPSG:3857"
+	}
+
+	if w.Version != "1.1.1" && w.Version != "1.3.0" {
+		return "", nil, fmt.Errorf("wms version %v not supported for provider %v", w.Version, s.Name)
+	}
+
+	bbox, err := wmsBBox(w.CRS, w.Version, proj)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w for provider %v", err, s.Name)
+	}
+
+	base, err := url.Parse(s.Request.URL)
+	if err != nil || base.Host == "" {
+		return "", nil, fmt.Errorf("invalid wms url of provider %v", s.Name)
+	}
+
+	crsParam := "CRS"
+	if w.Version == "1.1.1" {
+		crsParam = "SRS"
+	}
+
+	query := base.Query()
+	query.Set("SERVICE", "WMS")
+	query.Set("REQUEST", "GetMap")
+	query.Set("VERSION", w.Version)
+	query.Set("LAYERS", w.Layers)
+	query.Set("STYLES", w.Styles)
+	query.Set("FORMAT", w.Format)
+	query.Set(crsParam, w.CRS)
+	query.Set("WIDTH", strconv.Itoa(tile.Size))
+	query.Set("HEIGHT", strconv.Itoa(tile.Size))
+
+	// placeholder must not be escaped
+	base.RawQuery = query.Encode() + "&BBOX={bbox}"
+
+	return base.String(), bbox, nil
+}
+
+// wmsBBox return function which calculate bbox of tile of provider projection in CRS,
+// EPSG:4326 of WMS 1.3.0 has latitude first axis order
+func wmsBBox(crs, version string, proj *tile.Elips) (func(t *tile.Tile) string, error) {
+	format := func(values ...float64) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	switch strings.ToUpper(crs) {
+	case "EPSG:3857", "EPSG:3395":
+		mercator := &tile.ElipsSpherical
+		if strings.ToUpper(crs) == "EPSG:3395" {
+			mercator = &tile.ElipsWGS84
+		}
+
+		return func(t *tile.Tile) string {
+			minLong, minLat, maxLong, maxLat := t.Bounds(proj)
+			minX, minY := tile.ConvertToMercator(minLat, minLong, mercator)
+			maxX, maxY := tile.ConvertToMercator(maxLat, maxLong, mercator)
+			return format(minX, minY, maxX, maxY)
+		}, nil
+	case "EPSG:4326", "CRS:84":
+		latFirst := strings.ToUpper(crs) == "EPSG:4326" && version == "1.3.0"
+
+		return func(t *tile.Tile) string {
+			minLong, minLat, maxLong, maxLat := t.Bounds(proj)
+			if latFirst {
+				return format(minLat, minLong, maxLat, maxLong)
+			}
+			return format(minLong, minLat, maxLong, maxLat)
+		}, nil
+	default:
+		return nil, fmt.Errorf("wms crs %v not supported", crs)
+	}
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// bboxOf parse BBOX parameter of request
+func bboxOf(t *testing.T, p Provider, tl tile.Tile) []float64 {
+	req := p.GetRequest(&tl)
+
+	parts := strings.Split(req.URL.Query().Get("BBOX"), ",")
+	assert.Len(t, parts, 4)
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		assert.NoError(t, err)
+		values[i] = v
+	}
+	return values
+}
+
+func TestCreateProvider_WMS(t *testing.T) {
+	s := schema{
+		Name: "Agency", ID: "agency", Type: "wms", MaxJobs: 2, MaxZoom: 18, Projection: "spherical",
+		Request: reqSchema{URL: "https://example.com/wms?map=ortho"},
+		WMS:     wmsSchema{Layers: "ortho,roads"},
+	}
+
+	p, err := createProvider(&s)
+	assert.NoError(t, err)
+
+	req := p.GetRequest(&tile.Tile{X: 0, Y: 0, Z: 1})
+	query := req.URL.Query()
+	assert.Equal(t, "example.com", req.URL.Host)
+	assert.Equal(t, "/wms", req.URL.Path)
+	assert.Equal(t, "ortho", query.Get("map"))
+	assert.Equal(t, "WMS", query.Get("SERVICE"))
+	assert.Equal(t, "GetMap", query.Get("REQUEST"))
+	assert.Equal(t, "1.3.0", query.Get("VERSION"))
+	assert.Equal(t, "ortho,roads", query.Get("LAYERS"))
+	assert.Equal(t, "image/png", query.Get("FORMAT"))
+	assert.Equal(t, "EPSG:3857", query.Get("CRS"))
+	assert.Equal(t, "256", query.Get("WIDTH"))
+	assert.Equal(t, "256", query.Get("HEIGHT"))
+
+	// top left quarter of the world
+	bbox := bboxOf(t, p, tile.Tile{X: 0, Y: 0, Z: 1})
+	assert.InDeltaSlice(t, []float64{-20037508.34, 0, 0, 20037508.34}, bbox, 0.01)
+
+	// EPSG:4326 of WMS 1.3.0 has latitude first
+	s.WMS.CRS = "EPSG:4326"
+	p, err = createProvider(&s)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{0, 0, 85.0511287798, 180}, bboxOf(t, p, tile.Tile{X: 1, Y: 0, Z: 1}), 1e-9)
+
+	s.WMS.Version = "1.1.1"
+	p, err = createProvider(&s)
+	assert.NoError(t, err)
+	assert.Equal(t, "EPSG:4326", p.GetRequest(&tile.Tile{}).URL.Query().Get("SRS"))
+	assert.InDeltaSlice(t, []float64{0, 0, 180, 85.0511287798}, bboxOf(t, p, tile.Tile{X: 1, Y: 0, Z: 1}), 1e-9)
+
+	// tiles of WGS84 mercator grid requested in EPSG:3395 are aligned with it
+	s.Projection, s.WMS.CRS, s.WMS.Version = "wgs84", "EPSG:3395", ""
+	p, err = createProvider(&s)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{0, 0, 20037508.34, 20037508.34}, bboxOf(t, p, tile.Tile{X: 1, Y: 0, Z: 1}), 0.01)
+}
+
+func TestCreateProvider_WMSFailed(t *testing.T) {
+	tests := []struct {
+		wms wmsSchema
+		url string
+		err string
+	}{
+		{wms: wmsSchema{}, url: "https://example.com/wms", err: "layers must be specified for wms provider Agency"},
+		{wms: wmsSchema{Layers: "a", Version: "1.0.0"}, url: "https://example.com/wms",
+			err: "wms version 1.0.0 not supported for provider Agency"},
+		{wms: wmsSchema{Layers: "a", CRS: "EPSG:2154"}, url: "https://example.com/wms",
+			err: "wms crs EPSG:2154 not supported for provider Agency"},
+		{wms: wmsSchema{Layers: "a"}, url: "example", err: "invalid wms url of provider Agency"},
+	}
+
+	for _, tt := range tests {
+		_, err := createProvider(&schema{Name: "Agency", ID: "agency", Type: "wms", Projection: "spherical",
+			Request: reqSchema{URL: tt.url}, WMS: tt.wms})
+		assert.EqualError(t, err, tt.err)
+	}
+}
@@ -0,0 +1,248 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/superboomer/maptile/app/geojson"
+	"github.com/superboomer/maptile/app/provider"
+)
+
+// Area is a seeded area: bounding box or polygons, coordinates are [long, lat] like in GeoJSON
+type Area struct {
+	BBox     []float64        `json:"bbox,omitempty"`     // min_long, min_lat, max_long, max_lat
+	Polygons [][][][2]float64 `json:"polygons,omitempty"` // polygons of rings, first ring is outer, others are holes
+}
+
+// span is a row of tiles from x0 to x1 inclusive
+type span struct {
+	y, x0, x1 int
+}
+
+// ParseBBox parse bounding box "min_long,min_lat,max_long,max_lat", empty value is nil bounding box
+func ParseBBox(value string) ([]float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("must be min_long,min_lat,max_long,max_lat")
+	}
+
+	bbox := make([]float64, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %q: %w", part, err)
+		}
+		bbox[i] = v
+	}
+
+	if err := validBBox(bbox); err != nil {
+		return nil, err
+	}
+
+	return bbox, nil
+}
+
+// validBBox check bounding box bounds and order
+func validBBox(bbox []float64) error {
+	if len(bbox) != 4 {
+		return fmt.Errorf("must be min_long,min_lat,max_long,max_lat")
+	}
+
+	if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+		return fmt.Errorf("min values must be less than max values")
+	}
+
+	if bbox[0] < -180 || bbox[2] > 180 || bbox[1] < -90 || bbox[3] > 90 {
+		return fmt.Errorf("coordinates are out of range")
+	}
+
+	return nil
+}
+
+// ParseGeoJSON parse polygons from GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection
+func ParseGeoJSON(data []byte) (*Area, error) {
+	g, err := geojson.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	area := &Area{}
+	err = g.Walk(func(g *geojson.Geometry, _ map[string]any) error {
+		if g.Type != "Polygon" && g.Type != "MultiPolygon" {
+			return fmt.Errorf("geojson type %q not supported", g.Type)
+		}
+		area.Polygons = append(area.Polygons, g.Polygons...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(area.Polygons) == 0 {
+		return nil, fmt.Errorf("geojson doesn't contain polygons")
+	}
+
+	return area, nil
+}
+
+// Validate check that area contains valid bounding box or polygons
+func (a *Area) Validate() error {
+	if a.BBox == nil && len(a.Polygons) == 0 {
+		return fmt.Errorf("bbox or polygon must be specified")
+	}
+
+	if a.BBox != nil {
+		if err := validBBox(a.BBox); err != nil {
+			return fmt.Errorf("bbox is invalid: %w", err)
+		}
+	}
+
+	for _, polygon := range a.Polygons {
+		if len(polygon) == 0 || len(polygon[0]) < 3 {
+			return fmt.Errorf("polygon must contain at least 3 points")
+		}
+
+		for _, ring := range polygon {
+			for _, p := range ring {
+				if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+					return fmt.Errorf("polygon coordinates are out of range")
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// spans return rows of area tiles for zoom ordered by y and x
+func (a *Area) spans(p provider.Provider, zoom int) []span {
+	var spans []span
+
+	if a.BBox != nil {
+		topLeft := p.GetTile(a.BBox[3], a.BBox[0], float64(zoom))
+		bottomRight := p.GetTile(a.BBox[1], a.BBox[2], float64(zoom))
+
+		for y := clamp(topLeft.Y, zoom); y <= clamp(bottomRight.Y, zoom); y++ {
+			spans = append(spans, span{y: y, x0: clamp(topLeft.X, zoom), x1: clamp(bottomRight.X, zoom)})
+		}
+	}
+
+	for _, polygon := range a.Polygons {
+		spans = append(spans, polygonSpans(p, polygon, zoom)...)
+	}
+
+	return mergeSpans(spans)
+}
+
+// polygonSpans return rows of tiles which intersect polygon. Tile is covered if polygon edge crosses it
+// or its middle line is inside polygon (even-odd rule, so holes are excluded).
+func polygonSpans(p provider.Provider, polygon [][][2]float64, zoom int) []span {
+	rings := make([][][2]float64, len(polygon))
+	minY, maxY := math.Inf(1), math.Inf(-1)
+
+	for i, ring := range polygon {
+		rings[i] = make([][2]float64, len(ring))
+		for j, c := range ring {
+			x, y := point(p, c[1], c[0], zoom)
+			rings[i][j] = [2]float64{x, y}
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+
+	var spans []span
+	for y := clamp(int(math.Floor(minY)), zoom); y <= clamp(int(math.Floor(maxY)), zoom); y++ {
+		top, bottom := float64(y), float64(y+1)
+		middle := float64(y) + 0.5
+
+		var crossings []float64
+		for _, ring := range rings {
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%len(ring)]
+
+				// part of edge inside row
+				if x0, x1, ok := clipEdge(a, b, top, bottom); ok {
+					spans = append(spans, span{y: y, x0: clamp(int(math.Floor(x0)), zoom), x1: clamp(int(math.Floor(x1)), zoom)})
+				}
+
+				if (a[1] <= middle) != (b[1] <= middle) {
+					crossings = append(crossings, a[0]+(middle-a[1])*(b[0]-a[0])/(b[1]-a[1]))
+				}
+			}
+		}
+
+		sort.Float64s(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			spans = append(spans, span{y: y, x0: clamp(int(math.Floor(crossings[i])), zoom), x1: clamp(int(math.Floor(crossings[i+1])), zoom)})
+		}
+	}
+
+	return spans
+}
+
+// clipEdge return x range of edge part between top and bottom lines
+func clipEdge(a, b [2]float64, top, bottom float64) (x0, x1 float64, ok bool) {
+	if math.Max(a[1], b[1]) < top || math.Min(a[1], b[1]) >= bottom {
+		return 0, 0, false
+	}
+
+	if a[1] == b[1] {
+		return math.Min(a[0], b[0]), math.Max(a[0], b[0]), true
+	}
+
+	xAt := func(y float64) float64 {
+		y = math.Max(math.Min(y, math.Max(a[1], b[1])), math.Min(a[1], b[1]))
+		return a[0] + (y-a[1])*(b[0]-a[0])/(b[1]-a[1])
+	}
+
+	x0, x1 = xAt(top), xAt(bottom)
+	return math.Min(x0, x1), math.Max(x0, x1), true
+}
+
+// point return position of coordinate in tiles with fractional part. Provider calculates only whole tiles,
+// so the position is calculated 8 zooms deeper where one tile is 1/256 of tile of requested zoom.
+func point(p provider.Provider, lat, long float64, zoom int) (x, y float64) {
+	t := p.GetTile(lat, long, float64(zoom+8))
+	return float64(t.X) / 256, float64(t.Y) / 256
+}
+
+// clamp limit tile coordinate by tiles count of zoom
+func clamp(v, zoom int) int {
+	return max(0, min(v, 1<<zoom-1))
+}
+
+// mergeSpans sort spans and join overlapping and adjacent ones
+func mergeSpans(spans []span) []span {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].y != spans[j].y {
+			return spans[i].y < spans[j].y
+		}
+		return spans[i].x0 < spans[j].x0
+	})
+
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && merged[n-1].y == s.y && s.x0 <= merged[n-1].x1+1 {
+			merged[n-1].x1 = max(merged[n-1].x1, s.x1)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// count return tiles count of spans
+func count(spans []span) int {
+	n := 0
+	for _, s := range spans {
+		n += s.x1 - s.x0 + 1
+	}
+	return n
+}
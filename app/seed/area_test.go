@@ -0,0 +1,146 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// newProvider create provider mock with spherical mercator projection
+func newProvider(maxJobs int) *provider.ProviderMock {
+	return &provider.ProviderMock{
+		IDFunc:      func() string { return "vendor" },
+		MaxZoomFunc: func() int { return 19 },
+		MaxJobsFunc: func() int { return maxJobs },
+		IsBlankFunc: func(img []byte) bool { return string(img) == "blank" },
+		GetTileFunc: func(lat, long, scale float64) tile.Tile {
+			x, y := tile.ConvertToTile(lat, long, scale, &tile.ElipsSpherical)
+			return tile.Tile{X: x, Y: y, Z: int(scale)}
+		},
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	bbox, err := ParseBBox("")
+	assert.NoError(t, err)
+	assert.Nil(t, bbox)
+
+	bbox, err = ParseBBox("10, 20,30,40")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{10, 20, 30, 40}, bbox)
+
+	for _, value := range []string{"1,2,3", "1,2,3,a", "3,2,1,4", "1,4,3,2", "-181,0,0,1", "0,0,1,91"} {
+		_, err = ParseBBox(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseGeoJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		polygons int
+		err      bool
+	}{
+		{"polygon", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, 1, false},
+		{"multipolygon", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[2,2],[3,2],[3,3],[2,2]]]]}`, 2, false},
+		{"feature", `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`, 1, false},
+		{"collection", `{"type":"FeatureCollection","features":[
+			{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}},
+			{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[2,2],[3,2],[3,3],[2,2]]]}}
+		]}`, 2, false},
+		{"point", `{"type":"Point","coordinates":[0,0]}`, 0, true},
+		{"feature without geometry", `{"type":"Feature"}`, 0, true},
+		{"empty collection", `{"type":"FeatureCollection","features":[]}`, 0, true},
+		{"bad coordinates", `{"type":"Polygon","coordinates":[0,0]}`, 0, true},
+		{"invalid json", `{`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area, err := ParseGeoJSON([]byte(tt.data))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, area.Polygons, tt.polygons)
+		})
+	}
+}
+
+func TestArea_Validate(t *testing.T) {
+	assert.Error(t, (&Area{}).Validate())
+	assert.Error(t, (&Area{BBox: []float64{1, 2, 3}}).Validate())
+	assert.Error(t, (&Area{Polygons: [][][][2]float64{{{{0, 0}, {1, 1}}}}}).Validate())
+	assert.Error(t, (&Area{Polygons: [][][][2]float64{{{{0, 0}, {1, 1}, {200, 0}}}}}).Validate())
+	assert.NoError(t, (&Area{BBox: []float64{1, 2, 3, 4}}).Validate())
+	assert.NoError(t, (&Area{Polygons: [][][][2]float64{{{{0, 0}, {1, 1}, {1, 0}}}}}).Validate())
+}
+
+func TestArea_SpansBBox(t *testing.T) {
+	p := newProvider(1)
+	world := &Area{BBox: []float64{-180, -85, 180, 85}}
+
+	assert.Equal(t, []span{{y: 0, x0: 0, x1: 0}}, world.spans(p, 0))
+	assert.Equal(t, 16, count(world.spans(p, 2)))
+
+	// longitude 180 and latitude -85 are on the edge of the last tile
+	assert.Equal(t, 1<<16, count(world.spans(p, 8)))
+}
+
+func TestArea_SpansPolygon(t *testing.T) {
+	p := newProvider(1)
+
+	// rectangle polygon covers the same tiles as bbox
+	bbox := &Area{BBox: []float64{10.1, 40.1, 20.1, 50.1}}
+	rectangle := &Area{Polygons: [][][][2]float64{{{{10.1, 40.1}, {20.1, 40.1}, {20.1, 50.1}, {10.1, 50.1}, {10.1, 40.1}}}}}
+	for z := 0; z <= 10; z++ {
+		assert.Equal(t, bbox.spans(p, z), rectangle.spans(p, z), z)
+	}
+
+	// triangle covers about half of its bbox
+	triangle := &Area{Polygons: [][][][2]float64{{{{10.1, 40.1}, {20.1, 40.1}, {10.1, 50.1}, {10.1, 40.1}}}}}
+	full, half := count(bbox.spans(p, 10)), count(triangle.spans(p, 10))
+	assert.Less(t, half, full*6/10)
+	assert.Greater(t, half, full*4/10)
+
+	tl := p.GetTile(50, 10.2, 10)
+	br := p.GetTile(40.2, 20, 10)
+	assert.True(t, covered(triangle.spans(p, 10), tl))
+	assert.False(t, covered(triangle.spans(p, 10), tile.Tile{X: br.X, Y: tl.Y}))
+	assert.True(t, covered(triangle.spans(p, 10), br))
+
+	// hole excludes tiles inside it
+	withHole := &Area{Polygons: [][][][2]float64{{
+		{{10.1, 40.1}, {20.1, 40.1}, {20.1, 50.1}, {10.1, 50.1}, {10.1, 40.1}},
+		{{12, 42}, {18, 42}, {18, 48}, {12, 48}, {12, 42}},
+	}}}
+	assert.Less(t, count(withHole.spans(p, 10)), full)
+	assert.False(t, covered(withHole.spans(p, 10), p.GetTile(45, 15, 10)))
+	assert.True(t, covered(withHole.spans(p, 10), p.GetTile(41, 11, 10)))
+
+	// bbox and polygon are merged without duplicates
+	both := &Area{BBox: bbox.BBox, Polygons: triangle.Polygons}
+	assert.Equal(t, full, count(both.spans(p, 10)))
+}
+
+func TestMergeSpans(t *testing.T) {
+	merged := mergeSpans([]span{{1, 5, 6}, {0, 0, 2}, {1, 0, 3}, {1, 4, 4}, {0, 4, 5}, {0, 1, 1}})
+	assert.Equal(t, []span{{0, 0, 2}, {0, 4, 5}, {1, 0, 6}}, merged)
+	assert.Equal(t, 12, count(merged))
+}
+
+// covered check if tile is inside spans
+func covered(spans []span, t tile.Tile) bool {
+	for _, s := range spans {
+		if s.y == t.Y && t.X >= s.x0 && t.X <= s.x1 {
+			return true
+		}
+	}
+	return false
+}
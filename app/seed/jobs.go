@@ -0,0 +1,73 @@
+package seed
+
+import (
+	"context"
+	"time"
+
+	"github.com/superboomer/maptile/app/jobs"
+)
+
+// ErrBusy is returned when max count of jobs are already running
+var ErrBusy = jobs.ErrBusy
+
+// Status contains state of seeding job started by Jobs
+type Status struct {
+	jobs.Job
+	Provider string   `json:"provider"`
+	MinZoom  int      `json:"min_zoom"`
+	MaxZoom  int      `json:"max_zoom"`
+	Progress Progress `json:"progress"`
+}
+
+// Jobs runs seeding jobs in background and keeps their statuses in memory,
+// finished jobs are removed after alive duration
+type Jobs struct {
+	seeder   *Seeder
+	registry *jobs.Registry[Status, *Status]
+}
+
+// NewJobs create Jobs which seed tiles with seeder, run up to maxRunning jobs at the same time with deadline of timeout
+// and keep finished jobs for alive duration
+func NewJobs(seeder *Seeder, alive, timeout time.Duration, maxRunning int) *Jobs {
+	return &Jobs{
+		seeder:   seeder,
+		registry: jobs.NewRegistry[Status]("job", alive, timeout, maxRunning),
+	}
+}
+
+// Start validate job and run it in background
+func (j *Jobs) Start(job *Job) (Status, error) {
+	if err := job.Validate(); err != nil {
+		return Status{}, err
+	}
+
+	status := Status{
+		Provider: job.Provider.ID(),
+		MinZoom:  job.MinZoom,
+		MaxZoom:  job.MaxZoom,
+		Progress: Progress{Total: job.Count()},
+	}
+
+	return j.registry.Start(status, func(ctx context.Context, update func(fn func(st *Status))) error {
+		p, err := j.seeder.Run(ctx, job, "", func(p Progress) {
+			update(func(st *Status) { st.Progress = p })
+		})
+		update(func(st *Status) { st.Progress = p })
+		return err
+	})
+}
+
+// Get return status of job
+func (j *Jobs) Get(id string) (Status, error) {
+	return j.registry.Get(id)
+}
+
+// List return statuses of all jobs ordered by start time
+func (j *Jobs) List() []Status {
+	return j.registry.List()
+}
+
+// Cancel stop running job, already seeded tiles are kept in cache
+func (j *Jobs) Cancel(id string) error {
+	return j.registry.Cancel(id)
+}
@@ -0,0 +1,61 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/jobs"
+)
+
+func TestJobs(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	var downloads int64
+	j := NewJobs(&Seeder{Cache: c, Downloader: newDownloader("img", &downloads)}, 0, 0, 0)
+
+	_, err = j.Start(&Job{Provider: newProvider(1)})
+	assert.Error(t, err)
+
+	st, err := j.Start(&Job{Provider: newProvider(2), Area: &Area{BBox: []float64{1, 1, 100, 60}}, MinZoom: 0, MaxZoom: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, jobs.StatusRunning, st.Status)
+	assert.Equal(t, "vendor", st.Provider)
+	assert.Equal(t, 10, st.Progress.Total)
+
+	assert.Eventually(t, func() bool {
+		st, err = j.Get(st.ID)
+		return err == nil && st.Status == jobs.StatusDone
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 9, st.Progress.Downloaded)
+	assert.False(t, st.Finished.IsZero())
+
+	assert.ErrorContains(t, j.Cancel(st.ID), "is not running")
+	assert.ErrorContains(t, j.Cancel("missing"), "not found")
+
+	_, err = j.Get("missing")
+	assert.Error(t, err)
+
+	assert.Len(t, j.List(), 1)
+}
+
+func TestJobs_Cancel(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	j := NewJobs(&Seeder{Cache: c, Downloader: newDownloader("img", new(int64))}, 0, 0, 0)
+
+	st, err := j.Start(&Job{Provider: newProvider(1), Area: &Area{BBox: []float64{1, -80, 179, 80}}, MinZoom: 5, MaxZoom: 5})
+	assert.NoError(t, err)
+	assert.NoError(t, j.Cancel(st.ID))
+
+	assert.Eventually(t, func() bool {
+		st, err = j.Get(st.ID)
+		return err == nil && st.Status == jobs.StatusCancelled
+	}, time.Second, 10*time.Millisecond)
+	assert.Less(t, st.Progress.Done, st.Progress.Total)
+	assert.Empty(t, st.Error)
+}
@@ -0,0 +1,277 @@
+package seed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// batchSize is a count of tiles seeded between progress reports and state saves
+const batchSize = 256
+
+// Job describe seeded tiles
+type Job struct {
+	Provider provider.Provider
+	Area     *Area
+	MinZoom  int
+	MaxZoom  int
+}
+
+// Progress contains seeding counters
+type Progress struct {
+	Total      int `json:"total"`
+	Done       int `json:"done"`       // processed tiles, including resumed ones
+	Resumed    int `json:"resumed"`    // tiles processed by previous run
+	Fresh      int `json:"fresh"`      // tiles already fresh in cache
+	Downloaded int `json:"downloaded"` // tiles downloaded and saved to cache
+	Blank      int `json:"blank"`      // downloaded placeholder tiles which are not cached
+	Failed     int `json:"failed"`
+	Zoom       int `json:"zoom"` // zoom of last processed tile
+}
+
+// state is a checkpoint of seeding which allows to resume it
+type state struct {
+	Key  string `json:"key"`
+	Done int    `json:"done"`
+}
+
+// Seeder downloads tiles of area and saves them to cache
+type Seeder struct {
+	Cache      cache.Cache
+	Downloader downloader.Downloader
+}
+
+// Validate check job zoom range and area
+func (j *Job) Validate() error {
+	if j.Provider == nil {
+		return fmt.Errorf("provider must be specified")
+	}
+
+	if j.MinZoom < 0 || j.MinZoom > j.MaxZoom || j.MaxZoom > j.Provider.MaxZoom() {
+		return fmt.Errorf("zoom range must be within 0-%d and min zoom must not exceed max zoom", j.Provider.MaxZoom())
+	}
+
+	if j.Area == nil {
+		return fmt.Errorf("area must be specified")
+	}
+
+	return j.Area.Validate()
+}
+
+// key return hash of job parameters, state of another job is not resumed
+func (j *Job) key() string {
+	data, _ := json.Marshal(struct {
+		Provider string `json:"provider"`
+		Area     *Area  `json:"area"`
+		MinZoom  int    `json:"min_zoom"`
+		MaxZoom  int    `json:"max_zoom"`
+	}{j.Provider.ID(), j.Area, j.MinZoom, j.MaxZoom})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Count return count of job tiles
+func (j *Job) Count() int {
+	total := 0
+	for z := j.MinZoom; z <= j.MaxZoom; z++ {
+		total += count(j.Area.spans(j.Provider, z))
+	}
+	return total
+}
+
+// Run seed tiles of job ordered by zoom, y and x. Tiles already fresh in cache are skipped.
+// If statePath is specified, progress is saved there after every batch and the next run of
+// the same job continues from saved position, state file is removed when seeding is finished.
+// Progress is reported after every batch.
+func (s *Seeder) Run(ctx context.Context, job *Job, statePath string, progress func(Progress)) (Progress, error) {
+	if err := job.Validate(); err != nil {
+		return Progress{}, err
+	}
+
+	key := job.key()
+	p := Progress{Total: job.Count()}
+
+	if statePath != "" {
+		st, err := loadState(statePath)
+		if err != nil {
+			return p, err
+		}
+		if st.Key == key {
+			p.Resumed = min(st.Done, p.Total)
+		}
+	}
+
+	batch := make([]tile.Tile, 0, batchSize)
+	flush := func() error {
+		s.seedBatch(job.Provider, batch, &p)
+		p.Done += len(batch)
+		batch = batch[:0]
+
+		if statePath != "" {
+			if err := saveState(statePath, state{Key: key, Done: p.Done}); err != nil {
+				return err
+			}
+		}
+
+		if progress != nil {
+			progress(p)
+		}
+		return nil
+	}
+
+	i := 0
+	for z := job.MinZoom; z <= job.MaxZoom; z++ {
+		for _, sp := range job.Area.spans(job.Provider, z) {
+			for x := sp.x0; x <= sp.x1; x++ {
+				i++
+				if i <= p.Resumed {
+					p.Done, p.Zoom = i, z
+					continue
+				}
+
+				if err := ctx.Err(); err != nil {
+					return p, fmt.Errorf("seeding interrupted: %w", err)
+				}
+
+				batch = append(batch, tile.Tile{X: x, Y: sp.y, Z: z})
+				if len(batch) == batchSize {
+					if err := flush(); err != nil {
+						return p, err
+					}
+				}
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return p, err
+		}
+	}
+
+	if statePath != "" {
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return p, fmt.Errorf("failed to remove seed state: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// seedBatch seed tiles concurrently, count of workers is limited by provider max jobs
+func (s *Seeder) seedBatch(l provider.Provider, tiles []tile.Tile, p *Progress) {
+	jobs := make(chan tile.Tile)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < max(1, l.MaxJobs()); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				res := s.seedTile(l, t)
+
+				mutex.Lock()
+				switch res {
+				case resultFresh:
+					p.Fresh++
+				case resultDownloaded:
+					p.Downloaded++
+				case resultBlank:
+					p.Blank++
+				default:
+					p.Failed++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, t := range tiles {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+
+	p.Zoom = tiles[len(tiles)-1].Z
+}
+
+// result is an outcome of single tile seeding
+type result int
+
+const (
+	resultFailed result = iota
+	resultFresh
+	resultDownloaded
+	resultBlank
+)
+
+// seedTile download tile if it's not fresh in cache and save it synchronously
+func (s *Seeder) seedTile(l provider.Provider, t tile.Tile) result {
+	cached := t
+	if _, err := s.Cache.LoadTile(l.ID(), &cached); err == nil {
+		return resultFresh
+	}
+
+	// cache is not passed to downloader, it saves tiles in background and seeding must be durable
+	tiles, err := s.Downloader.Download(nil, l, t)
+	if err != nil || len(tiles) == 0 {
+		return resultFailed
+	}
+
+	if l.IsBlank(tiles[0].Image) {
+		return resultBlank
+	}
+
+	if err = s.Cache.SaveTile(l.ID(), &tiles[0]); err != nil {
+		return resultFailed
+	}
+
+	return resultDownloaded
+}
+
+// loadState read seed state, missing state file is empty state
+func loadState(path string) (state, error) {
+	var st state
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("failed to read seed state: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("failed to decode seed state: %w", err)
+	}
+
+	return st, nil
+}
+
+// saveState write seed state, directory of state file is created if needed
+func saveState(path string, st state) error {
+	data, _ := json.Marshal(st)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create seed state directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save seed state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// newDownloader create downloader mock which returns image for every tile, download of tile with x=0 is failed
+func newDownloader(image string, downloads *int64) *downloader.DownloaderMock {
+	return &downloader.DownloaderMock{
+		DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+			atomic.AddInt64(downloads, 1)
+			if c != nil {
+				return nil, fmt.Errorf("cache must not be passed")
+			}
+
+			result := make([]tile.Tile, 0, len(tiles))
+			for _, t := range tiles {
+				if t.X == 0 {
+					return nil, fmt.Errorf("mock error")
+				}
+				t.Image = []byte(image)
+				result = append(result, t)
+			}
+			return result, nil
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	var downloads int64
+	s := &Seeder{Cache: c, Downloader: newDownloader("img", &downloads)}
+	job := &Job{Provider: newProvider(3), Area: &Area{BBox: []float64{1, 1, 100, 60}}, MinZoom: 0, MaxZoom: 3}
+
+	var reports []Progress
+	p, err := s.Run(context.Background(), job, "", func(p Progress) { reports = append(reports, p) })
+	assert.NoError(t, err)
+
+	// z0: 1 tile, z1: 1, z2: 2, z3: 6, tile of z0 is failed by mock
+	assert.Equal(t, Progress{Total: 10, Done: 10, Downloaded: 9, Failed: 1, Zoom: 3}, p)
+	assert.Equal(t, []Progress{p}, reports)
+	assert.Equal(t, int64(10), downloads)
+
+	img, err := c.LoadTile("vendor", &tile.Tile{X: 6, Y: 2, Z: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("img"), img)
+
+	// fresh tiles are skipped, failed ones are retried
+	p, err = s.Run(context.Background(), job, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Progress{Total: 10, Done: 10, Fresh: 9, Failed: 1, Zoom: 3}, p)
+	assert.Equal(t, int64(11), downloads)
+}
+
+func TestRun_Blank(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	var downloads int64
+	s := &Seeder{Cache: c, Downloader: newDownloader("blank", &downloads)}
+	job := &Job{Provider: newProvider(1), Area: &Area{BBox: []float64{1, 1, 2, 2}}, MinZoom: 1, MaxZoom: 1}
+
+	p, err := s.Run(context.Background(), job, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Progress{Total: 1, Done: 1, Blank: 1, Zoom: 1}, p)
+
+	_, err = c.LoadTile("vendor", &tile.Tile{X: 1, Y: 0, Z: 1})
+	assert.Error(t, err)
+}
+
+func TestRun_Resume(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	var downloads int64
+	s := &Seeder{Cache: c, Downloader: newDownloader("img", &downloads)}
+	job := &Job{Provider: newProvider(4), Area: &Area{BBox: []float64{1, -80, 179, 80}}, MinZoom: 5, MaxZoom: 5}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// interrupt after the first batch
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := s.Run(ctx, job, statePath, func(Progress) { cancel() })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, batchSize, p.Done)
+
+	st, err := loadState(statePath)
+	assert.NoError(t, err)
+	assert.Equal(t, state{Key: job.key(), Done: batchSize}, st)
+
+	// state of another job is ignored
+	other := &Job{Provider: job.Provider, Area: job.Area, MinZoom: 4, MaxZoom: 4}
+	assert.NotEqual(t, job.key(), other.key())
+
+	downloads = 0
+	p, err = s.Run(context.Background(), job, statePath, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, batchSize, p.Resumed)
+	assert.Equal(t, p.Total, p.Done)
+	assert.Equal(t, int64(p.Total-batchSize), downloads)
+
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRun_FailedState(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	var downloads int64
+	s := &Seeder{Cache: c, Downloader: newDownloader("img", &downloads)}
+	job := &Job{Provider: newProvider(1), Area: &Area{BBox: []float64{1, 1, 2, 2}}, MinZoom: 1, MaxZoom: 1}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(statePath, []byte("{"), 0o600))
+
+	_, err = s.Run(context.Background(), job, statePath, nil)
+	assert.ErrorContains(t, err, "failed to decode seed state")
+
+	// state path is a directory
+	_, err = s.Run(context.Background(), job, t.TempDir(), nil)
+	assert.ErrorContains(t, err, "failed to read seed state")
+
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err = s.Run(context.Background(), job, filepath.Join(file, "state.json"), nil)
+	assert.Error(t, err)
+}
+
+func TestJob_Validate(t *testing.T) {
+	area := &Area{BBox: []float64{1, 1, 2, 2}}
+
+	assert.NoError(t, (&Job{Provider: newProvider(1), Area: area, MinZoom: 0, MaxZoom: 19}).Validate())
+	assert.Error(t, (&Job{Area: area}).Validate())
+	assert.Error(t, (&Job{Provider: newProvider(1)}).Validate())
+	assert.Error(t, (&Job{Provider: newProvider(1), Area: area, MinZoom: -1, MaxZoom: 1}).Validate())
+	assert.Error(t, (&Job{Provider: newProvider(1), Area: area, MinZoom: 2, MaxZoom: 1}).Validate())
+	assert.Error(t, (&Job{Provider: newProvider(1), Area: area, MinZoom: 0, MaxZoom: 20}).Validate())
+	assert.Error(t, (&Job{Provider: newProvider(1), Area: &Area{}, MaxZoom: 1}).Validate())
+
+	_, err := (&Seeder{}).Run(context.Background(), &Job{}, "", nil)
+	assert.Error(t, err)
+}
@@ -3,24 +3,52 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/export"
 	"github.com/superboomer/maptile/app/options"
 	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/seed"
+	"github.com/superboomer/maptile/app/watermark"
+	"go.etcd.io/bbolt"
 	"go.uber.org/zap"
 )
 
+// Limits of PDF exports running in background
+const (
+	exportAlive    = time.Hour        // finished exports are kept for download
+	exportTimeout  = 10 * time.Minute // exports running longer are failed
+	maxExports     = 2                // max count of exports running at the same time
+	maxExportTiles = 2048             // max count of tiles of export, tiles are shrunk at scales beyond min zoom of provider
+)
+
+// Limits of cache seeding jobs
+const (
+	seedAlive   = 24 * time.Hour // finished seeding jobs are kept for status requests
+	seedTimeout = 24 * time.Hour // seeding jobs running longer are failed, seeded tiles are kept
+	maxSeeds    = 2              // max count of seeding jobs running at the same time
+)
+
 // API represent struct for business logic
 type API struct {
 	Cache      cache.Cache
+	CacheAdmin cache.Admin        // nil if cache is disabled or doesn't support management
+	Results    *cache.ResultCache // merged images, nil if result cache is disabled
+	Seeds      *seed.Jobs         // nil if cache is disabled
+	Exports    *export.Jobs       // PDF exports running in background, nil if disabled
 	Providers  provider.List
 	Downloader downloader.Downloader
 
 	Logger *zap.Logger
 
 	MaxSide int // max side value
+
+	Offline     bool               // serve tiles only from cache, upstream is never requested
+	Placeholder bool               // replace tiles missing in cache with placeholders instead of not found answer
+	Watermark   *watermark.Options // stamp provider attribution onto merged images, disabled if nil
 }
 
 // CreateAPI create API struct
@@ -31,22 +59,136 @@ func CreateAPI(logger *zap.Logger, cacheOpts *options.Cache, providerSource stri
 		return nil, fmt.Errorf("can't load provider list: %w", err)
 	}
 
+	md := downloader.NewMapDownloader(&http.Client{Timeout: time.Minute})
+
 	api := &API{
 		Cache:      nil,
 		Logger:     logger,
 		Providers:  pl,
 		MaxSide:    maxSide,
-		Downloader: downloader.NewMapDownloader(http.DefaultClient),
+		Downloader: md,
+		Exports:    export.NewJobs(exportAlive, exportTimeout, maxExports),
+	}
+
+	if cacheOpts.Result.Enable {
+		alive := time.Minute * time.Duration(cacheOpts.Result.Alive)
+
+		logger.Info("result cache enabled", zap.Duration("alive", alive), zap.Int("max_size", cacheOpts.Result.MaxSize))
+		api.Results, err = cache.NewResultCache(alive, int64(cacheOpts.Result.MaxSize)<<20)
+		if err != nil {
+			return nil, fmt.Errorf("can't create result cache: %w", err)
+		}
 	}
 
 	if cacheOpts.Enable {
-		logger.Info("cache enabled", zap.String("path", cacheOpts.Path), zap.Duration("alive", time.Minute*time.Duration(cacheOpts.Alive)))
-		с, err := cache.NewCache(cacheOpts.Path, time.Minute*time.Duration(cacheOpts.Alive), nil)
+		md.StaleWhileRevalidate = cacheOpts.StaleWhileRevalidate
+		md.MaxStale = time.Minute * time.Duration(cacheOpts.MaxStale)
+
+		logger.Info("cache enabled", zap.String("backend", cacheOpts.Backend), zap.String("path", cacheOpts.Path),
+			zap.Duration("alive", time.Minute*time.Duration(cacheOpts.Alive)))
+		c, err := createCache(logger, cacheOpts, md.MaxStale)
 		if err != nil {
-			return nil, fmt.Errorf("can't load cache: %w", err)
+			return nil, err
+		}
+
+		api.CacheAdmin = cacheAdmin(c)
+		if api.Results != nil {
+			// saved tile is refreshed, so results built from its previous version are outdated
+			c = cache.NewNotifyCache(c, api.Results.Invalidate)
 		}
-		api.Cache = с
+
+		api.Cache = c
+		api.Seeds = seed.NewJobs(&seed.Seeder{Cache: c, Downloader: md}, seedAlive, seedTimeout, maxSeeds)
 	}
 
 	return api, nil
 }
+
+// createCache create cache tiers listed in cache backend option, disk tier is used by default
+func createCache(logger *zap.Logger, cacheOpts *options.Cache, maxStale time.Duration) (cache.Cache, error) {
+	alive := time.Minute * time.Duration(cacheOpts.Alive)
+
+	backends := strings.Split(cacheOpts.Backend, ",")
+	if strings.TrimSpace(cacheOpts.Backend) == "" {
+		backends = []string{"disk"}
+	}
+
+	var tiers []cache.Cache
+	closeTiers := func() {
+		for _, tier := range tiers {
+			_ = tier.Close()
+		}
+	}
+
+	for _, backend := range backends {
+		var tier cache.Cache
+		var err error
+
+		switch strings.TrimSpace(backend) {
+		case "memory":
+			tier, err = cache.NewMemoryCache(alive, int64(cacheOpts.MemorySize)<<20)
+		case "disk":
+			tier, err = CreateDiskCache(logger, cacheOpts, maxStale, nil)
+		case "s3":
+			tier, err = cache.NewS3Cache(cache.S3Config{
+				Endpoint:  cacheOpts.S3.Endpoint,
+				Bucket:    cacheOpts.S3.Bucket,
+				Region:    cacheOpts.S3.Region,
+				AccessKey: cacheOpts.S3.AccessKey,
+				SecretKey: cacheOpts.S3.SecretKey,
+				Prefix:    cacheOpts.S3.Prefix,
+				PathStyle: cacheOpts.S3.PathStyle,
+			}, alive, &http.Client{Timeout: time.Minute})
+		default:
+			err = fmt.Errorf("cache backend %q not supported", backend)
+		}
+
+		if err != nil {
+			closeTiers()
+			return nil, fmt.Errorf("can't load cache: %w", err)
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	if len(tiers) == 1 {
+		return tiers[0], nil
+	}
+
+	return cache.NewTieredCache(tiers...)
+}
+
+// CreateDiskCache create disk cache with size limit and sweeper, tiles of legacy cache are migrated,
+// index options are passed to bbolt
+func CreateDiskCache(logger *zap.Logger, cacheOpts *options.Cache, maxStale time.Duration, index *bbolt.Options) (*cache.MapCache, error) {
+	c, err := cache.NewCache(cacheOpts.Path, time.Minute*time.Duration(cacheOpts.Alive), index)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := c.Migrate()
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("can't migrate cache: %w", err)
+	}
+	if migrated > 0 {
+		logger.Info("cache migrated", zap.Int("tiles", migrated))
+	}
+
+	if err = c.SetLimit(int64(cacheOpts.MaxSize)<<20, cache.EvictionPolicy(cacheOpts.Eviction)); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("can't set cache limit: %w", err)
+	}
+
+	if cacheOpts.SweepInterval > 0 {
+		c.RunSweeper(time.Minute*time.Duration(cacheOpts.SweepInterval), maxStale, func(removed int, err error) {
+			if err != nil {
+				logger.Error("error occurred when sweeping cache", zap.Error(err))
+				return
+			}
+			logger.Info("cache swept", zap.Int("removed", removed), zap.Any("sizes", c.Sizes()))
+		})
+	}
+
+	return c, nil
+}
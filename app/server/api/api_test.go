@@ -6,8 +6,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/options"
 	"github.com/superboomer/maptile/app/server/api"
+	"github.com/superboomer/maptile/app/tile"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +22,8 @@ func TestCreateAPI_Success(t *testing.T) {
 	assert.NotNil(t, res)
 	assert.Equal(t, 512, res.MaxSide)
 	assert.Nil(t, res.Cache)
+	assert.Nil(t, res.CacheAdmin)
+	assert.Nil(t, res.Seeds)
 	assert.Contains(t, res.Providers.GetAllID(), "google")
 	assert.Contains(t, res.Providers.GetAllID(), "arcgis")
 	assert.Contains(t, res.Providers.GetAllID(), "osm")
@@ -35,6 +39,8 @@ func TestCreateAPI_EnableCacheSuccess(t *testing.T) {
 	assert.NotNil(t, res)
 	assert.Equal(t, 512, res.MaxSide)
 	assert.NotNil(t, res.Cache)
+	assert.NotNil(t, res.CacheAdmin)
+	assert.NotNil(t, res.Seeds)
 }
 
 func TestCreateAPI_EnableCacheFailure(t *testing.T) {
@@ -55,3 +61,68 @@ func TestCreateAPI_LoadProviderListFailure(t *testing.T) {
 	assert.Contains(t, err.Error(), "can't load provider list")
 	assert.Nil(t, res)
 }
+
+func TestCreateAPI_EnableCacheFailedEviction(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "cache-test-eviction")
+	// Execute
+	res, err := api.CreateAPI(zap.NewNop(), &options.Cache{Enable: true, Path: tmpDir, Alive: 60, MaxSize: 1, Eviction: "fifo"},
+		"./../../../example/providers.json", 512)
+	defer os.RemoveAll(tmpDir)
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "can't set cache limit")
+	assert.Nil(t, res)
+}
+
+func TestCreateAPI_EnableTieredCache(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "cache-test-tiered")
+	// Execute
+	res, err := api.CreateAPI(zap.NewNop(), &options.Cache{Enable: true, Path: tmpDir, Alive: 60, Backend: "memory,disk", MemorySize: 1},
+		"./../../../example/providers.json", 512)
+	defer os.RemoveAll(tmpDir)
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+	assert.IsType(t, &cache.TieredCache{}, res.Cache)
+}
+
+func TestCreateAPI_EnableCacheFailedBackend(t *testing.T) {
+	// Execute
+	res, err := api.CreateAPI(zap.NewNop(), &options.Cache{Enable: true, Alive: 60, Backend: "memory,redis", MemorySize: 1},
+		"./../../../example/providers.json", 512)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `cache backend "redis" not supported`)
+	assert.Nil(t, res)
+}
+
+func TestCreateAPI_EnableResultCache(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "cache-test-result")
+	// Execute
+	result := options.Result{Enable: true, Alive: 1, MaxSize: 1}
+	res, err := api.CreateAPI(zap.NewNop(), &options.Cache{Enable: true, Path: tmpDir, Alive: 60, Result: result},
+		"./../../../example/providers.json", 512)
+	defer os.RemoveAll(tmpDir)
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, res.Results)
+	assert.IsType(t, &cache.NotifyCache{}, res.Cache)
+	assert.NotNil(t, res.CacheAdmin)
+
+	// saved tile invalidates results built from it
+	res.Results.Put("key", "osm", []tile.Tile{{X: 1, Y: 1, Z: 1}}, []byte("merged"))
+	assert.NoError(t, res.Cache.SaveTile("osm", &tile.Tile{X: 1, Y: 1, Z: 1, Image: []byte("img")}))
+	_, ok := res.Results.Get("key")
+	assert.False(t, ok)
+	assert.NoError(t, res.Cache.Close())
+}
+
+func TestCreateAPI_EnableResultCacheFailed(t *testing.T) {
+	// Execute
+	res, err := api.CreateAPI(zap.NewNop(), &options.Cache{Result: options.Result{Enable: true, MaxSize: 0}},
+		"./../../../example/providers.json", 512)
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, res)
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/seed"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// cachePurgeModel contains count of purged tiles
+type cachePurgeModel struct {
+	Purged int `json:"purged"`
+}
+
+// cacheSeedModel contains parameters of seeding job, bbox or GeoJSON polygon must be specified
+type cacheSeedModel struct {
+	Provider string          `json:"provider"`
+	BBox     []float64       `json:"bbox"`                         // min_long, min_lat, max_long, max_lat
+	Polygon  json.RawMessage `json:"polygon" swaggertype:"object"` // GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection
+	MinZoom  int             `json:"min_zoom"`
+	MaxZoom  int             `json:"max_zoom"`
+}
+
+// CacheStats godoc
+// @Summary handler return statistics of cached tiles
+// @Description return JSON object with tile count, size, age histogram and hit/miss ratio per provider
+// @Accept  text/plain
+// @Produce  application/json
+// @Security AdminToken
+// @Success 200 {object} map[string]cache.Stats
+// @Failure 500 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /cache/stats [get]
+func (a *API) CacheStats(w http.ResponseWriter, req *http.Request) {
+	stats, err := a.CacheAdmin.Stats()
+	if err != nil {
+		a.Logger.Error("error occurred when collecting cache stats", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeJSON(w, http.StatusInternalServerError, mapErrorModel{Status: http.StatusInternalServerError, Body: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// CachePurge godoc
+// @Summary handler remove cached tiles of provider
+// @Description remove all cached tiles of provider or only tiles in zoom range and/or bounding box
+// @Accept  text/plain
+// @Produce  application/json
+// @Security AdminToken
+// @Param provider query string true "tile provider"
+// @Param min_zoom query int false "min zoom of purged tiles"
+// @Param max_zoom query int false "max zoom of purged tiles"
+// @Param bbox query string false "bounding box of purged tiles: min_long,min_lat,max_long,max_lat"
+// @Success 200 {object} cachePurgeModel
+// @Failure 400 {object} mapErrorModel
+// @Failure 500 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /cache/purge [post]
+func (a *API) CachePurge(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, mapErrorModel{Status: http.StatusMethodNotAllowed, Body: "method not allowed"})
+		return
+	}
+
+	vendor, match, err := a.parsePurgeRequest(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: err.Error()})
+		return
+	}
+
+	purged, err := a.CacheAdmin.Purge(vendor.ID(), match)
+	if err != nil {
+		a.Logger.Error("error occurred when purging cache", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeJSON(w, http.StatusInternalServerError, mapErrorModel{Status: http.StatusInternalServerError, Body: err.Error()})
+		return
+	}
+
+	if a.Results != nil {
+		a.Results.Purge(vendor.ID())
+	}
+
+	a.Logger.Info("cache purged", zap.String("vendor", vendor.ID()), zap.Int("purged", purged), zap.String("query", req.URL.RawQuery),
+		zap.String("req_id", req.Header.Get("X-Request-ID")))
+
+	writeJSON(w, http.StatusOK, cachePurgeModel{Purged: purged})
+}
+
+// CacheTile godoc
+// @Summary handler return cache metadata of single tile
+// @Description return JSON object with validators, content type, hash, source, saved and expiration time of cached tile
+// @Accept  text/plain
+// @Produce  application/json
+// @Security AdminToken
+// @Param provider query string true "tile provider"
+// @Param x query int true "tile x"
+// @Param y query int true "tile y"
+// @Param z query int true "tile zoom"
+// @Success 200 {object} cache.Info
+// @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /cache/tile [get]
+func (a *API) CacheTile(w http.ResponseWriter, req *http.Request) {
+	vendor, err := a.parseProvider(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: err.Error()})
+		return
+	}
+
+	var t tile.Tile
+	coords := []struct {
+		name  string
+		value *int
+	}{{"x", &t.X}, {"y", &t.Y}, {"z", &t.Z}}
+
+	for _, c := range coords {
+		if *c.value, err = strconv.Atoi(req.URL.Query().Get(c.name)); err != nil {
+			writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest,
+				Body: fmt.Sprintf("%s parameter error: %s", c.name, err.Error())})
+			return
+		}
+	}
+
+	info, err := a.CacheAdmin.Info(vendor.ID(), &t)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// CacheSeed godoc
+// @Summary handler start, show and cancel cache seeding jobs
+// @Description POST start seeding job for bbox or GeoJSON polygon and zoom range, tiles already fresh in cache are skipped.
+// @Description GET return status of job specified by id or all jobs. DELETE cancel job specified by id.
+// @Accept  application/json
+// @Produce  application/json
+// @Security AdminToken
+// @Param job body cacheSeedModel false "seeding job, required for POST"
+// @Param id query string false "job id, required for DELETE"
+// @Success 200 {object} seed.Status
+// @Success 202 {object} seed.Status
+// @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapErrorModel
+// @Failure 429 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /cache/seed [post]
+// @Router /cache/seed [get]
+// @Router /cache/seed [delete]
+func (a *API) CacheSeed(w http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+
+	switch req.Method {
+	case http.MethodGet:
+		if id == "" {
+			writeJSON(w, http.StatusOK, a.Seeds.List())
+			return
+		}
+
+		st, err := a.Seeds.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	case http.MethodPost:
+		if a.Offline {
+			writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: "seeding is not possible in offline mode"})
+			return
+		}
+
+		job, err := a.parseSeedRequest(req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: err.Error()})
+			return
+		}
+
+		st, err := a.Seeds.Start(job)
+		if errors.Is(err, seed.ErrBusy) {
+			writeJSON(w, http.StatusTooManyRequests, mapErrorModel{Status: http.StatusTooManyRequests, Body: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: err.Error()})
+			return
+		}
+
+		a.Logger.Info("cache seeding started", zap.String("id", st.ID), zap.String("vendor", st.Provider), zap.Int("tiles", st.Progress.Total),
+			zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeJSON(w, http.StatusAccepted, st)
+	case http.MethodDelete:
+		if err := a.Seeds.Cancel(id); err != nil {
+			writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: err.Error()})
+			return
+		}
+
+		st, _ := a.Seeds.Get(id)
+		writeJSON(w, http.StatusOK, st)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, mapErrorModel{Status: http.StatusMethodNotAllowed, Body: "method not allowed"})
+	}
+}
+
+// parseSeedRequest return seeding job from request body
+func (a *API) parseSeedRequest(req *http.Request) (*seed.Job, error) {
+	var m cacheSeedModel
+	if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("can't decode job: %w", err)
+	}
+
+	vendor, err := a.Providers.Get(m.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("provider parameter error: %s not found", m.Provider)
+	}
+
+	area := &seed.Area{}
+	if len(m.Polygon) > 0 {
+		if area, err = seed.ParseGeoJSON(m.Polygon); err != nil {
+			return nil, fmt.Errorf("polygon parameter error: %w", err)
+		}
+	}
+	area.BBox = m.BBox
+
+	return &seed.Job{Provider: vendor, Area: area, MinZoom: m.MinZoom, MaxZoom: m.MaxZoom}, nil
+}
+
+// parseProvider return provider specified in query
+func (a *API) parseProvider(req *http.Request) (provider.Provider, error) {
+	pVendor := req.URL.Query().Get("provider")
+	if pVendor == "" {
+		return nil, fmt.Errorf("provider parameter error: not specified")
+	}
+
+	vendor, err := a.Providers.Get(pVendor)
+	if err != nil {
+		return nil, fmt.Errorf("provider parameter error: %s not found", pVendor)
+	}
+
+	return vendor, nil
+}
+
+// parsePurgeRequest return provider and matcher of purged tiles, nil matcher purges all tiles
+func (a *API) parsePurgeRequest(req *http.Request) (provider.Provider, func(t *tile.Tile) bool, error) {
+	vendor, err := a.parseProvider(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	minZoom, maxZoom := 0, vendor.MaxZoom()
+	if v := req.URL.Query().Get("min_zoom"); v != "" {
+		if minZoom, err = strconv.Atoi(v); err != nil {
+			return nil, nil, fmt.Errorf("min_zoom parameter error: %w", err)
+		}
+	}
+	if v := req.URL.Query().Get("max_zoom"); v != "" {
+		if maxZoom, err = strconv.Atoi(v); err != nil {
+			return nil, nil, fmt.Errorf("max_zoom parameter error: %w", err)
+		}
+	}
+
+	bbox, err := seed.ParseBBox(req.URL.Query().Get("bbox"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bbox parameter error: %w", err)
+	}
+
+	if req.URL.Query().Get("min_zoom") == "" && req.URL.Query().Get("max_zoom") == "" && bbox == nil {
+		return vendor, nil, nil
+	}
+
+	// tile ranges of bbox are calculated once per zoom
+	ranges := make(map[int][2]tile.Tile)
+
+	return vendor, func(t *tile.Tile) bool {
+		if t.Z < minZoom || t.Z > maxZoom {
+			return false
+		}
+
+		if bbox == nil {
+			return true
+		}
+
+		r, ok := ranges[t.Z]
+		if !ok {
+			r = [2]tile.Tile{
+				vendor.GetTile(bbox[3], bbox[0], float64(t.Z)), // top left
+				vendor.GetTile(bbox[1], bbox[2], float64(t.Z)), // bottom right
+			}
+			ranges[t.Z] = r
+		}
+
+		return t.X >= r[0].X && t.X <= r[1].X && t.Y >= r[0].Y && t.Y <= r[1].Y
+	}, nil
+}
+
+// writeJSON write value as JSON response with status code
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	results, _ := json.Marshal(value)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(results)
+}
+
+// cacheAdmin return management interface of cache, nil if cache doesn't support it
+func cacheAdmin(c cache.Cache) cache.Admin {
+	if admin, ok := cache.AdminOf(c); ok {
+		return admin
+	}
+	return nil
+}
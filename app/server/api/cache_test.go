@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/seed"
+	"github.com/superboomer/maptile/app/tile"
+	"go.uber.org/zap"
+)
+
+// newCacheAPI create API with mocked providers and cache admin
+func newCacheAPI(admin cache.Admin) *API {
+	return &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				if key != "example" {
+					return nil, fmt.Errorf("not found")
+				}
+				return &provider.ProviderMock{
+					MaxZoomFunc: func() int { return 19 },
+					IDFunc:      func() string { return "ex" },
+					MaxJobsFunc: func() int { return 1 },
+					IsBlankFunc: func(img []byte) bool { return false },
+					GetTileFunc: func(lat, long, scale float64) tile.Tile {
+						// 1 degree tiles, y grows to the south
+						return tile.Tile{X: int(long), Y: int(-lat), Z: int(scale)}
+					},
+				}, nil
+			},
+		},
+		CacheAdmin: admin,
+	}
+}
+
+func TestCacheStatsHandler(t *testing.T) {
+	a := newCacheAPI(&cache.AdminMock{
+		StatsFunc: func() (map[string]*cache.Stats, error) {
+			return map[string]*cache.Stats{"ex": {Tiles: 2, Bytes: 20, Hits: 1, Misses: 1, HitRatio: 0.5}}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	a.CacheStats(rr, httptest.NewRequest(http.MethodGet, "/cache/stats", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var stats map[string]cache.Stats
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Equal(t, 2, stats["ex"].Tiles)
+	assert.Equal(t, 0.5, stats["ex"].HitRatio)
+}
+
+func TestCacheStatsHandler_Error(t *testing.T) {
+	a := newCacheAPI(&cache.AdminMock{
+		StatsFunc: func() (map[string]*cache.Stats, error) { return nil, fmt.Errorf("mock error") },
+	})
+
+	rr := httptest.NewRecorder()
+	a.CacheStats(rr, httptest.NewRequest(http.MethodGet, "/cache/stats", http.NoBody))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"status":500,"body":"mock error"}`, rr.Body.String())
+}
+
+func TestCachePurgeHandler(t *testing.T) {
+	admin := &cache.AdminMock{
+		PurgeFunc: func(vendor string, match func(t *tile.Tile) bool) (int, error) { return 3, nil },
+	}
+	a := newCacheAPI(admin)
+
+	// all tiles
+	rr := httptest.NewRecorder()
+	a.CachePurge(rr, httptest.NewRequest(http.MethodPost, "/cache/purge?provider=example", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"purged":3}`, rr.Body.String())
+	assert.Equal(t, "ex", admin.PurgeCalls()[0].Vendor)
+	assert.Nil(t, admin.PurgeCalls()[0].Match)
+
+	// zoom range and bbox
+	rr = httptest.NewRecorder()
+	target := "/cache/purge?provider=example&min_zoom=5&max_zoom=10&bbox=10,-20,12,-18"
+	a.CachePurge(rr, httptest.NewRequest(http.MethodDelete, target, http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	match := admin.PurgeCalls()[1].Match
+	assert.True(t, match(&tile.Tile{X: 11, Y: 19, Z: 5}))
+	assert.True(t, match(&tile.Tile{X: 10, Y: 18, Z: 10}))
+	assert.True(t, match(&tile.Tile{X: 12, Y: 20, Z: 10}))
+	assert.False(t, match(&tile.Tile{X: 11, Y: 19, Z: 4}))
+	assert.False(t, match(&tile.Tile{X: 11, Y: 19, Z: 11}))
+	assert.False(t, match(&tile.Tile{X: 13, Y: 19, Z: 5}))
+	assert.False(t, match(&tile.Tile{X: 11, Y: 17, Z: 5}))
+}
+
+func TestCachePurgeHandler_BadRequest(t *testing.T) {
+	a := newCacheAPI(&cache.AdminMock{})
+
+	tests := []struct {
+		method string
+		query  string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "provider=example", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodPost, "", http.StatusBadRequest, "provider parameter error: not specified"},
+		{http.MethodPost, "provider=example2", http.StatusBadRequest, "provider parameter error: example2 not found"},
+		{http.MethodPost, "provider=example&min_zoom=a", http.StatusBadRequest, "min_zoom parameter error"},
+		{http.MethodPost, "provider=example&max_zoom=a", http.StatusBadRequest, "max_zoom parameter error"},
+		{http.MethodPost, "provider=example&bbox=1,2,3", http.StatusBadRequest, "bbox parameter error: must be"},
+		{http.MethodPost, "provider=example&bbox=1,2,3,a", http.StatusBadRequest, "bbox parameter error"},
+		{http.MethodPost, "provider=example&bbox=3,2,1,4", http.StatusBadRequest, "bbox parameter error: min values"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.CachePurge(rr, httptest.NewRequest(tt.method, "/cache/purge?"+tt.query, http.NoBody))
+
+		assert.Equal(t, tt.code, rr.Code, tt.query)
+		assert.Contains(t, rr.Body.String(), tt.body, tt.query)
+	}
+}
+
+func TestCacheTileHandler(t *testing.T) {
+	admin := &cache.AdminMock{
+		InfoFunc: func(vendor string, t *tile.Tile) (*cache.Info, error) {
+			if t.X != 1 || t.Y != 2 || t.Z != 3 {
+				return nil, fmt.Errorf("failed to load tile info: tile not found")
+			}
+			return &cache.Info{Meta: tile.Meta{ContentType: "image/png"}, Size: 10}, nil
+		},
+	}
+	a := newCacheAPI(admin)
+
+	rr := httptest.NewRecorder()
+	a.CacheTile(rr, httptest.NewRequest(http.MethodGet, "/cache/tile?provider=example&x=1&y=2&z=3", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"content_type":"image/png"`)
+	assert.Contains(t, rr.Body.String(), `"size":10`)
+	assert.Equal(t, "ex", admin.InfoCalls()[0].Vendor)
+
+	rr = httptest.NewRecorder()
+	a.CacheTile(rr, httptest.NewRequest(http.MethodGet, "/cache/tile?provider=example&x=2&y=2&z=3", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	a.CacheTile(rr, httptest.NewRequest(http.MethodGet, "/cache/tile?provider=example&x=1&y=a&z=3", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "y parameter error")
+
+	rr = httptest.NewRecorder()
+	a.CacheTile(rr, httptest.NewRequest(http.MethodGet, "/cache/tile?x=1&y=2&z=3", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCacheSeedHandler(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	a := newCacheAPI(nil)
+	a.Seeds = seed.NewJobs(&seed.Seeder{Cache: c, Downloader: &downloader.DownloaderMock{
+		DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+			tiles[0].Image = []byte("img")
+			return tiles, nil
+		},
+	}}, 0, 0, 0)
+
+	rr := httptest.NewRecorder()
+	body := `{"provider":"example","bbox":[10,-20,12,-18],"min_zoom":1,"max_zoom":2}`
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodPost, "/cache/seed", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	var st seed.Status
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &st))
+	assert.Equal(t, "ex", st.Provider)
+	assert.Equal(t, 2, st.Progress.Total) // mock tiles are clamped by zoom
+
+	assert.Eventually(t, func() bool {
+		rr = httptest.NewRecorder()
+		a.CacheSeed(rr, httptest.NewRequest(http.MethodGet, "/cache/seed?id="+st.ID, http.NoBody))
+		return rr.Code == http.StatusOK && strings.Contains(rr.Body.String(), `"status":"done"`)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = c.LoadTile("ex", &tile.Tile{X: 3, Y: 3, Z: 2})
+	assert.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodGet, "/cache/seed", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), st.ID)
+
+	// polygon job
+	rr = httptest.NewRecorder()
+	body = `{"provider":"example","polygon":{"type":"Polygon","coordinates":[[[10,-18],[12,-18],[12,-20],[10,-18]]]},"max_zoom":2}`
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodPost, "/cache/seed", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	// finished job can't be cancelled
+	rr = httptest.NewRecorder()
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodDelete, "/cache/seed?id="+st.ID, http.NoBody))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestCacheSeedHandler_Busy(t *testing.T) {
+	c, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	a := newCacheAPI(nil)
+	a.Seeds = seed.NewJobs(&seed.Seeder{Cache: c, Downloader: &downloader.DownloaderMock{
+		DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+			<-release
+			tiles[0].Image = []byte("img")
+			return tiles, nil
+		},
+	}}, time.Hour, time.Minute, 1)
+
+	body := `{"provider":"example","bbox":[10,-20,12,-18],"min_zoom":1,"max_zoom":2}`
+
+	rr := httptest.NewRecorder()
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodPost, "/cache/seed", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	rr = httptest.NewRecorder()
+	a.CacheSeed(rr, httptest.NewRequest(http.MethodPost, "/cache/seed", strings.NewReader(body)))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "too many jobs are running")
+}
+
+func TestCacheSeedHandler_BadRequest(t *testing.T) {
+	a := newCacheAPI(nil)
+	a.Seeds = seed.NewJobs(&seed.Seeder{}, 0, 0, 0)
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		code   int
+		error  string
+	}{
+		{http.MethodPut, "/cache/seed", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodGet, "/cache/seed?id=missing", "", http.StatusNotFound, "job missing not found"},
+		{http.MethodDelete, "/cache/seed?id=missing", "", http.StatusNotFound, "job missing not found"},
+		{http.MethodPost, "/cache/seed", "{", http.StatusBadRequest, "can't decode job"},
+		{http.MethodPost, "/cache/seed", `{"provider":"example2"}`, http.StatusBadRequest, "provider parameter error"},
+		{http.MethodPost, "/cache/seed", `{"provider":"example","polygon":{"type":"Point"}}`, http.StatusBadRequest, "polygon parameter error"},
+		{http.MethodPost, "/cache/seed", `{"provider":"example","max_zoom":2}`, http.StatusBadRequest, "bbox or polygon must be specified"},
+		{http.MethodPost, "/cache/seed", `{"provider":"example","bbox":[1,2,3,4],"max_zoom":20}`, http.StatusBadRequest, "zoom range"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.CacheSeed(rr, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+
+		assert.Equal(t, tt.code, rr.Code, tt.body)
+		assert.Contains(t, rr.Body.String(), tt.error, tt.body)
+	}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/export"
+	"github.com/superboomer/maptile/app/overlay"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/seed"
+	"github.com/superboomer/maptile/app/tile"
+	"github.com/superboomer/maptile/app/watermark"
+)
+
+// exportParams contains parsed parameters of export request
+type exportParams struct {
+	Sheet     export.Sheet
+	View      *overlay.View
+	Scale     float64 // denominator of scale at the center of map frame
+	Title     string
+	CacheOnly bool
+	Async     bool
+}
+
+// Export godoc
+// @Summary handler for print-ready PDF export of map at paper size, DPI and scale
+// @Description return PDF sheet with map frame, title, scale bar and provider attribution.
+// @Description Map frame is centered on lat, long at scale 1:scale or shows bbox, zoom and pixel size of the frame are chosen by paper, orientation and dpi.
+// @Description Exports of more than max side squared tiles or with async run in background, status is returned with 202 and the document is served by /export/{id}/pdf.
+// @Produce application/pdf
+// @Param provider query string true "tile provider"
+// @Param paper query string false "paper size" Enums(a0, a1, a2, a3, a4, a5, letter, legal, tabloid) default(a4)
+// @Param orientation query string false "paper orientation" Enums(portrait, landscape) default(portrait)
+// @Param dpi query int false "resolution of map frame in dots per inch" default(300) minimum(72) maximum(600)
+// @Param scale query number false "denominator of scale 1:scale, required unless bbox is set" minimum(100) maximum(100000000)
+// @Param lat query number false "latitude of sheet center, required unless bbox is set"
+// @Param long query number false "longitude of sheet center, required unless bbox is set"
+// @Param bbox query string false "min_long,min_lat,max_long,max_lat fitted into map frame"
+// @Param title query string false "title above map frame" maxlength(200)
+// @Param cache_only query bool false "serve tiles only from cache, never request upstream"
+// @Param async query bool false "run export in background regardless of its size"
+// @Success 200 {file} application/pdf
+// @Success 202 {object} export.Status
+// @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapMissingModel
+// @Failure 429 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Header 200 {integer} X-Map-Zoom "zoom of tiles of map frame"
+// @Header 200 {string} X-Map-Bounds "west,south,east,north of map frame"
+// @Header 200 {number} X-Map-Scale "denominator of scale at the center of map frame"
+// @Router /export [get]
+func (a *API) Export(w http.ResponseWriter, req *http.Request) {
+	params, vendor, err := a.parseExportRequest(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest, Body: err.Error()})
+		return
+	}
+
+	width, height := params.Sheet.Pixels()
+	setViewHeaders(w, params.View, width, height)
+	w.Header().Set("X-Map-Scale", strconv.FormatFloat(params.Scale, 'f', 0, 64))
+
+	columns, rows, tiles := fitGrid(params.View, width, height)
+
+	// tiles are magnified or shrunk to keep the scale, zoom limits of provider may make too many of them
+	if len(tiles) > maxExportTiles {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest,
+			Body: fmt.Sprintf("export of %d tiles is larger than %d tiles, change scale or bbox", len(tiles), maxExportTiles)})
+		return
+	}
+
+	if params.Async || len(tiles) > a.MaxSide*a.MaxSide {
+		a.startExport(w, req, params, vendor, columns, rows, tiles)
+		return
+	}
+
+	tiles, _, ok := a.downloadTiles(w, req, vendor, params.CacheOnly, tiles)
+	if !ok {
+		return
+	}
+
+	result, err := a.renderExport(columns, rows, tiles, vendor, params)
+	if err != nil {
+		a.Logger.Error("error occurred when rendering tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeJSON(w, http.StatusInternalServerError, mapErrorModel{
+			Status: http.StatusInternalServerError,
+			Body:   fmt.Sprintf("error occurred when rendering tiles: %s", err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	_, _ = w.Write(result)
+
+	a.Logger.Info("new export request", zap.String("paper", params.Sheet.Paper), zap.Int("dpi", params.Sheet.DPI),
+		zap.Int("zoom", params.View.Zoom), zap.Int("tiles", len(tiles)), zap.String("vendor", vendor.Name()),
+		zap.String("req_id", req.Header.Get("X-Request-ID")))
+}
+
+// ExportStatus godoc
+// @Summary handler return status of export running in background
+// @Produce application/json
+// @Param id path string true "export id"
+// @Success 200 {object} export.Status
+// @Failure 404 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /export/{id} [get]
+func (a *API) ExportStatus(w http.ResponseWriter, req *http.Request) {
+	if a.Exports == nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: "exports in background are disabled"})
+		return
+	}
+
+	st, err := a.Exports.Get(req.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, st)
+}
+
+// ExportResult godoc
+// @Summary handler return PDF document of export done in background
+// @Produce application/pdf
+// @Param id path string true "export id"
+// @Success 200 {file} application/pdf
+// @Failure 404 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /export/{id}/pdf [get]
+func (a *API) ExportResult(w http.ResponseWriter, req *http.Request) {
+	if a.Exports == nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: "exports in background are disabled"})
+		return
+	}
+
+	result, err := a.Exports.Result(req.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	_, _ = w.Write(result)
+}
+
+// startExport run export in background and answer its status
+func (a *API) startExport(w http.ResponseWriter, req *http.Request, params *exportParams, vendor provider.Provider,
+	columns, rows []float64, tiles []tile.Tile) {
+	if a.Exports == nil {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest,
+			Body: fmt.Sprintf("export of %d tiles is larger than %d tiles and exports in background are disabled", len(tiles), a.MaxSide*a.MaxSide)})
+		return
+	}
+
+	st, err := a.Exports.Start(vendor.ID(), len(tiles), func(ctx context.Context) ([]byte, error) {
+		downloaded, err := a.fetchBatches(ctx, vendor, params.CacheOnly, tiles)
+		if err != nil {
+			return nil, err
+		}
+
+		return a.renderExport(columns, rows, downloaded, vendor, params)
+	})
+	if errors.Is(err, export.ErrBusy) {
+		writeJSON(w, http.StatusTooManyRequests, mapErrorModel{Status: http.StatusTooManyRequests, Body: err.Error()})
+		return
+	}
+
+	a.Logger.Info("export started", zap.String("id", st.ID), zap.String("paper", params.Sheet.Paper), zap.Int("dpi", params.Sheet.DPI),
+		zap.Int("zoom", params.View.Zoom), zap.Int("tiles", st.Tiles), zap.String("vendor", vendor.Name()),
+		zap.String("req_id", req.Header.Get("X-Request-ID")))
+
+	w.Header().Set("Location", "/export/"+st.ID)
+	writeJSON(w, http.StatusAccepted, st)
+}
+
+// fetchBatches fetch tiles by batches of max side squared tiles, downloader isn't aware of context,
+// so it's checked between batches and before rendering
+func (a *API) fetchBatches(ctx context.Context, vendor provider.Provider, cacheOnly bool, tiles []tile.Tile) ([]tile.Tile, error) {
+	batch := max(1, a.MaxSide*a.MaxSide)
+	result := make([]tile.Tile, 0, len(tiles))
+
+	for start := 0; start < len(tiles); start += batch {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("export is stopped: %w", err)
+		}
+
+		downloaded, _, err := a.fetchTiles(vendor, cacheOnly, tiles[start:min(start+batch, len(tiles))])
+		if err != nil {
+			return nil, fmt.Errorf("error occurred when dowloading tiles: %w", err)
+		}
+		result = append(result, downloaded...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("export is stopped: %w", err)
+	}
+
+	return result, nil
+}
+
+// renderExport render tiles into map frame and compose PDF sheet of it, areas beyond the poles are white
+func (a *API) renderExport(columns, rows []float64, tiles []tile.Tile, vendor provider.Provider, params *exportParams) ([]byte, error) {
+	layer, err := downloader.Render(columns, rows, tiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(layer.Rect)
+	draw.Draw(img, img.Rect, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(img, img.Rect, layer, image.Point{}, draw.Over)
+
+	frame, err := encodeJPEG(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return params.Sheet.Compose(&export.Map{
+		Image:       frame,
+		Title:       params.Title,
+		Attribution: watermark.PlainText(vendor.Attribution()),
+		Scale:       params.Scale,
+	}), nil
+}
+
+// parseExportRequest parse sheet, position and scale of export, view of map frame is chosen by them
+func (a *API) parseExportRequest(req *http.Request) (*exportParams, provider.Provider, error) {
+	vendor, err := a.parseProvider(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := req.URL.Query()
+	params := exportParams{Sheet: export.Sheet{Paper: "a4", Orientation: export.Portrait, DPI: 300}}
+
+	if p := query.Get("paper"); p != "" {
+		params.Sheet.Paper = strings.ToLower(p)
+	}
+	if p := query.Get("orientation"); p != "" {
+		params.Sheet.Orientation = strings.ToLower(p)
+	}
+	if p := query.Get("dpi"); p != "" {
+		if params.Sheet.DPI, err = strconv.Atoi(p); err != nil {
+			return nil, nil, fmt.Errorf("dpi parameter error: %w", err)
+		}
+	}
+	if err = params.Sheet.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("sheet parameters error: %w", err)
+	}
+
+	params.Title = query.Get("title")
+	if utf8.RuneCountInString(params.Title) > export.MaxTitle {
+		return nil, nil, fmt.Errorf("title parameter error: must not be longer than %d characters", export.MaxTitle)
+	}
+
+	for name, value := range map[string]*bool{"cache_only": &params.CacheOnly, "async": &params.Async} {
+		if p := query.Get(name); p != "" {
+			if *value, err = strconv.ParseBool(p); err != nil {
+				return nil, nil, fmt.Errorf("%s parameter error: %w", name, err)
+			}
+		}
+	}
+
+	proj := vendorProjection(vendor)
+
+	bbox, err := seed.ParseBBox(query.Get("bbox"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bbox parameter error: %w", err)
+	}
+	if bbox != nil {
+		params.View, params.Scale = params.Sheet.FitBBox(proj, [4]float64(bbox), vendor.MinZoom(), vendor.MaxZoom())
+		return &params, vendor, nil
+	}
+
+	lat, err := parseFloatParam(query.Get("lat"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("lat parameter error: %w", err)
+	}
+	if !(lat >= -90 && lat <= 90) {
+		return nil, nil, fmt.Errorf("lat parameter error: must be within -90..90")
+	}
+
+	long, err := parseFloatParam(query.Get("long"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("long parameter error: %w", err)
+	}
+	if !(long >= -180 && long <= 180) {
+		return nil, nil, fmt.Errorf("long parameter error: must be within -180..180")
+	}
+
+	if params.Scale, err = parseFloatParam(query.Get("scale")); err != nil {
+		return nil, nil, fmt.Errorf("scale parameter error: %w", err)
+	}
+	if !(params.Scale >= export.MinScale && params.Scale <= export.MaxScale) {
+		return nil, nil, fmt.Errorf("scale parameter error: must be within %d..%d", export.MinScale, export.MaxScale)
+	}
+
+	params.View = params.Sheet.AtScale(proj, lat, long, params.Scale, vendor.MinZoom(), vendor.MaxZoom())
+
+	return &params, vendor, nil
+}
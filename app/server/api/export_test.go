@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/export"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+func TestExportHandler(t *testing.T) {
+	a, d := fitAPI(t)
+
+	rr := httptest.NewRecorder()
+	target := "/export?provider=example&paper=A5&dpi=72&lat=0&long=0&scale=100000000&title=Flood"
+	a.Export(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-1.4")))
+	assert.Contains(t, rr.Body.String(), "(Flood) Tj")
+	assert.Contains(t, rr.Body.String(), "/Width 363 /Height 465")
+
+	// zoom is limited by provider, tiles are magnified to keep the scale
+	assert.Equal(t, "2", rr.Header().Get("X-Map-Zoom"))
+	assert.Equal(t, "100000000", rr.Header().Get("X-Map-Scale"))
+	assert.Len(t, d.DownloadCalls(), 1)
+
+	bounds := parseBounds(t, rr.Header().Get("X-Map-Bounds"))
+	assert.InDelta(t, 0, bounds[0]+bounds[2], 1e-6)
+	assert.InDelta(t, 0, bounds[1]+bounds[3], 1e-6)
+
+	rr = httptest.NewRecorder()
+	a.Export(rr, httptest.NewRequest(http.MethodGet, "/export?provider=example&dpi=72&bbox=-90,-45,90,45&orientation=landscape", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	bounds = parseBounds(t, rr.Header().Get("X-Map-Bounds"))
+	assert.InDelta(t, -90, bounds[0], 1e-6)
+	assert.InDelta(t, 90, bounds[2], 1e-6)
+	assert.Less(t, bounds[1], -45.0)
+}
+
+func TestExportHandler_Async(t *testing.T) {
+	a, _ := fitAPI(t)
+
+	// exports of more than max side squared tiles run in background
+	a.MaxSide = 1
+	rr := httptest.NewRecorder()
+	a.Export(rr, httptest.NewRequest(http.MethodGet, "/export?provider=example&paper=a5&dpi=72&lat=0&long=0&scale=100000000", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "exports in background are disabled")
+
+	a.MaxSide = 10
+	a.Exports = export.NewJobs(time.Hour, time.Minute, 1)
+
+	rr = httptest.NewRecorder()
+	target := "/export?provider=example&paper=a5&dpi=72&lat=0&long=0&scale=100000000&async=true"
+	a.Export(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	var st export.Status
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &st))
+	assert.Equal(t, "/export/"+st.ID, rr.Header().Get("Location"))
+	assert.Equal(t, "ex", st.Provider)
+
+	assert.Eventually(t, func() bool {
+		req := httptest.NewRequest(http.MethodGet, "/export/"+st.ID, http.NoBody)
+		req.SetPathValue("id", st.ID)
+
+		rr = httptest.NewRecorder()
+		a.ExportStatus(rr, req)
+		return rr.Code == http.StatusOK && strings.Contains(rr.Body.String(), `"status":"done"`)
+	}, time.Second, 10*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/export/"+st.ID+"/pdf", http.NoBody)
+	req.SetPathValue("id", st.ID)
+	rr = httptest.NewRecorder()
+	a.ExportResult(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-1.4")))
+
+	for _, handler := range []http.HandlerFunc{a.ExportStatus, a.ExportResult} {
+		req = httptest.NewRequest(http.MethodGet, "/export/missing", http.NoBody)
+		req.SetPathValue("id", "missing")
+		rr = httptest.NewRecorder()
+		handler(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "export missing not found")
+	}
+}
+
+func TestExportHandler_Errors(t *testing.T) {
+	a, _ := fitAPI(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"", "provider parameter error: not specified"},
+		{"provider=example&paper=b5&lat=0&long=0&scale=5000", `sheet parameters error: paper \"b5\" not supported`},
+		{"provider=example&orientation=diagonal&lat=0&long=0&scale=5000", `sheet parameters error: orientation \"diagonal\" not supported`},
+		{"provider=example&dpi=x&lat=0&long=0&scale=5000", "dpi parameter error"},
+		{"provider=example&dpi=1200&lat=0&long=0&scale=5000", "sheet parameters error: dpi must be within 72..600"},
+		{"provider=example&paper=a0&dpi=300&lat=0&long=0&scale=5000", "decrease paper size or dpi"},
+		{"provider=example&title=" + strings.Repeat("a", 201) + "&lat=0&long=0&scale=5000",
+			"title parameter error: must not be longer than 200 characters"},
+		{"provider=example&async=maybe&lat=0&long=0&scale=5000", "async parameter error"},
+		{"provider=example&lat=0&long=0", "scale parameter error: not specified"},
+		{"provider=example&lat=0&long=0&scale=10", "scale parameter error: must be within 100..100000000"},
+		{"provider=example&lat=0&long=0&scale=NaN", "scale parameter error: must be a finite number"},
+		{"provider=example&long=0&scale=5000", "lat parameter error: not specified"},
+		{"provider=example&lat=91&long=0&scale=5000", "lat parameter error: must be within -90..90"},
+		{"provider=example&lat=0&long=-Inf&scale=5000", "long parameter error: must be a finite number"},
+		{"provider=example&lat=0&long=181&scale=5000", "long parameter error: must be within -180..180"},
+		{"provider=example&bbox=1,2,3", "bbox parameter error"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Export(rr, httptest.NewRequest(http.MethodGet, "/export?"+tt.query, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query)
+		assert.Contains(t, rr.Body.String(), tt.err, tt.query)
+	}
+}
+
+func TestExportHandler_TooManyTiles(t *testing.T) {
+	a, d := fitAPI(t)
+	a.Exports = export.NewJobs(time.Hour, time.Minute, 1)
+
+	// min zoom of provider shrinks tiles at small scales
+	a.Providers = &provider.ListMock{
+		GetFunc: func(key string) (provider.Provider, error) {
+			return &provider.ProviderMock{
+				MinZoomFunc:    func() int { return 12 },
+				MaxZoomFunc:    func() int { return 12 },
+				NameFunc:       func() string { return "example" },
+				IDFunc:         func() string { return "ex" },
+				ProjectionFunc: func() *tile.Elips { return &tile.ElipsSpherical },
+			}, nil
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	target := "/export?provider=example&paper=a5&dpi=72&lat=0&long=0&scale=100000000&async=true"
+	a.Export(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "tiles is larger than 2048 tiles")
+	assert.Empty(t, d.DownloadCalls())
+}
+
+func TestExportHandler_Deadline(t *testing.T) {
+	a, d := fitAPI(t)
+	download := d.DownloadFunc
+	d.DownloadFunc = func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+		time.Sleep(30 * time.Millisecond)
+		return download(c, l, tiles...)
+	}
+
+	// every tile is a batch, deadline is exceeded after the second one
+	a.MaxSide = 1
+	a.Exports = export.NewJobs(time.Hour, 50*time.Millisecond, 1)
+
+	rr := httptest.NewRecorder()
+	a.Export(rr, httptest.NewRequest(http.MethodGet, "/export?provider=example&paper=a5&dpi=72&lat=0&long=0&scale=100000000", http.NoBody))
+	assert.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	var st export.Status
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &st))
+	assert.Greater(t, st.Tiles, 2)
+
+	assert.Eventually(t, func() bool {
+		st, _ = a.Exports.Get(st.ID)
+		return st.Status == "failed"
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, st.Error, "export is stopped: context deadline exceeded")
+	assert.Less(t, len(d.DownloadCalls()), st.Tiles)
+}
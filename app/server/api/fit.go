@@ -0,0 +1,222 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/overlay"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// defaultPadding is a default padding of fitted overlays in pixels, it leaves room for icons of markers
+const defaultPadding = 32
+
+// parseFitParams parse width, height and padding of fitted map, size is side tiles by default
+func (a *API) parseFitParams(req *http.Request, params *mapParams) error {
+	if params.Overlays.Empty() {
+		return fmt.Errorf("fit parameter error: markers, paths, polygons or geojson to fit are not specified")
+	}
+
+	maxSize := a.MaxSide * tile.Size
+	size := func(name string, value *int) error {
+		*value = params.Side * tile.Size
+
+		p := req.URL.Query().Get(name)
+		if p == "" {
+			return nil
+		}
+
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return fmt.Errorf("%s parameter error: %w", name, err)
+		}
+		if v < 1 || v > maxSize {
+			return fmt.Errorf("%s parameter error: must be within 1..%d", name, maxSize)
+		}
+
+		*value = v
+		return nil
+	}
+
+	if err := size("width", &params.Width); err != nil {
+		return err
+	}
+	if err := size("height", &params.Height); err != nil {
+		return err
+	}
+
+	params.Padding = min(defaultPadding, (min(params.Width, params.Height)-1)/2)
+	if p := req.URL.Query().Get("padding"); p != "" {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return fmt.Errorf("padding parameter error: %w", err)
+		}
+		if v < 0 || 2*v >= min(params.Width, params.Height) {
+			return fmt.Errorf("padding parameter error: must be non-negative and less than half of width and height")
+		}
+		params.Padding = v
+	}
+
+	return nil
+}
+
+// fitMap render map of width x height pixels at the highest zoom where all overlays fit,
+// chosen zoom and bounds are returned in headers
+func (a *API) fitMap(w http.ResponseWriter, req *http.Request, params *mapParams, vendor provider.Provider) {
+	maxZoom := int(params.Zoom)
+	view := params.Overlays.Fit(vendorProjection(vendor), params.Width, params.Height, params.Padding, min(vendor.MinZoom(), maxZoom), maxZoom)
+	setViewHeaders(w, view, params.Width, params.Height)
+
+	var resultKey string
+	if a.Results != nil {
+		decorations, err := params.decorationsKey()
+		if err != nil {
+			a.Logger.Error("error occurred when building result key", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+			writeJSON(w, http.StatusInternalServerError, mapErrorModel{
+				Status: http.StatusInternalServerError,
+				Body:   fmt.Sprintf("error occurred when building result key: %s", err.Error()),
+			})
+			return
+		}
+
+		resultKey = fmt.Sprintf("%s/fit/%d/%d/%d/%d/jpeg", vendor.ID(), view.Zoom, params.Width, params.Height, params.Padding) + decorations
+		if result, ok := a.Results.Get(resultKey); ok {
+			w.Header().Set("X-Result-Cache", "hit")
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(result)
+
+			a.Logger.Info("new fitted map download request", zap.Int("zoom", view.Zoom), zap.Int("width", params.Width),
+				zap.Int("height", params.Height), zap.String("vendor", vendor.Name()), zap.Bool("result_cache", true),
+				zap.String("req_id", req.Header.Get("X-Request-ID")))
+			return
+		}
+		w.Header().Set("X-Result-Cache", "miss")
+	}
+
+	columns, rows, tiles := fitGrid(view, params.Width, params.Height)
+
+	tiles, cacheable, ok := a.downloadTiles(w, req, vendor, params.CacheOnly, tiles)
+	if !ok {
+		return
+	}
+
+	result, err := a.renderFit(columns, rows, tiles, vendor, view, params)
+	if err != nil {
+		a.Logger.Error("error occurred when rendering tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeJSON(w, http.StatusInternalServerError, mapErrorModel{
+			Status: http.StatusInternalServerError,
+			Body:   fmt.Sprintf("error occurred when rendering tiles: %s", err.Error()),
+		})
+		return
+	}
+
+	for _, t := range tiles {
+		if t.Stale {
+			w.Header().Set("X-Cache-Stale", "true")
+			cacheable = false
+			break
+		}
+	}
+
+	if a.Results != nil && cacheable {
+		a.Results.Put(resultKey, vendor.ID(), tiles, result)
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	_, _ = w.Write(result)
+
+	a.Logger.Info("new fitted map download request", zap.Int("zoom", view.Zoom), zap.Int("width", params.Width),
+		zap.Int("height", params.Height), zap.String("vendor", vendor.Name()), zap.String("req_id", req.Header.Get("X-Request-ID")))
+}
+
+// renderFit render tiles into JPEG image of view and apply filters to it, areas beyond the poles are white
+func (a *API) renderFit(columns, rows []float64, tiles []tile.Tile, vendor provider.Provider, view *overlay.View,
+	params *mapParams) ([]byte, error) {
+	layer, err := downloader.Render(columns, rows, tiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(layer.Rect)
+	draw.Draw(img, img.Rect, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(img, img.Rect, layer, image.Point{}, draw.Over)
+
+	resized := params.Filters.Apply(img, a.MaxSide*tile.Size)
+	if resized.Rect != img.Rect {
+		// resized image shows the same area, so only tile size of view is changed
+		scaled := *view
+		scaled.TileSize *= float64(resized.Rect.Dx()) / float64(img.Rect.Dx())
+		view = &scaled
+	}
+	img = resized
+
+	a.annotate(img, vendor, view, params)
+
+	return encodeJPEG(img)
+}
+
+// setViewHeaders set zoom and bounds of image shown by view
+func setViewHeaders(w http.ResponseWriter, view *overlay.View, width, height int) {
+	bounds := view.Bounds(width, height)
+	w.Header().Set("X-Map-Zoom", strconv.Itoa(view.Zoom))
+	w.Header().Set("X-Map-Bounds", fmt.Sprintf("%s,%s,%s,%s", formatCoordinate(bounds[0]), formatCoordinate(bounds[1]),
+		formatCoordinate(bounds[2]), formatCoordinate(bounds[3])))
+}
+
+// formatCoordinate format coordinate in degrees with precision of a few centimeters
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', 7, 64)
+}
+
+// fitGrid return positions of pixels of view in tiles and tiles which cover them,
+// columns are wrapped around the world and rows beyond the poles are NaN
+func fitGrid(view *overlay.View, width, height int) (columns, rows []float64, tiles []tile.Tile) {
+	n := math.Pow(2, float64(view.Zoom))
+
+	columns = make([]float64, width)
+	var xs []int
+	seen := make(map[int]bool)
+	for i := range columns {
+		columns[i] = math.Mod(view.X+(float64(i)+0.5)/view.TileSize, n)
+		if columns[i] < 0 {
+			columns[i] += n
+		}
+		if x := int(columns[i]); !seen[x] {
+			seen[x] = true
+			xs = append(xs, x)
+		}
+	}
+
+	rows = make([]float64, height)
+	var ys []int
+	clear(seen)
+	for i := range rows {
+		rows[i] = view.Y + (float64(i)+0.5)/view.TileSize
+		if !(rows[i] >= 0 && rows[i] < n) {
+			rows[i] = math.NaN()
+			continue
+		}
+		if y := int(rows[i]); !seen[y] {
+			seen[y] = true
+			ys = append(ys, y)
+		}
+	}
+
+	tiles = make([]tile.Tile, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			tiles = append(tiles, tile.Tile{X: x, Y: y, Z: view.Zoom})
+		}
+	}
+
+	return columns, rows, tiles
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/overlay"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+	"go.uber.org/zap"
+)
+
+// fitAPI return API serving gray tiles of zoom 0..2
+func fitAPI(t *testing.T) (*API, *downloader.DownloaderMock) {
+	gray := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(gray, gray.Bounds(), &image.Uniform{C: color.Gray{Y: 0x80}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, gray, &jpeg.Options{Quality: 100}))
+
+	d := &downloader.DownloaderMock{
+		DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+			result := make([]tile.Tile, 0, len(tiles))
+			for _, t := range tiles {
+				t.Image = buf.Bytes()
+				result = append(result, t)
+			}
+			return result, nil
+		},
+	}
+
+	return &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc:     func() int { return 0 },
+					MaxZoomFunc:     func() int { return 2 },
+					NameFunc:        func() string { return "example" },
+					IDFunc:          func() string { return "ex" },
+					AttributionFunc: func() string { return "" },
+					ProjectionFunc:  func() *tile.Elips { return &tile.ElipsSpherical },
+				}, nil
+			},
+		},
+		MaxSide:    10,
+		Downloader: d,
+	}, d
+}
+
+// parseBounds parse X-Map-Bounds header
+func parseBounds(t *testing.T, header string) []float64 {
+	parts := strings.Split(header, ",")
+	assert.Len(t, parts, 4)
+
+	bounds := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		assert.NoError(t, err)
+		bounds = append(bounds, v)
+	}
+	return bounds
+}
+
+func TestMapHandler_Fit(t *testing.T) {
+	a, d := fitAPI(t)
+
+	// markers at long -90 and 90 are 512 pixels apart at zoom 2
+	rr := httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&fit=true&width=512&height=256&padding=0"+
+		"&marker=icon:circle|0,-90|0,90&marker=color:red|icon:circle|0,0", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "2", rr.Header().Get("X-Map-Zoom"))
+
+	// north of image is at tile row 1.5 of zoom 2
+	north := math.Atan(math.Sinh(math.Pi/4)) * 180 / math.Pi
+	bounds := parseBounds(t, rr.Header().Get("X-Map-Bounds"))
+	assert.InDeltaSlice(t, []float64{-90, -north, 90, north}, bounds, 1e-6)
+
+	assert.Len(t, d.DownloadCalls(), 1)
+	assert.ElementsMatch(t, []tile.Tile{{X: 1, Y: 1, Z: 2}, {X: 2, Y: 1, Z: 2}, {X: 1, Y: 2, Z: 2}, {X: 2, Y: 2, Z: 2}},
+		d.DownloadCalls()[0].Tiles)
+
+	img, err := jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 256), img.Bounds())
+	assertColor(t, color.RGBA{R: 0xe5, G: 0x39, B: 0x35}, img, 256, 128)
+	assertColor(t, color.RGBA{R: 0x80, G: 0x80, B: 0x80}, img, 100, 200)
+
+	// zoom parameter limits the chosen zoom, default padding leaves room around overlays
+	rr = httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&fit=1&zoom=1&width=512&height=256&marker=0,-90|0,90", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "1", rr.Header().Get("X-Map-Zoom"))
+
+	rr = httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&fit=1&width=512&height=256&marker=0,-90|0,90", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "1", rr.Header().Get("X-Map-Zoom"))
+
+	// GeoJSON of POST body is fitted, size is side tiles by default
+	rr = httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodPost, "/map?provider=example&fit=true&side=2", strings.NewReader(`{
+		"geojson":{"type":"LineString","coordinates":[[10,10],[20,20]]}
+	}`)))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "2", rr.Header().Get("X-Map-Zoom"))
+
+	img, err = jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 512), img.Bounds())
+}
+
+func TestMapHandler_FitErrors(t *testing.T) {
+	a, _ := fitAPI(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"&fit=yes&marker=1,2", "fit parameter error"},
+		{"&fit=true", "fit parameter error: markers, paths, polygons or geojson to fit are not specified"},
+		{"&fit=true&marker=10,NaN", "marker parameter error"},
+		{"&fit=true&zoom=3&marker=1,2", "zoom parameter error: zoom for provider example must be within 1-2"},
+		{"&fit=true&width=0&marker=1,2", "width parameter error: must be within 1..2560"},
+		{"&fit=true&height=2561&marker=1,2", "height parameter error: must be within 1..2560"},
+		{"&fit=true&width=a&marker=1,2", "width parameter error"},
+		{"&fit=true&height=256&padding=128&marker=1,2", "padding parameter error: must be non-negative and less than half of width and height"},
+		{"&fit=true&padding=-1&marker=1,2", "padding parameter error"},
+		{"&fit=false&marker=1,2", "lat parameter error: not specified"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example"+tt.query, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query)
+		assert.Contains(t, rr.Body.String(), tt.err, tt.query)
+	}
+}
+
+func TestMapHandler_FitResultCache(t *testing.T) {
+	a, d := fitAPI(t)
+
+	var err error
+	a.Results, err = cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	request := func(query string) string {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&fit=true&width=300&height=200"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("X-Map-Bounds"))
+		return rr.Header().Get("X-Result-Cache")
+	}
+
+	assert.Equal(t, "miss", request("&marker=10,10"))
+	assert.Equal(t, "hit", request("&marker=10,10"))
+	assert.Equal(t, "miss", request("&marker=10,10&padding=10"))
+	assert.Equal(t, "miss", request("&marker=10,11"))
+	assert.Len(t, d.DownloadCalls(), 3)
+}
+
+func TestFitGrid(t *testing.T) {
+	// view of zoom 1 centered on the north-west corner of the world
+	view := &overlay.View{Proj: &tile.ElipsSpherical, Zoom: 1, X: -0.5, Y: -0.5, TileSize: 256}
+
+	columns, rows, tiles := fitGrid(view, 256, 256)
+	assert.Equal(t, []tile.Tile{{X: 1, Y: 0, Z: 1}, {X: 0, Y: 0, Z: 1}}, tiles)
+
+	// columns are wrapped around the world, rows beyond the pole are skipped
+	assert.InDelta(t, 1.5+0.5/256, columns[0], 1e-9)
+	assert.InDelta(t, 0.5/256, columns[128], 1e-9)
+	assert.True(t, math.IsNaN(rows[0]))
+	assert.True(t, math.IsNaN(rows[127]))
+	assert.InDelta(t, 0.5/256, rows[128], 1e-9)
+}
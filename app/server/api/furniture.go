@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/superboomer/maptile/app/overlay"
+)
+
+// parseFurniture return cartographic furniture of scale, north, grid, caption and timestamp query parameters
+func parseFurniture(req *http.Request) (*overlay.Furniture, error) {
+	query := req.URL.Query()
+	f := &overlay.Furniture{Scale: query.Get("scale"), Grid: query.Get("grid"), Caption: query.Get("caption")}
+
+	if p := query.Get("north"); p != "" {
+		north, err := strconv.ParseBool(p)
+		if err != nil {
+			return nil, fmt.Errorf("north parameter error: %w", err)
+		}
+		f.North = north
+	}
+
+	if p := query.Get("timestamp"); p != "" {
+		timestamp, err := strconv.ParseBool(p)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp parameter error: %w", err)
+		}
+		// timestamp is shown with minutes precision, so images are cached in result cache for a minute
+		if timestamp {
+			f.Timestamp = time.Now().UTC().Truncate(time.Minute)
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("furniture parameters error: %w", err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/overlay"
+)
+
+func TestMapHandler_Furniture(t *testing.T) {
+	a := overlayAPI(t)
+
+	request := func(query string) image.Image {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		img, err := jpeg.Decode(rr.Body)
+		assert.NoError(t, err)
+		return img
+	}
+
+	img := request("")
+	assertColor(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff}, img, 236, 36)
+
+	// north arrow is in top right corner and scale bar in bottom left
+	img = request("&north=true&scale=metric&grid=latlong&caption=Flood&timestamp=1")
+	assertColor(t, color.RGBA{}, img, 236, 36)
+	assertColor(t, color.RGBA{}, img, 11, 238)
+}
+
+func TestMapHandler_FurnitureErrors(t *testing.T) {
+	a := overlayAPI(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"&scale=nautical", `furniture parameters error: scale \"nautical\" not supported`},
+		{"&grid=mgrs", `furniture parameters error: grid \"mgrs\" not supported`},
+		{"&caption=" + strings.Repeat("a", 201), "furniture parameters error: caption must not be longer than 200 characters"},
+		{"&north=maybe", "north parameter error"},
+		{"&timestamp=x", "timestamp parameter error"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+tt.query, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query)
+		assert.Contains(t, rr.Body.String(), tt.err, tt.query)
+	}
+}
+
+func TestMapHandler_FurnitureResultCache(t *testing.T) {
+	a := overlayAPI(t)
+
+	var err error
+	a.Results, err = cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	request := func(query string) string {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header().Get("X-Result-Cache")
+	}
+
+	assert.Equal(t, "miss", request("&scale=metric"))
+	assert.Equal(t, "hit", request("&scale=metric"))
+	assert.Equal(t, "miss", request("&scale=imperial"))
+	assert.Equal(t, "miss", request("&scale=metric&caption=A"))
+	assert.Equal(t, "miss", request(""))
+}
+
+func TestMapHandler_FitFurniture(t *testing.T) {
+	a, _ := fitAPI(t)
+
+	rr := httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&fit=true&width=300&height=200&marker=10,10&north=true", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	img, err := jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assertColor(t, color.RGBA{}, img, 300-20, 36)
+}
+
+func TestParseFurniture(t *testing.T) {
+	f, err := parseFurniture(httptest.NewRequest(http.MethodGet, "/map?scale=both&grid=utm&north=1&caption=A%20B", http.NoBody))
+	assert.NoError(t, err)
+	assert.Equal(t, &overlay.Furniture{Scale: overlay.ScaleBoth, Grid: overlay.GridUTM, North: true, Caption: "A B"}, f)
+
+	f, err = parseFurniture(httptest.NewRequest(http.MethodGet, "/map?timestamp=true", http.NoBody))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), f.Timestamp, time.Minute)
+	assert.Equal(t, 0, f.Timestamp.Second())
+
+	f, err = parseFurniture(httptest.NewRequest(http.MethodGet, "/map", http.NoBody))
+	assert.NoError(t, err)
+	assert.True(t, f.Empty())
+}
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
 	"net/http"
 
 	"strconv"
@@ -10,7 +16,12 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/filter"
+	"github.com/superboomer/maptile/app/overlay"
 	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+	"github.com/superboomer/maptile/app/watermark"
 )
 
 // mapErrorModel contains data about error query
@@ -19,20 +30,59 @@ type mapErrorModel struct {
 	Body   string `json:"body"`
 }
 
+// mapMissingModel contains tiles which are not found in cache in offline mode
+type mapMissingModel struct {
+	Status  int         `json:"status"`
+	Body    string      `json:"body"`
+	Missing []tileModel `json:"missing"`
+}
+
+// tileModel contains tile coordinates
+type tileModel struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z"`
+}
+
 // Map godoc
 // @Summary handler for generating satellite map for specified lat long and from specified vendor
-// @Description return merged satellite tiles in one image
-// @Accept  text/plain
+// @Description return merged satellite tiles in one image, markers, paths and polygons of query parameters or of POST body are drawn over it.
+// @Description With fit the zoom and the position of image are chosen to show all overlays.
+// @Description Scale bar, north arrow, coordinate grid and caption may be drawn for printing.
+// @Description Image may be post-processed by chain of filters: tone and color adjustments, sharpening and resize.
+// @Accept  application/json
 // @Produce image/jpeg
 // @Param provider query string true "tile provider"
-// @Param lat query		 number	 true "latitude"
-// @Param long query		 number	 true "longitude"
-// @Param zoom query		 int true "zoom of image"
+// @Param lat query		 number	 false "latitude, required unless fit is set"
+// @Param long query		 number	 false "longitude, required unless fit is set"
+// @Param zoom query		 int false "zoom of image, required unless fit is set, max zoom of fitted image otherwise"
 // @Param side query		 int false "count of tile of result image square" default(3) minimum(1)		maximum(10)
+// @Param cache_only query		 bool false "serve tiles only from cache, never request upstream"
+// @Param fit query		 bool false "choose the highest zoom and the position where all overlays fit into image"
+// @Param width query		 int false "width of fitted image in pixels, side*256 by default" minimum(1)
+// @Param height query		 int false "height of fitted image in pixels, side*256 by default" minimum(1)
+// @Param padding query		 int false "padding of fitted overlays in pixels" default(32) minimum(0)
+// @Param marker query []string false "markers color:red|icon:pin|label:A|lat,long|lat,long, icon is pin, circle or square" collectionFormat(multi)
+// @Param path query []string false "polyline color:blue|width:3|lat,long|lat,long or color:blue|enc:encoded_polyline" collectionFormat(multi)
+// @Param polygon query []string false "polygon color:blue|width:3|fill:0x0000ff80|lat,long|lat,long|lat,long or with enc:encoded_polyline" collectionFormat(multi)
+// @Param scale query		 string false "scale bar of ground resolution at image center" Enums(metric, imperial, both)
+// @Param north query		 bool false "draw north arrow"
+// @Param grid query		 string false "coordinate grid with labels" Enums(latlong, utm)
+// @Param caption query		 string false "caption in top left corner" maxlength(200)
+// @Param timestamp query		 bool false "draw UTC time of request under caption"
+// @Param filter query []string false "filters applied in order before overlays: brightness:-100..100, contrast:-100..100, gamma:0.1..10, grayscale, sharpen:0.1..5, autolevels or resize:width" collectionFormat(multi)
+// @Param overlays body overlaysModel false "overlays of POST request"
 // @Success 200 {file} image/jpeg
 // @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapMissingModel
 // @Header 200 {string} X-Request-Id "request_id"
+// @Header 200 {string} X-Cache-Stale "true if some tiles are served from cache after expiration"
+// @Header 200 {string} X-Cache-Missing "count of tiles which are not cached and replaced with placeholders"
+// @Header 200 {string} X-Result-Cache "hit if merged image is served from result cache, miss otherwise"
+// @Header 200 {integer} X-Map-Zoom "zoom of fitted image"
+// @Header 200 {string} X-Map-Bounds "west,south,east,north of fitted image"
 // @Router /map [get]
+// @Router /map [post]
 func (a *API) Map(w http.ResponseWriter, req *http.Request) {
 
 	params, vendor, err := a.parseRequest(req)
@@ -48,24 +98,48 @@ func (a *API) Map(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if params.Fit {
+		a.fitMap(w, req, params, vendor)
+		return
+	}
+
 	var centerTile = vendor.GetTile(params.Latitude, params.Longitude, params.Zoom)
 
-	tiles, err := a.Downloader.Download(a.Cache, vendor, centerTile.GetNearby(params.Side)...)
-	if err != nil {
-		a.Logger.Error("error occurred when downloading tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+	var resultKey string
+	if a.Results != nil {
+		decorations, err := params.decorationsKey()
+		if err != nil {
+			a.Logger.Error("error occurred when building result key", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+			writeJSON(w, http.StatusInternalServerError, mapErrorModel{
+				Status: http.StatusInternalServerError,
+				Body:   fmt.Sprintf("error occurred when building result key: %s", err.Error()),
+			})
+			return
+		}
 
-		results, _ := json.Marshal(mapErrorModel{
-			Status: http.StatusInternalServerError,
-			Body:   fmt.Sprintf("error occurred when dowloading tiles: %s", err.Error()),
-		})
+		resultKey = fmt.Sprintf("%s/%d/%d/%d/%d/jpeg", vendor.ID(), centerTile.Z, centerTile.X, centerTile.Y, params.Side) + decorations
+		if merged, ok := a.Results.Get(resultKey); ok {
+			w.Header().Set("X-Result-Cache", "hit")
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(merged)
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write(results)
+			a.Logger.Info("new map download request", zap.Float64("lat", params.Latitude), zap.Float64("long", params.Longitude),
+				zap.Int("side", params.Side), zap.String("vendor", vendor.Name()), zap.Bool("result_cache", true),
+				zap.String("req_id", req.Header.Get("X-Request-ID")))
+			return
+		}
+		w.Header().Set("X-Result-Cache", "miss")
+	}
+
+	tiles, cacheable, ok := a.downloadTiles(w, req, vendor, params.CacheOnly, centerTile.GetNearby(params.Side))
+	if !ok {
 		return
 	}
 
 	merged, err := a.Downloader.Merge(params.Side, centerTile, tiles...)
+	if err == nil {
+		merged, err = a.decorate(merged, vendor, centerTile, params)
+	}
 	if err != nil {
 		a.Logger.Error("error occurred when merging tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
 
@@ -80,6 +154,19 @@ func (a *API) Map(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	for _, t := range tiles {
+		if t.Stale {
+			w.Header().Set("X-Cache-Stale", "true")
+			cacheable = false
+			break
+		}
+	}
+
+	// results with stale tiles or placeholders are not cached, they are going to be replaced soon
+	if a.Results != nil && cacheable {
+		a.Results.Put(resultKey, vendor.ID(), tiles, merged)
+	}
+
 	w.Header().Set("Content-Type", "image/jpeg")
 	_, _ = w.Write(merged)
 
@@ -89,13 +176,170 @@ func (a *API) Map(w http.ResponseWriter, req *http.Request) {
 type mapParams struct {
 	Latitude  float64
 	Longitude float64
-	Zoom      float64
+	Zoom      float64 // max zoom in fit mode
 	Side      int
+	CacheOnly bool
+	Overlays  *overlay.Overlays
+	Furniture *overlay.Furniture
+	Filters   filter.Chain
+	Fit       bool
+	Width     int
+	Height    int
+	Padding   int
+}
+
+// decorationsKey return part of result cache key for overlays, cartographic furniture and filters
+func (p *mapParams) decorationsKey() (string, error) {
+	var key string
+
+	if !p.Overlays.Empty() {
+		hash, err := hashKey(p.Overlays)
+		if err != nil {
+			return "", fmt.Errorf("overlays: %w", err)
+		}
+		key += "/" + hash
+	}
+
+	if !p.Furniture.Empty() {
+		hash, err := hashKey(p.Furniture)
+		if err != nil {
+			return "", fmt.Errorf("furniture: %w", err)
+		}
+		key += "/furniture/" + hash
+	}
+
+	if len(p.Filters) > 0 {
+		hash, err := hashKey(p.Filters)
+		if err != nil {
+			return "", fmt.Errorf("filters: %w", err)
+		}
+		key += "/filter/" + hash
+	}
+
+	return key, nil
+}
+
+// decorate apply filters to merged image and draw overlays, cartographic furniture and attribution watermark onto it
+func (a *API) decorate(merged []byte, vendor provider.Provider, centerTile tile.Tile, params *mapParams) ([]byte, error) {
+	if params.Overlays.Empty() && params.Furniture.Empty() && len(params.Filters) == 0 && !a.stamped(vendor) {
+		return merged, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(merged))
+	if err != nil {
+		return nil, fmt.Errorf("error occurred with decoding image: %w", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	// tile size of view follows the width of resized image
+	img = params.Filters.Apply(img, a.MaxSide*tile.Size)
+
+	a.annotate(img, vendor, &overlay.View{
+		Proj:     vendorProjection(vendor),
+		Zoom:     centerTile.Z,
+		X:        float64(centerTile.X - params.Side/2),
+		Y:        float64(centerTile.Y - params.Side/2),
+		TileSize: float64(img.Rect.Dx()) / float64(params.Side),
+	}, params)
+
+	return encodeJPEG(img)
 }
 
-func (a *API) validateZoom(zoom float64, maxZoom int, vendorName string) error {
-	if zoom < 1 || zoom > float64(maxZoom) {
-		return fmt.Errorf("max zoom for provider %s - %d", vendorName, maxZoom)
+// annotate draw overlays, cartographic furniture and attribution watermark onto image shown by view
+func (a *API) annotate(img *image.RGBA, vendor provider.Provider, view *overlay.View, params *mapParams) {
+	if !params.Overlays.Empty() {
+		params.Overlays.Draw(img, view)
+	}
+
+	if !params.Furniture.Empty() {
+		params.Furniture.Draw(img, view)
+	}
+
+	if a.stamped(vendor) {
+		watermark.Draw(img, watermark.PlainText(vendor.Attribution()), a.Watermark)
+	}
+}
+
+// stamped return true if attribution watermark is drawn on images of vendor
+func (a *API) stamped(vendor provider.Provider) bool {
+	return a.Watermark != nil && vendor.Attribution() != ""
+}
+
+// encodeJPEG encode image with the best quality
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		return nil, fmt.Errorf("error occurred with encoding new image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// downloadTiles download tiles or take them only from cache, missing tiles are replaced with placeholders if it's enabled.
+// It writes error answer and returns false if tiles can't be served, tiles with placeholders aren't cacheable.
+func (a *API) downloadTiles(w http.ResponseWriter, req *http.Request, vendor provider.Provider, cacheOnly bool,
+	tiles []tile.Tile) (result []tile.Tile, cacheable, ok bool) {
+	result, missing, err := a.fetchTiles(vendor, cacheOnly, tiles)
+
+	var missingErr *downloader.MissingError
+	if errors.As(err, &missingErr) {
+		writeJSON(w, http.StatusNotFound, missingModel(missingErr))
+		return nil, false, false
+	}
+
+	if err != nil {
+		a.Logger.Error("error occurred when downloading tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+
+		results, _ := json.Marshal(mapErrorModel{
+			Status: http.StatusInternalServerError,
+			Body:   fmt.Sprintf("error occurred when dowloading tiles: %s", err.Error()),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write(results)
+		return nil, false, false
+	}
+
+	if missing > 0 {
+		w.Header().Set("X-Cache-Missing", strconv.Itoa(missing))
+	}
+
+	return result, missing == 0, true
+}
+
+// fetchTiles download tiles or take them only from cache, missing tiles are replaced with placeholders if it's enabled
+// and their count is returned, downloader.MissingError is returned otherwise
+func (a *API) fetchTiles(vendor provider.Provider, cacheOnly bool, tiles []tile.Tile) (result []tile.Tile, missing int, err error) {
+	if a.Offline || cacheOnly {
+		result, err = a.Downloader.DownloadCached(a.Cache, vendor, tiles...)
+	} else {
+		result, err = a.Downloader.Download(a.Cache, vendor, tiles...)
+	}
+
+	var missingErr *downloader.MissingError
+	if errors.As(err, &missingErr) && a.Placeholder {
+		return append(result, downloader.Placeholders(result, missingErr.Tiles)...), len(missingErr.Tiles), nil
+	}
+
+	return result, 0, err
+}
+
+// missingModel return not found answer with coordinates of missing tiles
+func missingModel(err *downloader.MissingError) mapMissingModel {
+	model := mapMissingModel{Status: http.StatusNotFound, Body: err.Error(), Missing: make([]tileModel, 0, len(err.Tiles))}
+	for _, t := range err.Tiles {
+		model.Missing = append(model.Missing, tileModel{X: t.X, Y: t.Y, Z: t.Z})
+	}
+	return model
+}
+
+func (a *API) validateZoom(zoom float64, minZoom, maxZoom int, vendorName string) error {
+	minZoom = max(1, minZoom)
+	if zoom < float64(minZoom) || zoom > float64(maxZoom) {
+		return fmt.Errorf("zoom for provider %s must be within %d-%d", vendorName, minZoom, maxZoom)
 	}
 	return nil
 }
@@ -116,25 +360,36 @@ func (a *API) parseRequest(req *http.Request) (*mapParams, provider.Provider, er
 		return nil, nil, fmt.Errorf("provider parameter error: %s not found", pVendor)
 	}
 
-	pLat := req.URL.Query().Get("lat")
-	params.Latitude, err = parseFloatParam(pLat)
-	if err != nil {
-		return nil, nil, fmt.Errorf("lat parameter error: %w", err)
+	if pFit := req.URL.Query().Get("fit"); pFit != "" {
+		params.Fit, err = strconv.ParseBool(pFit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fit parameter error: %w", err)
+		}
 	}
 
-	pLong := req.URL.Query().Get("long")
-	params.Longitude, err = parseFloatParam(pLong)
-	if err != nil {
-		return nil, nil, fmt.Errorf("long parameter error: %w", err)
+	// position of fitted map is chosen by overlays, zoom is optional and limits the chosen zoom
+	if !params.Fit {
+		pLat := req.URL.Query().Get("lat")
+		params.Latitude, err = parseFloatParam(pLat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("lat parameter error: %w", err)
+		}
+
+		pLong := req.URL.Query().Get("long")
+		params.Longitude, err = parseFloatParam(pLong)
+		if err != nil {
+			return nil, nil, fmt.Errorf("long parameter error: %w", err)
+		}
 	}
 
 	pZoom := req.URL.Query().Get("zoom")
-	params.Zoom, err = parseFloatParam(pZoom)
-	if err != nil {
+	if params.Fit && pZoom == "" {
+		params.Zoom = float64(vendor.MaxZoom())
+	} else if params.Zoom, err = parseFloatParam(pZoom); err != nil {
 		return nil, nil, fmt.Errorf("zoom parameter error: %w", err)
 	}
 
-	if zoomErr := a.validateZoom(params.Zoom, vendor.MaxZoom(), vendor.Name()); zoomErr != nil {
+	if zoomErr := a.validateZoom(params.Zoom, vendor.MinZoom(), vendor.MaxZoom(), vendor.Name()); zoomErr != nil {
 		return nil, nil, fmt.Errorf("zoom parameter error: %w", zoomErr)
 	}
 
@@ -151,6 +406,31 @@ func (a *API) parseRequest(req *http.Request) (*mapParams, provider.Provider, er
 		params.Side = sideInt
 	}
 
+	if pCacheOnly := req.URL.Query().Get("cache_only"); pCacheOnly != "" {
+		params.CacheOnly, err = strconv.ParseBool(pCacheOnly)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cache_only parameter error: %w", err)
+		}
+	}
+
+	if params.Overlays, err = parseOverlays(req); err != nil {
+		return nil, nil, err
+	}
+
+	if params.Furniture, err = parseFurniture(req); err != nil {
+		return nil, nil, err
+	}
+
+	if params.Filters, err = filter.Parse(req.URL.Query()["filter"], a.MaxSide*tile.Size); err != nil {
+		return nil, nil, fmt.Errorf("filter parameter error: %w", err)
+	}
+
+	if params.Fit {
+		if err = a.parseFitParams(req, &params); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return &params, vendor, nil
 }
 
@@ -166,5 +446,9 @@ func parseFloatParam(param string) (float64, error) {
 		return 0, err
 	}
 
+	if math.IsNaN(valueFloat) || math.IsInf(valueFloat, 0) {
+		return 0, fmt.Errorf("must be a finite number")
+	}
+
 	return valueFloat, nil
 }
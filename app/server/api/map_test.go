@@ -1,16 +1,24 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/superboomer/maptile/app/cache"
 	"github.com/superboomer/maptile/app/downloader"
 	"github.com/superboomer/maptile/app/provider"
 	"github.com/superboomer/maptile/app/tile"
+	"github.com/superboomer/maptile/app/watermark"
 
 	"go.uber.org/zap"
 )
@@ -24,6 +32,7 @@ var apiPkg = &API{
 				return nil, fmt.Errorf("not found")
 			}
 			return &provider.ProviderMock{
+				MinZoomFunc:    func() int { return 0 },
 				MaxZoomFunc:    func() int { return 2 },
 				NameFunc:       func() string { return "example" },
 				IDFunc:         func() string { return "ex" },
@@ -93,7 +102,35 @@ func TestMapHandler_InvalidParameterMaxZoom(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
-	expectedBody := `{"status":400,"body":"zoom parameter error: max zoom for provider example - 2"}`
+	expectedBody := `{"status":400,"body":"zoom parameter error: zoom for provider example must be within 1-2"}`
+	assert.JSONEq(t, expectedBody, rr.Body.String(), "Response body did not match expected JSON")
+}
+
+func TestMapHandler_InvalidParameterMinZoom(t *testing.T) {
+	var apiPkg = &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc: func() int { return 3 },
+					MaxZoomFunc: func() int { return 5 },
+					NameFunc:    func() string { return "example" },
+				}, nil
+			},
+		},
+		MaxSide: 10,
+	}
+
+	req, err := http.NewRequest("GET", "/map?provider=example&lat=12.0&long=-74.0060&zoom=2&side=3", http.NoBody)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+
+	apiPkg.Map(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	expectedBody := `{"status":400,"body":"zoom parameter error: zoom for provider example must be within 3-5"}`
 	assert.JSONEq(t, expectedBody, rr.Body.String(), "Response body did not match expected JSON")
 }
 
@@ -197,6 +234,7 @@ func TestMapHandler_ErrorDuringDownload(t *testing.T) {
 		Providers: &provider.ListMock{
 			GetFunc: func(key string) (provider.Provider, error) {
 				return &provider.ProviderMock{
+					MinZoomFunc:    func() int { return 0 },
 					MaxZoomFunc:    func() int { return 2 },
 					NameFunc:       func() string { return "example" },
 					IDFunc:         func() string { return "ex" },
@@ -236,6 +274,7 @@ func TestMapHandler_ErrorDuringMerging(t *testing.T) {
 		Providers: &provider.ListMock{
 			GetFunc: func(key string) (provider.Provider, error) {
 				return &provider.ProviderMock{
+					MinZoomFunc:    func() int { return 0 },
 					MaxZoomFunc:    func() int { return 2 },
 					NameFunc:       func() string { return "example" },
 					IDFunc:         func() string { return "ex" },
@@ -268,3 +307,364 @@ func TestMapHandler_ErrorDuringMerging(t *testing.T) {
 	expectedBody := `{"status":500,"body":"error occurred when merging tiles: mock error"}`
 	assert.JSONEq(t, expectedBody, rr.Body.String(), "Response body did not match expected JSON")
 }
+
+func TestMapHandler_StaleHeader(t *testing.T) {
+	var apiPkg = &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc: func() int { return 0 },
+					MaxZoomFunc: func() int { return 2 },
+					NameFunc:    func() string { return "example" },
+					GetTileFunc: func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 0, Y: 0, Z: 0} },
+				}, nil
+			},
+		},
+		MaxSide: 10,
+		Downloader: &downloader.DownloaderMock{
+			DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+				return []tile.Tile{{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0, Stale: true}}, nil
+			},
+			MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) { return []byte{}, nil },
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=3", http.NoBody)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+
+	apiPkg.Map(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "true", rr.Header().Get("X-Cache-Stale"))
+}
+
+func TestMapHandler_CacheOnly(t *testing.T) {
+	var apiPkg = &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc: func() int { return 0 },
+					MaxZoomFunc: func() int { return 2 },
+					NameFunc:    func() string { return "example" },
+					GetTileFunc: func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 1, Y: 1, Z: 1} },
+				}, nil
+			},
+		},
+		MaxSide: 10,
+		Downloader: &downloader.DownloaderMock{
+			DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+				return nil, fmt.Errorf("network must not be used")
+			},
+			DownloadCachedFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+				return tiles[:1], &downloader.MissingError{Tiles: tiles[1:]}
+			},
+			MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) {
+				if len(tiles) != side*side {
+					return nil, fmt.Errorf("unexpected tiles count: %d", len(tiles))
+				}
+				return []byte{}, nil
+			},
+		},
+	}
+
+	// missing tiles are listed
+	rr := httptest.NewRecorder()
+	apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=2&cache_only=1", http.NoBody))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"status":404,"body":"3 tiles not found in cache",
+		"missing":[{"x":0,"y":1,"z":1},{"x":1,"y":0,"z":1},{"x":1,"y":1,"z":1}]}`, rr.Body.String())
+
+	// missing tiles are replaced with placeholders
+	apiPkg.Placeholder = true
+	rr = httptest.NewRecorder()
+	apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=2&cache_only=true", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3", rr.Header().Get("X-Cache-Missing"))
+
+	// offline mode serves from cache without flag
+	apiPkg.Offline = true
+	rr = httptest.NewRecorder()
+	apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=2", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, apiPkg.Downloader.(*downloader.DownloaderMock).DownloadCalls(), 0)
+
+	rr = httptest.NewRecorder()
+	apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&cache_only=yes", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "cache_only parameter error")
+
+	// seeding is refused offline
+	rr = httptest.NewRecorder()
+	apiPkg.CacheSeed(rr, httptest.NewRequest(http.MethodPost, "/cache/seed", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestMapHandler_ResultCache(t *testing.T) {
+	results, err := cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	stale := false
+	mockDownloader := &downloader.DownloaderMock{
+		DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+			if stale {
+				tiles[0].Stale = true
+			}
+			return tiles, nil
+		},
+		MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) { return []byte("merged"), nil },
+	}
+
+	var apiPkg = &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc: func() int { return 0 },
+					MaxZoomFunc: func() int { return 2 },
+					NameFunc:    func() string { return "example" },
+					IDFunc:      func() string { return "ex" },
+					GetTileFunc: func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 1, Y: 1, Z: 1} },
+				}, nil
+			},
+		},
+		MaxSide:    10,
+		Downloader: mockDownloader,
+		Results:    results,
+	}
+
+	request := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+
+	assert.Equal(t, "miss", request("&side=2").Header().Get("X-Result-Cache"))
+
+	rr := request("&side=2")
+	assert.Equal(t, "hit", rr.Header().Get("X-Result-Cache"))
+	assert.Equal(t, "merged", rr.Body.String())
+	assert.Len(t, mockDownloader.MergeCalls(), 1)
+
+	// side is a part of key
+	assert.Equal(t, "miss", request("&side=3").Header().Get("X-Result-Cache"))
+
+	// refreshed tile with a new image invalidates results containing it
+	results.Invalidate("ex", &tile.Tile{X: 2, Y: 2, Z: 1, Image: []byte("new")})
+	assert.Equal(t, "hit", request("&side=2").Header().Get("X-Result-Cache"))
+	assert.Equal(t, "miss", request("&side=3").Header().Get("X-Result-Cache"))
+
+	// tile saved again with the same image keeps results
+	results.Invalidate("ex", &tile.Tile{X: 0, Y: 0, Z: 1})
+	assert.Equal(t, "hit", request("&side=2").Header().Get("X-Result-Cache"))
+
+	results.Invalidate("ex", &tile.Tile{X: 0, Y: 0, Z: 1, Image: []byte("new")})
+	assert.Equal(t, "miss", request("&side=2").Header().Get("X-Result-Cache"))
+	assert.Equal(t, "hit", request("&side=2").Header().Get("X-Result-Cache"))
+
+	// result with stale tiles is not cached
+	stale = true
+	apiPkg.Results.Purge("ex")
+	assert.Equal(t, "miss", request("&side=2").Header().Get("X-Result-Cache"))
+	assert.Equal(t, "miss", request("&side=2").Header().Get("X-Result-Cache"))
+}
+
+func TestMapHandler_ResultCacheAfterSave(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(white, white.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, white, &jpeg.Options{Quality: 100}))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(buf.Bytes()) }))
+	defer ts.Close()
+
+	results, err := cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	memory, err := cache.NewMemoryCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	p := &provider.ProviderMock{
+		MinZoomFunc: func() int { return 0 },
+		MaxZoomFunc: func() int { return 2 },
+		NameFunc:    func() string { return "example" },
+		IDFunc:      func() string { return "ex" },
+		GetTileFunc: func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 1, Y: 1, Z: 1} },
+		GetRequestFunc: func(*tile.Tile) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+			return req
+		},
+		MaxJobsFunc:       func() int { return 2 },
+		ClientFunc:        func() *http.Client { return nil },
+		MaxSizeFunc:       func() int64 { return 1 << 20 },
+		IsBlankFunc:       func([]byte) bool { return false },
+		RespectMaxAgeFunc: func() bool { return false },
+	}
+
+	a := &API{
+		Logger:     zap.NewNop(),
+		Providers:  &provider.ListMock{GetFunc: func(string) (provider.Provider, error) { return p, nil }},
+		MaxSide:    10,
+		Downloader: downloader.NewMapDownloader(http.DefaultClient),
+		Cache:      cache.NewNotifyCache(memory, results.Invalidate),
+		Results:    results,
+	}
+
+	request := func() string {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=1", http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return rr.Header().Get("X-Result-Cache")
+	}
+
+	assert.Equal(t, "miss", request())
+
+	// downloaded tile is already saved when result is put, so its save doesn't invalidate the result
+	_, err = memory.LoadTile("ex", &tile.Tile{X: 1, Y: 1, Z: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "hit", request())
+}
+
+func TestMapHandler_Watermark(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(white, white.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, white, &jpeg.Options{Quality: 100}))
+
+	attribution := "&copy; <b>A</b>"
+	var apiPkg = &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc:     func() int { return 0 },
+					MaxZoomFunc:     func() int { return 2 },
+					NameFunc:        func() string { return "example" },
+					IDFunc:          func() string { return "ex" },
+					AttributionFunc: func() string { return attribution },
+					ProjectionFunc:  func() *tile.Elips { return &tile.ElipsSpherical },
+					GetTileFunc:     func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 0, Y: 0, Z: 0} },
+				}, nil
+			},
+		},
+		MaxSide: 10,
+		Downloader: &downloader.DownloaderMock{
+			DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+				return tiles, nil
+			},
+			MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) { return buf.Bytes(), nil },
+		},
+		Watermark: &watermark.Options{Position: watermark.TopLeft, FontSize: 13, Color: color.Black, Background: color.NRGBA{R: 255, A: 255}},
+	}
+
+	request := func() image.Image {
+		rr := httptest.NewRecorder()
+		apiPkg.Map(rr, httptest.NewRequest("GET", "/map?provider=example&lat=40.7128&long=-74.0060&zoom=1&side=1", http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		img, err := jpeg.Decode(rr.Body)
+		assert.NoError(t, err)
+		return img
+	}
+
+	// background box of attribution is drawn in chosen corner only
+	img := request()
+	r, g, _, _ := img.At(1, 1).RGBA()
+	assert.Greater(t, r, uint32(0xf000))
+	assert.Less(t, g, uint32(0x1000))
+	_, g, _, _ = img.At(254, 254).RGBA()
+	assert.Greater(t, g, uint32(0xf000))
+
+	// provider without attribution is not stamped
+	attribution = ""
+	img = request()
+	_, g, _, _ = img.At(1, 1).RGBA()
+	assert.Greater(t, g, uint32(0xf000))
+}
+
+func TestMapHandler_Filters(t *testing.T) {
+	a := overlayAPI(t)
+
+	request := func(query string) image.Image {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		img, err := jpeg.Decode(rr.Body)
+		assert.NoError(t, err)
+		return img
+	}
+
+	img := request("&filter=brightness:-100")
+	assert.Equal(t, image.Rect(0, 0, 256, 256), img.Bounds())
+	assertColor(t, color.RGBA{}, img, 128, 128)
+
+	// overlays are drawn over filtered and resized image at the same position
+	img = request("&filter=grayscale&filter=brightness:-50&filter=resize:128&marker=color:red|icon:circle|-66.51326,90")
+	assert.Equal(t, image.Rect(0, 0, 128, 128), img.Bounds())
+	assertColor(t, color.RGBA{R: 0xe5, G: 0x39, B: 0x35}, img, 64, 64)
+	assertColor(t, color.RGBA{R: 0x80, G: 0x80, B: 0x80}, img, 10, 10)
+
+	// fitted image is resized with its view
+	a, _ = fitAPI(t)
+	rr := httptest.NewRecorder()
+	target := "/map?provider=example&fit=true&width=300&height=200&marker=color:red|icon:circle|10,10&filter=resize:150"
+	a.Map(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	img, err := jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 150, 100), img.Bounds())
+	assertColor(t, color.RGBA{R: 0xe5, G: 0x39, B: 0x35}, img, 75, 50)
+}
+
+func TestMapHandler_FiltersErrors(t *testing.T) {
+	a := overlayAPI(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"&filter=blur:2", `filter parameter error: filter \"blur\" not supported`},
+		{"&filter=gamma:20", "filter parameter error: filter gamma value must be within 0.1..10"},
+		{"&filter=resize:3000", "filter parameter error: filter resize value must be within 16..2560"},
+		{"&filter=sharpen", "filter parameter error: filter sharpen value not specified"},
+		{strings.Repeat("&filter=sharpen:5", 9), "filter parameter error: more than 8 filters"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+tt.query, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query)
+		assert.Contains(t, rr.Body.String(), tt.err, tt.query)
+	}
+}
+
+func TestMapHandler_FiltersResultCache(t *testing.T) {
+	a := overlayAPI(t)
+
+	var err error
+	a.Results, err = cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	request := func(query string) string {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header().Get("X-Result-Cache")
+	}
+
+	assert.Equal(t, "miss", request("&filter=grayscale&filter=sharpen:1"))
+	assert.Equal(t, "hit", request("&filter=grayscale&filter=sharpen:1"))
+	assert.Equal(t, "miss", request("&filter=sharpen:1&filter=grayscale"))
+	assert.Equal(t, "miss", request(""))
+}
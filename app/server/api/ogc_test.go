@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+	"go.uber.org/zap"
+)
+
+// ogcVendor return provider of WMS and WMTS tests
+func ogcVendor(id, name string, proj *tile.Elips, minZoom, maxZoom int) provider.Provider {
+	return &provider.ProviderMock{
+		IDFunc:         func() string { return id },
+		NameFunc:       func() string { return name },
+		MinZoomFunc:    func() int { return minZoom },
+		MaxZoomFunc:    func() int { return maxZoom },
+		BoundsFunc:     func() [4]float64 { return [4]float64{-180, -85.5, 180, 85.5} },
+		ProjectionFunc: func() *tile.Elips { return proj },
+	}
+}
+
+// ogcAPI return API of WMS and WMTS tests with providers listed in given order, tile images are made by image func.
+// Tiles of second column are missing in offline mode, tiles of second row are stale. Requested tiles are collected.
+func ogcAPI(offline bool, image func(t tile.Tile) []byte, vendors ...provider.Provider) (*API, *[]tile.Tile) {
+	var requested []tile.Tile
+
+	download := func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+		requested = append(requested, tiles...)
+
+		var result, missing []tile.Tile
+		for _, t := range tiles {
+			if offline && t.X == 1 {
+				missing = append(missing, t)
+				continue
+			}
+
+			t.Image, t.Stale = image(t), t.Y == 1
+			result = append(result, t)
+		}
+
+		if len(missing) > 0 {
+			return result, &downloader.MissingError{Tiles: missing}
+		}
+		return result, nil
+	}
+
+	return &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetAllIDFunc: func() []string {
+				ids := make([]string, 0, len(vendors))
+				for _, v := range vendors {
+					ids = append(ids, v.ID())
+				}
+				return ids
+			},
+			GetFunc: func(key string) (provider.Provider, error) {
+				for _, v := range vendors {
+					if v.ID() == key {
+						return v, nil
+					}
+				}
+				return nil, fmt.Errorf("not found")
+			},
+		},
+		Downloader: &downloader.DownloaderMock{DownloadFunc: download, DownloadCachedFunc: download},
+		MaxSide:    4,
+		Offline:    offline,
+	}, &requested
+}
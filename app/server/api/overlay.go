@@ -0,0 +1,337 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/superboomer/maptile/app/overlay"
+)
+
+// maxOverlaysSize is a max size of overlays in request body
+const maxOverlaysSize = 4 << 20
+
+// overlaysModel contains overlays drawn on map, coordinates are [long, lat] like in GeoJSON
+type overlaysModel struct {
+	Markers  []markerModel   `json:"markers"`
+	Paths    []pathModel     `json:"paths"`
+	Polygons []polygonModel  `json:"polygons"`
+	GeoJSON  json.RawMessage `json:"geojson" swaggertype:"object"` // geometry, Feature or FeatureCollection styled with simplestyle-spec
+}
+
+// markerModel is a marker of map
+type markerModel struct {
+	Point [2]float64 `json:"point"`
+	Icon  string     `json:"icon" enums:"pin,circle,square"`
+	Color string     `json:"color"`
+	Label string     `json:"label"`
+}
+
+// pathModel is a polyline of map, encoded polyline points are appended to points
+type pathModel struct {
+	Points   [][2]float64 `json:"points"`
+	Polyline string       `json:"polyline"`
+	Color    string       `json:"color"`
+	Width    *float64     `json:"width"`
+}
+
+// polygonModel is a polygon of map, encoded polyline points are appended to points
+type polygonModel struct {
+	Points   [][2]float64 `json:"points"`
+	Polyline string       `json:"polyline"`
+	Color    string       `json:"color"`
+	Width    *float64     `json:"width"`
+	Fill     string       `json:"fill"`
+}
+
+// parseOverlays return overlays of marker, path and polygon query parameters and of body of POST request
+func parseOverlays(req *http.Request) (*overlay.Overlays, error) {
+	o := &overlay.Overlays{}
+
+	for _, value := range req.URL.Query()["marker"] {
+		markers, err := parseMarkerParam(value)
+		if err != nil {
+			return nil, fmt.Errorf("marker parameter error: %w", err)
+		}
+		o.Markers = append(o.Markers, markers...)
+	}
+
+	for _, value := range req.URL.Query()["path"] {
+		path, err := parsePathParam(value)
+		if err != nil {
+			return nil, fmt.Errorf("path parameter error: %w", err)
+		}
+		o.Paths = append(o.Paths, *path)
+	}
+
+	for _, value := range req.URL.Query()["polygon"] {
+		polygon, err := parsePolygonParam(value)
+		if err != nil {
+			return nil, fmt.Errorf("polygon parameter error: %w", err)
+		}
+		o.Polygons = append(o.Polygons, *polygon)
+	}
+
+	if req.Method == http.MethodPost && req.Body != nil {
+		body, err := parseOverlaysBody(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("overlays body error: %w", err)
+		}
+		o.Add(body)
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("overlays error: %w", err)
+	}
+
+	return o, nil
+}
+
+// parseOverlaysBody return overlays of JSON body, empty body has no overlays
+func parseOverlaysBody(body io.Reader) (*overlay.Overlays, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxOverlaysSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't read body: %w", err)
+	}
+	if len(data) > maxOverlaysSize {
+		return nil, fmt.Errorf("body must not be larger than %d bytes", maxOverlaysSize)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &overlay.Overlays{}, nil
+	}
+
+	var m overlaysModel
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("can't decode overlays: %w", err)
+	}
+
+	o := &overlay.Overlays{}
+	for i, mm := range m.Markers {
+		marker := overlay.Marker{Point: mm.Point, Icon: mm.Icon, Color: overlay.DefaultMarker, Label: mm.Label}
+		if err = parseColor(mm.Color, &marker.Color); err != nil {
+			return nil, fmt.Errorf("marker %d: %w", i, err)
+		}
+		o.Markers = append(o.Markers, marker)
+	}
+
+	for i, pm := range m.Paths {
+		path := overlay.Path{Color: overlay.DefaultStroke, Width: overlay.DefaultWidth}
+		if path.Points, err = appendPolyline(pm.Points, pm.Polyline); err != nil {
+			return nil, fmt.Errorf("path %d: %w", i, err)
+		}
+		if err = parseColor(pm.Color, &path.Color); err != nil {
+			return nil, fmt.Errorf("path %d: %w", i, err)
+		}
+		if pm.Width != nil {
+			path.Width = *pm.Width
+		}
+		o.Paths = append(o.Paths, path)
+	}
+
+	for i, pm := range m.Polygons {
+		polygon := overlay.Polygon{Color: overlay.DefaultStroke, Width: overlay.DefaultWidth, Fill: overlay.DefaultFill}
+		points, err := appendPolyline(pm.Points, pm.Polyline)
+		if err != nil {
+			return nil, fmt.Errorf("polygon %d: %w", i, err)
+		}
+		polygon.Rings = [][][2]float64{points}
+		if err = parseColor(pm.Color, &polygon.Color); err != nil {
+			return nil, fmt.Errorf("polygon %d: %w", i, err)
+		}
+		if err = parseColor(pm.Fill, &polygon.Fill); err != nil {
+			return nil, fmt.Errorf("polygon %d: %w", i, err)
+		}
+		if pm.Width != nil {
+			polygon.Width = *pm.Width
+		}
+		o.Polygons = append(o.Polygons, polygon)
+	}
+
+	if len(m.GeoJSON) > 0 {
+		g, err := overlay.ParseGeoJSON(m.GeoJSON)
+		if err != nil {
+			return nil, err
+		}
+		o.Add(g)
+	}
+
+	return o, nil
+}
+
+// parseMarkerParam parse markers "color:red|icon:pin|label:A|lat,long|lat,long", styles are optional and shared by points
+func parseMarkerParam(value string) ([]overlay.Marker, error) {
+	styles, points, err := parseOverlayParam(value, "color", "icon", "label")
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("points are not specified")
+	}
+
+	marker := overlay.Marker{Icon: styles["icon"], Color: overlay.DefaultMarker, Label: styles["label"]}
+	if err = parseColor(styles["color"], &marker.Color); err != nil {
+		return nil, err
+	}
+
+	markers := make([]overlay.Marker, 0, len(points))
+	for _, p := range points {
+		marker.Point = p
+		markers = append(markers, marker)
+	}
+
+	return markers, nil
+}
+
+// parsePathParam parse path "color:blue|width:3|lat,long|lat,long" or "color:blue|enc:polyline"
+func parsePathParam(value string) (*overlay.Path, error) {
+	styles, points, err := parseOverlayParam(value, "color", "width")
+	if err != nil {
+		return nil, err
+	}
+
+	path := &overlay.Path{Points: points, Color: overlay.DefaultStroke, Width: overlay.DefaultWidth}
+	if err = parseColor(styles["color"], &path.Color); err != nil {
+		return nil, err
+	}
+	if err = parseWidth(styles["width"], &path.Width); err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+// parsePolygonParam parse polygon "color:blue|width:3|fill:#0000ff80|lat,long|lat,long|lat,long" or with encoded polyline
+func parsePolygonParam(value string) (*overlay.Polygon, error) {
+	styles, points, err := parseOverlayParam(value, "color", "width", "fill")
+	if err != nil {
+		return nil, err
+	}
+
+	polygon := &overlay.Polygon{Rings: [][][2]float64{points}, Color: overlay.DefaultStroke, Width: overlay.DefaultWidth,
+		Fill: overlay.DefaultFill}
+	if err = parseColor(styles["color"], &polygon.Color); err != nil {
+		return nil, err
+	}
+	if err = parseColor(styles["fill"], &polygon.Fill); err != nil {
+		return nil, err
+	}
+	if err = parseWidth(styles["width"], &polygon.Width); err != nil {
+		return nil, err
+	}
+
+	return polygon, nil
+}
+
+// parseOverlayParam split overlay parameter by "|" into styles "key:value" and points "lat,long",
+// encoded polyline "enc:..." is the last part as it may contain "|"
+func parseOverlayParam(value string, keys ...string) (map[string]string, [][2]float64, error) {
+	styles := make(map[string]string)
+	var points [][2]float64
+
+	for value != "" {
+		part := value
+		if strings.HasPrefix(value, "enc:") {
+			value = ""
+		} else if i := strings.IndexByte(value, '|'); i >= 0 {
+			part, value = value[:i], value[i+1:]
+		} else {
+			value = ""
+		}
+
+		if key, v, ok := strings.Cut(part, ":"); ok {
+			if key == "enc" {
+				line, err := overlay.DecodePolyline(v)
+				if err != nil {
+					return nil, nil, err
+				}
+				points = append(points, line...)
+				continue
+			}
+
+			if !slices.Contains(keys, key) {
+				return nil, nil, fmt.Errorf("style %q not supported", key)
+			}
+			styles[key] = v
+			continue
+		}
+
+		lat, long, ok := strings.Cut(part, ",")
+		if !ok {
+			return nil, nil, fmt.Errorf("point %q must be lat,long", part)
+		}
+
+		latValue, err := parseFloatParam(lat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("latitude of point %q: %w", part, err)
+		}
+		longValue, err := parseFloatParam(long)
+		if err != nil {
+			return nil, nil, fmt.Errorf("longitude of point %q: %w", part, err)
+		}
+		points = append(points, [2]float64{longValue, latValue})
+	}
+
+	return styles, points, nil
+}
+
+// appendPolyline return points with points of encoded polyline appended
+func appendPolyline(points [][2]float64, polyline string) ([][2]float64, error) {
+	if polyline == "" {
+		return points, nil
+	}
+
+	line, err := overlay.DecodePolyline(polyline)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(points, line...), nil
+}
+
+// parseColor parse color if value is not empty
+func parseColor(value string, c *color.NRGBA) error {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := overlay.ParseColor(value)
+	if err != nil {
+		return err
+	}
+
+	*c = parsed
+	return nil
+}
+
+// parseWidth parse width if value is not empty
+func parseWidth(value string, width *float64) error {
+	if value == "" {
+		return nil
+	}
+
+	w, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("width: %w", err)
+	}
+
+	*width = w
+	return nil
+}
+
+// hashKey return hash of JSON of value, it's a part of result cache key
+func hashKey(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("can't encode key: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/overlay"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+	"go.uber.org/zap"
+)
+
+// overlayAPI return API serving white tile 1/1/1 which shows long 0..180 and lat 0..-85
+func overlayAPI(t *testing.T) *API {
+	white := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(white, white.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, white, &jpeg.Options{Quality: 100}))
+
+	return &API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				return &provider.ProviderMock{
+					MinZoomFunc:     func() int { return 0 },
+					MaxZoomFunc:     func() int { return 2 },
+					NameFunc:        func() string { return "example" },
+					IDFunc:          func() string { return "ex" },
+					AttributionFunc: func() string { return "" },
+					ProjectionFunc:  func() *tile.Elips { return &tile.ElipsSpherical },
+					GetTileFunc:     func(lat, long, scale float64) tile.Tile { return tile.Tile{X: 1, Y: 1, Z: 1} },
+				}, nil
+			},
+		},
+		MaxSide: 10,
+		Downloader: &downloader.DownloaderMock{
+			DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+				return tiles, nil
+			},
+			MergeFunc: func(side int, centerTile tile.Tile, tiles ...tile.Tile) ([]byte, error) { return buf.Bytes(), nil },
+		},
+	}
+}
+
+// assertColor check color of pixel of JPEG image with tolerance of compression
+func assertColor(t *testing.T, expected color.RGBA, img image.Image, x, y int) {
+	r, g, b, _ := img.At(x, y).RGBA()
+	actual := [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	for i, v := range [3]uint8{expected.R, expected.G, expected.B} {
+		assert.InDelta(t, int(v), actual[i], 32, "pixel %d,%d is %v", x, y, actual)
+	}
+}
+
+func TestMapHandler_Overlays(t *testing.T) {
+	a := overlayAPI(t)
+
+	// center of image is lat -66.51326, long 90
+	rr := httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+
+		"&marker=color:red|icon:circle|-66.51326,90"+
+		"&path=color:0x0000ff|width:6|-66.51326,10|-66.51326,80"+
+		"&polygon=color:black|width:0|fill:green|-10,10|-10,80|-40,80|-40,10", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	img, err := jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assertColor(t, color.RGBA{R: 0xe5, G: 0x39, B: 0x35}, img, 128, 128)
+	assertColor(t, color.RGBA{B: 0xff}, img, 64, 128)
+	assertColor(t, color.RGBA{R: 0x43, G: 0xa0, B: 0x47}, img, 64, 30)
+	assertColor(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff}, img, 200, 200)
+
+	// overlays of POST body are drawn too
+	rr = httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodPost, "/map?provider=example&lat=-66&long=90&zoom=1&side=1", strings.NewReader(`{
+		"markers":[{"point":[90,-66.51326],"icon":"square","color":"#f00"}],
+		"paths":[{"points":[[10,-66.51326],[80,-66.51326]],"color":"blue","width":6}],
+		"geojson":{"type":"Feature","properties":{"fill":"#0f0","fill-opacity":1},
+			"geometry":{"type":"Polygon","coordinates":[[[10,-10],[80,-10],[80,-40],[10,-40],[10,-10]]]}}
+	}`)))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	img, err = jpeg.Decode(rr.Body)
+	assert.NoError(t, err)
+	assertColor(t, color.RGBA{R: 0xff}, img, 128+3, 128+3)
+	assertColor(t, color.RGBA{R: 0x1e, G: 0x88, B: 0xe5}, img, 64, 128)
+	assertColor(t, color.RGBA{G: 0xff}, img, 64, 30)
+}
+
+func TestMapHandler_OverlaysErrors(t *testing.T) {
+	a := overlayAPI(t)
+
+	tests := []struct {
+		method, query, body string
+		err                 string
+	}{
+		{http.MethodGet, "&marker=color:red", "", "marker parameter error: points are not specified"},
+		{http.MethodGet, "&marker=size:big|1,2", "", `marker parameter error: style \"size\" not supported`},
+		{http.MethodGet, "&marker=1%202", "", `marker parameter error: point \"1 2\" must be lat,long`},
+		{http.MethodGet, "&marker=a,2", "", `marker parameter error: latitude of point \"a,2\"`},
+		{http.MethodGet, "&marker=10,NaN", "", `marker parameter error: longitude of point \"10,NaN\": must be a finite number`},
+		{http.MethodGet, "&marker=Inf,2", "", `marker parameter error: latitude of point \"Inf,2\": must be a finite number`},
+		{http.MethodGet, "&path=width:NaN|1,2|3,4", "", "overlays error: path 0: width must be within 0..64"},
+		{http.MethodGet, "&path=width:x|1,2|3,4", "", "path parameter error: width"},
+		{http.MethodGet, "&path=enc:_p~iF~ps", "", "path parameter error: polyline is truncated"},
+		{http.MethodGet, "&polygon=fill:pink|1,2|3,4|5,6", "", "polygon parameter error: invalid color"},
+		{http.MethodGet, "&path=1,2", "", "overlays error: path 0: must contain at least 2 points"},
+		{http.MethodGet, "&marker=icon:star|1,2", "", `overlays error: marker 0: icon \"star\" not supported`},
+		{http.MethodGet, "&marker=91,2", "", "overlays error: marker 0: coordinates 91, 2 are out of range"},
+		{http.MethodPost, "", "{", "overlays body error: can't decode overlays"},
+		{http.MethodPost, "", `{"paths":[{"points":[[1,2],[3,4]],"color":"pink"}]}`, "overlays body error: path 0: invalid color"},
+		{http.MethodPost, "", `{"geojson":{"type":"Circle"}}`, `overlays body error: geojson type \"Circle\" not supported`},
+		{http.MethodPost, "", `{"polygons":[{"points":[[1,2],[3,4],[5,6]],"width":100}]}`,
+			"overlays error: polygon 0: width must be within 0..64"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(tt.method, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+tt.query, strings.NewReader(tt.body)))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query+tt.body)
+		assert.Contains(t, rr.Body.String(), tt.err, tt.query+tt.body)
+	}
+
+	// empty body of POST request has no overlays
+	rr := httptest.NewRecorder()
+	a.Map(rr, httptest.NewRequest(http.MethodPost, "/map?provider=example&lat=-66&long=90&zoom=1&side=1", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestMapHandler_OverlaysResultCache(t *testing.T) {
+	a := overlayAPI(t)
+
+	var err error
+	a.Results, err = cache.NewResultCache(time.Hour, 1<<20)
+	assert.NoError(t, err)
+
+	request := func(query string) string {
+		rr := httptest.NewRecorder()
+		a.Map(rr, httptest.NewRequest(http.MethodGet, "/map?provider=example&lat=-66&long=90&zoom=1&side=1"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header().Get("X-Result-Cache")
+	}
+
+	assert.Equal(t, "miss", request(""))
+	assert.Equal(t, "miss", request("&marker=-60,90"))
+	assert.Equal(t, "hit", request("&marker=-60,90"))
+	assert.Equal(t, "miss", request("&marker=color:red|-60,90"))
+	assert.Equal(t, "hit", request(""))
+}
+
+func TestParseOverlayParam(t *testing.T) {
+	styles, points, err := parseOverlayParam("color:red|55.75,37.61|label:A|enc:_p~iF~ps|U", "color", "label")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"color": "red", "label": "A"}, styles)
+	assert.Equal(t, [][2]float64{{37.61, 55.75}, {-120.2, 38.5}}, points)
+
+	markers, err := parseMarkerParam("icon:square|label:B|1,2|3,4")
+	assert.NoError(t, err)
+	assert.Equal(t, []overlay.Marker{
+		{Point: [2]float64{2, 1}, Icon: overlay.IconSquare, Color: overlay.DefaultMarker, Label: "B"},
+		{Point: [2]float64{4, 3}, Icon: overlay.IconSquare, Color: overlay.DefaultMarker, Label: "B"},
+	}, markers)
+
+	path, err := parsePathParam("1,2|3,4")
+	assert.NoError(t, err)
+	assert.Equal(t, &overlay.Path{Points: [][2]float64{{2, 1}, {4, 3}}, Color: overlay.DefaultStroke, Width: overlay.DefaultWidth}, path)
+
+	polygon, err := parsePolygonParam("width:0|1,2|3,4|5,6")
+	assert.NoError(t, err)
+	assert.Equal(t, &overlay.Polygon{Rings: [][][2]float64{{{2, 1}, {4, 3}, {6, 5}}}, Color: overlay.DefaultStroke, Fill: overlay.DefaultFill},
+		polygon)
+}
+
+func TestHashKey(t *testing.T) {
+	key, err := hashKey(&overlay.Overlays{Markers: []overlay.Marker{{Point: [2]float64{1, 2}}}})
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	_, err = hashKey(math.NaN())
+	assert.Error(t, err)
+}
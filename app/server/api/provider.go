@@ -2,14 +2,65 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
 )
 
 // providerModel contains data about provider
 type providerModel struct {
-	Name    string `json:"name"`
-	Key     string `json:"key"`
-	MaxZoom int    `json:"max_zoom"`
+	Name         string `json:"name"`
+	Key          string `json:"key"`
+	MinZoom      int    `json:"min_zoom"`
+	MaxZoom      int    `json:"max_zoom"`
+	Attribution  string `json:"attribution,omitempty"`
+	License      string `json:"license,omitempty"`
+	Restrictions string `json:"restrictions,omitempty"`
+}
+
+// tileJSONModel is a TileJSON 3.0.0 document of provider
+type tileJSONModel struct {
+	TileJSON    string     `json:"tilejson"`
+	Name        string     `json:"name"`
+	Tiles       []string   `json:"tiles"`
+	MinZoom     int        `json:"minzoom"`
+	MaxZoom     int        `json:"maxzoom"`
+	Bounds      [4]float64 `json:"bounds"`
+	Center      [3]float64 `json:"center"`
+	Attribution string     `json:"attribution,omitempty"`
+	Scheme      string     `json:"scheme"`
+}
+
+// styleModel is a MapLibre style with single raster layer of provider
+type styleModel struct {
+	Version int                         `json:"version"`
+	Name    string                      `json:"name"`
+	Center  [2]float64                  `json:"center"`
+	Zoom    int                         `json:"zoom"`
+	Sources map[string]styleSourceModel `json:"sources"`
+	Layers  []styleLayerModel           `json:"layers"`
+}
+
+// styleSourceModel is a raster source of MapLibre style
+type styleSourceModel struct {
+	Type        string     `json:"type"`
+	Tiles       []string   `json:"tiles"`
+	TileSize    int        `json:"tileSize"`
+	MinZoom     int        `json:"minzoom"`
+	MaxZoom     int        `json:"maxzoom"`
+	Bounds      [4]float64 `json:"bounds"`
+	Attribution string     `json:"attribution,omitempty"`
+	Scheme      string     `json:"scheme"`
+}
+
+// styleLayerModel is a layer of MapLibre style
+type styleLayerModel struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
 }
 
 // Provider godoc
@@ -30,7 +81,8 @@ func (a *API) Provider(w http.ResponseWriter, _ *http.Request) {
 			continue
 		}
 
-		allProviders = append(allProviders, providerModel{Name: p.Name(), Key: p.ID(), MaxZoom: p.MaxZoom()})
+		allProviders = append(allProviders, providerModel{Name: p.Name(), Key: p.ID(), MinZoom: p.MinZoom(), MaxZoom: p.MaxZoom(),
+			Attribution: p.Attribution(), License: p.License(), Restrictions: p.Restrictions()})
 	}
 
 	results, _ := json.Marshal(allProviders)
@@ -38,3 +90,99 @@ func (a *API) Provider(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(results)
 }
+
+// ProviderTileJSON godoc
+// @Summary handler return TileJSON of provider
+// @Description return TileJSON 3.0.0 with url template of provider tiles served by WMTS endpoint, wgs84 providers aren't supported
+// @Produce  application/json
+// @Param id path string true "provider ID"
+// @Success		200	{object}	tileJSONModel
+// @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /provider/{id}/tilejson.json [get]
+func (a *API) ProviderTileJSON(w http.ResponseWriter, req *http.Request) {
+	vendor, ok := a.xyzProvider(w, req)
+	if !ok {
+		return
+	}
+
+	bounds := vendor.Bounds()
+	writeJSON(w, http.StatusOK, tileJSONModel{
+		TileJSON:    "3.0.0",
+		Name:        vendor.Name(),
+		Tiles:       []string{tileURL(req, vendor)},
+		MinZoom:     vendor.MinZoom(),
+		MaxZoom:     vendor.MaxZoom(),
+		Bounds:      bounds,
+		Center:      [3]float64{(bounds[0] + bounds[2]) / 2, (bounds[1] + bounds[3]) / 2, float64(vendor.MinZoom())},
+		Attribution: vendor.Attribution(),
+		Scheme:      "xyz",
+	})
+}
+
+// ProviderStyle godoc
+// @Summary handler return MapLibre style of provider
+// @Description return MapLibre style with single raster layer of provider tiles, wgs84 providers aren't supported
+// @Produce  application/json
+// @Param id path string true "provider ID"
+// @Success		200	{object}	styleModel
+// @Failure 400 {object} mapErrorModel
+// @Failure 404 {object} mapErrorModel
+// @Header 200 {string} X-Request-Id "request_id"
+// @Router /provider/{id}/style.json [get]
+func (a *API) ProviderStyle(w http.ResponseWriter, req *http.Request) {
+	vendor, ok := a.xyzProvider(w, req)
+	if !ok {
+		return
+	}
+
+	bounds := vendor.Bounds()
+	writeJSON(w, http.StatusOK, styleModel{
+		Version: 8,
+		Name:    vendor.Name(),
+		Center:  [2]float64{(bounds[0] + bounds[2]) / 2, (bounds[1] + bounds[3]) / 2},
+		Zoom:    vendor.MinZoom(),
+		Sources: map[string]styleSourceModel{vendor.ID(): {
+			Type:        "raster",
+			Tiles:       []string{tileURL(req, vendor)},
+			TileSize:    tile.Size,
+			MinZoom:     vendor.MinZoom(),
+			MaxZoom:     vendor.MaxZoom(),
+			Bounds:      bounds,
+			Attribution: vendor.Attribution(),
+			Scheme:      "xyz",
+		}},
+		Layers: []styleLayerModel{{ID: vendor.ID(), Type: "raster", Source: vendor.ID()}},
+	})
+}
+
+// xyzProvider return provider of path which tiles are usable as XYZ tiles of web maps, only spherical mercator is.
+// It writes error answer and returns false if provider isn't found or its tiles are in other projection.
+func (a *API) xyzProvider(w http.ResponseWriter, req *http.Request) (provider.Provider, bool) {
+	id := req.PathValue("id")
+
+	vendor, err := a.Providers.Get(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, mapErrorModel{Status: http.StatusNotFound, Body: fmt.Sprintf("provider %s not found", id)})
+		return nil, false
+	}
+
+	if wmtsMatrixSetOf(vendor) != matrixSetSpherical {
+		writeJSON(w, http.StatusBadRequest, mapErrorModel{Status: http.StatusBadRequest,
+			Body: fmt.Sprintf("provider %s tiles are in wgs84 projection, TileJSON and MapLibre support spherical tiles only", id)})
+		return nil, false
+	}
+
+	return vendor, true
+}
+
+// tileURL return XYZ url template of provider tiles served by WMTS endpoint, extension is of provider format
+// so tiles are served as is without conversion
+func tileURL(req *http.Request, vendor provider.Provider) string {
+	ext := "png"
+	if vendor.Format() == "image/jpeg" {
+		ext = "jpg"
+	}
+	return strings.Join([]string{baseURL(req), "wmts", vendor.ID(), "default", wmtsMatrixSetOf(vendor), "{z}/{y}/{x}." + ext}, "/")
+}
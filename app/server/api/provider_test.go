@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/superboomer/maptile/app/provider"
 	"github.com/superboomer/maptile/app/server/api"
+	"github.com/superboomer/maptile/app/tile"
 	"go.uber.org/zap"
 )
 
@@ -28,8 +29,20 @@ func TestProviderHandler_Success(t *testing.T) {
 					IDFunc: func() string {
 						return "a"
 					},
+					MinZoomFunc: func() int {
+						return 0
+					},
 					MaxZoomFunc: func() int {
 						return 2
+					},
+					AttributionFunc: func() string {
+						return "&copy; A"
+					},
+					LicenseFunc: func() string {
+						return "https://example.com/terms"
+					},
+					RestrictionsFunc: func() string {
+						return "no bulk download"
 					}}, nil
 			case "providerB":
 				return &provider.ProviderMock{
@@ -39,8 +52,20 @@ func TestProviderHandler_Success(t *testing.T) {
 					IDFunc: func() string {
 						return "b"
 					},
+					MinZoomFunc: func() int {
+						return 0
+					},
 					MaxZoomFunc: func() int {
 						return 3
+					},
+					AttributionFunc: func() string {
+						return ""
+					},
+					LicenseFunc: func() string {
+						return ""
+					},
+					RestrictionsFunc: func() string {
+						return ""
 					}}, nil
 			default:
 				return nil, fmt.Errorf("not found")
@@ -69,7 +94,11 @@ func TestProviderHandler_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Handler did not return expected status code")
 
 	// Check the response body
-	expectedBody := `[{"name":"providerA","key":"a","max_zoom":2},{"name":"providerB","key":"b","max_zoom":3}]`
+	expectedBody := `[
+		{"name":"providerA","key":"a","min_zoom":0,"max_zoom":2,"attribution":"&copy; A","license":"https://example.com/terms",
+			"restrictions":"no bulk download"},
+		{"name":"providerB","key":"b","min_zoom":0,"max_zoom":3}
+	]`
 	assert.JSONEq(t, expectedBody, rr.Body.String(), "Response body did not match expected JSON")
 }
 
@@ -89,8 +118,20 @@ func TestProviderHandler_FailedUnregistered(t *testing.T) {
 					IDFunc: func() string {
 						return "a"
 					},
+					MinZoomFunc: func() int {
+						return 0
+					},
 					MaxZoomFunc: func() int {
 						return 2
+					},
+					AttributionFunc: func() string {
+						return ""
+					},
+					LicenseFunc: func() string {
+						return ""
+					},
+					RestrictionsFunc: func() string {
+						return ""
 					}}, nil
 			default:
 				return nil, fmt.Errorf("not found")
@@ -119,6 +160,83 @@ func TestProviderHandler_FailedUnregistered(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Handler did not return expected status code")
 
 	// Check the response body
-	expectedBody := `[{"name":"providerA","key":"a","max_zoom":2}]`
+	expectedBody := `[{"name":"providerA","key":"a","min_zoom":0,"max_zoom":2}]`
 	assert.JSONEq(t, expectedBody, rr.Body.String(), "Response body did not match expected JSON")
 }
+
+func TestProviderTileJSON(t *testing.T) {
+	apiPkg := &api.API{
+		Logger: zap.NewNop(),
+		Providers: &provider.ListMock{
+			GetFunc: func(key string) (provider.Provider, error) {
+				if key == "yandex" {
+					return &provider.ProviderMock{ProjectionFunc: func() *tile.Elips { return &tile.ElipsWGS84 }}, nil
+				}
+				if key != "osm" && key != "google" {
+					return nil, fmt.Errorf("not found")
+				}
+				format := "image/png"
+				if key == "google" {
+					format = "image/jpeg"
+				}
+				return &provider.ProviderMock{
+					NameFunc:        func() string { return "OpenStreetMap" },
+					IDFunc:          func() string { return key },
+					FormatFunc:      func() string { return format },
+					MinZoomFunc:     func() int { return 2 },
+					MaxZoomFunc:     func() int { return 19 },
+					BoundsFunc:      func() [4]float64 { return [4]float64{30, 50, 40, 60} },
+					AttributionFunc: func() string { return "© OpenStreetMap contributors" },
+					ProjectionFunc:  func() *tile.Elips { return &tile.ElipsSpherical },
+				}, nil
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/provider/{id}/tilejson.json", apiPkg.ProviderTileJSON)
+	mux.HandleFunc("/provider/{id}/style.json", apiPkg.ProviderStyle)
+
+	req := httptest.NewRequest(http.MethodGet, "http://maps.example.com/provider/osm/tilejson.json", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"tilejson":"3.0.0","name":"OpenStreetMap",
+		"tiles":["http://maps.example.com/wmts/osm/default/GoogleMapsCompatible/{z}/{y}/{x}.png"],
+		"minzoom":2,"maxzoom":19,"bounds":[30,50,40,60],"center":[35,55,2],
+		"attribution":"© OpenStreetMap contributors","scheme":"xyz"}`, rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "http://maps.example.com/provider/osm/style.json", http.NoBody)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"version":8,"name":"OpenStreetMap","center":[35,55],"zoom":2,
+		"sources":{"osm":{"type":"raster","tiles":["https://maps.example.com/wmts/osm/default/GoogleMapsCompatible/{z}/{y}/{x}.png"],
+		"tileSize":256,"minzoom":2,"maxzoom":19,"bounds":[30,50,40,60],"attribution":"© OpenStreetMap contributors","scheme":"xyz"}},
+		"layers":[{"id":"osm","type":"raster","source":"osm"}]}`, rr.Body.String())
+
+	// tiles are requested in native format of provider, so they are not converted
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://maps.example.com/provider/google/tilejson.json", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"tiles":["http://maps.example.com/wmts/google/default/GoogleMapsCompatible/{z}/{y}/{x}.jpg"]`)
+
+	for _, target := range []string{"/provider/bing/tilejson.json", "/provider/bing/style.json"} {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.JSONEq(t, `{"status":404,"body":"provider bing not found"}`, rr.Body.String())
+	}
+
+	// tiles of wgs84 provider would be shifted in web maps
+	for _, target := range []string{"/provider/yandex/tilejson.json", "/provider/yandex/style.json"} {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "provider yandex tiles are in wgs84 projection")
+	}
+}
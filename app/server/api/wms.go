@@ -0,0 +1,550 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap"
+
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// WMS versions, 1.1.0 requests are answered as 1.1.1
+const (
+	wmsVersion111 = "1.1.1"
+	wmsVersion130 = "1.3.0"
+)
+
+// wmsCRSList is a list of supported CRS, mercator ones are in meters and geographic ones are in degrees
+var wmsCRSList = []string{"EPSG:3857", "EPSG:3395", "EPSG:4326", "CRS:84"}
+
+// wmsMap contains parameters of GetMap request, bbox is in east, north order regardless of CRS axis order
+type wmsMap struct {
+	Version     string
+	Layers      []string
+	CRS         string
+	MinX, MinY  float64
+	MaxX, MaxY  float64
+	Width       int
+	Height      int
+	Format      string
+	Transparent bool
+	Background  color.RGBA
+	CacheOnly   bool
+}
+
+// wmsError is an error of request with WMS exception code
+type wmsError struct {
+	Code string
+	Text string
+}
+
+func (e *wmsError) Error() string {
+	return e.Text
+}
+
+// wmsBoundingBox is a bounding box of layer in CRS, it's written in axis order of CRS
+type wmsBoundingBox struct {
+	CRS                    string
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// wmsLayer is a layer of capabilities
+type wmsLayer struct {
+	ID     string
+	Name   string
+	MaxLat float64
+	Boxes  []wmsBoundingBox
+}
+
+// wmsException is an OGC service exception report
+type wmsException struct {
+	XMLName   xml.Name
+	Version   string `xml:"version,attr"`
+	Exception struct {
+		Code string `xml:"code,attr,omitempty"`
+		Text string `xml:",chardata"`
+	} `xml:"ServiceException"`
+}
+
+// WMS godoc
+// @Summary OGC WMS 1.1.1 and 1.3.0 service
+// @Description GetCapabilities lists every provider as a layer, GetMap renders providers tiles to the exact requested bbox and size,
+// @Description best zoom is chosen by resolution of request. Layers are drawn over each other in requested order.
+// @Produce text/xml
+// @Produce image/png
+// @Produce image/jpeg
+// @Param SERVICE query string true "WMS"
+// @Param REQUEST query string true "GetCapabilities or GetMap"
+// @Param VERSION query string false "1.1.1 or 1.3.0" default(1.3.0)
+// @Param LAYERS query string false "comma separated provider IDs"
+// @Param CRS query string false "EPSG:3857, EPSG:3395, EPSG:4326 or CRS:84 (SRS in 1.1.1)"
+// @Param BBOX query string false "minx,miny,maxx,maxy in CRS axis order"
+// @Param WIDTH query int false "image width"
+// @Param HEIGHT query int false "image height"
+// @Param FORMAT query string false "image/png or image/jpeg"
+// @Param TRANSPARENT query bool false "transparent background of png image"
+// @Param BGCOLOR query string false "background color" default(0xFFFFFF)
+// @Param cache_only query bool false "serve tiles only from cache, never request upstream"
+// @Success 200 {file} image/png
+// @Failure 400 {string} string "OGC service exception report"
+// @Failure 404 {string} string "OGC service exception report"
+// @Header 200 {string} X-Request-Id "request_id"
+// @Header 200 {string} X-Cache-Stale "true if some tiles are served from cache after expiration"
+// @Header 200 {string} X-Cache-Missing "count of tiles which are not cached and replaced with placeholders"
+// @Router /wms [get]
+func (a *API) WMS(w http.ResponseWriter, req *http.Request) {
+	query := upperQuery(req)
+
+	version := wmsVersion130
+	if strings.HasPrefix(query["VERSION"], "1.1") || strings.HasPrefix(query["WMTVER"], "1.1") {
+		version = wmsVersion111
+	}
+
+	if query["SERVICE"] != "" && !strings.EqualFold(query["SERVICE"], "WMS") {
+		writeWMSException(w, version, http.StatusBadRequest, "", "service must be WMS")
+		return
+	}
+
+	switch strings.ToLower(query["REQUEST"]) {
+	case "getcapabilities", "capabilities":
+		a.wmsCapabilities(w, req, version)
+	case "getmap", "map":
+		a.wmsGetMap(w, req, query, version)
+	case "":
+		writeWMSException(w, version, http.StatusBadRequest, "", "request is not specified")
+	default:
+		writeWMSException(w, version, http.StatusBadRequest, "OperationNotSupported", fmt.Sprintf("request %s not supported", query["REQUEST"]))
+	}
+}
+
+// wmsGetMap render map of requested layers
+func (a *API) wmsGetMap(w http.ResponseWriter, req *http.Request, query map[string]string, version string) {
+	params, err := a.parseWMSMap(query, version)
+	if err != nil {
+		var wErr *wmsError
+		errors.As(err, &wErr)
+		writeWMSException(w, version, http.StatusBadRequest, wErr.Code, wErr.Text)
+		return
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, params.Width, params.Height))
+	if !params.Transparent {
+		draw.Draw(result, result.Bounds(), &image.Uniform{C: params.Background}, image.Point{}, draw.Src)
+	}
+
+	var stale bool
+	var missing int
+
+	for _, id := range params.Layers {
+		vendor, _ := a.Providers.Get(id)
+
+		columns, rows, tiles, err := wmsGrid(params, vendorProjection(vendor), vendor.MinZoom(), vendor.MaxZoom(), 4*a.MaxSide*a.MaxSide)
+		if err != nil {
+			var wErr *wmsError
+			errors.As(err, &wErr)
+			writeWMSException(w, version, http.StatusBadRequest, wErr.Code, fmt.Sprintf("layer %s: %s", id, wErr.Text))
+			return
+		}
+		if len(tiles) == 0 {
+			continue
+		}
+
+		if a.Offline || params.CacheOnly {
+			tiles, err = a.Downloader.DownloadCached(a.Cache, vendor, tiles...)
+		} else {
+			tiles, err = a.Downloader.Download(a.Cache, vendor, tiles...)
+		}
+
+		var missingErr *downloader.MissingError
+		if errors.As(err, &missingErr) {
+			if !a.Placeholder {
+				writeWMSException(w, version, http.StatusNotFound, "", fmt.Sprintf("layer %s: %v", id, err))
+				return
+			}
+
+			tiles = append(tiles, downloader.Placeholders(tiles, missingErr.Tiles)...)
+			missing += len(missingErr.Tiles)
+			err = nil
+		}
+
+		if err != nil {
+			a.Logger.Error("error occurred when downloading tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+			writeWMSException(w, version, http.StatusInternalServerError, "",
+				fmt.Sprintf("error occurred when downloading tiles of layer %s: %v", id, err))
+			return
+		}
+
+		layer, err := downloader.Render(columns, rows, tiles...)
+		if err != nil {
+			a.Logger.Error("error occurred when rendering tiles", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+			writeWMSException(w, version, http.StatusInternalServerError, "",
+				fmt.Sprintf("error occurred when rendering tiles of layer %s: %v", id, err))
+			return
+		}
+
+		draw.Draw(result, result.Bounds(), layer, image.Point{}, draw.Over)
+
+		for _, t := range tiles {
+			stale = stale || t.Stale
+		}
+	}
+
+	var buf bytes.Buffer
+	if params.Format == "image/png" {
+		err = png.Encode(&buf, result)
+	} else {
+		err = jpeg.Encode(&buf, result, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		a.Logger.Error("error occurred when encoding map", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeWMSException(w, version, http.StatusInternalServerError, "", fmt.Sprintf("error occurred when encoding map: %v", err))
+		return
+	}
+
+	if stale {
+		w.Header().Set("X-Cache-Stale", "true")
+	}
+	if missing > 0 {
+		w.Header().Set("X-Cache-Missing", strconv.Itoa(missing))
+	}
+
+	w.Header().Set("Content-Type", params.Format)
+	_, _ = w.Write(buf.Bytes())
+
+	a.Logger.Info("new wms map request", zap.Strings("layers", params.Layers), zap.String("crs", params.CRS),
+		zap.Int("width", params.Width), zap.Int("height", params.Height), zap.String("req_id", req.Header.Get("X-Request-ID")))
+}
+
+// parseWMSMap parse and validate GetMap parameters
+func (a *API) parseWMSMap(query map[string]string, version string) (*wmsMap, error) {
+	params := &wmsMap{Version: version, Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}}
+
+	if query["LAYERS"] == "" {
+		return nil, &wmsError{Code: "LayerNotDefined", Text: "layers are not specified"}
+	}
+	for _, id := range strings.Split(query["LAYERS"], ",") {
+		if _, err := a.Providers.Get(id); err != nil {
+			return nil, &wmsError{Code: "LayerNotDefined", Text: fmt.Sprintf("layer %s not found", id)}
+		}
+		params.Layers = append(params.Layers, id)
+	}
+
+	crsCode, crsParam := "InvalidCRS", "CRS"
+	if version == wmsVersion111 {
+		crsCode, crsParam = "InvalidSRS", "SRS"
+	}
+
+	params.CRS = strings.ToUpper(query[crsParam])
+	if params.CRS == "EPSG:900913" {
+		params.CRS = "EPSG:3857"
+	}
+	if !slices.Contains(wmsCRSList, params.CRS) {
+		return nil, &wmsError{Code: crsCode, Text: fmt.Sprintf("%s %s not supported", strings.ToLower(crsParam), query[crsParam])}
+	}
+
+	bbox := strings.Split(query["BBOX"], ",")
+	if len(bbox) != 4 {
+		return nil, &wmsError{Text: "bbox must be minx,miny,maxx,maxy"}
+	}
+
+	values := make([]float64, 4)
+	for i, v := range bbox {
+		var err error
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			return nil, &wmsError{Text: "bbox must be minx,miny,maxx,maxy"}
+		}
+	}
+
+	// EPSG:4326 of WMS 1.3.0 has latitude first axis order
+	if params.CRS == "EPSG:4326" && version == wmsVersion130 {
+		values[0], values[1], values[2], values[3] = values[1], values[0], values[3], values[2]
+	}
+	params.MinX, params.MinY, params.MaxX, params.MaxY = values[0], values[1], values[2], values[3]
+
+	if params.MinX >= params.MaxX || params.MinY >= params.MaxY {
+		return nil, &wmsError{Text: "bbox min values must be less than max values"}
+	}
+
+	for _, size := range []struct {
+		name string
+		dst  *int
+	}{{"WIDTH", &params.Width}, {"HEIGHT", &params.Height}} {
+		value, err := strconv.Atoi(query[size.name])
+		if err != nil || value < 1 || value > a.MaxSide*tile.Size {
+			return nil, &wmsError{Text: fmt.Sprintf("%s must be within 1-%d", strings.ToLower(size.name), a.MaxSide*tile.Size)}
+		}
+		*size.dst = value
+	}
+
+	params.Format, _, _ = strings.Cut(strings.ToLower(query["FORMAT"]), ";")
+	if params.Format != "image/png" && params.Format != "image/jpeg" {
+		return nil, &wmsError{Code: "InvalidFormat", Text: fmt.Sprintf("format %s not supported", query["FORMAT"])}
+	}
+
+	// jpeg has no alpha channel, so background is always filled
+	params.Transparent = strings.EqualFold(query["TRANSPARENT"], "true") && params.Format == "image/png"
+
+	if bg := query["BGCOLOR"]; bg != "" {
+		rgb, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(bg), "0x"), 16, 32)
+		if err != nil || rgb > 0xffffff {
+			return nil, &wmsError{Text: "bgcolor must be 0xRRGGBB"}
+		}
+		params.Background = color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}
+	}
+
+	if p := query["CACHE_ONLY"]; p != "" {
+		var err error
+		if params.CacheOnly, err = strconv.ParseBool(p); err != nil {
+			return nil, &wmsError{Text: "cache_only must be boolean"}
+		}
+	}
+
+	return params, nil
+}
+
+// wmsGrid choose zoom of provider tiles closest to resolution of map and return positions of pixels
+// in tiles of this zoom and tiles which cover the map. Zoom is within minZoom..maxZoom and it's reduced while count
+// of tiles is above maxTiles, error is returned if there are too many of them even at minZoom.
+func wmsGrid(params *wmsMap, proj *tile.Elips, minZoom, maxZoom, maxTiles int) (columns, rows []float64, tiles []tile.Tile, err error) {
+	longs := make([]float64, params.Width)
+	for i := range longs {
+		x := params.MinX + (float64(i)+0.5)*(params.MaxX-params.MinX)/float64(params.Width)
+		_, longs[i] = wmsToLatLong(params.CRS, x, 0)
+	}
+
+	lats := make([]float64, params.Height)
+	for i := range lats {
+		y := params.MaxY - (float64(i)+0.5)*(params.MaxY-params.MinY)/float64(params.Height)
+		lats[i], _ = wmsToLatLong(params.CRS, 0, y)
+	}
+
+	_, minLong := wmsToLatLong(params.CRS, params.MinX, 0)
+	_, maxLong := wmsToLatLong(params.CRS, params.MaxX, 0)
+	zoom := int(math.Round(math.Log2(360.0 / tile.Size * float64(params.Width) / (maxLong - minLong))))
+	zoom = max(minZoom, min(zoom, maxZoom))
+
+	for ; ; zoom-- {
+		n := float64(int(1) << zoom)
+
+		columns = make([]float64, len(longs))
+		var xs []int
+		for i, long := range longs {
+			columns[i], _ = tile.ConvertToPosition(0, long, float64(zoom), proj)
+			if !(columns[i] >= 0 && columns[i] < n) {
+				columns[i] = math.NaN()
+				continue
+			}
+			if x := int(columns[i]); len(xs) == 0 || xs[len(xs)-1] != x {
+				xs = append(xs, x)
+			}
+		}
+
+		rows = make([]float64, len(lats))
+		var ys []int
+		for i, lat := range lats {
+			_, rows[i] = tile.ConvertToPosition(lat, 0, float64(zoom), proj)
+			if !(rows[i] >= 0 && rows[i] < n) {
+				rows[i] = math.NaN()
+				continue
+			}
+			if y := int(rows[i]); len(ys) == 0 || ys[len(ys)-1] != y {
+				ys = append(ys, y)
+			}
+		}
+
+		if len(xs)*len(ys) > maxTiles {
+			if zoom > minZoom {
+				continue
+			}
+			return nil, nil, nil, &wmsError{Code: "InvalidParameterValue",
+				Text: fmt.Sprintf("map needs more than %d tiles at min zoom %d, reduce bbox or size", maxTiles, minZoom)}
+		}
+
+		tiles = make([]tile.Tile, 0, len(xs)*len(ys))
+		for _, y := range ys {
+			for _, x := range xs {
+				tiles = append(tiles, tile.Tile{X: x, Y: y, Z: zoom})
+			}
+		}
+
+		return columns, rows, tiles, nil
+	}
+}
+
+// wmsToLatLong convert coordinates of CRS to latitude and longitude
+func wmsToLatLong(crs string, x, y float64) (lat, long float64) {
+	switch crs {
+	case "EPSG:3857":
+		return tile.ConvertFromMercator(x, y, &tile.ElipsSpherical)
+	case "EPSG:3395":
+		return tile.ConvertFromMercator(x, y, &tile.ElipsWGS84)
+	default:
+		return y, x
+	}
+}
+
+// wmsCapabilities write capabilities document of all providers
+func (a *API) wmsCapabilities(w http.ResponseWriter, req *http.Request, version string) {
+	var layers []wmsLayer
+	for _, id := range a.Providers.GetAllID() {
+		vendor, err := a.Providers.Get(id)
+		if err != nil {
+			continue
+		}
+		layers = append(layers, newWMSLayer(vendor, version))
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].ID < layers[j].ID })
+
+	contentType := "text/xml; charset=utf-8"
+	if version == wmsVersion111 {
+		contentType = "application/vnd.ogc.wms_xml; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	err := wmsCapabilitiesTemplate.Execute(w, struct {
+		URL     string
+		V111    bool
+		CRSList []string
+		Layers  []wmsLayer
+	}{URL: baseURL(req) + "/wms", V111: version == wmsVersion111, CRSList: wmsCRSList, Layers: layers})
+	if err != nil {
+		a.Logger.Error("error occurred when writing capabilities", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+	}
+}
+
+// newWMSLayer return layer of provider, its extent is the extent of provider tiles grid
+func newWMSLayer(vendor provider.Provider, version string) wmsLayer {
+	maxLat, _ := tile.ConvertFromTile(0, 0, 0, vendorProjection(vendor))
+	layer := wmsLayer{ID: vendor.ID(), Name: vendor.Name(), MaxLat: maxLat}
+
+	for _, crs := range wmsCRSList {
+		box := wmsBoundingBox{CRS: crs, MinX: -180, MinY: -maxLat, MaxX: 180, MaxY: maxLat}
+
+		switch crs {
+		case "EPSG:3857":
+			box.MinX, box.MinY = tile.ConvertToMercator(-maxLat, -180, &tile.ElipsSpherical)
+			box.MaxX, box.MaxY = tile.ConvertToMercator(maxLat, 180, &tile.ElipsSpherical)
+		case "EPSG:3395":
+			box.MinX, box.MinY = tile.ConvertToMercator(-maxLat, -180, &tile.ElipsWGS84)
+			box.MaxX, box.MaxY = tile.ConvertToMercator(maxLat, 180, &tile.ElipsWGS84)
+		case "EPSG:4326":
+			if version == wmsVersion130 {
+				box.MinX, box.MinY, box.MaxX, box.MaxY = box.MinY, box.MinX, box.MaxY, box.MaxX
+			}
+		case "CRS:84":
+			if version == wmsVersion111 {
+				continue // 1.1.1 has no CRS:84 bounding box, EPSG:4326 is the same there
+			}
+		}
+
+		layer.Boxes = append(layer.Boxes, box)
+	}
+
+	return layer
+}
+
+// vendorProjection return projection of provider tiles grid, it's spherical if not specified
+func vendorProjection(vendor provider.Provider) *tile.Elips {
+	if proj := vendor.Projection(); proj != nil {
+		return proj
+	}
+	return &tile.ElipsSpherical
+}
+
+// writeWMSException write OGC service exception report of requested version
+func writeWMSException(w http.ResponseWriter, version string, status int, code, text string) {
+	report := wmsException{Version: version, XMLName: xml.Name{Local: "ServiceExceptionReport"}}
+	report.Exception.Code, report.Exception.Text = code, text
+
+	contentType := "application/vnd.ogc.se_xml; charset=utf-8"
+	if version == wmsVersion130 {
+		report.XMLName.Space = "http://www.opengis.net/ogc"
+		contentType = "text/xml; charset=utf-8"
+	}
+
+	data, _ := xml.Marshal(report)
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+// wmsCapabilitiesTemplate is a WMS capabilities document of 1.3.0 or 1.1.1 version
+var wmsCapabilitiesTemplate = template.Must(template.New("capabilities").Funcs(capabilitiesFuncs).Parse(
+	`<?xml version="1.0" encoding="UTF-8"?>
+{{- $crs := "CRS"}}{{if .V111}}{{$crs = "SRS"}}{{end}}
+{{- if .V111}}
+<WMT_MS_Capabilities version="1.1.1" xmlns:xlink="http://www.w3.org/1999/xlink">
+{{- else}}
+<WMS_Capabilities version="1.3.0" xmlns="http://www.opengis.net/wms" xmlns:xlink="http://www.w3.org/1999/xlink">
+{{- end}}
+  <Service>
+    <Name>{{if .V111}}OGC:WMS{{else}}WMS{{end}}</Name>
+    <Title>Map tiles</Title>
+    <OnlineResource xlink:type="simple" xlink:href="{{xml .URL}}"/>
+  </Service>
+  <Capability>
+    <Request>
+      <GetCapabilities>
+        <Format>{{if .V111}}application/vnd.ogc.wms_xml{{else}}text/xml{{end}}</Format>
+        <DCPType><HTTP><Get><OnlineResource xlink:type="simple" xlink:href="{{xml .URL}}?"/></Get></HTTP></DCPType>
+      </GetCapabilities>
+      <GetMap>
+        <Format>image/png</Format>
+        <Format>image/jpeg</Format>
+        <DCPType><HTTP><Get><OnlineResource xlink:type="simple" xlink:href="{{xml .URL}}?"/></Get></HTTP></DCPType>
+      </GetMap>
+    </Request>
+    <Exception>
+      <Format>{{if .V111}}application/vnd.ogc.se_xml{{else}}XML{{end}}</Format>
+    </Exception>
+    <Layer>
+      <Title>Map tiles</Title>
+{{- range .CRSList}}{{if or (ne . "CRS:84") (not $.V111)}}
+      <{{$crs}}>{{.}}</{{$crs}}>
+{{- end}}{{end}}
+{{- range .Layers}}
+      <Layer queryable="0" opaque="1">
+        <Name>{{xml .ID}}</Name>
+        <Title>{{xml .Name}}</Title>
+{{- if $.V111}}
+        <LatLonBoundingBox minx="-180" miny="-{{num .MaxLat}}" maxx="180" maxy="{{num .MaxLat}}"/>
+{{- else}}
+        <EX_GeographicBoundingBox>
+          <westBoundLongitude>-180</westBoundLongitude>
+          <eastBoundLongitude>180</eastBoundLongitude>
+          <southBoundLatitude>-{{num .MaxLat}}</southBoundLatitude>
+          <northBoundLatitude>{{num .MaxLat}}</northBoundLatitude>
+        </EX_GeographicBoundingBox>
+{{- end}}
+{{- range .Boxes}}
+        <BoundingBox {{$crs}}="{{.CRS}}" minx="{{num .MinX}}" miny="{{num .MinY}}" maxx="{{num .MaxX}}" maxy="{{num .MaxY}}"/>
+{{- end}}
+      </Layer>
+{{- end}}
+    </Layer>
+  </Capability>
+{{- if .V111}}
+</WMT_MS_Capabilities>
+{{- else}}
+</WMS_Capabilities>
+{{- end}}
+`))
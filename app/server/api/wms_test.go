@@ -0,0 +1,289 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// wmsTileColor is a color of tile of test provider
+func wmsTileColor(t tile.Tile) color.RGBA {
+	return color.RGBA{R: uint8(50*t.X + 10), G: uint8(50*t.Y + 10), B: uint8(50 * t.Z), A: 255}
+}
+
+func wmsAPI(offline bool) (*API, *[]tile.Tile) {
+	tileImage := func(t tile.Tile) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+		draw.Draw(img, img.Bounds(), &image.Uniform{C: wmsTileColor(t)}, image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, img)
+		return buf.Bytes()
+	}
+
+	return ogcAPI(offline, tileImage, ogcVendor("yandex", "yandex <maps>", &tile.ElipsWGS84, 0, 18),
+		ogcVendor("osm", "osm <maps>", &tile.ElipsSpherical, 0, 2))
+}
+
+func decodeWMSImage(t *testing.T, rr *httptest.ResponseRecorder) image.Image {
+	img, _, err := image.Decode(bytes.NewReader(rr.Body.Bytes()))
+	assert.NoError(t, err)
+	return img
+}
+
+func TestWMS_GetMap(t *testing.T) {
+	a, requested := wmsAPI(false)
+
+	rr := httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, "/wms?SERVICE=WMS&VERSION=1.3.0&REQUEST=GetMap&LAYERS=osm&STYLES=&CRS=EPSG:3857"+
+		"&BBOX=-20037508.342789,-20037508.342789,20037508.342789,20037508.342789&WIDTH=512&HEIGHT=256&FORMAT=image/png", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "true", rr.Header().Get("X-Cache-Stale"))
+
+	// 512 pixels of the world width is zoom 1
+	assert.Equal(t, []tile.Tile{{X: 0, Y: 0, Z: 1}, {X: 1, Y: 0, Z: 1}, {X: 0, Y: 1, Z: 1}, {X: 1, Y: 1, Z: 1}}, *requested)
+
+	img := decodeWMSImage(t, rr)
+	assert.Equal(t, image.Rect(0, 0, 512, 256), img.Bounds())
+	assert.Equal(t, wmsTileColor(tile.Tile{X: 0, Y: 0, Z: 1}), img.At(10, 10))
+	assert.Equal(t, wmsTileColor(tile.Tile{X: 1, Y: 0, Z: 1}), img.At(500, 10))
+	assert.Equal(t, wmsTileColor(tile.Tile{X: 1, Y: 1, Z: 1}), img.At(500, 250))
+}
+
+func TestWMS_GetMapAxisOrder(t *testing.T) {
+	for _, target := range []string{
+		"/wms?SERVICE=WMS&VERSION=1.3.0&REQUEST=GetMap&LAYERS=osm&CRS=EPSG:4326&BBOX=-80,-170,80,-10&WIDTH=200&HEIGHT=200&FORMAT=image/jpeg",
+		"/wms?SERVICE=WMS&VERSION=1.1.1&REQUEST=GetMap&LAYERS=osm&SRS=EPSG:4326&BBOX=-170,-80,-10,80&WIDTH=200&HEIGHT=200&FORMAT=image/jpeg",
+		"/wms?SERVICE=WMS&VERSION=1.3.0&REQUEST=GetMap&LAYERS=osm&CRS=CRS:84&BBOX=-170,-80,-10,80&WIDTH=200&HEIGHT=200&FORMAT=image/jpeg",
+	} {
+		a, requested := wmsAPI(false)
+
+		rr := httptest.NewRecorder()
+		a.WMS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+
+		// western half of the world
+		assert.Equal(t, []tile.Tile{{X: 0, Y: 0, Z: 1}, {X: 0, Y: 1, Z: 1}}, *requested, target)
+		assert.Equal(t, image.Rect(0, 0, 200, 200), decodeWMSImage(t, rr).Bounds())
+	}
+}
+
+func TestWMS_GetMapBackground(t *testing.T) {
+	a, requested := wmsAPI(false)
+
+	// the map is out of the world, so nothing is downloaded
+	rr := httptest.NewRecorder()
+	target := "/wms?SERVICE=WMS&REQUEST=GetMap&LAYERS=osm&CRS=CRS:84&BBOX=190,0,200,10&WIDTH=10&HEIGHT=10&FORMAT=image/png"
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, target+"&BGCOLOR=0x102030", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Empty(t, *requested)
+	assert.Equal(t, color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, color.RGBAModel.Convert(decodeWMSImage(t, rr).At(5, 5)))
+
+	rr = httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, target+"&TRANSPARENT=TRUE", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	_, _, _, alpha := decodeWMSImage(t, rr).At(5, 5).RGBA()
+	assert.Zero(t, alpha)
+}
+
+func TestWMS_GetMapMissing(t *testing.T) {
+	a, _ := wmsAPI(true)
+	target := "/wms?SERVICE=WMS&REQUEST=GetMap&LAYERS=osm&CRS=CRS:84&BBOX=-180,-85,180,85&WIDTH=512&HEIGHT=512&FORMAT=image/png"
+
+	rr := httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "2 tiles not found in cache")
+
+	a.Placeholder = true
+
+	rr = httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-Cache-Missing"))
+}
+
+func TestWMS_GetMapCacheOnly(t *testing.T) {
+	a, requested := wmsAPI(false)
+	mock := a.Downloader.(*downloader.DownloaderMock)
+
+	rr := httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, "/wms?SERVICE=WMS&REQUEST=GetMap&LAYERS=osm,yandex&CRS=CRS:84"+
+		"&BBOX=-180,-85,180,85&WIDTH=512&HEIGHT=512&FORMAT=image/png&cache_only=true", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotEmpty(t, *requested)
+	assert.Len(t, mock.DownloadCachedCalls(), 2)
+	assert.Empty(t, mock.DownloadCalls())
+}
+
+func TestWMS_Errors(t *testing.T) {
+	a, requested := wmsAPI(false)
+	getMap := "/wms?SERVICE=WMS&REQUEST=GetMap&FORMAT=image/png&"
+
+	tests := []struct {
+		name   string
+		target string
+		code   string
+		text   string
+	}{
+		{"wrong service", "/wms?SERVICE=WMTS&REQUEST=GetMap", "", "service must be WMS"},
+		{"no request", "/wms?SERVICE=WMS", "", "request is not specified"},
+		{"unknown request", "/wms?SERVICE=WMS&REQUEST=GetFeatureInfo", "OperationNotSupported", "request GetFeatureInfo not supported"},
+		{"no layers", getMap + "CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1", "LayerNotDefined", "layers are not specified"},
+		{"unknown layer", getMap + "LAYERS=osm,bing&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1", "LayerNotDefined", "layer bing not found"},
+		{"unknown crs", getMap + "LAYERS=osm&CRS=EPSG:32637&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1", "InvalidCRS", "crs EPSG:32637 not supported"},
+		{"srs of 1.1.1", getMap + "VERSION=1.1.1&LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1", "InvalidSRS", "srs  not supported"},
+		{"invalid bbox", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1&WIDTH=1&HEIGHT=1", "", "bbox must be minx,miny,maxx,maxy"},
+		{"empty bbox", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,0,1&WIDTH=1&HEIGHT=1", "", "bbox min values must be less than max values"},
+		{"too wide", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1025&HEIGHT=1", "", "width must be within 1-1024"},
+		{"no height", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1", "", "height must be within 1-1024"},
+		{"invalid format", "/wms?SERVICE=WMS&REQUEST=GetMap&FORMAT=image/gif&LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1",
+			"InvalidFormat", "format image/gif not supported"},
+		{"invalid bgcolor", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1&BGCOLOR=red", "", "bgcolor must be 0xRRGGBB"},
+		{"invalid cache_only", getMap + "LAYERS=osm&CRS=EPSG:3857&BBOX=0,0,1,1&WIDTH=1&HEIGHT=1&CACHE_ONLY=yes", "",
+			"cache_only must be boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			a.WMS(rr, httptest.NewRequest(http.MethodGet, tt.target, http.NoBody))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+			var report struct {
+				Exception struct {
+					Code string `xml:"code,attr"`
+					Text string `xml:",chardata"`
+				} `xml:"ServiceException"`
+			}
+			assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &report))
+			assert.Equal(t, tt.code, report.Exception.Code)
+			assert.Equal(t, tt.text, report.Exception.Text)
+		})
+	}
+
+	assert.Empty(t, *requested)
+}
+
+func TestWMS_GetMapTooManyTiles(t *testing.T) {
+	a, requested := ogcAPI(false, func(tile.Tile) []byte { return pngData }, ogcVendor("topo", "topo", &tile.ElipsSpherical, 10, 18))
+
+	rr := httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, "/wms?SERVICE=WMS&VERSION=1.3.0&REQUEST=GetMap&LAYERS=topo&CRS=CRS:84"+
+		"&BBOX=-180,-85,180,85&WIDTH=1024&HEIGHT=1024&FORMAT=image/png", http.NoBody))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<ServiceException code="InvalidParameterValue">layer topo: map needs more than 64 tiles`)
+	assert.Empty(t, *requested)
+}
+
+func TestWMS_Capabilities(t *testing.T) {
+	a, _ := wmsAPI(false)
+
+	type box struct {
+		CRS  string  `xml:"CRS,attr"`
+		SRS  string  `xml:"SRS,attr"`
+		MinX float64 `xml:"minx,attr"`
+		MinY float64 `xml:"miny,attr"`
+	}
+	var doc struct {
+		XMLName xml.Name
+		Version string   `xml:"version,attr"`
+		CRS     []string `xml:"Capability>Layer>CRS"`
+		SRS     []string `xml:"Capability>Layer>SRS"`
+		Layers  []struct {
+			Name  string `xml:"Name"`
+			Title string `xml:"Title"`
+			Boxes []box  `xml:"BoundingBox"`
+		} `xml:"Capability>Layer>Layer"`
+	}
+
+	rr := httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, "http://maps.example.com/wms?service=WMS&request=GetCapabilities", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `xlink:href="http://maps.example.com/wms?"`)
+
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &doc))
+	assert.Equal(t, "WMS_Capabilities", doc.XMLName.Local)
+	assert.Equal(t, "1.3.0", doc.Version)
+	assert.Equal(t, []string{"EPSG:3857", "EPSG:3395", "EPSG:4326", "CRS:84"}, doc.CRS)
+	if assert.Len(t, doc.Layers, 2) {
+		assert.Equal(t, "osm", doc.Layers[0].Name)
+		assert.Equal(t, "osm <maps>", doc.Layers[0].Title)
+		assert.Len(t, doc.Layers[0].Boxes, 4)
+		assert.InDelta(t, -20037508.342789, doc.Layers[0].Boxes[0].MinY, 1e-3)
+		assert.Equal(t, "EPSG:4326", doc.Layers[0].Boxes[2].CRS)
+		assert.InDelta(t, -85.0511287798, doc.Layers[0].Boxes[2].MinX, 1e-9) // latitude first
+		assert.InDelta(t, -85.0840590501, doc.Layers[1].Boxes[2].MinX, 1e-9)
+	}
+
+	rr = httptest.NewRecorder()
+	a.WMS(rr, httptest.NewRequest(http.MethodGet, "/wms?SERVICE=WMS&REQUEST=GetCapabilities&VERSION=1.1.1", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/vnd.ogc.wms_xml; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	doc.Layers = nil
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &doc))
+	assert.Equal(t, "WMT_MS_Capabilities", doc.XMLName.Local)
+	assert.Equal(t, "1.1.1", doc.Version)
+	assert.Equal(t, []string{"EPSG:3857", "EPSG:3395", "EPSG:4326"}, doc.SRS)
+	if assert.Len(t, doc.Layers, 2) {
+		assert.Len(t, doc.Layers[0].Boxes, 3)
+		assert.Equal(t, "EPSG:4326", doc.Layers[0].Boxes[2].SRS)
+		assert.Equal(t, -180.0, doc.Layers[0].Boxes[2].MinX)
+	}
+}
+
+func TestWMSGrid(t *testing.T) {
+	params := &wmsMap{CRS: "CRS:84", MinX: -180, MinY: -85, MaxX: 180, MaxY: 85, Width: 2048, Height: 1024}
+
+	// 2048 pixels of the world width is zoom 3
+	columns, rows, tiles, err := wmsGrid(params, &tile.ElipsSpherical, 0, 18, 64)
+	assert.NoError(t, err)
+	assert.Len(t, columns, 2048)
+	assert.Len(t, rows, 1024)
+	assert.Len(t, tiles, 64)
+	assert.Equal(t, 3, tiles[0].Z)
+	assert.InDelta(t, 8.0/2048/2, columns[0], 1e-9)
+
+	// zoom is limited by max zoom of provider and by count of tiles
+	_, _, tiles, err = wmsGrid(params, &tile.ElipsSpherical, 0, 2, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tiles[0].Z)
+	_, _, tiles, err = wmsGrid(params, &tile.ElipsSpherical, 0, 18, 63)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tiles[0].Z)
+
+	// zoom is not reduced below min zoom of provider, it's an error if there are too many tiles at min zoom
+	_, _, tiles, err = wmsGrid(params, &tile.ElipsSpherical, 4, 18, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, tiles[0].Z)
+	_, _, tiles, err = wmsGrid(params, &tile.ElipsSpherical, 3, 18, 63)
+	assert.EqualError(t, err, "map needs more than 63 tiles at min zoom 3, reduce bbox or size")
+	assert.Empty(t, tiles)
+
+	// rows out of projection are skipped
+	params.MinY, params.MaxY = -90, 90
+	_, rows, tiles, err = wmsGrid(params, &tile.ElipsSpherical, 0, 18, 64)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(rows[0]))
+	assert.False(t, math.IsNaN(rows[512]))
+	assert.Len(t, tiles, 64)
+}
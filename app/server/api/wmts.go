@@ -0,0 +1,442 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap"
+
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+// tile matrix sets of WMTS, tiles of spherical providers are in GoogleMapsCompatible
+// and tiles of WGS84 providers are in WorldMercatorWGS84Quad
+const (
+	matrixSetSpherical = "GoogleMapsCompatible"
+	matrixSetWGS84     = "WorldMercatorWGS84Quad"
+)
+
+// wmtsFormats contains formats of RESTful tiles by their extension
+var wmtsFormats = map[string]string{"png": "image/png", "jpg": "image/jpeg", "jpeg": "image/jpeg"}
+
+// scaleDenominator0 is a scale denominator of zoom 0 of 256px mercator tiles with 0.28mm pixel
+const scaleDenominator0 = 559082264.0287178
+
+// wmtsMatrixSet is a tile matrix set of capabilities
+type wmtsMatrixSet struct {
+	ID      string
+	CRS     string
+	MaxZoom int
+}
+
+// wmtsLayer is a layer of capabilities
+type wmtsLayer struct {
+	ID        string
+	Name      string
+	MatrixSet *wmtsMatrixSet
+	MinZoom   int
+	MaxZoom   int
+	Bounds    [4]float64
+}
+
+// wmtsException is an OGC exception report
+type wmtsException struct {
+	XMLName   xml.Name `xml:"http://www.opengis.net/ows/1.1 ExceptionReport"`
+	Version   string   `xml:"version,attr"`
+	Exception struct {
+		Code    string `xml:"exceptionCode,attr"`
+		Locator string `xml:"locator,attr,omitempty"`
+		Text    string `xml:"http://www.opengis.net/ows/1.1 ExceptionText"`
+	} `xml:"http://www.opengis.net/ows/1.1 Exception"`
+}
+
+// WMTS godoc
+// @Summary OGC WMTS 1.0.0 service
+// @Description GetCapabilities and GetTile requests in KVP form. RESTful form is served too:
+// @Description /wmts/1.0.0/WMTSCapabilities.xml and /wmts/{layer}/{style}/{tile_matrix_set}/{tile_matrix}/{tile_row}/{tile_col}.{png|jpg}
+// @Description Tiles are converted to the requested format if it differs from the format of provider.
+// @Produce text/xml
+// @Produce image/png
+// @Produce image/jpeg
+// @Param SERVICE query string true "WMTS"
+// @Param REQUEST query string true "GetCapabilities or GetTile"
+// @Param LAYER query string false "provider ID"
+// @Param TILEMATRIXSET query string false "GoogleMapsCompatible or WorldMercatorWGS84Quad"
+// @Param TILEMATRIX query int false "zoom"
+// @Param TILEROW query int false "tile y"
+// @Param TILECOL query int false "tile x"
+// @Param FORMAT query string false "image/png or image/jpeg, format of provider if omitted"
+// @Param cache_only query bool false "serve tile only from cache, never request upstream"
+// @Success 200 {file} image/png
+// @Failure 400 {string} string "OGC exception report"
+// @Failure 404 {string} string "OGC exception report"
+// @Header 200 {string} X-Request-Id "request_id"
+// @Header 200 {string} X-Cache-Stale "true if tile is served from cache after expiration"
+// @Header 200 {string} X-Cache-Missing "1 if tile is not cached and replaced with placeholder"
+// @Router /wmts [get]
+func (a *API) WMTS(w http.ResponseWriter, req *http.Request) {
+	if path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/wmts"), "/"); path != "" {
+		a.wmtsREST(w, req, path)
+		return
+	}
+
+	query := upperQuery(req)
+
+	if !strings.EqualFold(query["SERVICE"], "WMTS") {
+		writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "service", "service must be WMTS")
+		return
+	}
+
+	switch strings.ToLower(query["REQUEST"]) {
+	case "getcapabilities":
+		a.wmtsCapabilities(w, req)
+	case "gettile":
+		a.wmtsTile(w, req, query["LAYER"], query["TILEMATRIXSET"], query["TILEMATRIX"], query["TILEROW"], query["TILECOL"], query["FORMAT"])
+	case "":
+		writeWMTSException(w, http.StatusBadRequest, "MissingParameterValue", "request", "request is not specified")
+	default:
+		writeWMTSException(w, http.StatusBadRequest, "OperationNotSupported", "request",
+			fmt.Sprintf("request %s not supported", query["REQUEST"]))
+	}
+}
+
+// wmtsREST serve RESTful capabilities and tiles
+func (a *API) wmtsREST(w http.ResponseWriter, req *http.Request, path string) {
+	if path == "1.0.0/WMTSCapabilities.xml" {
+		a.wmtsCapabilities(w, req)
+		return
+	}
+
+	// {layer}/{style}/{tile_matrix_set}/{tile_matrix}/{tile_row}/{tile_col}.{ext}
+	parts := strings.Split(path, "/")
+	if len(parts) != 6 {
+		writeWMTSException(w, http.StatusNotFound, "InvalidParameterValue", "", "resource not found")
+		return
+	}
+
+	col, ext, _ := strings.Cut(parts[5], ".")
+	format, ok := wmtsFormats[strings.ToLower(ext)]
+	if !ok {
+		writeWMTSException(w, http.StatusNotFound, "InvalidParameterValue", "format", "resource not found")
+		return
+	}
+
+	a.wmtsTile(w, req, parts[0], parts[2], parts[3], parts[4], col, format)
+}
+
+// wmtsTile serve single tile, tile is loaded from cache or downloaded like tiles of map and converted to format
+// unless it's empty
+func (a *API) wmtsTile(w http.ResponseWriter, req *http.Request, layer, matrixSet, matrix, row, col, format string) {
+	if format != "" && format != "image/png" && format != "image/jpeg" {
+		writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "format", fmt.Sprintf("format %s not supported", format))
+		return
+	}
+
+	vendor, err := a.Providers.Get(layer)
+	if err != nil {
+		writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "layer", fmt.Sprintf("layer %s not found", layer))
+		return
+	}
+
+	if matrixSet != wmtsMatrixSetOf(vendor) {
+		writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "tilematrixset",
+			fmt.Sprintf("layer %s is available in %s only", layer, wmtsMatrixSetOf(vendor)))
+		return
+	}
+
+	t := tile.Tile{}
+	for _, p := range []struct {
+		name  string
+		value string
+		dst   *int
+	}{{"tilematrix", matrix, &t.Z}, {"tilerow", row, &t.Y}, {"tilecol", col, &t.X}} {
+		if p.value == "" {
+			writeWMTSException(w, http.StatusBadRequest, "MissingParameterValue", p.name, p.name+" is not specified")
+			return
+		}
+		if *p.dst, err = strconv.Atoi(p.value); err != nil {
+			writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", p.name, p.name+" must be integer")
+			return
+		}
+	}
+
+	if t.Z < vendor.MinZoom() || t.Z > vendor.MaxZoom() {
+		writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "tilematrix",
+			fmt.Sprintf("tilematrix must be within %d-%d", vendor.MinZoom(), vendor.MaxZoom()))
+		return
+	}
+
+	if t.X < 0 || t.Y < 0 || t.X >= 1<<t.Z || t.Y >= 1<<t.Z {
+		writeWMTSException(w, http.StatusBadRequest, "TileOutOfRange", "tilerow", "tile is out of tile matrix")
+		return
+	}
+
+	var cacheOnly bool
+	if p := upperQuery(req)["CACHE_ONLY"]; p != "" {
+		if cacheOnly, err = strconv.ParseBool(p); err != nil {
+			writeWMTSException(w, http.StatusBadRequest, "InvalidParameterValue", "cache_only", "cache_only must be boolean")
+			return
+		}
+	}
+
+	var tiles []tile.Tile
+	if a.Offline || cacheOnly {
+		tiles, err = a.Downloader.DownloadCached(a.Cache, vendor, t)
+	} else {
+		tiles, err = a.Downloader.Download(a.Cache, vendor, t)
+	}
+
+	var missingErr *downloader.MissingError
+	if errors.As(err, &missingErr) {
+		if !a.Placeholder {
+			writeWMTSException(w, http.StatusNotFound, "TileOutOfRange", "tilerow", "tile not found in cache")
+			return
+		}
+
+		tiles = downloader.Placeholders(nil, missingErr.Tiles)
+		w.Header().Set("X-Cache-Missing", "1")
+		err = nil
+	}
+
+	if err != nil || len(tiles) == 0 {
+		a.Logger.Error("error occurred when downloading tile", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+		writeWMTSException(w, http.StatusInternalServerError, "NoApplicableCode", "",
+			fmt.Sprintf("error occurred when downloading tile: %v", err))
+		return
+	}
+
+	img, contentType := tiles[0].Image, http.DetectContentType(tiles[0].Image)
+	if format != "" && format != contentType {
+		if img, err = convertTile(img, format); err != nil {
+			a.Logger.Error("error occurred when converting tile", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+			writeWMTSException(w, http.StatusInternalServerError, "NoApplicableCode", "",
+				fmt.Sprintf("error occurred when converting tile: %v", err))
+			return
+		}
+		contentType = format
+	}
+
+	if tiles[0].Stale {
+		w.Header().Set("X-Cache-Stale", "true")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(img)
+}
+
+// convertTile encode tile image as image/png or image/jpeg, transparent areas of JPEG are white
+func convertTile(data []byte, format string) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if format == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Rect, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Rect, img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tile: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// wmtsCapabilities write capabilities document of all providers
+func (a *API) wmtsCapabilities(w http.ResponseWriter, req *http.Request) {
+	sets := map[string]*wmtsMatrixSet{
+		matrixSetSpherical: {ID: matrixSetSpherical, CRS: "urn:ogc:def:crs:EPSG::3857", MaxZoom: -1},
+		matrixSetWGS84:     {ID: matrixSetWGS84, CRS: "urn:ogc:def:crs:EPSG::3395", MaxZoom: -1},
+	}
+
+	var layers []wmtsLayer
+	for _, id := range a.Providers.GetAllID() {
+		vendor, err := a.Providers.Get(id)
+		if err != nil {
+			continue
+		}
+
+		set := sets[wmtsMatrixSetOf(vendor)]
+		set.MaxZoom = max(set.MaxZoom, vendor.MaxZoom())
+		layers = append(layers, wmtsLayer{ID: vendor.ID(), Name: vendor.Name(), MatrixSet: set,
+			MinZoom: vendor.MinZoom(), MaxZoom: vendor.MaxZoom(), Bounds: vendor.Bounds()})
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].ID < layers[j].ID })
+
+	var usedSets []*wmtsMatrixSet
+	for _, id := range []string{matrixSetSpherical, matrixSetWGS84} {
+		if sets[id].MaxZoom >= 0 {
+			usedSets = append(usedSets, sets[id])
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	err := capabilitiesTemplate.Execute(w, struct {
+		URL    string
+		Layers []wmtsLayer
+		Sets   []*wmtsMatrixSet
+	}{URL: baseURL(req) + "/wmts", Layers: layers, Sets: usedSets})
+	if err != nil {
+		a.Logger.Error("error occurred when writing capabilities", zap.Error(err), zap.String("req_id", req.Header.Get("X-Request-ID")))
+	}
+}
+
+// wmtsMatrixSetOf return tile matrix set of provider tiles grid
+func wmtsMatrixSetOf(vendor provider.Provider) string {
+	if vendorProjection(vendor).Eccentricity != 0 {
+		return matrixSetWGS84
+	}
+	return matrixSetSpherical
+}
+
+// baseURL return scheme and host of service as seen by client
+func baseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + req.Host
+}
+
+// upperQuery return query parameters with upper case names, KVP parameter names are case insensitive
+func upperQuery(req *http.Request) map[string]string {
+	query := make(map[string]string)
+	for key, values := range req.URL.Query() {
+		query[strings.ToUpper(key)] = values[0]
+	}
+	return query
+}
+
+// writeWMTSException write OGC exception report
+func writeWMTSException(w http.ResponseWriter, status int, code, locator, text string) {
+	report := wmtsException{Version: "1.1.0"}
+	report.Exception.Code, report.Exception.Locator, report.Exception.Text = code, locator, text
+
+	data, _ := xml.Marshal(report)
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+// capabilitiesFuncs contains functions of WMTS and WMS capabilities templates
+var capabilitiesFuncs = template.FuncMap{
+	"xml": func(s string) string {
+		var b strings.Builder
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	},
+	"zooms": func(from, to int) []int {
+		zooms := make([]int, 0, to-from+1)
+		for z := from; z <= to; z++ {
+			zooms = append(zooms, z)
+		}
+		return zooms
+	},
+	"num": func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) },
+	"scale": func(z int) string {
+		return strconv.FormatFloat(scaleDenominator0/math.Pow(2, float64(z)), 'f', -1, 64)
+	},
+	"matrixSize": func(z int) int { return 1 << z },
+	"lastTile":   func(z int) int { return 1<<z - 1 },
+}
+
+// capabilitiesTemplate is a WMTS capabilities document
+var capabilitiesTemplate = template.Must(template.New("capabilities").Funcs(capabilitiesFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1"
+    xmlns:xlink="http://www.w3.org/1999/xlink" version="1.0.0">
+  <ows:ServiceIdentification>
+    <ows:Title>Map tiles</ows:Title>
+    <ows:ServiceType>OGC WMTS</ows:ServiceType>
+    <ows:ServiceTypeVersion>1.0.0</ows:ServiceTypeVersion>
+  </ows:ServiceIdentification>
+  <ows:OperationsMetadata>
+    <ows:Operation name="GetCapabilities">
+      <ows:DCP><ows:HTTP><ows:Get xlink:href="{{xml .URL}}?">
+        <ows:Constraint name="GetEncoding"><ows:AllowedValues><ows:Value>KVP</ows:Value></ows:AllowedValues></ows:Constraint>
+      </ows:Get></ows:HTTP></ows:DCP>
+    </ows:Operation>
+    <ows:Operation name="GetTile">
+      <ows:DCP><ows:HTTP><ows:Get xlink:href="{{xml .URL}}?">
+        <ows:Constraint name="GetEncoding"><ows:AllowedValues><ows:Value>KVP</ows:Value></ows:AllowedValues></ows:Constraint>
+      </ows:Get></ows:HTTP></ows:DCP>
+    </ows:Operation>
+  </ows:OperationsMetadata>
+  <Contents>
+{{- range .Layers}}
+    <Layer>
+      <ows:Title>{{xml .Name}}</ows:Title>
+      <ows:WGS84BoundingBox>
+        <ows:LowerCorner>{{num (index .Bounds 0)}} {{num (index .Bounds 1)}}</ows:LowerCorner>
+        <ows:UpperCorner>{{num (index .Bounds 2)}} {{num (index .Bounds 3)}}</ows:UpperCorner>
+      </ows:WGS84BoundingBox>
+      <ows:Identifier>{{xml .ID}}</ows:Identifier>
+      <Style isDefault="true"><ows:Identifier>default</ows:Identifier></Style>
+      <Format>image/png</Format>
+      <Format>image/jpeg</Format>
+      <TileMatrixSetLink>
+        <TileMatrixSet>{{.MatrixSet.ID}}</TileMatrixSet>
+        <TileMatrixSetLimits>
+{{- range zooms .MinZoom .MaxZoom}}
+          <TileMatrixLimits><TileMatrix>{{.}}</TileMatrix>
+            <MinTileRow>0</MinTileRow><MaxTileRow>{{lastTile .}}</MaxTileRow>
+            <MinTileCol>0</MinTileCol><MaxTileCol>{{lastTile .}}</MaxTileCol>
+          </TileMatrixLimits>
+{{- end}}
+        </TileMatrixSetLimits>
+      </TileMatrixSetLink>
+      <ResourceURL format="image/png" resourceType="tile"
+        template="{{xml $.URL}}/{{xml .ID}}/{Style}/{TileMatrixSet}/{TileMatrix}/{TileRow}/{TileCol}.png"/>
+      <ResourceURL format="image/jpeg" resourceType="tile"
+        template="{{xml $.URL}}/{{xml .ID}}/{Style}/{TileMatrixSet}/{TileMatrix}/{TileRow}/{TileCol}.jpg"/>
+    </Layer>
+{{- end}}
+{{- range .Sets}}
+    <TileMatrixSet>
+      <ows:Identifier>{{.ID}}</ows:Identifier>
+      <ows:SupportedCRS>{{.CRS}}</ows:SupportedCRS>
+{{- if eq .ID "GoogleMapsCompatible"}}
+      <WellKnownScaleSet>urn:ogc:def:wkss:OGC:1.0:GoogleMapsCompatible</WellKnownScaleSet>
+{{- end}}
+{{- range zooms 0 .MaxZoom}}
+      <TileMatrix>
+        <ows:Identifier>{{.}}</ows:Identifier>
+        <ScaleDenominator>{{scale .}}</ScaleDenominator>
+        <TopLeftCorner>-20037508.3427892 20037508.3427892</TopLeftCorner>
+        <TileWidth>256</TileWidth>
+        <TileHeight>256</TileHeight>
+        <MatrixWidth>{{matrixSize .}}</MatrixWidth>
+        <MatrixHeight>{{matrixSize .}}</MatrixHeight>
+      </TileMatrix>
+{{- end}}
+    </TileMatrixSet>
+{{- end}}
+  </Contents>
+  <ServiceMetadataURL xlink:href="{{xml .URL}}/1.0.0/WMTSCapabilities.xml"/>
+</Capabilities>
+`))
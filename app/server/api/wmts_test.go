@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/superboomer/maptile/app/cache"
+	"github.com/superboomer/maptile/app/downloader"
+	"github.com/superboomer/maptile/app/provider"
+	"github.com/superboomer/maptile/app/tile"
+)
+
+var pngData = []byte("\x89PNG\r\n\x1a\n0000")
+
+func wmtsAPI(offline bool) (*API, *[]tile.Tile) {
+	return ogcAPI(offline, func(tile.Tile) []byte { return pngData }, ogcVendor("yandex", "yandex & co", &tile.ElipsWGS84, 1, 1),
+		ogcVendor("osm", "osm & co", &tile.ElipsSpherical, 0, 2))
+}
+
+func TestWMTS_Capabilities(t *testing.T) {
+	a, _ := wmtsAPI(false)
+
+	for _, target := range []string{"/wmts?service=wmts&request=GetCapabilities", "/wmts/1.0.0/WMTSCapabilities.xml"} {
+		req := httptest.NewRequest(http.MethodGet, "http://maps.example.com"+target, http.NoBody)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rr := httptest.NewRecorder()
+
+		a.WMTS(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/xml; charset=utf-8", rr.Header().Get("Content-Type"))
+
+		var doc struct {
+			Layers []struct {
+				Title      string `xml:"Title"`
+				Identifier string `xml:"Identifier"`
+				MatrixSet  string `xml:"TileMatrixSetLink>TileMatrixSet"`
+				Limits     []struct {
+					TileMatrix int `xml:"TileMatrix"`
+					MaxTileRow int `xml:"MaxTileRow"`
+				} `xml:"TileMatrixSetLink>TileMatrixSetLimits>TileMatrixLimits"`
+				LowerCorner string `xml:"WGS84BoundingBox>LowerCorner"`
+				ResourceURL []struct {
+					Template string `xml:"template,attr"`
+				} `xml:"ResourceURL"`
+			} `xml:"Contents>Layer"`
+			Sets []struct {
+				Identifier string `xml:"Identifier"`
+				Matrices   []struct {
+					Scale float64 `xml:"ScaleDenominator"`
+					Width int     `xml:"MatrixWidth"`
+				} `xml:"TileMatrix"`
+			} `xml:"Contents>TileMatrixSet"`
+		}
+		assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &doc))
+
+		if assert.Len(t, doc.Layers, 2) {
+			assert.Equal(t, "osm", doc.Layers[0].Identifier)
+			assert.Equal(t, "osm & co", doc.Layers[0].Title)
+			assert.Equal(t, "GoogleMapsCompatible", doc.Layers[0].MatrixSet)
+			assert.Len(t, doc.Layers[0].Limits, 3)
+			assert.Equal(t, 3, doc.Layers[0].Limits[2].MaxTileRow)
+			assert.Equal(t, "-180 -85.5", doc.Layers[0].LowerCorner)
+			assert.Equal(t, "https://maps.example.com/wmts/osm/{Style}/{TileMatrixSet}/{TileMatrix}/{TileRow}/{TileCol}.png",
+				doc.Layers[0].ResourceURL[0].Template)
+
+			assert.Equal(t, "yandex", doc.Layers[1].Identifier)
+			assert.Equal(t, "WorldMercatorWGS84Quad", doc.Layers[1].MatrixSet)
+			if assert.Len(t, doc.Layers[1].Limits, 1) {
+				assert.Equal(t, 1, doc.Layers[1].Limits[0].TileMatrix)
+			}
+		}
+
+		if assert.Len(t, doc.Sets, 2) {
+			assert.Equal(t, "GoogleMapsCompatible", doc.Sets[0].Identifier)
+			assert.Len(t, doc.Sets[0].Matrices, 3)
+			assert.InDelta(t, 559082264.0287178, doc.Sets[0].Matrices[0].Scale, 1e-6)
+			assert.Equal(t, 4, doc.Sets[0].Matrices[2].Width)
+			assert.Len(t, doc.Sets[1].Matrices, 2)
+		}
+	}
+}
+
+func TestWMTS_GetTile(t *testing.T) {
+	a, requested := wmtsAPI(false)
+
+	for _, target := range []string{
+		"/wmts?SERVICE=WMTS&REQUEST=GetTile&LAYER=osm&STYLE=default&TILEMATRIXSET=GoogleMapsCompatible" +
+			"&TILEMATRIX=2&TILEROW=3&TILECOL=2&FORMAT=image/png",
+		"/wmts/osm/default/GoogleMapsCompatible/2/3/2.png",
+	} {
+		rr := httptest.NewRecorder()
+		a.WMTS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+		assert.Equal(t, pngData, rr.Body.Bytes())
+		assert.Empty(t, rr.Header().Get("X-Cache-Stale"))
+	}
+
+	assert.Equal(t, []tile.Tile{{X: 2, Y: 3, Z: 2}, {X: 2, Y: 3, Z: 2}}, *requested)
+
+	rr := httptest.NewRecorder()
+	a.WMTS(rr, httptest.NewRequest(http.MethodGet, "/wmts/osm/default/GoogleMapsCompatible/2/1/2.png", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "true", rr.Header().Get("X-Cache-Stale"))
+}
+
+func TestWMTS_GetTileFormat(t *testing.T) {
+	a, _ := wmtsAPI(false)
+
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 256, 256))))
+	a.Downloader = &downloader.DownloaderMock{DownloadFunc: func(c cache.Cache, l provider.Provider, tiles ...tile.Tile) ([]tile.Tile, error) {
+		return []tile.Tile{{X: tiles[0].X, Y: tiles[0].Y, Z: tiles[0].Z, Image: buf.Bytes()}}, nil
+	}}
+
+	for _, target := range []string{
+		"/wmts?SERVICE=WMTS&REQUEST=GetTile&LAYER=osm&TILEMATRIXSET=GoogleMapsCompatible&TILEMATRIX=2&TILEROW=3&TILECOL=2&FORMAT=image/jpeg",
+		"/wmts/osm/default/GoogleMapsCompatible/2/3/2.jpg",
+	} {
+		rr := httptest.NewRecorder()
+		a.WMTS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+
+		// transparent tile is white in JPEG
+		img, err := jpeg.Decode(rr.Body)
+		assert.NoError(t, err)
+		r, g, b, _ := img.At(128, 128).RGBA()
+		assert.Greater(t, min(r, g, b), uint32(0xf000))
+	}
+
+	// tile of native format is served as is
+	rr := httptest.NewRecorder()
+	a.WMTS(rr, httptest.NewRequest(http.MethodGet, "/wmts/osm/default/GoogleMapsCompatible/2/3/2.png", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, buf.Bytes(), rr.Body.Bytes())
+}
+
+func TestWMTS_GetTileMissing(t *testing.T) {
+	a, _ := wmtsAPI(true)
+
+	rr := httptest.NewRecorder()
+	a.WMTS(rr, httptest.NewRequest(http.MethodGet, "/wmts/osm/default/GoogleMapsCompatible/2/0/1.png", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), `exceptionCode="TileOutOfRange"`)
+
+	a.Placeholder = true
+
+	rr = httptest.NewRecorder()
+	a.WMTS(rr, httptest.NewRequest(http.MethodGet, "/wmts/osm/default/GoogleMapsCompatible/2/0/1.png", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "1", rr.Header().Get("X-Cache-Missing"))
+}
+
+func TestWMTS_GetTileCacheOnly(t *testing.T) {
+	a, _ := wmtsAPI(false)
+	mock := a.Downloader.(*downloader.DownloaderMock)
+
+	for _, target := range []string{
+		"/wmts?SERVICE=WMTS&REQUEST=GetTile&LAYER=osm&TILEMATRIXSET=GoogleMapsCompatible&TILEMATRIX=2&TILEROW=3&TILECOL=2&CACHE_ONLY=true",
+		"/wmts/osm/default/GoogleMapsCompatible/2/3/2.png?cache_only=1",
+	} {
+		rr := httptest.NewRecorder()
+		a.WMTS(rr, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	assert.Len(t, mock.DownloadCachedCalls(), 2)
+	assert.Empty(t, mock.DownloadCalls())
+}
+
+func TestWMTS_Errors(t *testing.T) {
+	a, requested := wmtsAPI(false)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"no service", "/wmts?REQUEST=GetCapabilities", http.StatusBadRequest, "InvalidParameterValue"},
+		{"no request", "/wmts?SERVICE=WMTS", http.StatusBadRequest, "MissingParameterValue"},
+		{"unknown request", "/wmts?SERVICE=WMTS&REQUEST=GetFeatureInfo", http.StatusBadRequest, "OperationNotSupported"},
+		{"unknown layer", "/wmts/bing/default/GoogleMapsCompatible/1/0/0.png", http.StatusBadRequest, "InvalidParameterValue"},
+		{"wrong matrix set", "/wmts/yandex/default/GoogleMapsCompatible/1/0/0.png", http.StatusBadRequest, "InvalidParameterValue"},
+		{"zoom above max", "/wmts/osm/default/GoogleMapsCompatible/3/0/0.png", http.StatusBadRequest, "InvalidParameterValue"},
+		{"zoom below min", "/wmts/yandex/default/WorldMercatorWGS84Quad/0/0/0.png", http.StatusBadRequest, "InvalidParameterValue"},
+		{"tile out of matrix", "/wmts/osm/default/GoogleMapsCompatible/1/2/0.png", http.StatusBadRequest, "TileOutOfRange"},
+		{"no tile row", "/wmts?SERVICE=WMTS&REQUEST=GetTile&LAYER=osm&TILEMATRIXSET=GoogleMapsCompatible&TILEMATRIX=1&TILECOL=0",
+			http.StatusBadRequest, "MissingParameterValue"},
+		{"invalid tile col", "/wmts/osm/default/GoogleMapsCompatible/1/0/a.png", http.StatusBadRequest, "InvalidParameterValue"},
+		{"unknown resource", "/wmts/osm/1/0/0.png", http.StatusNotFound, "InvalidParameterValue"},
+		{"unknown extension", "/wmts/osm/default/GoogleMapsCompatible/1/0/0.gif", http.StatusNotFound, "InvalidParameterValue"},
+		{"no extension", "/wmts/osm/default/GoogleMapsCompatible/1/0/0", http.StatusNotFound, "InvalidParameterValue"},
+		{"invalid cache_only", "/wmts/osm/default/GoogleMapsCompatible/1/0/0.png?cache_only=yes", http.StatusBadRequest, "InvalidParameterValue"},
+		{"unknown format", "/wmts?SERVICE=WMTS&REQUEST=GetTile&LAYER=osm&TILEMATRIXSET=GoogleMapsCompatible" +
+			"&TILEMATRIX=1&TILEROW=0&TILECOL=0&FORMAT=image/gif",
+			http.StatusBadRequest, "InvalidParameterValue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			a.WMTS(rr, httptest.NewRequest(http.MethodGet, tt.target, http.NoBody))
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), `exceptionCode="`+tt.code+`"`)
+		})
+	}
+
+	assert.Empty(t, *requested)
+}
@@ -94,3 +94,25 @@ func TestMiddleware_RequestID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.NotEmpty(t, req.Header.Get("X-Request-ID"))
 }
+
+func TestMiddleware_Auth(t *testing.T) {
+	md := &MD{
+		Logger: zap.NewNop(),
+	}
+
+	handler := md.Auth("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("authorized"))
+	}))
+
+	codes := map[string]int{"": http.StatusUnauthorized, "Bearer wrong": http.StatusUnauthorized, "secret": http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK}
+	for header, code := range codes {
+		req, _ := http.NewRequest("GET", "/", http.NoBody)
+		req.Header.Set("Authorization", header)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, code, rr.Code, header)
+	}
+}
@@ -16,6 +16,25 @@ func (s *Server) SetRoutes(a *api.API, md *middleware.MD) {
 	h.HandleFunc("/map", a.Map)
 	h.HandleFunc("/healthcheck", a.HealthCheck)
 	h.HandleFunc("/provider", a.Provider)
+	h.HandleFunc("/provider/{id}/tilejson.json", a.ProviderTileJSON)
+	h.HandleFunc("/provider/{id}/style.json", a.ProviderStyle)
+	h.HandleFunc("/export", a.Export)
+	h.HandleFunc("/export/{id}", a.ExportStatus)
+	h.HandleFunc("/export/{id}/pdf", a.ExportResult)
+	h.HandleFunc("/wms", a.WMS)
+	h.HandleFunc("/wmts", a.WMTS)
+	h.HandleFunc("/wmts/", a.WMTS)
+
+	if a.CacheAdmin != nil && s.options.AdminToken != "" {
+		s.logger.Info("cache admin endpoints enabled")
+		h.Handle("/cache/stats", md.Auth(s.options.AdminToken, http.HandlerFunc(a.CacheStats)))
+		h.Handle("/cache/purge", md.Auth(s.options.AdminToken, http.HandlerFunc(a.CachePurge)))
+		h.Handle("/cache/tile", md.Auth(s.options.AdminToken, http.HandlerFunc(a.CacheTile)))
+	}
+
+	if a.Seeds != nil && s.options.AdminToken != "" {
+		h.Handle("/cache/seed", md.Auth(s.options.AdminToken, http.HandlerFunc(a.CacheSeed)))
+	}
 
 	if s.options.Swagger {
 		s.logger.Info("http swagger enabled")
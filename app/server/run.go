@@ -9,6 +9,7 @@ import (
 	"github.com/superboomer/maptile/app/options"
 	"github.com/superboomer/maptile/app/server/api"
 	"github.com/superboomer/maptile/app/server/middleware"
+	"github.com/superboomer/maptile/app/watermark"
 	"go.uber.org/zap"
 )
 
@@ -36,11 +37,33 @@ func NewServer(opts *options.Opts, logger *zap.Logger) *Server {
 // Run start program with specified parameters
 func Run(ctx context.Context, logger *zap.Logger, opts *options.Opts) error {
 
-	apiService, err := api.CreateAPI(logger, &opts.Cache, opts.Schema, opts.MaxSide)
+	cacheOpts := opts.Cache
+	if opts.Offline {
+		if !cacheOpts.Enable {
+			return fmt.Errorf("offline mode requires enabled cache")
+		}
+
+		// expired tiles are still served offline, so they must not be swept
+		cacheOpts.SweepInterval = 0
+		logger.Info("offline mode enabled", zap.String("miss", opts.OfflineMiss))
+	}
+
+	apiService, err := api.CreateAPI(logger, &cacheOpts, opts.Schema, opts.MaxSide)
 	if err != nil {
 		return err
 	}
 
+	apiService.Offline = opts.Offline
+	apiService.Placeholder = opts.OfflineMiss == "placeholder"
+
+	if opts.Watermark.Enable {
+		apiService.Watermark, err = watermarkOptions(&opts.Watermark)
+		if err != nil {
+			return err
+		}
+		logger.Info("attribution watermark enabled", zap.String("position", opts.Watermark.Position))
+	}
+
 	var s = NewServer(opts, logger)
 	var md = &middleware.MD{Logger: logger}
 
@@ -75,3 +98,18 @@ func (s *Server) RunHTTP(ctx context.Context) error {
 
 	return err
 }
+
+// watermarkOptions parse colors of watermark options
+func watermarkOptions(opts *options.Watermark) (*watermark.Options, error) {
+	textColor, err := watermark.ParseColor(opts.Color)
+	if err != nil {
+		return nil, fmt.Errorf("watermark color option error: %w", err)
+	}
+
+	background, err := watermark.ParseColor(opts.Background)
+	if err != nil {
+		return nil, fmt.Errorf("watermark background option error: %w", err)
+	}
+
+	return &watermark.Options{Position: opts.Position, FontSize: opts.FontSize, Color: textColor, Background: background}, nil
+}
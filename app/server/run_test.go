@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -60,3 +61,22 @@ func TestRun_Failed(t *testing.T) {
 		t.Fatalf("expected error, got %v", err)
 	}
 }
+
+func TestRun_OfflineWithoutCache(t *testing.T) {
+	opts := &options.Opts{APIPort: "8080", Schema: "./../../example/providers.json", Offline: true}
+
+	err := Run(context.Background(), zap.NewNop(), opts)
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestRun_InvalidWatermarkColor(t *testing.T) {
+	opts := &options.Opts{APIPort: "8080", Schema: "./../../example/providers.json",
+		Watermark: options.Watermark{Enable: true, Color: "#000", Background: "white"}}
+
+	err := Run(context.Background(), zap.NewNop(), opts)
+	if err == nil || !strings.Contains(err.Error(), "watermark background option error") {
+		t.Fatalf("expected watermark error, got %v", err)
+	}
+}
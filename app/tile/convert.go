@@ -18,6 +18,17 @@ var (
 
 // ConvertToTile convert latitude and longtitude to XYZ tile for specified mercator projection
 func ConvertToTile(lat, long, zoom float64, proj *Elips) (x, y int) {
+	xP, yP := ConvertToPosition(lat, long, zoom, proj)
+
+	x = int(math.Floor(xP))
+	y = int(math.Floor(yP))
+
+	return
+}
+
+// ConvertToPosition convert latitude and longtitude to position in tiles (fractional part is position inside tile)
+// for specified mercator projection
+func ConvertToPosition(lat, long, zoom float64, proj *Elips) (x, y float64) {
 	rho := math.Pow(2, zoom+8) / 2
 	beta := lat * math.Pi / 180
 
@@ -27,8 +38,48 @@ func ConvertToTile(lat, long, zoom float64, proj *Elips) (x, y int) {
 	xP := rho * (1 + long/180)
 	yP := rho * (1 - math.Log(theta)/math.Pi)
 
-	x = int(math.Floor(xP / 256))
-	y = int(math.Floor(yP / 256))
+	return xP / 256, yP / 256
+}
 
-	return
+// EarthRadius is a semi-major axis of WGS84 ellipsoid in meters, it's the radius of spherical mercator too
+const EarthRadius = 6378137.0
+
+// ConvertFromTile convert position in tiles (fractional part is position inside tile) to latitude and longitude
+// for specified mercator projection, it's inverse of ConvertToTile
+func ConvertFromTile(x, y, zoom float64, proj *Elips) (lat, long float64) {
+	n := math.Pow(2, zoom)
+
+	long = x/n*360 - 180
+
+	return latitude(math.Pi*(1-2*y/n), proj), long
+}
+
+// ConvertFromMercator convert mercator meters to latitude and longitude for specified projection,
+// it's inverse of ConvertToMercator
+func ConvertFromMercator(x, y float64, proj *Elips) (lat, long float64) {
+	return latitude(y/EarthRadius, proj), x / EarthRadius * 180 / math.Pi
+}
+
+// latitude return latitude of mercator y in radians of earth radius
+func latitude(psi float64, proj *Elips) float64 {
+	// latitude of ellipsoidal mercator has no closed form, so it's refined starting from spherical one
+	beta := 2*math.Atan(math.Exp(psi)) - math.Pi/2
+	for i := 0; i < 10 && proj.Eccentricity != 0; i++ {
+		es := proj.Eccentricity * math.Sin(beta)
+		beta = 2*math.Atan(math.Exp(psi)*math.Pow((1+es)/(1-es), proj.Eccentricity/2)) - math.Pi/2
+	}
+
+	return beta * 180 / math.Pi
+}
+
+// ConvertToMercator convert latitude and longitude to mercator meters for specified projection,
+// spherical is EPSG:3857 and WGS84 is EPSG:3395
+func ConvertToMercator(lat, long float64, proj *Elips) (x, y float64) {
+	beta := lat * math.Pi / 180
+	es := proj.Eccentricity * math.Sin(beta)
+
+	x = EarthRadius * long * math.Pi / 180
+	y = EarthRadius * math.Log(math.Tan(math.Pi/4+beta/2)*math.Pow((1-es)/(1+es), proj.Eccentricity/2))
+
+	return x, y
 }
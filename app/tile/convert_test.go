@@ -1,6 +1,7 @@
 package tile
 
 import (
+	"math"
 	"testing"
 )
 
@@ -57,3 +58,79 @@ func TestConvertToTile(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertFromTile(t *testing.T) {
+	for _, proj := range []*Elips{&ElipsWGS84, &ElipsSpherical} {
+		for _, c := range [][2]float64{{0, 0}, {55.75, 37.61}, {-33.86, 151.2}, {84, -179}} {
+			// position 1/256 of tile deeper, so ConvertToTile result is not rounded
+			x, y := ConvertToTile(c[0], c[1], 20, proj)
+			lat, long := ConvertFromTile(float64(x)+0.5, float64(y)+0.5, 20, proj)
+			if math.Abs(lat-c[0]) > 1e-3 || math.Abs(long-c[1]) > 1e-3 {
+				t.Errorf("ConvertFromTile(ConvertToTile(%v, %v)) = (%v, %v)", c[0], c[1], lat, long)
+			}
+		}
+	}
+
+	lat, long := ConvertFromTile(0, 0, 0, &ElipsSpherical)
+	if math.Abs(lat-85.0511287798) > 1e-9 || long != -180 {
+		t.Errorf("ConvertFromTile(0, 0, 0) = (%v, %v); expected (85.0511287798, -180)", lat, long)
+	}
+}
+
+func TestConvertToMercator(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		proj      *Elips
+		x, y      float64
+	}{
+		{lat: 0, long: 0, proj: &ElipsSpherical, x: 0, y: 0},
+		{lat: 85.0511287798, long: 180, proj: &ElipsSpherical, x: 20037508.342789, y: 20037508.342789},
+		{lat: 55.75, long: 37.61, proj: &ElipsSpherical, x: 4186726.05, y: 7508807.85},
+	}
+
+	for _, test := range tests {
+		x, y := ConvertToMercator(test.lat, test.long, test.proj)
+		if math.Abs(x-test.x) > 0.01 || math.Abs(y-test.y) > 0.01 {
+			t.Errorf("ConvertToMercator(%v, %v, %v) = (%v, %v); expected (%v, %v)",
+				test.lat, test.long, test.proj, x, y, test.x, test.y)
+		}
+	}
+
+	// mercator meters are linear in tiles of the same projection
+	for _, proj := range []*Elips{&ElipsWGS84, &ElipsSpherical} {
+		tx, ty := ConvertToTile(55.75, 37.61, 24, proj)
+		x, y := ConvertToMercator(55.75, 37.61, proj)
+
+		size := 2 * math.Pi * 6378137 / math.Pow(2, 24)
+		if math.Abs(x-(float64(tx)*size-math.Pi*6378137)) > size || math.Abs(y-(math.Pi*6378137-float64(ty)*size)) > size {
+			t.Errorf("ConvertToMercator(55.75, 37.61, %v) = (%v, %v) doesn't match tile (%v, %v)", proj, x, y, tx, ty)
+		}
+	}
+}
+
+func TestConvertToPosition(t *testing.T) {
+	for _, proj := range []*Elips{&ElipsWGS84, &ElipsSpherical} {
+		x, y := ConvertToPosition(55.75, 37.61, 15, proj)
+		tx, ty := ConvertToTile(55.75, 37.61, 15, proj)
+		if math.Floor(x) != float64(tx) || math.Floor(y) != float64(ty) {
+			t.Errorf("ConvertToPosition(55.75, 37.61, 15, %v) = (%v, %v) doesn't match tile (%v, %v)", proj, x, y, tx, ty)
+		}
+
+		lat, long := ConvertFromTile(x, y, 15, proj)
+		if math.Abs(lat-55.75) > 1e-9 || math.Abs(long-37.61) > 1e-9 {
+			t.Errorf("ConvertFromTile(ConvertToPosition(55.75, 37.61)) = (%v, %v)", lat, long)
+		}
+	}
+}
+
+func TestConvertFromMercator(t *testing.T) {
+	for _, proj := range []*Elips{&ElipsWGS84, &ElipsSpherical} {
+		for _, c := range [][2]float64{{0, 0}, {55.75, 37.61}, {-33.86, 151.2}, {84, -179}} {
+			x, y := ConvertToMercator(c[0], c[1], proj)
+			lat, long := ConvertFromMercator(x, y, proj)
+			if math.Abs(lat-c[0]) > 1e-9 || math.Abs(long-c[1]) > 1e-9 {
+				t.Errorf("ConvertFromMercator(ConvertToMercator(%v, %v)) = (%v, %v)", c[0], c[1], lat, long)
+			}
+		}
+	}
+}
@@ -1,11 +1,40 @@
 package tile
 
+import "time"
+
+// Size is a side of tile in pixels, positions in tiles are converted to pixels by it
+const Size = 256
+
 // Tile contains coords and image []byte
 type Tile struct {
 	X     int
 	Y     int
 	Z     int
 	Image []byte
+	Meta  Meta
+	Stale bool // image is served from cache after expiration
+	Blank bool // image is a placeholder of upstream without data, it's not drawn on maps
+}
+
+// Meta contains upstream cache validators and stored format of tile
+type Meta struct {
+	ETag         string        `json:"etag,omitempty"`
+	LastModified string        `json:"last_modified,omitempty"`
+	CacheControl string        `json:"cache_control,omitempty"`
+	MaxAge       time.Duration `json:"max_age,omitempty"` // overrides cache alive if not zero
+	Expires      time.Time     `json:"-"`                 // filled by cache on load
+
+	ContentType string `json:"content_type,omitempty"` // sniffed image type, e.g. image/png
+	Hash        string `json:"hash,omitempty"`         // sha256 of image, filled by cache on save
+	Source      string `json:"source,omitempty"`       // upstream URL of image
+}
+
+// Revalidate copy cache validators from other meta, stored format of image is kept
+func (m *Meta) Revalidate(other *Meta) {
+	m.ETag = other.ETag
+	m.LastModified = other.LastModified
+	m.CacheControl = other.CacheControl
+	m.MaxAge = other.MaxAge
 }
 
 // GetNearby return all nearby tiles for specified square side
@@ -29,3 +58,10 @@ func (t Tile) GetNearby(side int) []Tile {
 
 	return tiles
 }
+
+// Bounds return tile bounds in degrees for specified mercator projection
+func (t Tile) Bounds(proj *Elips) (minLong, minLat, maxLong, maxLat float64) {
+	maxLat, minLong = ConvertFromTile(float64(t.X), float64(t.Y), float64(t.Z), proj)
+	minLat, maxLong = ConvertFromTile(float64(t.X+1), float64(t.Y+1), float64(t.Z), proj)
+	return minLong, minLat, maxLong, maxLat
+}
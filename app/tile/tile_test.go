@@ -2,6 +2,8 @@ package tile
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetNearby(t *testing.T) {
@@ -57,3 +59,23 @@ func TestGetNearby(t *testing.T) {
 func tilesEqual(a, b Tile) bool {
 	return a.X == b.X && a.Y == b.Y && a.Z == b.Z
 }
+
+func TestTile_Bounds(t *testing.T) {
+	minLong, minLat, maxLong, maxLat := Tile{X: 0, Y: 0, Z: 0}.Bounds(&ElipsSpherical)
+	assert.InDelta(t, -180, minLong, 1e-9)
+	assert.InDelta(t, -85.0511287798, minLat, 1e-9)
+	assert.InDelta(t, 180, maxLong, 1e-9)
+	assert.InDelta(t, 85.0511287798, maxLat, 1e-9)
+
+	minLong, minLat, maxLong, maxLat = Tile{X: 1, Y: 0, Z: 1}.Bounds(&ElipsWGS84)
+	assert.InDelta(t, 0, minLong, 1e-9)
+	assert.InDelta(t, 0, minLat, 1e-9)
+	assert.InDelta(t, 180, maxLong, 1e-9)
+	assert.InDelta(t, 85.0840590501, maxLat, 1e-9)
+
+	// tile contains point it was calculated from
+	tl := Tile{Z: 15}
+	tl.X, tl.Y = ConvertToTile(55.75, 37.61, 15, &ElipsWGS84)
+	minLong, minLat, maxLong, maxLat = tl.Bounds(&ElipsWGS84)
+	assert.True(t, minLong <= 37.61 && 37.61 < maxLong && minLat < 55.75 && 55.75 <= maxLat)
+}
@@ -0,0 +1,129 @@
+// Package watermark stamps attribution text onto map images
+package watermark
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// corners of image where text is placed
+const (
+	TopLeft     = "top-left"
+	TopRight    = "top-right"
+	BottomLeft  = "bottom-left"
+	BottomRight = "bottom-right"
+)
+
+// padding is a space around text inside background box in font pixels
+const padding = 3
+
+// face is a bitmap font of text, it has ASCII glyphs only
+var face = basicfont.Face7x13
+
+// Options contains look of watermark
+type Options struct {
+	Position   string // one of corners
+	FontSize   int    // height of text line in pixels, glyphs of 13px font are scaled by integer factor
+	Color      color.Color
+	Background color.Color
+}
+
+// Draw draw text on background box in corner of image. Text is scaled down if it doesn't fit
+// into image width and is clipped if it doesn't fit even unscaled.
+func Draw(dst draw.Image, text string, opts *Options) {
+	text = Transliterate(text)
+	if text == "" {
+		return
+	}
+
+	// text is drawn in font pixels and scaled afterwards
+	box := image.NewRGBA(image.Rect(0, 0, face.Advance*len(text)+2*padding, face.Height+2*padding))
+	draw.Draw(box, box.Bounds(), &image.Uniform{C: opts.Background}, image.Point{}, draw.Src)
+
+	d := font.Drawer{Dst: box, Src: &image.Uniform{C: opts.Color}, Face: face, Dot: fixed.P(padding, padding+face.Ascent)}
+	d.DrawString(text)
+
+	bounds := dst.Bounds()
+	scale := max(1, (opts.FontSize+face.Height/2)/face.Height)
+	for scale > 1 && box.Rect.Dx()*scale > bounds.Dx() {
+		scale--
+	}
+
+	width, height := box.Rect.Dx()*scale, box.Rect.Dy()*scale
+
+	at := image.Point{X: bounds.Min.X, Y: bounds.Min.Y}
+	if opts.Position == TopRight || opts.Position == BottomRight {
+		at.X = max(bounds.Min.X, bounds.Max.X-width)
+	}
+	if opts.Position == BottomLeft || opts.Position == BottomRight {
+		at.Y = max(bounds.Min.Y, bounds.Max.Y-height)
+	}
+
+	scaled := image.NewRGBA(image.Rect(at.X, at.Y, at.X+width, at.Y+height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src := box.PixOffset(x/scale, y/scale)
+			copy(scaled.Pix[scaled.PixOffset(at.X+x, at.Y+y):], box.Pix[src:src+4])
+		}
+	}
+
+	draw.Draw(dst, scaled.Rect.Intersect(bounds), scaled, scaled.Rect.Intersect(bounds).Min, draw.Over)
+}
+
+// tagRegexp matches HTML tags of attribution
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// PlainText return text of attribution HTML without tags and entities
+func PlainText(attribution string) string {
+	text := html.UnescapeString(tagRegexp.ReplaceAllString(attribution, ""))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Transliterate replace signs which are common in attributions with ASCII, other non-ASCII runes are replaced with '?'
+func Transliterate(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '©':
+			b.WriteString("(c)")
+		case r == '®':
+			b.WriteString("(R)")
+		case r == '–' || r == '—':
+			b.WriteByte('-')
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ParseColor parse #rgb, #rrggbb or #rrggbbaa color
+func ParseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q, must be #rgb, #rrggbb or #rrggbbaa", s)
+	}
+
+	// NRGBA is not alpha-premultiplied, so components are taken as written
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
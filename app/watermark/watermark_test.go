@@ -0,0 +1,105 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	black = color.NRGBA{A: 255}
+	red   = color.NRGBA{R: 255, A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+func whiteImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	return img
+}
+
+// box return bounds of pixels which aren't white
+func box(img *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if img.RGBAAt(x, y) != white {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		position string
+		fontSize int
+		expected image.Rectangle
+	}{
+		// box of 5 glyphs of 7x13 font with 3px padding is 41x19
+		{TopLeft, 13, image.Rect(0, 0, 41, 19)},
+		{TopRight, 13, image.Rect(259, 0, 300, 19)},
+		{BottomLeft, 13, image.Rect(0, 181, 41, 200)},
+		{BottomRight, 13, image.Rect(259, 181, 300, 200)},
+		{BottomRight, 26, image.Rect(218, 162, 300, 200)},
+		{BottomRight, 0, image.Rect(259, 181, 300, 200)},
+		// text is scaled down to image width
+		{TopLeft, 130, image.Rect(0, 0, 287, 133)},
+	}
+
+	for _, tt := range tests {
+		img := whiteImage(300, 200)
+		Draw(img, "(c) A", &Options{Position: tt.position, FontSize: tt.fontSize, Color: black, Background: red})
+		assert.Equal(t, tt.expected, box(img), "%s %d", tt.position, tt.fontSize)
+	}
+
+	// background is blended and text is drawn over it
+	img := whiteImage(300, 200)
+	Draw(img, "|", &Options{Position: TopLeft, FontSize: 13, Color: black, Background: color.NRGBA{R: 255, A: 128}})
+	assert.Equal(t, color.RGBA{R: 255, G: 127, B: 127, A: 255}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{A: 255}, img.RGBAAt(6, 8))
+
+	// text wider than image is clipped
+	img = whiteImage(20, 20)
+	Draw(img, "long attribution", &Options{Position: BottomRight, FontSize: 13, Color: black, Background: red})
+	assert.Equal(t, image.Rect(0, 1, 20, 20), box(img))
+
+	// nothing is drawn without text
+	img = whiteImage(20, 20)
+	Draw(img, "", &Options{Position: BottomRight, FontSize: 13, Color: black, Background: red})
+	assert.Equal(t, image.Rectangle{}, box(img))
+}
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "© OpenStreetMap contributors",
+		PlainText(`&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a>
+		contributors`))
+	assert.Equal(t, "Esri, Maxar", PlainText("Esri, Maxar"))
+	assert.Equal(t, "(c) OSM (R) A-B ?", Transliterate("© OSM ® A–B ж"))
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected color.Color
+	}{
+		{"#000", color.NRGBA{A: 255}},
+		{"#ff8000", color.NRGBA{R: 255, G: 128, A: 255}},
+		{"ffffffb3", color.NRGBA{R: 255, G: 255, B: 255, A: 0xb3}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseColor(tt.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, c)
+	}
+
+	for _, value := range []string{"", "#12", "#12345", "#gggggg", "#1234567890"} {
+		_, err := ParseColor(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/swaggo/swag v1.16.3
 	github.com/umputun/go-flags v1.5.1
 	go.uber.org/zap v1.27.0
+	golang.org/x/image v0.18.0
 )
 
 require (